@@ -0,0 +1,235 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ToolFunc executes a tool call. arguments is the JSON object sent by the
+// model; the returned string is handed back to the model as the tool
+// result.
+type ToolFunc func(ctx context.Context, arguments json.RawMessage) (string, error)
+
+// AgentStepKind identifies the kind of an AgentStep.
+type AgentStepKind int
+
+const (
+	// StepChunk carries a fragment of the model reply in Content.
+	StepChunk AgentStepKind = iota
+
+	// StepToolCall announces a tool call requested by the model.
+	StepToolCall
+
+	// StepToolResult carries the output of a tool call in Content, or
+	// the tool failure in Error.
+	StepToolResult
+
+	// StepDone is always the last step. Content holds the final answer
+	// and Messages the complete transcript; Error is set if the loop was
+	// aborted.
+	StepDone
+)
+
+// AgentStep is an intermediate or final event of an agent run.
+type AgentStep struct {
+	Kind      AgentStepKind
+	Iteration int
+	Content   string
+	ToolCall  *ToolCall
+	Messages  []ChatMessage
+	Error     error
+}
+
+// ErrMaxIterations is reported when an agent run does not reach a final
+// answer within Agent.MaxIterations model turns.
+var ErrMaxIterations = errors.New("agent exceeded maximum iterations")
+
+// DefaultMaxIterations is the iteration limit used when
+// Agent.MaxIterations is zero.
+const DefaultMaxIterations = 10
+
+type registeredTool struct {
+	def ToolFunction
+	fn  ToolFunc
+}
+
+// Agent runs the chat, tool call, tool result loop on behalf of the caller
+// until the model produces an answer without calling any tools.
+type Agent struct {
+	// Client is used to talk to the Ollama server.
+	Client API
+
+	// Model is the chat model. It must support tool calling.
+	Model string
+
+	// System is the system prompt sent ahead of the conversation.
+	System string
+
+	// Options are the model parameters sent with every request.
+	Options ModelParameters
+
+	// MaxIterations caps the number of model turns of a run. If zero,
+	// DefaultMaxIterations is used.
+	MaxIterations int
+
+	mu    sync.Mutex
+	tools []registeredTool
+}
+
+// RegisterTool makes a tool available to the model, replacing any tool
+// previously registered under the same name.
+func (a *Agent) RegisterTool(def ToolFunction, fn ToolFunc) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for i, t := range a.tools {
+		if t.def.Name == def.Name {
+			a.tools[i] = registeredTool{def, fn}
+			return
+		}
+	}
+	a.tools = append(a.tools, registeredTool{def, fn})
+}
+
+// Tools returns the definitions of the registered tools, in registration
+// order.
+func (a *Agent) Tools() []Tool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	tools := make([]Tool, len(a.tools))
+	for i, t := range a.tools {
+		tools[i] = Tool{Type: "function", Function: t.def}
+	}
+	return tools
+}
+
+func (a *Agent) lookup(name string) (ToolFunc, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, t := range a.tools {
+		if t.def.Name == name {
+			return t.fn, true
+		}
+	}
+	return nil, false
+}
+
+// Run starts an agent loop over the given conversation and streams its
+// steps. The channel is closed after the StepDone step, which is delivered
+// even if ctx is canceled; the steps before it may then be dropped.
+func (a *Agent) Run(ctx context.Context, messages ...ChatMessage) (<-chan AgentStep, error) {
+	if a.Client == nil {
+		return nil, errors.New("agent has no client")
+	}
+	if len(messages) == 0 {
+		return nil, errors.New("agent needs at least one message")
+	}
+	var transcript []ChatMessage
+	if a.System != "" {
+		transcript = append(transcript, ChatMessage{Role: RoleSystem, Content: a.System})
+	}
+	transcript = append(transcript, messages...)
+	out := make(chan AgentStep, 1)
+	go func() {
+		defer close(out)
+		final, err := a.loop(ctx, out, &transcript)
+		done := AgentStep{Kind: StepDone, Content: final, Messages: transcript, Error: err}
+		if !send(ctx, out, done) {
+			// Make room for StepDone in the buffer, dropping the step
+			// that has not been received yet, if any.
+			select {
+			case <-out:
+			default:
+			}
+			out <- done
+		}
+	}()
+	return out, nil
+}
+
+func (a *Agent) loop(ctx context.Context, out chan<- AgentStep, transcript *[]ChatMessage) (string, error) {
+	maxIterations := a.MaxIterations
+	if maxIterations <= 0 {
+		maxIterations = DefaultMaxIterations
+	}
+	tools := a.Tools()
+	for iteration := range maxIterations {
+		resp, err := a.Client.GenerateChat(ctx, ChatRequest{
+			Model:    a.Model,
+			Messages: *transcript,
+			Tools:    tools,
+			Stream:   true,
+			Options:  a.Options,
+		})
+		if err != nil {
+			return "", fmt.Errorf("cannot run agent iteration %d: %w", iteration, err)
+		}
+		reply := ChatMessage{Role: RoleAssistant}
+		var content strings.Builder
+		for r := range resp {
+			if r.Error != nil {
+				for range resp {
+				}
+				return "", fmt.Errorf("cannot run agent iteration %d: %w", iteration, r.Error)
+			}
+			if r.Message.Content != "" {
+				content.WriteString(r.Message.Content)
+				send(ctx, out, AgentStep{Kind: StepChunk, Iteration: iteration, Content: r.Message.Content})
+			}
+			reply.ToolCalls = append(reply.ToolCalls, r.Message.ToolCalls...)
+		}
+		reply.Content = content.String()
+		*transcript = append(*transcript, reply)
+		if len(reply.ToolCalls) == 0 {
+			return reply.Content, nil
+		}
+		for _, call := range reply.ToolCalls {
+			send(ctx, out, AgentStep{Kind: StepToolCall, Iteration: iteration, ToolCall: &call})
+			result, err := a.call(ctx, call)
+			send(ctx, out, AgentStep{Kind: StepToolResult, Iteration: iteration, ToolCall: &call, Content: result, Error: err})
+			if err != nil {
+				result = "error: " + err.Error()
+			}
+			*transcript = append(*transcript, ToolResultFor(call, result))
+		}
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+	}
+	return "", ErrMaxIterations
+}
+
+func (a *Agent) call(ctx context.Context, call ToolCall) (string, error) {
+	fn, ok := a.lookup(call.Function.Name)
+	if !ok {
+		return "", fmt.Errorf("unknown tool %q", call.Function.Name)
+	}
+	return fn(ctx, call.Function.Arguments)
+}
+
+// send delivers v on out unless ctx is canceled first.
+func send[T any](ctx context.Context, out chan<- T, v T) bool {
+	select {
+	case out <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamagotest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgent(t *testing.T) {
+	mock := &ollamagotest.MockClient{
+		GenerateChatFunc: func(ctx context.Context, req ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error) {
+			last := req.Messages[len(req.Messages)-1]
+			if last.Role == "tool" {
+				return ollamagotest.Stream(ollamago.ChatResponse{
+					Message: ollamago.ChatMessage{Role: "assistant", Content: "it is " + last.Content},
+					Done:    true,
+				}), nil
+			}
+			return ollamagotest.Stream(ollamago.ChatResponse{
+				Message: ollamago.ChatMessage{Role: "assistant", ToolCalls: []ollamago.ToolCall{{
+					Function: ollamago.ToolCallFunction{Name: "weather", Arguments: json.RawMessage(`{"city":"Paris"}`)},
+				}}},
+				Done: true,
+			}), nil
+		},
+	}
+	agent := &ollamago.Agent{Client: mock, Model: "test"}
+	agent.RegisterTool(ollamago.ToolFunction{Name: "weather"}, func(ctx context.Context, arguments json.RawMessage) (string, error) {
+		var args struct{ City string }
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return "", err
+		}
+		return "sunny in " + args.City, nil
+	})
+	steps, err := agent.Run(context.Background(), ollamago.ChatMessage{Role: "user", Content: "weather in Paris?"})
+	require.NoError(t, err)
+	var kinds []ollamago.AgentStepKind
+	var last ollamago.AgentStep
+	for s := range steps {
+		kinds = append(kinds, s.Kind)
+		last = s
+	}
+	require.Equal(t, []ollamago.AgentStepKind{
+		ollamago.StepToolCall,
+		ollamago.StepToolResult,
+		ollamago.StepChunk,
+		ollamago.StepDone,
+	}, kinds)
+	require.NoError(t, last.Error)
+	require.Equal(t, "it is sunny in Paris", last.Content)
+	require.Len(t, last.Messages, 4)
+
+	req := mock.CallsTo("GenerateChat")[0].Request.(ollamago.ChatRequest)
+	require.Equal(t, agent.Tools(), req.Tools)
+}
+
+func TestAgentCanceled(t *testing.T) {
+	mock := &ollamagotest.MockClient{
+		GenerateChatFunc: func(ctx context.Context, req ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error) {
+			return ollamagotest.Stream(ollamago.ChatResponse{
+				Message: ollamago.ChatMessage{Role: "assistant", Content: "hi"},
+				Done:    true,
+			}), nil
+		},
+	}
+	agent := &ollamago.Agent{Client: mock, Model: "test"}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	steps, err := agent.Run(ctx, ollamago.ChatMessage{Role: "user", Content: "hello"})
+	require.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+	var last ollamago.AgentStep
+	for s := range steps {
+		last = s
+	}
+	require.Equal(t, ollamago.StepDone, last.Kind, "StepDone is delivered after cancellation")
+	require.Equal(t, "hi", last.Content)
+}
+
+func TestAgentMaxIterations(t *testing.T) {
+	mock := &ollamagotest.MockClient{
+		GenerateChatFunc: func(ctx context.Context, req ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error) {
+			return ollamagotest.Stream(ollamago.ChatResponse{
+				Message: ollamago.ChatMessage{Role: "assistant", ToolCalls: []ollamago.ToolCall{{
+					Function: ollamago.ToolCallFunction{Name: "missing"},
+				}}},
+				Done: true,
+			}), nil
+		},
+	}
+	agent := &ollamago.Agent{Client: mock, Model: "test", MaxIterations: 3}
+	steps, err := agent.Run(context.Background(), ollamago.ChatMessage{Role: "user", Content: "loop"})
+	require.NoError(t, err)
+	var last ollamago.AgentStep
+	for s := range steps {
+		last = s
+	}
+	require.ErrorIs(t, last.Error, ollamago.ErrMaxIterations)
+	require.Len(t, mock.CallsTo("GenerateChat"), 3)
+}
@@ -0,0 +1,335 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditRecord is a line of an audit log: a call to the server with its
+// request and response bodies.
+type AuditRecord struct {
+	Time     time.Time     `json:"time"`
+	Method   string        `json:"method"`
+	Endpoint string        `json:"endpoint"`
+	Model    string        `json:"model,omitempty"`
+	Status   int           `json:"status,omitempty"`
+	Duration time.Duration `json:"duration"`
+
+	// Request is the request body.
+	Request json.RawMessage `json:"request,omitempty"`
+
+	// Response holds the lines of the response body, one per chunk of
+	// streamed responses. Lines that are not JSON are kept as strings.
+	Response []json.RawMessage `json:"response,omitempty"`
+
+	// Error is the transport or body read error, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// Content returns the text generated in the response of a chat or
+// completion call.
+func (r AuditRecord) Content() string {
+	var sb strings.Builder
+	for _, line := range r.Response {
+		var chunk struct {
+			Response string      `json:"response"`
+			Message  ChatMessage `json:"message"`
+		}
+		if json.Unmarshal(line, &chunk) == nil {
+			sb.WriteString(chunk.Response)
+			sb.WriteString(chunk.Message.Content)
+		}
+	}
+	return sb.String()
+}
+
+// AuditOption configures NewAuditLog.
+type AuditOption func(*AuditLog)
+
+// WithAuditHook calls fn on every record before it is written, such as to
+// redact prompts and generated text or to drop the bodies altogether.
+func WithAuditHook(fn func(*AuditRecord)) AuditOption {
+	return func(l *AuditLog) {
+		l.hooks = append(l.hooks, fn)
+	}
+}
+
+// AuditLog writes every call made by the clients it intercepts as a JSON
+// line, for later inspection or Replay:
+//
+//	audit := ollamago.NewAuditLog(f)
+//	client := &ollamago.Client{Interceptors: []ollamago.Interceptor{audit.Interceptor()}}
+//
+// It is safe for concurrent use.
+type AuditLog struct {
+	hooks []func(*AuditRecord)
+
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// NewAuditLog returns an audit log writing to w.
+func NewAuditLog(w io.Writer, opts ...AuditOption) *AuditLog {
+	l := &AuditLog{w: w}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Err returns the first error writing the log. Records are dropped after
+// an error.
+func (l *AuditLog) Err() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+func (l *AuditLog) write(rec AuditRecord) {
+	for _, hook := range l.hooks {
+		hook(&rec)
+	}
+	line, err := json.Marshal(rec)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return
+	}
+	if err != nil {
+		l.err = fmt.Errorf("cannot encode audit record: %w", err)
+		return
+	}
+	if _, err := l.w.Write(append(line, '\n')); err != nil {
+		l.err = fmt.Errorf("cannot write audit record: %w", err)
+	}
+}
+
+// Interceptor returns the interceptor recording the calls. Calls are
+// written once their response has been consumed.
+func (l *AuditLog) Interceptor() Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			rec := AuditRecord{Time: time.Now().UTC(), Method: req.Method, Endpoint: req.URL.Path}
+			if req.Body != nil {
+				body, err := io.ReadAll(req.Body)
+				req.Body.Close()
+				if err != nil {
+					return nil, err
+				}
+				req = req.Clone(req.Context())
+				req.Body = io.NopCloser(bytes.NewReader(body))
+				req.ContentLength = int64(len(body))
+				var named struct {
+					Model string `json:"model"`
+				}
+				if json.Unmarshal(body, &named) == nil {
+					rec.Model = named.Model
+					rec.Request = body
+				}
+			}
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				rec.Duration = time.Since(start)
+				rec.Error = err.Error()
+				l.write(rec)
+				return nil, err
+			}
+			rec.Status = resp.StatusCode
+			resp.Body = &auditBody{ReadCloser: resp.Body, log: l, rec: rec, start: start}
+			return resp, nil
+		})
+	}
+}
+
+// auditBody copies a response body as it is read, writing the record once
+// the body is consumed or closed.
+type auditBody struct {
+	io.ReadCloser
+	log   *AuditLog
+	start time.Time
+
+	mu      sync.Mutex
+	rec     AuditRecord
+	buf     bytes.Buffer
+	written bool
+}
+
+func (b *auditBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Write(p[:n])
+	if err != nil {
+		if err != io.EOF {
+			b.rec.Error = err.Error()
+		}
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *auditBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.mu.Lock()
+	b.finish()
+	b.mu.Unlock()
+	return err
+}
+
+func (b *auditBody) finish() {
+	if b.written {
+		return
+	}
+	b.written = true
+	b.rec.Duration = time.Since(b.start)
+	for _, line := range bytes.Split(b.buf.Bytes(), []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			line, _ = json.Marshal(string(line))
+		}
+		b.rec.Response = append(b.rec.Response, json.RawMessage(line))
+	}
+	b.log.write(b.rec)
+}
+
+// ReadAuditLog reads the records of an audit log.
+func ReadAuditLog(r io.Reader) ([]AuditRecord, error) {
+	var records []AuditRecord
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var rec AuditRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, fmt.Errorf("cannot decode audit record %d: %w", len(records)+1, err)
+		}
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read audit log: %w", err)
+	}
+	return records, nil
+}
+
+// ReplayResult compares the logged output of a call with its replay.
+type ReplayResult struct {
+	Record   AuditRecord
+	Original string
+	Replayed string
+
+	// Err is set if the call could not be replayed.
+	Err error
+}
+
+// Changed reports whether the replayed output differs from the original.
+func (r ReplayResult) Changed() bool {
+	return r.Err != nil || r.Original != r.Replayed
+}
+
+// Replay re-executes the chat and completion calls of an audit log,
+// skipping the other calls and the failed ones, so that their outputs can
+// be compared. If model is not empty, it replaces the model of the logged
+// requests. Failures of single calls are recorded in the results; Replay
+// only fails if ctx is done.
+func Replay(ctx context.Context, client API, records []AuditRecord, model string) ([]ReplayResult, error) {
+	var results []ReplayResult
+	for _, rec := range records {
+		if rec.Error != "" || rec.Status != http.StatusOK {
+			continue
+		}
+		var (
+			replayed string
+			err      error
+		)
+		switch {
+		case strings.HasSuffix(rec.Endpoint, "/api/chat"):
+			replayed, err = replayChat(ctx, client, rec.Request, model)
+		case strings.HasSuffix(rec.Endpoint, "/api/generate"):
+			replayed, err = replayCompletion(ctx, client, rec.Request, model)
+		default:
+			continue
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		results = append(results, ReplayResult{Record: rec, Original: rec.Content(), Replayed: replayed, Err: err})
+	}
+	return results, nil
+}
+
+func replayChat(ctx context.Context, client API, body json.RawMessage, model string) (string, error) {
+	var req ChatRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return "", fmt.Errorf("cannot decode ChatRequest: %w", err)
+	}
+	if model != "" {
+		req.Model = model
+	}
+	req.Stream = true
+	resp, err := client.GenerateChat(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for r := range resp {
+		if r.Error != nil {
+			err = errors.Join(err, r.Error)
+		}
+		sb.WriteString(r.Message.Content)
+	}
+	return sb.String(), err
+}
+
+func replayCompletion(ctx context.Context, client API, body json.RawMessage, model string) (string, error) {
+	var req CompletionRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return "", fmt.Errorf("cannot decode CompletionRequest: %w", err)
+	}
+	if model != "" {
+		req.Model = model
+	}
+	req.Stream = true
+	resp, err := client.GenerateCompletion(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for r := range resp {
+		if r.Error != nil {
+			err = errors.Join(err, r.Error)
+		}
+		sb.WriteString(r.Response)
+	}
+	return sb.String(), err
+}
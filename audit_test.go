@@ -0,0 +1,91 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog(t *testing.T) {
+	srv := ollamatest.NewServer(
+		ollamatest.Model{Name: "llama3.2", Chunks: []string{"Hello", " world"}},
+		ollamatest.Model{Name: "qwen2.5", Chunks: []string{"Hi"}},
+	)
+	t.Cleanup(srv.Close)
+	var buf bytes.Buffer
+	audit := ollamago.NewAuditLog(&buf, ollamago.WithAuditHook(func(rec *ollamago.AuditRecord) {
+		rec.Request = bytes.ReplaceAll(rec.Request, []byte("secret"), []byte("[redacted]"))
+	}))
+	client := srv.Client()
+	client.Interceptors = append(client.Interceptors, audit.Interceptor())
+	ctx := context.Background()
+	resp, err := client.GenerateChat(ctx, ollamago.ChatRequest{Model: "llama3.2", Messages: []ollamago.ChatMessage{ollamago.UserMessage("the secret")}, Stream: true})
+	require.NoError(t, err)
+	for range resp {
+	}
+	completion, err := client.GenerateCompletion(ctx, ollamago.CompletionRequest{Model: "llama3.2", Prompt: "hi", Stream: true})
+	require.NoError(t, err)
+	for range completion {
+	}
+	_, err = client.ListModels(ctx)
+	require.NoError(t, err)
+	_, err = client.GenerateChat(ctx, ollamago.ChatRequest{Model: "missing", Messages: []ollamago.ChatMessage{ollamago.UserMessage("hi")}})
+	require.Error(t, err)
+	require.NoError(t, audit.Err())
+
+	records, err := ollamago.ReadAuditLog(strings.NewReader(buf.String()))
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+	require.Equal(t, "/api/chat", records[0].Endpoint)
+	require.Equal(t, "llama3.2", records[0].Model)
+	require.Contains(t, string(records[0].Request), "the [redacted]")
+	require.Len(t, records[0].Response, 3)
+	require.Equal(t, "Hello world", records[0].Content())
+	require.Equal(t, "/api/tags", records[2].Endpoint)
+	require.NotEqual(t, 200, records[3].Status)
+
+	results, err := ollamago.Replay(ctx, client, records, "")
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.False(t, results[0].Changed())
+	require.Equal(t, "Hello world", results[1].Replayed)
+
+	results, err = ollamago.Replay(ctx, client, records, "qwen2.5")
+	require.NoError(t, err)
+	require.True(t, results[0].Changed())
+	require.Equal(t, "Hi", results[0].Replayed)
+}
+
+func TestAuditLogLeavesRequestAlone(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test"})
+	t.Cleanup(srv.Close)
+	audit := ollamago.NewAuditLog(io.Discard)
+	body := io.NopCloser(strings.NewReader(`{"model":"test","input":["a"]}`))
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/embed", body)
+	require.NoError(t, err)
+	resp, err := audit.Interceptor()(http.DefaultTransport).RoundTrip(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.True(t, req.Body == body, "the request body is not replaced")
+}
@@ -0,0 +1,158 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// APIKey returns an Interceptor authenticating every request with an API
+// key, as ollama.com expects.
+func APIKey(key string) Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", "Bearer "+key)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// SignRequests returns an Interceptor signing every request with key the
+// way the ollama CLI does, so that servers and registries that know its
+// public key accept pulls and pushes. LoadIdentity reads the key of the
+// CLI.
+func SignRequests(key ed25519.PrivateKey) Interceptor {
+	publicKey := sshPublicKey(key.Public().(ed25519.PublicKey))
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			now := strconv.FormatInt(time.Now().Unix(), 10)
+			challenge := req.Method + "," + req.URL.Path + "?ts=" + now
+			signature := ed25519.Sign(key, []byte(challenge))
+			req = req.Clone(req.Context())
+			q := req.URL.Query()
+			q.Set("ts", now)
+			req.URL.RawQuery = q.Encode()
+			req.Header.Set("Authorization", publicKey+":"+base64.StdEncoding.EncodeToString(signature))
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// sshPublicKey encodes a public key as in an OpenSSH authorized_keys line,
+// without the key type.
+func sshPublicKey(key ed25519.PublicKey) string {
+	var buf bytes.Buffer
+	writeSSHString(&buf, []byte("ssh-ed25519"))
+	writeSSHString(&buf, key)
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func writeSSHString(buf *bytes.Buffer, s []byte) {
+	binary.Write(buf, binary.BigEndian, uint32(len(s)))
+	buf.Write(s)
+}
+
+// LoadIdentity reads an unencrypted ed25519 private key in the OpenSSH
+// format, such as the one the ollama CLI signs requests with. If path is
+// empty, ~/.ollama/id_ed25519 is read.
+func LoadIdentity(path string) (ed25519.PrivateKey, error) {
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("cannot locate identity: %w", err)
+		}
+		path = filepath.Join(home, ".ollama", "id_ed25519")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read identity: %w", err)
+	}
+	key, err := parseOpenSSHKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse identity %s: %w", path, err)
+	}
+	return key, nil
+}
+
+var errInvalidKey = errors.New("invalid OpenSSH ed25519 key")
+
+func parseOpenSSHKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "OPENSSH PRIVATE KEY" {
+		return nil, errInvalidKey
+	}
+	rest, ok := bytes.CutPrefix(block.Bytes, []byte("openssh-key-v1\x00"))
+	if !ok {
+		return nil, errInvalidKey
+	}
+	r := sshReader(rest)
+	cipher, kdf := r.string(), r.string()
+	r.string() // KDF options
+	if string(cipher) != "none" || string(kdf) != "none" {
+		return nil, errors.New("encrypted keys are not supported")
+	}
+	if r.uint32() != 1 {
+		return nil, errInvalidKey
+	}
+	r.string() // public key
+	private := sshReader(r.string())
+	if private.uint32() != private.uint32() {
+		return nil, errInvalidKey
+	}
+	keyType := private.string()
+	private.string() // public key
+	key := private.string()
+	if r == nil || private == nil || string(keyType) != "ssh-ed25519" || len(key) != ed25519.PrivateKeySize {
+		return nil, errInvalidKey
+	}
+	return ed25519.PrivateKey(key), nil
+}
+
+// sshReader reads the fields of the SSH wire format. It becomes nil once
+// the data runs short.
+type sshReader []byte
+
+func (r *sshReader) uint32() uint32 {
+	if len(*r) < 4 {
+		*r = nil
+		return 0
+	}
+	v := binary.BigEndian.Uint32(*r)
+	*r = (*r)[4:]
+	return v
+}
+
+func (r *sshReader) string() []byte {
+	n := r.uint32()
+	if uint64(len(*r)) < uint64(n) {
+		*r = nil
+		return nil
+	}
+	s := (*r)[:n]
+	*r = (*r)[n:]
+	return s
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignRequests(t *testing.T) {
+	key, err := ollamago.LoadIdentity(filepath.Join("testdata", "id_ed25519"))
+	require.NoError(t, err)
+	authorizedKey, err := os.ReadFile(filepath.Join("testdata", "id_ed25519.pub"))
+	require.NoError(t, err)
+	publicKey := strings.Fields(string(authorizedKey))[1]
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pub, sig, ok := strings.Cut(r.Header.Get("Authorization"), ":")
+		require.True(t, ok)
+		require.Equal(t, publicKey, pub)
+		signature, err := base64.StdEncoding.DecodeString(sig)
+		require.NoError(t, err)
+		challenge := r.Method + "," + r.URL.Path + "?ts=" + r.URL.Query().Get("ts")
+		require.True(t, ed25519.Verify(key.Public().(ed25519.PublicKey), []byte(challenge), signature))
+		w.Write([]byte(`{"status":"success"}`))
+	}))
+	t.Cleanup(server.Close)
+	client := &ollamago.Client{BaseURL: server.URL, Interceptors: []ollamago.Interceptor{ollamago.SignRequests(key)}}
+	progress, err := client.PushModel(context.Background(), ollamago.PushModelRequest{Model: "me/model"})
+	require.NoError(t, err)
+	require.Equal(t, "success", (<-progress).Status)
+
+	_, err = ollamago.LoadIdentity(filepath.Join("testdata", "id_ed25519.pub"))
+	require.Error(t, err)
+}
+
+func TestAPIKey(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"version":"1.0.0"}`))
+	}))
+	t.Cleanup(server.Close)
+	client := &ollamago.Client{BaseURL: server.URL, Interceptors: []ollamago.Interceptor{ollamago.APIKey("secret")}}
+	_, err := client.Version(context.Background())
+	require.NoError(t, err)
+}
+
+func TestPushModel(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "me/model", Size: 10})
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+	ctx := context.Background()
+
+	progress, err := client.PushModel(ctx, ollamago.PushModelRequest{Model: "me/model"})
+	require.NoError(t, err)
+	var last ollamago.ProgressEvent
+	for p := range progress {
+		require.NoError(t, p.Error)
+		last = p
+	}
+	require.Equal(t, "success", last.Status)
+
+	require.NoError(t, client.DeleteModel(ctx, ollamago.DeleteModelRequest{Model: "me/model"}))
+	require.NoError(t, ollamago.EnsureModel(ctx, client, "me/model"), "the pushed model can be pulled back")
+}
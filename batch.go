@@ -0,0 +1,265 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BatchOption configures EmbedBatch.
+type BatchOption func(*batchConfig)
+
+type batchConfig struct {
+	size        int
+	concurrency int
+	retries     int
+	backoff     time.Duration
+	progress    func(done, total int)
+}
+
+// WithBatchSize sets how many inputs are sent per request. The default is
+// 64.
+func WithBatchSize(n int) BatchOption {
+	return func(c *batchConfig) {
+		c.size = n
+	}
+}
+
+// WithBatchConcurrency sets how many requests may be in flight at once.
+// The default is 4.
+func WithBatchConcurrency(n int) BatchOption {
+	return func(c *batchConfig) {
+		c.concurrency = n
+	}
+}
+
+// WithBatchRetries sets how many times a failed chunk is retried, waiting
+// backoff before the first retry and doubling the wait after each one. The
+// default is 2 retries with a 100ms backoff.
+func WithBatchRetries(n int, backoff time.Duration) BatchOption {
+	return func(c *batchConfig) {
+		c.retries = n
+		c.backoff = backoff
+	}
+}
+
+// WithBatchProgress registers a callback invoked after each chunk
+// completes with the number of inputs embedded so far. Calls are
+// serialized.
+func WithBatchProgress(progress func(done, total int)) BatchOption {
+	return func(c *batchConfig) {
+		c.progress = progress
+	}
+}
+
+// EmbedBatch embeds a large set of inputs by splitting them into chunks
+// processed by a bounded pool of workers. The returned embeddings are in
+// the same order as inputs. The first chunk to fail after exhausting its
+// retries aborts the whole batch.
+func EmbedBatch(ctx context.Context, client API, model string, inputs []string, opts ...BatchOption) ([][]float64, error) {
+	cfg := batchConfig{
+		size:        64,
+		concurrency: 4,
+		retries:     2,
+		backoff:     100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	cfg.size = max(cfg.size, 1)
+	cfg.concurrency = max(cfg.concurrency, 1)
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	embeddings := make([][]float64, len(inputs))
+	chunks := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		embedded int
+	)
+	for range min(cfg.concurrency, (len(inputs)+cfg.size-1)/cfg.size) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range chunks {
+				end := min(start+cfg.size, len(inputs))
+				vectors, err := embedChunk(ctx, client, model, inputs[start:end], cfg)
+				if err != nil {
+					cancel(fmt.Errorf("cannot embed inputs %d to %d: %w", start, end-1, err))
+					continue
+				}
+				copy(embeddings[start:end], vectors)
+				mu.Lock()
+				embedded += end - start
+				if cfg.progress != nil {
+					cfg.progress(embedded, len(inputs))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for start := 0; start < len(inputs); start += cfg.size {
+		select {
+		case chunks <- start:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(chunks)
+	wg.Wait()
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	return embeddings, nil
+}
+
+func embedChunk(ctx context.Context, client API, model string, inputs []string, cfg batchConfig) ([][]float64, error) {
+	backoff := cfg.backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := client.GenerateEmbeddings(ctx, EmbedRequest{Model: model, Input: inputs})
+		if err == nil && len(resp.Embeddings) != len(inputs) {
+			err = fmt.Errorf("got %d embeddings for %d inputs", len(resp.Embeddings), len(inputs))
+		}
+		if err == nil {
+			return resp.Embeddings, nil
+		}
+		if attempt >= cfg.retries || ctx.Err() != nil {
+			return nil, err
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
+
+// CompletionResult is the outcome of a completion of CompleteAll.
+type CompletionResult struct {
+	// Response is the final chunk of the completion, with the whole
+	// generated text in Response.
+	Response CompletionResponse
+
+	// Err is why the completion failed, after its retries.
+	Err error
+}
+
+// CompleteAll runs completions with at most concurrency of them in flight,
+// retrying the failed ones as set by WithBatchRetries, and returns their
+// results in the order of reqs. Failures are reported per completion and
+// do not stop the others; canceling ctx fails the pending ones.
+// WithBatchProgress counts completions; the other BatchOptions do not
+// apply.
+func CompleteAll(ctx context.Context, client API, reqs []CompletionRequest, concurrency int, opts ...BatchOption) []CompletionResult {
+	cfg := batchConfig{
+		retries: 2,
+		backoff: 100 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	results := make([]CompletionResult, len(reqs))
+	items := make(chan int)
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done int
+	)
+	for range min(max(concurrency, 1), len(reqs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range items {
+				resp, err := completeWithRetries(ctx, client, reqs[i], cfg)
+				results[i] = CompletionResult{Response: resp, Err: err}
+				mu.Lock()
+				done++
+				if cfg.progress != nil {
+					cfg.progress(done, len(reqs))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range reqs {
+		items <- i
+	}
+	close(items)
+	wg.Wait()
+	return results
+}
+
+func completeWithRetries(ctx context.Context, client API, req CompletionRequest, cfg batchConfig) (CompletionResponse, error) {
+	backoff := cfg.backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := complete(ctx, client, req)
+		if err == nil {
+			return resp, nil
+		}
+		if attempt >= cfg.retries || ctx.Err() != nil {
+			return resp, err
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return resp, err
+		}
+		backoff *= 2
+	}
+}
+
+// complete runs a completion and assembles its chunks into the final one.
+func complete(ctx context.Context, client API, req CompletionRequest) (CompletionResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return CompletionResponse{}, err
+	}
+	stream, err := client.GenerateCompletion(ctx, req)
+	if err != nil {
+		return CompletionResponse{}, err
+	}
+	var (
+		final    CompletionResponse
+		text     strings.Builder
+		finished bool
+	)
+	for r := range stream {
+		if r.Error != nil && err == nil {
+			err = r.Error
+		}
+		text.WriteString(r.Response)
+		if r.Done && !finished {
+			final, finished = r, true
+		}
+	}
+	final.Response = text.String()
+	if err == nil && !finished {
+		err = errors.New("completion ended without its final chunk")
+	}
+	return final, err
+}
@@ -0,0 +1,134 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamagotest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbedBatch(t *testing.T) {
+	var calls atomic.Int32
+	mock := &ollamagotest.MockClient{
+		GenerateEmbeddingsFunc: func(ctx context.Context, req ollamago.EmbedRequest) (*ollamago.EmbedResponse, error) {
+			if calls.Add(1) == 2 {
+				return nil, errors.New("transient failure")
+			}
+			resp := &ollamago.EmbedResponse{Model: req.Model}
+			for _, in := range req.Input {
+				n, _ := strconv.Atoi(in)
+				resp.Embeddings = append(resp.Embeddings, []float64{float64(n)})
+			}
+			return resp, nil
+		},
+	}
+	var inputs []string
+	for i := range 10 {
+		inputs = append(inputs, strconv.Itoa(i))
+	}
+	var lastDone int
+	embeddings, err := ollamago.EmbedBatch(context.Background(), mock, "test", inputs,
+		ollamago.WithBatchSize(3),
+		ollamago.WithBatchConcurrency(2),
+		ollamago.WithBatchRetries(1, 0),
+		ollamago.WithBatchProgress(func(done, total int) {
+			require.Equal(t, 10, total)
+			require.Greater(t, done, lastDone)
+			lastDone = done
+		}),
+	)
+	require.NoError(t, err)
+	require.Equal(t, 10, lastDone)
+	require.Len(t, embeddings, 10)
+	for i, e := range embeddings {
+		require.Equal(t, []float64{float64(i)}, e)
+	}
+	require.Len(t, mock.CallsTo("GenerateEmbeddings"), 5)
+}
+
+func TestEmbedBatchFailure(t *testing.T) {
+	mock := &ollamagotest.MockClient{
+		GenerateEmbeddingsFunc: func(ctx context.Context, req ollamago.EmbedRequest) (*ollamago.EmbedResponse, error) {
+			return nil, errors.New("permanent failure")
+		},
+	}
+	_, err := ollamago.EmbedBatch(context.Background(), mock, "test", []string{"a", "b", "c"},
+		ollamago.WithBatchSize(1),
+		ollamago.WithBatchRetries(2, 0),
+	)
+	require.ErrorContains(t, err, "permanent failure")
+}
+
+func TestCompleteAll(t *testing.T) {
+	var (
+		inFlight, peak atomic.Int32
+		flaky          atomic.Bool
+	)
+	mock := &ollamagotest.MockClient{
+		GenerateCompletionFunc: func(ctx context.Context, req ollamago.CompletionRequest) (<-chan ollamago.CompletionResponse, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			switch req.Prompt {
+			case "fail":
+				return nil, errors.New("permanent failure")
+			case "flaky":
+				if !flaky.Swap(true) {
+					return nil, errors.New("transient failure")
+				}
+			}
+			return ollamagotest.Stream(
+				ollamago.CompletionResponse{Response: req.Prompt},
+				ollamago.CompletionResponse{Response: "!", Done: true, DoneReason: ollamago.DoneStop},
+			), nil
+		},
+	}
+	prompts := []string{"a", "flaky", "fail", "b", "c"}
+	var reqs []ollamago.CompletionRequest
+	for _, p := range prompts {
+		reqs = append(reqs, ollamago.CompletionRequest{Model: "test", Prompt: p})
+	}
+	results := ollamago.CompleteAll(context.Background(), mock, reqs, 2, ollamago.WithBatchRetries(1, 0))
+	require.Len(t, results, len(reqs))
+	for i, r := range results {
+		if prompts[i] == "fail" {
+			require.ErrorContains(t, r.Err, "permanent failure")
+			continue
+		}
+		require.NoError(t, r.Err)
+		require.Equal(t, prompts[i]+"!", r.Response.Response)
+		require.Equal(t, ollamago.DoneStop, r.Response.DoneReason)
+	}
+	require.LessOrEqual(t, peak.Load(), int32(2))
+	require.Len(t, mock.CallsTo("GenerateCompletion"), len(reqs)+2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, r := range ollamago.CompleteAll(ctx, mock, reqs, 2) {
+		require.ErrorIs(t, r.Err, context.Canceled)
+	}
+}
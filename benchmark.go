@@ -0,0 +1,223 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// BenchmarkOption configures Benchmark.
+type BenchmarkOption func(*benchmarkConfig)
+
+type benchmarkConfig struct {
+	levels  []int
+	options ModelParameters
+	cold    bool
+}
+
+// WithConcurrencyLevels sets the numbers of concurrent requests the
+// prompts are run with, one round per level. The default is a single
+// round with one request at a time.
+func WithConcurrencyLevels(levels ...int) BenchmarkOption {
+	return func(c *benchmarkConfig) {
+		c.levels = levels
+	}
+}
+
+// WithBenchmarkOptions sets the model parameters of the requests, such as
+// a fixed NumPredict for comparable runs.
+func WithBenchmarkOptions(options ModelParameters) BenchmarkOption {
+	return func(c *benchmarkConfig) {
+		c.options = options
+	}
+}
+
+// WithColdStart unloads the model before measuring its load time, which is
+// otherwise zero when the model is already loaded.
+func WithColdStart() BenchmarkOption {
+	return func(c *benchmarkConfig) {
+		c.cold = true
+	}
+}
+
+// BenchmarkReport is the outcome of Benchmark.
+type BenchmarkReport struct {
+	Model string
+
+	// LoadTime is the time taken to load the model.
+	LoadTime time.Duration
+
+	// Size and SizeVRAM are the memory used by the loaded model, and the
+	// part of it in video memory, as reported by ListRunningModels.
+	Size     int64
+	SizeVRAM int64
+
+	Levels []BenchmarkLevel
+}
+
+// BenchmarkLevel holds the measures of a round at a concurrency level.
+type BenchmarkLevel struct {
+	Concurrency int
+	Requests    int
+	Errors      int
+
+	// Wall is the duration of the round.
+	Wall time.Duration
+
+	// TTFT holds the mean, median and 95th percentile time to first
+	// token.
+	MeanTTFT time.Duration
+	P50TTFT  time.Duration
+	P95TTFT  time.Duration
+
+	// TokensPerSecond is the mean generation rate of a request, as
+	// reported by the server.
+	TokensPerSecond float64
+
+	// Throughput is the number of tokens generated per second of Wall,
+	// across all requests.
+	Throughput float64
+}
+
+type benchmarkSample struct {
+	ttft      time.Duration
+	generated int
+	eval      time.Duration
+	err       error
+}
+
+// Benchmark measures the load time, time to first token, generation rate
+// and memory residency of model, running every prompt once per concurrency
+// level.
+func Benchmark(ctx context.Context, client API, model string, prompts []string, opts ...BenchmarkOption) (*BenchmarkReport, error) {
+	cfg := benchmarkConfig{levels: []int{1}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if len(prompts) == 0 {
+		return nil, errors.New("cannot benchmark without prompts")
+	}
+	report := &BenchmarkReport{Model: model}
+	if cfg.cold {
+		if err := UnloadModel(ctx, client, model); err != nil {
+			return nil, err
+		}
+	}
+	start := time.Now()
+	if err := LoadModel(ctx, client, model, 0); err != nil {
+		return nil, err
+	}
+	report.LoadTime = time.Since(start)
+	running, err := client.ListRunningModels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot measure memory: %w", err)
+	}
+	for _, m := range running.Models {
+		if sameModel(m.Name, model) {
+			report.Size, report.SizeVRAM = m.Size, m.SizeVRAM
+		}
+	}
+	for _, level := range cfg.levels {
+		l, err := benchmarkLevel(ctx, client, model, prompts, max(level, 1), cfg.options)
+		if err != nil {
+			return nil, err
+		}
+		report.Levels = append(report.Levels, l)
+	}
+	return report, nil
+}
+
+func benchmarkLevel(ctx context.Context, client API, model string, prompts []string, concurrency int, options ModelParameters) (BenchmarkLevel, error) {
+	samples := make([]benchmarkSample, len(prompts))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i, prompt := range prompts {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			samples[i] = benchmarkPrompt(ctx, client, model, prompt, options)
+		}()
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return BenchmarkLevel{}, err
+	}
+	l := BenchmarkLevel{Concurrency: concurrency, Requests: len(prompts), Wall: time.Since(start)}
+	var (
+		ttfts     []time.Duration
+		rates     float64
+		generated int
+	)
+	for _, s := range samples {
+		if s.err != nil {
+			l.Errors++
+			continue
+		}
+		ttfts = append(ttfts, s.ttft)
+		generated += s.generated
+		if s.eval > 0 {
+			rates += float64(s.generated) / s.eval.Seconds()
+		}
+	}
+	if len(ttfts) == 0 {
+		return l, nil
+	}
+	slices.Sort(ttfts)
+	var sum time.Duration
+	for _, d := range ttfts {
+		sum += d
+	}
+	l.MeanTTFT = sum / time.Duration(len(ttfts))
+	l.P50TTFT = ttfts[(len(ttfts)-1)/2]
+	l.P95TTFT = ttfts[(len(ttfts)-1)*95/100]
+	l.TokensPerSecond = rates / float64(len(ttfts))
+	l.Throughput = float64(generated) / l.Wall.Seconds()
+	return l, nil
+}
+
+func benchmarkPrompt(ctx context.Context, client API, model, prompt string, options ModelParameters) benchmarkSample {
+	start := time.Now()
+	resp, err := client.GenerateCompletion(ctx, CompletionRequest{Model: model, Prompt: prompt, Stream: true, Options: options})
+	if err != nil {
+		return benchmarkSample{err: err}
+	}
+	var s benchmarkSample
+	for r := range resp {
+		if r.Error != nil && s.err == nil {
+			s.err = r.Error
+		}
+		if s.ttft == 0 && r.Response != "" {
+			s.ttft = time.Since(start)
+		}
+		if r.Done {
+			s.generated, s.eval = r.EvalCount, r.EvalDuration
+		}
+	}
+	return s
+}
@@ -0,0 +1,54 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmark(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "llama3.2", Size: 2_000_000, Chunks: []string{"a", "b", "c", "d"}})
+	t.Cleanup(srv.Close)
+	srv.SetChunkDelay(5 * time.Millisecond)
+
+	report, err := ollamago.Benchmark(context.Background(), srv.Client(), "llama3.2", []string{"one", "two", "three", "four"},
+		ollamago.WithConcurrencyLevels(1, 4),
+		ollamago.WithColdStart(),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "llama3.2", report.Model)
+	require.EqualValues(t, 2_000_000, report.Size)
+	require.Len(t, report.Levels, 2)
+	for _, l := range report.Levels {
+		require.Equal(t, 4, l.Requests)
+		require.Zero(t, l.Errors)
+		require.GreaterOrEqual(t, l.P50TTFT, 5*time.Millisecond)
+		require.LessOrEqual(t, l.P50TTFT, l.P95TTFT)
+		// The fake server reports one token per millisecond.
+		require.InDelta(t, 1000, l.TokensPerSecond, 1e-6)
+		require.Greater(t, l.Throughput, 0.0)
+	}
+	serial, parallel := report.Levels[0], report.Levels[1]
+	require.Less(t, parallel.Wall, serial.Wall)
+
+	_, err = ollamago.Benchmark(context.Background(), srv.Client(), "missing", []string{"x"})
+	require.Error(t, err)
+}
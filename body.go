@@ -0,0 +1,156 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// maxPooledBuffer is the capacity beyond which request buffers are left
+// to the garbage collector rather than kept in the pool.
+const maxPooledBuffer = 16 << 20
+
+var bufferPool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+// DefaultStreamBodyThreshold is the payload size beyond which request
+// bodies are streamed when Client.StreamBodyThreshold is zero.
+const DefaultStreamBodyThreshold = 1 << 20
+
+// jsonBody is a request body, either encoded into a pooled buffer, which
+// goes back to the pool once the transport closes the body, or encoded
+// while it is sent, through a pipe.
+type jsonBody struct {
+	io.Reader
+	size   int64 // -1 if unknown
+	v      any
+	codec  Codec
+	buf    *bytes.Buffer
+	pipe   *io.PipeReader
+	closed sync.Once
+}
+
+// newJSONBody encodes v as the body of a request, streaming it when its
+// payload exceeds the threshold of the client.
+func (c *Client) newJSONBody(v any) (*jsonBody, error) {
+	threshold := c.StreamBodyThreshold
+	if threshold == 0 {
+		threshold = DefaultStreamBodyThreshold
+	}
+	return newJSONBody(c.codec(), v, threshold > 0 && payloadSize(v) > threshold)
+}
+
+func newJSONBody(codec Codec, v any, stream bool) (*jsonBody, error) {
+	if stream {
+		pr, pw := io.Pipe()
+		go func() {
+			pw.CloseWithError(encode(pw, codec, v))
+		}()
+		return &jsonBody{Reader: pr, size: -1, v: v, codec: codec, pipe: pr}, nil
+	}
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	if err := encode(buf, codec, v); err != nil {
+		bufferPool.Put(buf)
+		return nil, err
+	}
+	return &jsonBody{Reader: bytes.NewReader(buf.Bytes()), size: int64(buf.Len()), v: v, codec: codec, buf: buf}, nil
+}
+
+// encode writes v to w. encoding/json encodes straight into w; other
+// codecs marshal v first.
+func encode(w io.Writer, codec Codec, v any) error {
+	if _, ok := codec.(JSONCodec); !ok {
+		data, err := codec.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	if buf, ok := w.(*bytes.Buffer); ok {
+		if err := json.NewEncoder(buf).Encode(v); err != nil {
+			return err
+		}
+		// Send the same bytes as json.Marshal, without the trailing
+		// newline of the encoder.
+		buf.Truncate(buf.Len() - 1)
+		return nil
+	}
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (b *jsonBody) Close() error {
+	b.closed.Do(func() {
+		switch {
+		case b.pipe != nil:
+			b.pipe.Close()
+		case b.buf.Cap() <= maxPooledBuffer:
+			bufferPool.Put(b.buf)
+		}
+	})
+	return nil
+}
+
+// payloadSize estimates the encoded size of the bulky parts of a request:
+// its images and texts.
+func payloadSize(v any) int {
+	n := 0
+	switch req := v.(type) {
+	case ChatRequest:
+		for _, m := range req.Messages {
+			n += len(m.Content)
+			for _, img := range m.Images {
+				n += len(img)
+			}
+		}
+	case CompletionRequest:
+		n += len(req.Prompt)
+		for _, img := range req.Images {
+			n += len(img)
+		}
+	case EmbedRequest:
+		for _, in := range req.Input {
+			n += len(in)
+		}
+	}
+	return n
+}
+
+// newJSONRequest returns a request sending body, which it closes on
+// failure.
+func newJSONRequest(ctx context.Context, method, url string, body *jsonBody) (*http.Request, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	httpReq.ContentLength = body.size
+	// Redirects and retries encode the body anew, as the buffer of the
+	// first one may be back in the pool.
+	httpReq.GetBody = func() (io.ReadCloser, error) {
+		return newJSONBody(body.codec, body.v, body.pipe != nil)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	return httpReq, nil
+}
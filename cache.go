@@ -0,0 +1,303 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheEndpoints are the endpoints cached by DiskCache when
+// Endpoints is nil.
+var DefaultCacheEndpoints = []string{"/api/embed", "/api/generate", "/api/chat"}
+
+// DiskCache is a persistent cache of successful responses, keyed by
+// endpoint and request body, that is plugged into a Client as an
+// Interceptor:
+//
+//	cache := &ollamago.DiskCache{Dir: "cache", TTL: 24 * time.Hour}
+//	client := &ollamago.Client{Interceptors: []ollamago.Interceptor{cache.Intercept}}
+//
+// Entries expire after TTL and, when MaxSize is exceeded, the least
+// recently used entries are evicted. The server address is not part of the
+// key, so a cache can be shared by clients talking to equivalent servers.
+type DiskCache struct {
+	// Dir is the directory holding the entries.
+	Dir string
+
+	// TTL is how long an entry is valid. If zero, entries never expire.
+	TTL time.Duration
+
+	// MaxSize is the total size, in bytes, of the entries kept on disk.
+	// If zero, the cache is unbounded.
+	MaxSize int64
+
+	// Endpoints lists the path suffixes of the cached endpoints. If nil,
+	// DefaultCacheEndpoints is used.
+	Endpoints []string
+
+	mu sync.Mutex
+}
+
+type cacheEntry struct {
+	Created time.Time   `json:"created"`
+	Status  int         `json:"status"`
+	Header  http.Header `json:"header"`
+	Body    []byte      `json:"body"`
+}
+
+// Intercept is an Interceptor serving cached responses and storing new
+// ones. Streamed responses are stored once they have been read to the end.
+func (d *DiskCache) Intercept(next http.RoundTripper) http.RoundTripper {
+	return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		endpoint, ok := d.endpoint(req)
+		if !ok {
+			return next.RoundTrip(req)
+		}
+		var reqBody []byte
+		if req.Body != nil {
+			var err error
+			reqBody, err = io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("cannot read request body: %w", err)
+			}
+			req = req.Clone(req.Context())
+			req.Body = io.NopCloser(bytes.NewReader(reqBody))
+			req.ContentLength = int64(len(reqBody))
+		}
+		key := cacheKey(req.Method, endpoint, reqBody)
+		if entry, ok := d.get(key); ok {
+			return &http.Response{
+				Status:        fmt.Sprintf("%d %s", entry.Status, http.StatusText(entry.Status)),
+				StatusCode:    entry.Status,
+				Proto:         "HTTP/1.1",
+				ProtoMajor:    1,
+				ProtoMinor:    1,
+				Header:        entry.Header,
+				Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+				ContentLength: int64(len(entry.Body)),
+				Request:       req,
+			}, nil
+		}
+		resp, err := next.RoundTrip(req)
+		if err != nil || resp.StatusCode != http.StatusOK {
+			return resp, err
+		}
+		entry := cacheEntry{Status: resp.StatusCode, Header: resp.Header.Clone()}
+		if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/x-ndjson") {
+			resp.Body = &cachingBody{ReadCloser: resp.Body, done: func(body []byte) {
+				entry.Body = body
+				d.put(key, entry)
+			}}
+			return resp, nil
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read response body: %w", err)
+		}
+		entry.Body = body
+		d.put(key, entry)
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	})
+}
+
+// Clear removes all entries.
+func (d *DiskCache) Clear() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	files, err := d.files()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("cannot clear cache: %w", err)
+		}
+	}
+	return nil
+}
+
+func (d *DiskCache) endpoint(req *http.Request) (string, bool) {
+	if req.Method != http.MethodPost {
+		return "", false
+	}
+	endpoints := d.Endpoints
+	if endpoints == nil {
+		endpoints = DefaultCacheEndpoints
+	}
+	for _, e := range endpoints {
+		if strings.HasSuffix(req.URL.Path, e) {
+			return e, true
+		}
+	}
+	return "", false
+}
+
+func cacheKey(method, endpoint string, body []byte) string {
+	// Re-encoding the body makes the key independent of field order and
+	// whitespace.
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err == nil {
+		if normalized, err := json.Marshal(v); err == nil {
+			body = normalized
+		}
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", method, endpoint)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (d *DiskCache) path(key string) string {
+	return filepath.Join(d.Dir, key+".json")
+}
+
+func (d *DiskCache) get(key string) (cacheEntry, bool) {
+	path := d.path(key)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cacheEntry{}, false
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		os.Remove(path)
+		return cacheEntry{}, false
+	}
+	now := time.Now()
+	if d.TTL > 0 && now.Sub(entry.Created) > d.TTL {
+		os.Remove(path)
+		return cacheEntry{}, false
+	}
+	// The modification time tracks the last use for LRU eviction.
+	os.Chtimes(path, now, now)
+	return entry, true
+}
+
+// put stores an entry. Failures are ignored: the response has already been
+// handed to the caller and the cache is only an optimization.
+func (d *DiskCache) put(key string, entry cacheEntry) {
+	entry.Created = time.Now()
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(d.Dir, 0o755); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(d.Dir, ".cache-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		return
+	}
+	if err := os.Rename(tmp.Name(), d.path(key)); err != nil {
+		return
+	}
+	d.evict()
+}
+
+type cacheFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+func (d *DiskCache) files() ([]cacheFile, error) {
+	entries, err := os.ReadDir(d.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot list cache: %w", err)
+	}
+	var files []cacheFile
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, cacheFile{filepath.Join(d.Dir, name), info.Size(), info.ModTime()})
+	}
+	return files, nil
+}
+
+func (d *DiskCache) evict() {
+	if d.MaxSize <= 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	files, err := d.files()
+	if err != nil {
+		return
+	}
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if total <= d.MaxSize {
+			break
+		}
+		if err := os.Remove(f.path); err == nil || errors.Is(err, os.ErrNotExist) {
+			total -= f.size
+		}
+	}
+}
+
+// cachingBody buffers a streamed response and hands it over once it has
+// been read completely.
+type cachingBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	done func([]byte)
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	if err == io.EOF && b.done != nil {
+		b.done(b.buf.Bytes())
+		b.done = nil
+	}
+	return n, err
+}
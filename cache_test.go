@@ -0,0 +1,119 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiskCache(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test", Chunks: []string{"hello", " world"}})
+	t.Cleanup(srv.Close)
+	cache := &ollamago.DiskCache{Dir: t.TempDir()}
+	client := srv.Client()
+	client.Interceptors = []ollamago.Interceptor{cache.Intercept}
+	ctx := context.Background()
+
+	for range 2 {
+		resp, err := client.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: "test", Input: []string{"a"}})
+		require.NoError(t, err)
+		require.Len(t, resp.Embeddings, 1)
+	}
+	require.Len(t, srv.Requests(), 1)
+
+	for range 2 {
+		respChan, err := client.GenerateChat(ctx, ollamago.ChatRequest{
+			Model:    "test",
+			Messages: []ollamago.ChatMessage{{Role: "user", Content: "hi"}},
+			Stream:   true,
+		})
+		require.NoError(t, err)
+		var content string
+		for r := range respChan {
+			content += r.Message.Content
+		}
+		require.Equal(t, "hello world", content)
+	}
+	require.Len(t, srv.Requests(), 2)
+
+	_, err := client.ListModels(ctx)
+	require.NoError(t, err)
+	_, err = client.ListModels(ctx)
+	require.NoError(t, err)
+	require.Len(t, srv.Requests(), 4, "only the configured endpoints are cached")
+
+	require.NoError(t, cache.Clear())
+	_, err = client.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: "test", Input: []string{"a"}})
+	require.NoError(t, err)
+	require.Len(t, srv.Requests(), 5)
+}
+
+func TestDiskCacheEviction(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test"})
+	t.Cleanup(srv.Close)
+	dir := t.TempDir()
+	cache := &ollamago.DiskCache{Dir: dir, TTL: 50 * time.Millisecond}
+	client := srv.Client()
+	client.Interceptors = []ollamago.Interceptor{cache.Intercept}
+	ctx := context.Background()
+	embed := func(input string) {
+		_, err := client.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: "test", Input: []string{input}})
+		require.NoError(t, err)
+	}
+
+	embed("a")
+	time.Sleep(100 * time.Millisecond)
+	embed("a")
+	require.Len(t, srv.Requests(), 2, "expired entries are refetched")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	info, err := entries[0].Info()
+	require.NoError(t, err)
+
+	cache.TTL = 0
+	cache.MaxSize = 2*info.Size() + info.Size()/2
+	embed("b")
+	embed("c")
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	embed("a")
+	require.Len(t, srv.Requests(), 5, "the least recently used entry was evicted")
+}
+
+func TestDiskCacheLeavesRequestAlone(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test"})
+	t.Cleanup(srv.Close)
+	cache := &ollamago.DiskCache{Dir: t.TempDir()}
+	body := io.NopCloser(strings.NewReader(`{"model":"test","input":["a"]}`))
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/embed", body)
+	require.NoError(t, err)
+	resp, err := cache.Intercept(http.DefaultTransport).RoundTrip(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.True(t, req.Body == body, "the request body is not replaced")
+}
@@ -0,0 +1,262 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// CallOption configures a single call. Call options travel in the context
+// given to the Client methods:
+//
+//	ctx = ollamago.WithCallOptions(ctx, ollamago.WithStallTimeout(30*time.Second))
+//	resp, err := client.GenerateChat(ctx, req)
+type CallOption func(*callConfig)
+
+type callConfig struct {
+	connect    time.Duration
+	firstByte  time.Duration
+	firstToken time.Duration
+	stall      time.Duration
+	overall    time.Duration
+}
+
+type callOptionsKey struct{}
+
+// WithCallOptions returns a context carrying opts, in addition to the call
+// options already in ctx.
+func WithCallOptions(ctx context.Context, opts ...CallOption) context.Context {
+	cfg, _ := ctx.Value(callOptionsKey{}).(callConfig)
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return context.WithValue(ctx, callOptionsKey{}, cfg)
+}
+
+// WithConnectTimeout aborts the call if no connection to the server is
+// obtained within d.
+func WithConnectTimeout(d time.Duration) CallOption {
+	return func(c *callConfig) {
+		c.connect = d
+	}
+}
+
+// WithFirstByteTimeout aborts the call if the server does not start
+// responding within d.
+func WithFirstByteTimeout(d time.Duration) CallOption {
+	return func(c *callConfig) {
+		c.firstByte = d
+	}
+}
+
+// WithFirstTokenTimeout aborts the call if the first chunk of the response
+// body does not arrive within d.
+func WithFirstTokenTimeout(d time.Duration) CallOption {
+	return func(c *callConfig) {
+		c.firstToken = d
+	}
+}
+
+// WithStallTimeout aborts the call if more than d passes between two
+// chunks of the response body.
+func WithStallTimeout(d time.Duration) CallOption {
+	return func(c *callConfig) {
+		c.stall = d
+	}
+}
+
+// WithOverallDeadline aborts the call if it, response body included, is
+// not over within d. Unlike http.Client.Timeout, it only applies to the
+// calls made with the context carrying it.
+func WithOverallDeadline(d time.Duration) CallOption {
+	return func(c *callConfig) {
+		c.overall = d
+	}
+}
+
+// ErrWatchdog is matched by every WatchdogError.
+var ErrWatchdog = errors.New("ollamago: watchdog timeout")
+
+// Phase is the part of a call that a timeout applies to.
+type Phase int
+
+// Phases of a call, in the order they happen.
+const (
+	PhaseConnect Phase = iota
+	PhaseFirstByte
+	PhaseFirstToken
+	PhaseStall
+	PhaseOverall
+)
+
+func (p Phase) String() string {
+	switch p {
+	case PhaseConnect:
+		return "connect"
+	case PhaseFirstByte:
+		return "first byte"
+	case PhaseFirstToken:
+		return "first token"
+	case PhaseStall:
+		return "stall"
+	case PhaseOverall:
+		return "overall"
+	}
+	return fmt.Sprintf("Phase(%d)", int(p))
+}
+
+// WatchdogError reports a call aborted by one of the timeout call
+// options.
+type WatchdogError struct {
+	// Phase is the part of the call that took too long.
+	Phase Phase
+
+	// Timeout is the limit that was exceeded.
+	Timeout time.Duration
+}
+
+func (e *WatchdogError) Error() string {
+	switch e.Phase {
+	case PhaseStall:
+		return fmt.Sprintf("ollamago: stream stalled for %v", e.Timeout)
+	case PhaseOverall:
+		return fmt.Sprintf("ollamago: call not completed within %v", e.Timeout)
+	}
+	return fmt.Sprintf("ollamago: %v timeout after %v", e.Phase, e.Timeout)
+}
+
+func (e *WatchdogError) Is(target error) bool {
+	return target == ErrWatchdog
+}
+
+func applyCallOptions(next http.RoundTripper) http.RoundTripper {
+	return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		cfg, ok := req.Context().Value(callOptionsKey{}).(callConfig)
+		if !ok || cfg == (callConfig{}) {
+			return next.RoundTrip(req)
+		}
+		ctx, cancel := context.WithCancel(req.Context())
+		w := &watchdog{cancel: cancel}
+		w.arm(PhaseOverall, cfg.overall)
+		w.arm(PhaseConnect, cfg.connect)
+		w.arm(PhaseFirstByte, cfg.firstByte)
+		w.arm(PhaseFirstToken, cfg.firstToken)
+		ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+			GotConn:              func(httptrace.GotConnInfo) { w.disarm(PhaseConnect) },
+			GotFirstResponseByte: func() { w.disarm(PhaseFirstByte) },
+		})
+		resp, err := next.RoundTrip(req.WithContext(ctx))
+		if err != nil {
+			w.stop()
+			if werr := w.error(); werr != nil {
+				return nil, werr
+			}
+			return nil, err
+		}
+		w.disarm(PhaseConnect)
+		w.disarm(PhaseFirstByte)
+		resp.Body = &watchdogBody{ReadCloser: resp.Body, watchdog: w, stall: cfg.stall}
+		return resp, nil
+	})
+}
+
+// watchdog cancels a call when one of its timers fires, remembering why.
+type watchdog struct {
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	timers [PhaseOverall + 1]*time.Timer
+	fired  *WatchdogError
+}
+
+func (w *watchdog) arm(phase Phase, d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if t := w.timers[phase]; t != nil {
+		t.Stop()
+		w.timers[phase] = nil
+	}
+	if d <= 0 || w.fired != nil {
+		return
+	}
+	w.timers[phase] = time.AfterFunc(d, func() {
+		w.mu.Lock()
+		if w.fired == nil {
+			w.fired = &WatchdogError{Phase: phase, Timeout: d}
+		}
+		w.mu.Unlock()
+		w.cancel()
+	})
+}
+
+func (w *watchdog) disarm(phase Phase) {
+	w.arm(phase, 0)
+}
+
+func (w *watchdog) stop() {
+	w.mu.Lock()
+	for _, t := range w.timers {
+		if t != nil {
+			t.Stop()
+		}
+	}
+	w.mu.Unlock()
+	w.cancel()
+}
+
+func (w *watchdog) error() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fired == nil {
+		return nil
+	}
+	return w.fired
+}
+
+type watchdogBody struct {
+	io.ReadCloser
+	watchdog *watchdog
+	stall    time.Duration
+	started  bool
+}
+
+func (b *watchdogBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && err != io.EOF {
+		if werr := b.watchdog.error(); werr != nil {
+			return n, werr
+		}
+	}
+	if n > 0 {
+		if !b.started {
+			b.started = true
+			b.watchdog.disarm(PhaseFirstToken)
+		}
+		b.watchdog.arm(PhaseStall, b.stall)
+	}
+	return n, err
+}
+
+func (b *watchdogBody) Close() error {
+	b.watchdog.stop()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,125 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchdog(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test", Chunks: []string{"a", "b", "c"}})
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+	chat := func(ctx context.Context) (string, error) {
+		respChan, err := client.GenerateChat(ctx, ollamago.ChatRequest{
+			Model:    "test",
+			Messages: []ollamago.ChatMessage{{Role: "user", Content: "hi"}},
+			Stream:   true,
+		})
+		if err != nil {
+			return "", err
+		}
+		var content string
+		for r := range respChan {
+			if r.Error != nil {
+				return content, r.Error
+			}
+			content += r.Message.Content
+		}
+		return content, nil
+	}
+
+	srv.SetLatency(200 * time.Millisecond)
+	ctx := ollamago.WithCallOptions(context.Background(), ollamago.WithFirstTokenTimeout(50*time.Millisecond))
+	_, err := chat(ctx)
+	var werr *ollamago.WatchdogError
+	require.ErrorAs(t, err, &werr)
+	require.Equal(t, ollamago.PhaseFirstToken, werr.Phase)
+	require.ErrorIs(t, err, ollamago.ErrWatchdog)
+
+	srv.SetLatency(0)
+	srv.SetChunkDelay(100 * time.Millisecond)
+	ctx = ollamago.WithCallOptions(ctx, ollamago.WithFirstTokenTimeout(time.Second), ollamago.WithStallTimeout(30*time.Millisecond))
+	content, err := chat(ctx)
+	require.ErrorAs(t, err, &werr)
+	require.Equal(t, ollamago.PhaseStall, werr.Phase)
+	require.Equal(t, 30*time.Millisecond, werr.Timeout)
+	require.Equal(t, "a", content)
+
+	srv.SetChunkDelay(10 * time.Millisecond)
+	ctx = ollamago.WithCallOptions(ctx, ollamago.WithStallTimeout(time.Second))
+	content, err = chat(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "abc", content)
+
+	_, err = chat(context.Background())
+	require.False(t, errors.Is(err, ollamago.ErrWatchdog))
+}
+
+func TestCallTimeouts(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test", Chunks: []string{"a", "b", "c"}})
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+	phase := func(err error) ollamago.Phase {
+		t.Helper()
+		var werr *ollamago.WatchdogError
+		require.ErrorAs(t, err, &werr)
+		return werr.Phase
+	}
+
+	srv.SetLatency(100 * time.Millisecond)
+	ctx := ollamago.WithCallOptions(context.Background(), ollamago.WithFirstByteTimeout(20*time.Millisecond))
+	_, err := client.ListModels(ctx)
+	require.Equal(t, ollamago.PhaseFirstByte, phase(err))
+
+	ctx = ollamago.WithCallOptions(context.Background(), ollamago.WithOverallDeadline(50*time.Millisecond))
+	_, err = client.ListModels(ctx)
+	require.Equal(t, ollamago.PhaseOverall, phase(err))
+
+	srv.SetLatency(0)
+	srv.SetChunkDelay(30 * time.Millisecond)
+	respChan, err := client.GenerateChat(ctx, ollamago.ChatRequest{Model: "test", Stream: true})
+	require.NoError(t, err)
+	var streamErr error
+	for r := range respChan {
+		if r.Error != nil {
+			streamErr = r.Error
+		}
+	}
+	require.Equal(t, ollamago.PhaseOverall, phase(streamErr))
+
+	ctx = ollamago.WithCallOptions(context.Background(), ollamago.WithOverallDeadline(time.Second))
+	_, err = client.ListModels(ctx)
+	require.NoError(t, err)
+
+	client.HTTPClient = &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}}
+	ctx = ollamago.WithCallOptions(context.Background(), ollamago.WithConnectTimeout(20*time.Millisecond))
+	_, err = client.ListModels(ctx)
+	require.Equal(t, ollamago.PhaseConnect, phase(err))
+}
@@ -0,0 +1,161 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ServerCapabilities reports the features supported by a server.
+type ServerCapabilities struct {
+	Version string
+
+	// Tools is set when chats may offer tools to the model.
+	Tools bool
+
+	// StructuredOutputs is set when Format may hold a JSON schema rather
+	// than just "json".
+	StructuredOutputs bool
+
+	// Thinking is set when models may be asked to think before answering.
+	Thinking bool
+
+	// Embed is set when the server offers /api/embed. Otherwise, the
+	// client falls back to the legacy /api/embeddings endpoint.
+	Embed bool
+}
+
+// ErrUnsupported is matched by the errors returned when the server lacks
+// a feature.
+var ErrUnsupported = errors.New("feature not supported by the server")
+
+// UnsupportedError reports a feature missing from the server.
+type UnsupportedError struct {
+	Feature string
+	Version string
+}
+
+func (e *UnsupportedError) Error() string {
+	return e.Feature + " not supported by server version " + e.Version
+}
+
+func (e *UnsupportedError) Is(target error) bool {
+	return target == ErrUnsupported
+}
+
+// Capabilities detects the features supported by the server from its
+// version, probing the endpoints that can be told apart without one.
+// Development builds, which report version 0.0.0, are assumed to support
+// everything.
+func (c *Client) Capabilities(ctx context.Context) (*ServerCapabilities, error) {
+	version, err := c.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+	caps := &ServerCapabilities{
+		Version:           version,
+		Tools:             versionAtLeast(version, 0, 3, 0),
+		StructuredOutputs: versionAtLeast(version, 0, 5, 0),
+		Thinking:          versionAtLeast(version, 0, 9, 0),
+	}
+	caps.Embed, err = c.probe(ctx, "/api/embed")
+	if err != nil {
+		return nil, err
+	}
+	return caps, nil
+}
+
+// probe reports whether the server routes an endpoint, by posting it an
+// empty request: the server answers such requests for endpoints it lacks
+// with a 404 and no error message.
+func (c *Client) probe(ctx context.Context, endpoint string) (bool, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.endpoint(endpoint), strings.NewReader("{}"))
+	if err != nil {
+		return false, fmt.Errorf("cannot prepare HTTP request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	resp, err := c.httpClient().Do(httpReq)
+	if err != nil {
+		return false, fmt.Errorf("cannot execute HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return true, nil
+	}
+	return !missingEndpoint(newStatusError("probe", resp)), nil
+}
+
+// missingEndpoint reports whether err is the 404 the server answers for
+// endpoints it does not have.
+func missingEndpoint(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound && statusErr.Message == ""
+}
+
+// CheckChat returns an *UnsupportedError if req uses a feature the server
+// lacks.
+func (s *ServerCapabilities) CheckChat(req ChatRequest) error {
+	if len(req.Tools) > 0 && !s.Tools {
+		return &UnsupportedError{Feature: "tools", Version: s.Version}
+	}
+	return s.checkFormat(req.Format)
+}
+
+// CheckCompletion returns an *UnsupportedError if req uses a feature the
+// server lacks.
+func (s *ServerCapabilities) CheckCompletion(req CompletionRequest) error {
+	return s.checkFormat(req.Format)
+}
+
+func (s *ServerCapabilities) checkFormat(format []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(format), []byte("{")) && !s.StructuredOutputs {
+		return &UnsupportedError{Feature: "structured outputs", Version: s.Version}
+	}
+	return nil
+}
+
+// versionAtLeast reports whether version is at least major.minor.patch.
+// Unparsable and development versions are assumed recent.
+func versionAtLeast(version string, major, minor, patch int) bool {
+	version, _, _ = strings.Cut(strings.TrimPrefix(version, "v"), "-")
+	parts := strings.Split(version, ".")
+	if len(parts) != 3 {
+		return true
+	}
+	var got [3]int
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return true
+		}
+		got[i] = n
+	}
+	if got == [3]int{} {
+		return true
+	}
+	want := [3]int{major, minor, patch}
+	for i := range got {
+		if got[i] != want[i] {
+			return got[i] > want[i]
+		}
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapabilities(t *testing.T) {
+	srv := ollamatest.NewServer()
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+	ctx := context.Background()
+
+	caps, err := client.Capabilities(ctx)
+	require.NoError(t, err)
+	require.Equal(t, &ollamago.ServerCapabilities{
+		Version:           "0.0.0-ollamatest",
+		Tools:             true,
+		StructuredOutputs: true,
+		Thinking:          true,
+		Embed:             true,
+	}, caps, "development builds support everything")
+
+	srv.SetVersion("0.4.7")
+	caps, err = client.Capabilities(ctx)
+	require.NoError(t, err)
+	require.True(t, caps.Tools)
+	require.False(t, caps.StructuredOutputs)
+	require.False(t, caps.Thinking)
+	require.NoError(t, caps.CheckChat(ollamago.ChatRequest{Tools: []ollamago.Tool{{Type: "function"}}, Format: json.RawMessage(`"json"`)}))
+	err = caps.CheckCompletion(ollamago.CompletionRequest{Format: json.RawMessage(`{"type":"object"}`)})
+	require.ErrorIs(t, err, ollamago.ErrUnsupported)
+	var unsupported *ollamago.UnsupportedError
+	require.ErrorAs(t, err, &unsupported)
+	require.Equal(t, "structured outputs", unsupported.Feature)
+
+	srv.SetVersion("0.2.8")
+	caps, err = client.Capabilities(ctx)
+	require.NoError(t, err)
+	err = caps.CheckChat(ollamago.ChatRequest{Tools: []ollamago.Tool{{Type: "function"}}})
+	require.ErrorIs(t, err, ollamago.ErrUnsupported)
+}
+
+func TestLegacyEmbeddings(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/version", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"version":"0.1.30"}`)
+	})
+	mux.HandleFunc("/api/embeddings", func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Prompt string }
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(map[string]any{"embedding": []float64{float64(len(req.Prompt)), 1}})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	client := &ollamago.Client{BaseURL: server.URL}
+	ctx := context.Background()
+
+	caps, err := client.Capabilities(ctx)
+	require.NoError(t, err)
+	require.False(t, caps.Embed)
+
+	resp, err := client.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: "test", Input: []string{"a", "bb"}})
+	require.NoError(t, err)
+	require.Equal(t, "test", resp.Model)
+	require.Equal(t, [][]float64{{1, 1}, {2, 1}}, resp.Embeddings)
+	resp32, err := client.GenerateEmbeddings32(ctx, ollamago.EmbedRequest{Model: "test", Input: []string{"ccc"}})
+	require.NoError(t, err)
+	require.Equal(t, [][]float32{{3, 1}}, resp32.Embeddings)
+}
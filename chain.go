@@ -0,0 +1,120 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Chain is a step of a multi-step workflow, turning an In into an Out.
+// Chains are composed with Then, so that the output of one step feeds the
+// next:
+//
+//	summarize := ollamago.Then(
+//		ollamago.Then(ollamago.MustTemplate[Article]("Summarize: {{.Body}}"), ollamago.ChatStep(client, req)),
+//		ollamago.ParseJSON[Summary](),
+//	)
+type Chain[In, Out any] func(ctx context.Context, in In) (Out, error)
+
+// Then returns a chain running first and then second on its output. It
+// stops at the first error, and before second if ctx is done.
+func Then[A, B, C any](first Chain[A, B], second Chain[B, C]) Chain[A, C] {
+	return func(ctx context.Context, in A) (C, error) {
+		var zero C
+		mid, err := first(ctx, in)
+		if err != nil {
+			return zero, err
+		}
+		if err := ctx.Err(); err != nil {
+			return zero, err
+		}
+		return second(ctx, mid)
+	}
+}
+
+// Named wraps the errors of a chain with its name, telling which step of
+// a longer chain failed.
+func Named[In, Out any](name string, c Chain[In, Out]) Chain[In, Out] {
+	return func(ctx context.Context, in In) (Out, error) {
+		out, err := c(ctx, in)
+		if err != nil {
+			return out, fmt.Errorf("%s: %w", name, err)
+		}
+		return out, nil
+	}
+}
+
+// Map returns a chain applying a function that neither fails nor talks to
+// the server.
+func Map[In, Out any](fn func(In) Out) Chain[In, Out] {
+	return func(ctx context.Context, in In) (Out, error) {
+		return fn(in), nil
+	}
+}
+
+// Template returns a chain rendering a text/template with its input.
+func Template[In any](text string) (Chain[In, string], error) {
+	tmpl, err := template.New("chain").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse template: %w", err)
+	}
+	return func(ctx context.Context, in In) (string, error) {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, in); err != nil {
+			return "", fmt.Errorf("cannot render template: %w", err)
+		}
+		return b.String(), nil
+	}, nil
+}
+
+// MustTemplate is like Template but panics if the template does not
+// parse.
+func MustTemplate[In any](text string) Chain[In, string] {
+	c, err := Template[In](text)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// ChatStep returns a chain sending its input as a user message appended to
+// the messages of req, and returning the content of the reply.
+func ChatStep(client API, req ChatRequest) Chain[string, string] {
+	return func(ctx context.Context, prompt string) (string, error) {
+		req := req
+		req.Messages = append(append([]ChatMessage(nil), req.Messages...), UserMessage(prompt))
+		resp, err := client.GenerateChat(ctx, req)
+		if err != nil {
+			return "", fmt.Errorf("cannot generate chat: %w", err)
+		}
+		reply, err := collectChat(resp)
+		if err != nil {
+			return "", fmt.Errorf("cannot generate chat: %w", err)
+		}
+		return reply.Content, nil
+	}
+}
+
+// ParseJSON returns a chain decoding its input into a T, repairing
+// malformed JSON and validating the result as ChatInto does. Errors wrap
+// ErrInvalidOutput.
+func ParseJSON[T any]() Chain[string, T] {
+	return func(ctx context.Context, in string) (T, error) {
+		return decodeStructured[T](in, true)
+	}
+}
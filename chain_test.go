@@ -0,0 +1,64 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChain(t *testing.T) {
+	mock := scriptedChat(`{"name":"Ada","age":36,}`)
+	extract := ollamago.Then(
+		ollamago.Then(
+			ollamago.MustTemplate[string]("Extract the person from: {{.}}"),
+			ollamago.ChatStep(mock, ollamago.ChatRequest{Model: "test", Messages: []ollamago.ChatMessage{ollamago.SystemMessage("reply in JSON")}}),
+		),
+		ollamago.ParseJSON[person](),
+	)
+	greet := ollamago.Then(extract, ollamago.Map(func(p person) string { return "Hello, " + p.Name }))
+
+	out, err := greet(context.Background(), "Ada Lovelace, 36")
+	require.NoError(t, err)
+	require.Equal(t, "Hello, Ada", out)
+	req := mock.Calls()[0].Request.(ollamago.ChatRequest)
+	require.Equal(t, []ollamago.ChatMessage{
+		ollamago.SystemMessage("reply in JSON"),
+		ollamago.UserMessage("Extract the person from: Ada Lovelace, 36"),
+	}, req.Messages)
+
+	mock = scriptedChat(`{"name":"Ada","age":-1}`)
+	var reached bool
+	failing := ollamago.Then(
+		ollamago.Named("extract", ollamago.Then(ollamago.ChatStep(mock, ollamago.ChatRequest{Model: "test"}), ollamago.ParseJSON[person]())),
+		ollamago.Map(func(p person) person { reached = true; return p }),
+	)
+	_, err = failing(context.Background(), "Ada")
+	require.ErrorIs(t, err, ollamago.ErrInvalidOutput)
+	require.True(t, strings.HasPrefix(err.Error(), "extract: "))
+	require.False(t, reached, "the chain must stop at the first error")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = greet(ctx, "Ada")
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = ollamago.Template[string]("{{.")
+	require.Error(t, err)
+}
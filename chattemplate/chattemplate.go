@@ -0,0 +1,313 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package chattemplate renders chat requests through the prompt template
+// of a model, as the Ollama server does, to preview the exact prompt the
+// model sees and count its tokens before sending it:
+//
+//	prompt, err := chattemplate.Preview(ctx, client, req)
+//	n := tokens.ForFamily("llama").Count(prompt)
+//
+// Templates use the text/template syntax with the data and functions of
+// the server: .System, .Prompt, .Response, .Messages and .Tools, and the
+// json, currentDate and yesterdayDate functions.
+package chattemplate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"cirello.io/ollamago"
+)
+
+var funcs = template.FuncMap{
+	"json": func(v any) string {
+		b, _ := json.Marshal(v)
+		return string(b)
+	},
+	"currentDate": func() string {
+		return time.Now().Format("2006-01-02")
+	},
+	"yesterdayDate": func() string {
+		return time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	},
+}
+
+// Template is a parsed prompt template.
+type Template struct {
+	tmpl *template.Template
+
+	// messages reports whether the template ranges over .Messages; older
+	// templates only know of .System, .Prompt and .Response.
+	messages bool
+
+	// System is the default system prompt of the model, used when the
+	// messages have none.
+	System string
+}
+
+// Parse parses the template of a model, as found in
+// ollamago.ShowModelResponse.Template.
+func Parse(text string) (*Template, error) {
+	tmpl, err := template.New("").Option("missingkey=zero").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse chat template: %w", err)
+	}
+	t := &Template{tmpl: tmpl}
+	if tmpl.Tree != nil {
+		t.messages = usesMessages(tmpl.Tree.Root)
+	}
+	return t, nil
+}
+
+// usesMessages reports whether a template tree refers to .Messages.
+func usesMessages(n parse.Node) bool {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, c := range n.Nodes {
+			if usesMessages(c) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return usesMessages(n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, c := range n.Cmds {
+			if usesMessages(c) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, a := range n.Args {
+			if usesMessages(a) {
+				return true
+			}
+		}
+	case *parse.FieldNode:
+		return len(n.Ident) > 0 && n.Ident[0] == "Messages"
+	case *parse.ChainNode:
+		return usesMessages(n.Node)
+	case *parse.VariableNode:
+		return len(n.Ident) > 1 && n.Ident[1] == "Messages"
+	case *parse.IfNode:
+		return usesMessages(n.Pipe) || usesMessages(n.List) || usesMessages(n.ElseList)
+	case *parse.RangeNode:
+		return usesMessages(n.Pipe) || usesMessages(n.List) || usesMessages(n.ElseList)
+	case *parse.WithNode:
+		return usesMessages(n.Pipe) || usesMessages(n.List) || usesMessages(n.ElseList)
+	case *parse.TemplateNode:
+		return usesMessages(n.Pipe)
+	}
+	return false
+}
+
+// Fetch returns the template and the default system prompt of a model.
+func Fetch(ctx context.Context, client ollamago.API, model string) (*Template, error) {
+	show, err := client.ShowModelInfo(ctx, ollamago.ShowModelRequest{Model: model})
+	if err != nil {
+		return nil, fmt.Errorf("cannot show model %q: %w", model, err)
+	}
+	if show.Template == "" {
+		return nil, fmt.Errorf("model %q has no template", model)
+	}
+	t, err := Parse(show.Template)
+	if err != nil {
+		return nil, err
+	}
+	t.System = show.System
+	return t, nil
+}
+
+// Preview renders a chat request through the template of its model.
+func Preview(ctx context.Context, client ollamago.API, req ollamago.ChatRequest) (string, error) {
+	t, err := Fetch(ctx, client, req.Model)
+	if err != nil {
+		return "", err
+	}
+	return t.Render(req.Messages, req.Tools)
+}
+
+// The data of templates mirrors the types of the server, which templates
+// address by their Go field names.
+type (
+	values struct {
+		Messages []message
+		Tools    []tool
+		System   string
+		Prompt   string
+		Response string
+	}
+
+	message struct {
+		Role      string     `json:"role"`
+		Content   string     `json:"content"`
+		Images    []string   `json:"images,omitempty"`
+		ToolCalls []toolCall `json:"tool_calls,omitempty"`
+	}
+
+	toolCall struct {
+		Function toolCallFunction `json:"function"`
+	}
+
+	toolCallFunction struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	}
+
+	tool struct {
+		Type     string       `json:"type"`
+		Function toolFunction `json:"function"`
+	}
+
+	toolFunction struct {
+		Name        string     `json:"name"`
+		Description string     `json:"description"`
+		Parameters  parameters `json:"parameters"`
+	}
+
+	parameters struct {
+		Type       string              `json:"type"`
+		Required   []string            `json:"required"`
+		Properties map[string]property `json:"properties"`
+	}
+
+	property struct {
+		Type        any    `json:"type"`
+		Items       any    `json:"items,omitempty"`
+		Description string `json:"description"`
+		Enum        []any  `json:"enum,omitempty"`
+	}
+)
+
+func newMessages(in []ollamago.ChatMessage) ([]message, error) {
+	out := make([]message, len(in))
+	for i, m := range in {
+		out[i] = message{Role: m.Role, Content: m.Content, Images: m.Images}
+		for _, call := range m.ToolCalls {
+			c := toolCall{Function: toolCallFunction{Name: call.Function.Name}}
+			if len(call.Function.Arguments) > 0 {
+				if err := json.Unmarshal(call.Function.Arguments, &c.Function.Arguments); err != nil {
+					return nil, fmt.Errorf("message %d: invalid arguments of %s: %w", i, call.Function.Name, err)
+				}
+			}
+			out[i].ToolCalls = append(out[i].ToolCalls, c)
+		}
+	}
+	return out, nil
+}
+
+func newTools(in []ollamago.Tool) ([]tool, error) {
+	out := make([]tool, len(in))
+	for i, t := range in {
+		out[i] = tool{Type: t.Type, Function: toolFunction{Name: t.Function.Name, Description: t.Function.Description}}
+		if len(t.Function.Parameters) > 0 {
+			if err := json.Unmarshal(t.Function.Parameters, &out[i].Function.Parameters); err != nil {
+				return nil, fmt.Errorf("invalid parameters of tool %s: %w", t.Function.Name, err)
+			}
+		}
+	}
+	return out, nil
+}
+
+// responseMarker stands for the response in the last turn of the older
+// templates, whose prompt ends where the response would start.
+const responseMarker = "\x00response\x00"
+
+// Render renders the messages and the tools as the prompt of a model
+// turn. The default system prompt is prepended if the messages have none.
+func (t *Template) Render(messages []ollamago.ChatMessage, tools []ollamago.Tool) (string, error) {
+	if t.System != "" && (len(messages) == 0 || messages[0].Role != ollamago.RoleSystem) {
+		messages = append([]ollamago.ChatMessage{ollamago.SystemMessage(t.System)}, messages...)
+	}
+	msgs, err := newMessages(messages)
+	if err != nil {
+		return "", err
+	}
+	toolDefs, err := newTools(tools)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if t.messages {
+		v := values{Messages: msgs, Tools: toolDefs}
+		for _, m := range msgs {
+			if m.Role == ollamago.RoleSystem {
+				v.System = m.Content
+			}
+		}
+		if err := t.tmpl.Execute(&sb, v); err != nil {
+			return "", fmt.Errorf("cannot render chat template: %w", err)
+		}
+		return sb.String(), nil
+	}
+	// Older templates render a turn at a time: the system prompt and
+	// the user messages up to each response.
+	var turn values
+	for i, m := range msgs {
+		switch m.Role {
+		case ollamago.RoleSystem:
+			turn.System = join(turn.System, m.Content)
+		case ollamago.RoleAssistant:
+			turn.Response = m.Content
+			if err := t.execute(&sb, turn); err != nil {
+				return "", err
+			}
+			turn = values{}
+			continue
+		default:
+			turn.Prompt = join(turn.Prompt, m.Content)
+		}
+		if i == len(msgs)-1 {
+			turn.Response = responseMarker
+			var last bytes.Buffer
+			if err := t.execute(&last, turn); err != nil {
+				return "", err
+			}
+			prompt, _, found := strings.Cut(last.String(), responseMarker)
+			if !found {
+				return "", errors.New("cannot render chat template: the template has no .Response")
+			}
+			sb.WriteString(prompt)
+		}
+	}
+	return sb.String(), nil
+}
+
+func (t *Template) execute(w io.Writer, v values) error {
+	if err := t.tmpl.Execute(w, v); err != nil {
+		return fmt.Errorf("cannot render chat template: %w", err)
+	}
+	return nil
+}
+
+func join(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + "\n\n" + b
+}
@@ -0,0 +1,102 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package chattemplate_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/chattemplate"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+// messagesTemplate is modeled after the templates of recent models.
+const messagesTemplate = `{{- if .System }}<|system|>{{ .System }}<|end|>
+{{ end }}
+{{- if .Tools }}<|tools|>{{ range .Tools }}{{ .Function.Name }}({{ range $k, $v := .Function.Parameters.Properties }}{{ $k }} {{ $v.Type }}{{ end }}) {{ end }}<|end|>
+{{ end }}
+{{- range $i, $_ := .Messages }}
+{{- $last := eq (len (slice $.Messages $i)) 1 }}
+{{- if eq .Role "user" }}<|user|>{{ .Content }}<|end|>
+{{ else if eq .Role "assistant" }}<|assistant|>{{ .Content }}{{ range .ToolCalls }}{{ json .Function }}{{ end }}{{ if not $last }}<|end|>
+{{ end }}
+{{- else if eq .Role "tool" }}<|tool|>{{ .Content }}<|end|>
+{{ end }}
+{{- if and $last (ne .Role "assistant") }}<|assistant|>{{ end }}
+{{- end }}`
+
+// legacyTemplate only knows of a single turn.
+const legacyTemplate = `{{ if .System }}### System:
+{{ .System }}
+
+{{ end }}### User:
+{{ .Prompt }}
+
+### Response:
+{{ .Response }}
+`
+
+func TestRender(t *testing.T) {
+	tmpl, err := chattemplate.Parse(messagesTemplate)
+	require.NoError(t, err)
+	tmpl.System = "Be brief."
+	prompt, err := tmpl.Render([]ollamago.ChatMessage{
+		ollamago.UserMessage("weather in Rome?"),
+		{Role: ollamago.RoleAssistant, ToolCalls: []ollamago.ToolCall{{Function: ollamago.ToolCallFunction{Name: "weather", Arguments: json.RawMessage(`{"city":"Rome"}`)}}}},
+		ollamago.ToolResult("1", "sunny"),
+	}, []ollamago.Tool{{Type: "function", Function: ollamago.ToolFunction{Name: "weather", Parameters: json.RawMessage(`{"type":"object","properties":{"city":{"type":"string"}}}`)}}})
+	require.NoError(t, err)
+	require.Equal(t, "<|system|>Be brief.<|end|>\n"+
+		"<|tools|>weather(city string) <|end|>\n"+
+		"<|user|>weather in Rome?<|end|>\n"+
+		`<|assistant|>{"name":"weather","arguments":{"city":"Rome"}}<|end|>`+"\n"+
+		"<|tool|>sunny<|end|>\n"+
+		"<|assistant|>", prompt)
+
+	tmpl, err = chattemplate.Parse(legacyTemplate)
+	require.NoError(t, err)
+	prompt, err = tmpl.Render([]ollamago.ChatMessage{
+		ollamago.SystemMessage("Be brief."),
+		ollamago.UserMessage("hi"),
+		ollamago.AssistantMessage("hello"),
+		ollamago.UserMessage("bye"),
+	}, nil)
+	require.NoError(t, err)
+	require.Equal(t, "### System:\nBe brief.\n\n### User:\nhi\n\n### Response:\nhello\n"+
+		"### User:\nbye\n\n### Response:\n", prompt)
+
+	_, err = chattemplate.Parse("{{ .Prompt ")
+	require.Error(t, err)
+}
+
+func TestPreview(t *testing.T) {
+	srv := ollamatest.NewServer(
+		ollamatest.Model{Name: "legacy", Show: ollamago.ShowModelResponse{Template: legacyTemplate, System: "Be kind."}},
+		ollamatest.Model{Name: "raw"},
+	)
+	t.Cleanup(srv.Close)
+	prompt, err := chattemplate.Preview(context.Background(), srv.Client(), ollamago.ChatRequest{
+		Model:    "legacy",
+		Messages: []ollamago.ChatMessage{ollamago.UserMessage("hi")},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "### System:\nBe kind.\n\n### User:\nhi\n\n### Response:\n", prompt)
+
+	_, err = chattemplate.Preview(context.Background(), srv.Client(), ollamago.ChatRequest{Model: "raw"})
+	require.ErrorContains(t, err, "no template")
+}
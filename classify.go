@@ -0,0 +1,66 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Classify asks model which of the labels best fits text. The reply is
+// constrained to the labels by a JSON schema enum and checked against
+// them, ignoring case; opts configure the retries of invalid replies as
+// for ChatInto. The label is returned as spelled in labels.
+func Classify(ctx context.Context, client API, model, text string, labels []string, opts ...StructuredOption) (string, error) {
+	if len(labels) == 0 {
+		return "", errors.New("cannot classify without labels")
+	}
+	format, err := json.Marshal(map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"label": map[string]any{"type": "string", "enum": labels},
+		},
+		"required": []string{"label"},
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot prepare classification schema: %w", err)
+	}
+	req := ChatRequest{
+		Model: model,
+		Messages: []ChatMessage{
+			SystemMessage("Classify the text given by the user with exactly one of these labels: " + strings.Join(labels, ", ") + ". Reply with the label only."),
+			UserMessage(text),
+		},
+		Format:  format,
+		Options: ModelParameters{Temperature: Ptr(0.0)},
+	}
+	return chatStructured(ctx, client, req, opts, func(content string, repair bool) (string, error) {
+		reply, err := decodeStructured[struct {
+			Label string `json:"label"`
+		}](content, repair)
+		if err != nil {
+			return "", err
+		}
+		for _, l := range labels {
+			if strings.EqualFold(strings.TrimSpace(reply.Label), l) {
+				return l, nil
+			}
+		}
+		return "", fmt.Errorf("%w: %q is not one of the labels", ErrInvalidOutput, reply.Label)
+	})
+}
@@ -0,0 +1,45 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"testing"
+
+	"cirello.io/ollamago"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassify(t *testing.T) {
+	labels := []string{"Positive", "Negative", "Neutral"}
+	mock := scriptedChat(`{"label":"happy"}`, `{"label":" negative"}`)
+	label, err := ollamago.Classify(context.Background(), mock, "test", "this is awful", labels, ollamago.WithRetries(1))
+	require.NoError(t, err)
+	require.Equal(t, "Negative", label)
+	calls := mock.CallsTo("GenerateChat")
+	require.Len(t, calls, 2)
+	req := calls[0].Request.(ollamago.ChatRequest)
+	require.JSONEq(t, `{"type":"object","properties":{"label":{"type":"string","enum":["Positive","Negative","Neutral"]}},"required":["label"]}`, string(req.Format))
+	require.Equal(t, ollamago.UserMessage("this is awful"), req.Messages[1])
+	retry := calls[1].Request.(ollamago.ChatRequest)
+	require.Contains(t, retry.Messages[3].Content, `"happy" is not one of the labels`)
+
+	mock = scriptedChat(`{"label":"happy"}`)
+	_, err = ollamago.Classify(context.Background(), mock, "test", "great", labels)
+	require.ErrorIs(t, err, ollamago.ErrInvalidOutput)
+
+	_, err = ollamago.Classify(context.Background(), mock, "test", "great", nil)
+	require.Error(t, err)
+}
@@ -15,87 +15,423 @@
 package ollamago
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 )
 
+// API is the set of operations offered by the Ollama server. Client
+// implements it; code that depends on API can be unit-tested with
+// ollamagotest.MockClient.
+type API interface {
+	GenerateCompletion(ctx context.Context, req CompletionRequest) (<-chan CompletionResponse, error)
+	GenerateEmbeddings(ctx context.Context, req EmbedRequest) (*EmbedResponse, error)
+	GenerateEmbeddings32(ctx context.Context, req EmbedRequest) (*EmbedResponse32, error)
+	GenerateChat(ctx context.Context, req ChatRequest) (<-chan ChatResponse, error)
+	ListModels(ctx context.Context) (*ListModelsResponse, error)
+	ListRunningModels(ctx context.Context) (*ListRunningModelsResponse, error)
+	ShowModelInfo(ctx context.Context, req ShowModelRequest) (*ShowModelResponse, error)
+	DeleteModel(ctx context.Context, req DeleteModelRequest) error
+	PullModel(ctx context.Context, req PullModelRequest) (<-chan ProgressEvent, error)
+	PushModel(ctx context.Context, req PushModelRequest) (<-chan ProgressEvent, error)
+	CreateModel(ctx context.Context, req CreateModelRequest) (<-chan ProgressEvent, error)
+	Version(ctx context.Context) (string, error)
+}
+
+var _ API = (*Client)(nil)
+
+// ClientVersion is the version of this package, sent in the default
+// User-Agent.
+const ClientVersion = "0.1.0"
+
+// DefaultUserAgent is the User-Agent header of the requests of clients
+// that do not set one.
+const DefaultUserAgent = "ollamago/" + ClientVersion
+
 type Client struct {
-	BaseURL    string
+	// BaseURL is the URL of the server, which may include a path prefix
+	// when it is mounted behind a gateway, as in
+	// "https://gateway.example.com/ollama". If empty,
+	// "http://localhost:11434" is used.
+	BaseURL string
+
+	// HTTPClient sends the requests. If nil, a client with the transport
+	// of NewTransport is used.
 	HTTPClient *http.Client
+
+	// Routes overrides the paths of endpoints, relative to BaseURL, for
+	// gateways that expose them elsewhere. It maps the standard path of
+	// an endpoint, such as "/api/chat" or "/api/blobs", to the path to
+	// use instead.
+	Routes map[string]string
+
+	// UserAgent is the User-Agent header of the requests. If empty,
+	// DefaultUserAgent is used.
+	UserAgent string
+
+	// Application identifies the calling application to server logs and
+	// proxies, such as "myapp/1.2". It is appended to the User-Agent.
+	Application string
+
+	// Interceptors wrap the HTTP transport of every request, the first
+	// being the outermost.
+	Interceptors []Interceptor
+
+	// DefaultModel is the model of the requests that do not name one.
+	DefaultModel string
+
+	// DefaultOptions holds the options of the completion and chat
+	// requests that leave them unset.
+	DefaultOptions ModelParameters
+
+	// DefaultKeepAlive is the keep_alive of the completion and chat
+	// requests that leave it unset. If zero, the server default is used.
+	DefaultKeepAlive time.Duration
+
+	// Codec encodes the requests and decodes the responses. If nil,
+	// JSONCodec is used.
+	Codec Codec
+
+	// StreamBodyThreshold is the size of the images and texts of a
+	// request beyond which its body is encoded while it is sent, without
+	// Content-Length, instead of in memory beforehand. If zero,
+	// DefaultStreamBodyThreshold is used; if negative, bodies are never
+	// streamed.
+	StreamBodyThreshold int
+
+	// StreamBuffer is the number of chunks of chat and completion streams
+	// that can be pending for a lagging consumer before StreamOverflow
+	// applies.
+	StreamBuffer int
+
+	// StreamOverflow is what happens to the chunks of chat and completion
+	// streams beyond StreamBuffer. Unless it is OverflowBlock, the
+	// response is read as fast as the server sends it, so that slow
+	// consumers, such as terminals, do not stall the connection.
+	StreamOverflow OverflowPolicy
+
+	// Proxy is the URL of the HTTP, HTTPS or SOCKS5 proxy to reach the
+	// server through, instead of the one of the environment.
+	Proxy *url.URL
+
+	// DialContext, if set, opens the connections to the server or the
+	// proxy, such as through an SSH tunnel or a userspace network.
+	//
+	// Proxy and DialContext apply to the default transport, or to the
+	// transport of HTTPClient if it is an *http.Transport.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	mu            sync.RWMutex
+	modelDefaults map[string]ModelParameters
+
+	// proxied is the transport configured with Proxy and DialContext,
+	// derived from proxiedFrom, kept to reuse its connections.
+	proxied     *http.Transport
+	proxiedFrom *http.Transport
+}
+
+// StatusError is returned when the server answers a call with an HTTP
+// status other than 200 OK.
+type StatusError struct {
+	// Op is the failed operation, such as "generate chat".
+	Op string
+
+	// StatusCode and Status are those of the HTTP response.
+	StatusCode int
+	Status     string
+
+	// Message is the error message sent by the server, if any.
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return "failed to " + e.Op + ": " + e.Status
+	}
+	return "failed to " + e.Op + ": " + e.Status + ": " + e.Message
+}
+
+func newStatusError(op string, resp *http.Response) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	return &StatusError{Op: op, StatusCode: resp.StatusCode, Status: resp.Status, Message: body.Error}
+}
+
+// Interceptor wraps the transport used by Client. It can inspect, rewrite,
+// short-circuit or observe each exchange with the Ollama server.
+type Interceptor func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripFunc adapts a function to http.RoundTripper.
+type RoundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
 }
 
 type CompletionRequest struct {
 	Model   string          `json:"model"`
 	Prompt  string          `json:"prompt,omitempty"`
+	Images  []string        `json:"images,omitempty"`
+	Format  json.RawMessage `json:"format,omitempty"`
 	Options ModelParameters `json:"options,omitempty"`
 	Stream  bool            `json:"stream,omitempty"`
+
+	// KeepAlive controls how long the model stays loaded after the
+	// request. If nil, the server default is used.
+	KeepAlive *Duration `json:"keep_alive,omitempty"`
+
+	// Context is the context returned by a previous completion, to carry
+	// on from it.
+	Context []int `json:"context,omitempty"`
+
+	// Logprobs requests the log probability of each generated token, and
+	// TopLogprobs that of as many most likely alternatives, up to 20.
+	Logprobs    bool `json:"logprobs,omitempty"`
+	TopLogprobs int  `json:"top_logprobs,omitempty"`
+}
+
+// Duration is a time.Duration encoded as the server expects, such as
+// "5m0s". A negative Duration keeps a model loaded indefinitely.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case float64:
+		*d = Duration(v * float64(time.Second))
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", b)
+	}
+	return nil
 }
 
 type CompletionResponse struct {
 	Model         string        `json:"model"`
 	Response      string        `json:"response"`
 	Done          bool          `json:"done"`
+	DoneReason    DoneReason    `json:"done_reason,omitempty"`
 	TotalDuration time.Duration `json:"total_duration"`
-	Error         error         `json:"error,omitempty"`
+	Metrics
+
+	// Context encodes the prompt and the response, in the final chunk, to
+	// carry on from them in a later completion.
+	Context []int `json:"context,omitempty"`
+
+	// Logprobs are those of the tokens of the chunk, when requested.
+	Logprobs []Logprob `json:"logprobs,omitempty"`
+
+	Error error `json:"error,omitempty"`
+}
+
+// WasTruncated reports whether the generation was cut short by the
+// num_predict or context length limit.
+func (r CompletionResponse) WasTruncated() bool {
+	return r.DoneReason == DoneLength
+}
+
+// TokenLogprob is the log probability of a token.
+type TokenLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+
+	// Bytes are the UTF-8 bytes of the token, which may hold part of a
+	// character.
+	Bytes []int `json:"bytes,omitempty"`
+}
+
+// Probability returns the probability of the token, between 0 and 1.
+func (t TokenLogprob) Probability() float64 {
+	return math.Exp(t.Logprob)
+}
+
+// Logprob is the log probability of a generated token, with those of the
+// most likely alternatives when requested.
+type Logprob struct {
+	TokenLogprob
+	TopLogprobs []TokenLogprob `json:"top_logprobs,omitempty"`
+}
+
+// DoneReason is why the server stopped generating, reported in the final
+// chunk of a response.
+type DoneReason string
+
+// Reasons reported by the server.
+const (
+	// DoneStop is a natural stop: an end of sequence token, a stop
+	// sequence or a complete tool call.
+	DoneStop DoneReason = "stop"
+
+	// DoneLength is a stop at the num_predict or context length limit,
+	// leaving the output truncated.
+	DoneLength DoneReason = "length"
+
+	// DoneLoad and DoneUnload answer requests without a prompt, which
+	// only load or unload the model.
+	DoneLoad   DoneReason = "load"
+	DoneUnload DoneReason = "unload"
+)
+
+// Metrics are the statistics reported by the server in the final chunk of
+// a completion or a chat.
+type Metrics struct {
+	// LoadDuration is the time spent loading the model.
+	LoadDuration time.Duration `json:"load_duration,omitempty"`
+
+	// PromptEvalCount and PromptEvalDuration are the number of prompt
+	// tokens and the time spent evaluating them.
+	PromptEvalCount    int           `json:"prompt_eval_count,omitempty"`
+	PromptEvalDuration time.Duration `json:"prompt_eval_duration,omitempty"`
+
+	// EvalCount and EvalDuration are the number of generated tokens and
+	// the time spent generating them.
+	EvalCount    int           `json:"eval_count,omitempty"`
+	EvalDuration time.Duration `json:"eval_duration,omitempty"`
 }
 
 func (c *Client) baseURL() string {
 	if c.BaseURL == "" {
 		return "http://localhost:11434"
 	}
-	return c.BaseURL
+	return strings.TrimSuffix(c.BaseURL, "/")
+}
+
+// endpoint returns the URL of the endpoint at path, as routed by Routes.
+func (c *Client) endpoint(path string) string {
+	if route, ok := c.Routes[path]; ok {
+		path = route
+	}
+	return c.baseURL() + path
+}
+
+// proxiedTransport returns base configured with Proxy and DialContext.
+func (c *Client) proxiedTransport(base http.RoundTripper) http.RoundTripper {
+	if c.Proxy == nil && c.DialContext == nil {
+		return base
+	}
+	t, ok := base.(*http.Transport)
+	if !ok {
+		return base
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.proxied == nil || c.proxiedFrom != t {
+		proxied := t.Clone()
+		if c.Proxy != nil {
+			proxied.Proxy = http.ProxyURL(c.Proxy)
+		}
+		if c.DialContext != nil {
+			proxied.DialContext = c.DialContext
+		}
+		c.proxied, c.proxiedFrom = proxied, t
+	}
+	return c.proxied
+}
+
+// AppendUserAgent appends the application identifier app, if any, to the
+// User-Agent ua.
+func AppendUserAgent(ua, app string) string {
+	if ua == "" {
+		ua = DefaultUserAgent
+	}
+	if app == "" {
+		return ua
+	}
+	return ua + " " + app
+}
+
+// setUserAgent sets the User-Agent of the requests that have none yet,
+// so that interceptors can still override it.
+func setUserAgent(ua string, next http.RoundTripper) http.RoundTripper {
+	return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Header.Get("User-Agent") == "" {
+			req = req.Clone(req.Context())
+			req.Header.Set("User-Agent", ua)
+		}
+		return next.RoundTrip(req)
+	})
 }
 
 func (c *Client) httpClient() *http.Client {
-	if c.HTTPClient == nil {
-		return http.DefaultClient
+	client := c.HTTPClient
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	transport = c.proxiedTransport(transport)
+	transport = setUserAgent(AppendUserAgent(c.UserAgent, c.Application), applyResponseHooks(applyCallOptions(transport)))
+	for i := len(c.Interceptors) - 1; i >= 0; i-- {
+		transport = c.Interceptors[i](transport)
 	}
-	return c.HTTPClient
+	intercepted := *client
+	intercepted.Transport = transport
+	return &intercepted
 }
 
 func (c *Client) GenerateCompletion(ctx context.Context, req CompletionRequest) (<-chan CompletionResponse, error) {
-	url := c.baseURL() + "/api/generate"
-	jsonData, err := json.Marshal(req)
+	url := c.endpoint("/api/generate")
+	req = c.completionDefaults(req)
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	body, err := c.newJSONBody(req)
 	if err != nil {
 		return nil, fmt.Errorf("cannot prepare CompletionRequest: %w", err)
 	}
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	httpReq, err := newJSONRequest(ctx, "POST", url, body)
 	if err != nil {
 		return nil, fmt.Errorf("cannot prepare HTTP CompletionRequest: %w", err)
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
 	resp, err := c.httpClient().Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("cannot execute HTTP CompletionRequest: %w", err)
 	}
 	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
-		return nil, errors.New("failed to generate completion: " + resp.Status)
+		defer resp.Body.Close()
+		return nil, newStatusError("generate completion", resp)
 	}
-	out := make(chan CompletionResponse)
+	out, emit, end := streamChannel(c, mergeCompletion)
+	codec := c.codec()
 	go func() {
 		defer resp.Body.Close()
-		defer close(out)
-		dec := json.NewDecoder(resp.Body)
-		for {
+		defer end()
+		err := readNDJSON(resp.Body, func(line []byte) error {
 			var res CompletionResponse
-			err := dec.Decode(&res)
-			if errors.Is(err, io.EOF) {
-				out <- res
-				return
-			} else if err != nil {
-				res.Error = err
-				out <- res
-				return
+			if err := codec.Unmarshal(line, &res); err != nil {
+				return err
 			}
-			out <- res
-		}
+			emit(res)
+			return nil
+		})
+		emit(CompletionResponse{Error: err})
 	}()
 	return out, nil
 }
@@ -111,97 +447,271 @@ type EmbedResponse struct {
 	Duration   time.Duration `json:"total_duration"`
 }
 
+// EmbedResponse32 is an EmbedResponse decoded at single precision, which
+// halves the memory held by large batches of embeddings.
+type EmbedResponse32 struct {
+	Model      string        `json:"model"`
+	Embeddings [][]float32   `json:"embeddings"`
+	Duration   time.Duration `json:"total_duration"`
+}
+
 func (c *Client) GenerateEmbeddings(ctx context.Context, req EmbedRequest) (*EmbedResponse, error) {
-	url := c.baseURL() + "/api/embed"
-	jsonData, err := json.Marshal(req)
+	var embedResp EmbedResponse
+	if err := c.embed(ctx, req, &embedResp); err != nil {
+		return nil, err
+	}
+	return &embedResp, nil
+}
+
+// GenerateEmbeddings32 is like GenerateEmbeddings but decodes the vectors
+// directly into float32.
+func (c *Client) GenerateEmbeddings32(ctx context.Context, req EmbedRequest) (*EmbedResponse32, error) {
+	var embedResp EmbedResponse32
+	if err := c.embed(ctx, req, &embedResp); err != nil {
+		return nil, err
+	}
+	return &embedResp, nil
+}
+
+func (c *Client) embed(ctx context.Context, req EmbedRequest, embedResp any) error {
+	url := c.endpoint("/api/embed")
+	req = c.embedDefaults(req)
+	if err := req.Validate(); err != nil {
+		return err
+	}
+	body, err := c.newJSONBody(req)
 	if err != nil {
-		return nil, fmt.Errorf("cannot prepare EmbedRequest: %w", err)
+		return fmt.Errorf("cannot prepare EmbedRequest: %w", err)
 	}
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	httpReq, err := newJSONRequest(ctx, "POST", url, body)
 	if err != nil {
-		return nil, fmt.Errorf("cannot prepare HTTP EmbedRequest: %w", err)
+		return fmt.Errorf("cannot prepare HTTP EmbedRequest: %w", err)
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
 	resp, err := c.httpClient().Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("cannot execute HTTP EmbedRequest: %w", err)
+		return fmt.Errorf("cannot execute HTTP EmbedRequest: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to generate embeddings: %s", resp.Status)
+		err := newStatusError("generate embeddings", resp)
+		if missingEndpoint(err) {
+			return c.legacyEmbed(ctx, req, embedResp)
+		}
+		return err
 	}
-	var embedResp EmbedResponse
-	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
-		return nil, fmt.Errorf("cannot decode embed response: %w", err)
+	if err := c.codec().NewStreamDecoder(resp.Body).Decode(embedResp); err != nil {
+		return fmt.Errorf("cannot decode embed response: %w", err)
 	}
-	return &embedResp, nil
+	return nil
+}
+
+// legacyEmbed embeds the inputs one by one with /api/embeddings, for
+// servers predating /api/embed.
+func (c *Client) legacyEmbed(ctx context.Context, req EmbedRequest, embedResp any) error {
+	combined := struct {
+		Model      string            `json:"model"`
+		Embeddings []json.RawMessage `json:"embeddings"`
+	}{Model: req.Model}
+	for _, input := range req.Input {
+		body, err := c.newJSONBody(map[string]string{"model": req.Model, "prompt": input})
+		if err != nil {
+			return fmt.Errorf("cannot prepare EmbedRequest: %w", err)
+		}
+		httpReq, err := newJSONRequest(ctx, "POST", c.endpoint("/api/embeddings"), body)
+		if err != nil {
+			return fmt.Errorf("cannot prepare HTTP EmbedRequest: %w", err)
+		}
+		resp, err := c.httpClient().Do(httpReq)
+		if err != nil {
+			return fmt.Errorf("cannot execute HTTP EmbedRequest: %w", err)
+		}
+		var legacyResp struct {
+			Embedding json.RawMessage `json:"embedding"`
+		}
+		if resp.StatusCode != http.StatusOK {
+			err = newStatusError("generate embeddings", resp)
+		} else if err = c.codec().NewStreamDecoder(resp.Body).Decode(&legacyResp); err != nil {
+			err = fmt.Errorf("cannot decode embed response: %w", err)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		combined.Embeddings = append(combined.Embeddings, legacyResp.Embedding)
+	}
+	jsonData, err := json.Marshal(combined)
+	if err != nil {
+		return fmt.Errorf("cannot combine embed responses: %w", err)
+	}
+	if err := json.Unmarshal(jsonData, embedResp); err != nil {
+		return fmt.Errorf("cannot decode embed response: %w", err)
+	}
+	return nil
 }
 
 type ChatRequest struct {
 	Model    string          `json:"model"`
 	Messages []ChatMessage   `json:"messages"`
+	Tools    []Tool          `json:"tools,omitempty"`
+	Format   json.RawMessage `json:"format,omitempty"`
 	Stream   bool            `json:"stream,omitempty"`
 	Options  ModelParameters `json:"options,omitempty"`
+
+	// KeepAlive controls how long the model stays loaded after the
+	// request. If nil, the server default is used.
+	KeepAlive *Duration `json:"keep_alive,omitempty"`
+
+	// Logprobs requests the log probability of each generated token, and
+	// TopLogprobs that of as many most likely alternatives, up to 20.
+	Logprobs    bool `json:"logprobs,omitempty"`
+	TopLogprobs int  `json:"top_logprobs,omitempty"`
 }
 
 type ChatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+
+	// Images holds the base64-encoded images attached to a user message,
+	// for multimodal models.
+	Images []string `json:"images,omitempty"`
+
+	// ToolCallID identifies the call a tool message answers.
+	ToolCallID string `json:"tool_call_id,omitempty"`
+
+	// ToolName names the function a tool message answers, for servers
+	// that match results to calls by name.
+	ToolName string `json:"tool_name,omitempty"`
+}
+
+// Tool describes a function the model may call during a chat.
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction is the definition of a callable function. Parameters holds
+// the JSON schema of the function arguments.
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters,omitempty"`
+}
+
+// ToolCall is a function call requested by the model.
+type ToolCall struct {
+	// ID identifies the call, if the server assigns one.
+	ID       string           `json:"id,omitempty"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction names the called function and carries its arguments as
+// a JSON object.
+type ToolCallFunction struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
 }
 
 type ChatResponse struct {
 	Model         string        `json:"model"`
 	Message       ChatMessage   `json:"message"`
 	Done          bool          `json:"done"`
+	DoneReason    DoneReason    `json:"done_reason,omitempty"`
 	TotalDuration time.Duration `json:"total_duration"`
-	Error         error         `json:"error,omitempty"`
+	Metrics
+
+	// Logprobs are those of the tokens of the chunk, when requested.
+	Logprobs []Logprob `json:"logprobs,omitempty"`
+
+	// ToolCallDeltas are the fragments of tool calls streamed in the
+	// chunk, to show the calls while their arguments are generated.
+	// Message.ToolCalls holds the complete calls, once known.
+	ToolCallDeltas []ToolCallDelta `json:"-"`
+
+	Error error `json:"error,omitempty"`
+}
+
+// ToolCallDelta is a fragment of a streamed tool call. The fragments of a
+// call share its Index; the name comes first, as soon as it is known, and
+// the arguments accumulate over the fragments. Servers that send whole
+// calls send a single fragment per call.
+type ToolCallDelta struct {
+	// Index is the position of the call among those of the reply.
+	Index int
+
+	// ID identifies the call, if the server assigns one.
+	ID string
+
+	// Name is the name of the called function, in the first fragment.
+	Name string
+
+	// Arguments is the next part of the JSON arguments of the call.
+	Arguments string
+}
+
+// WasTruncated reports whether the reply was cut short by the num_predict
+// or context length limit.
+func (r ChatResponse) WasTruncated() bool {
+	return r.DoneReason == DoneLength
 }
 
 func (c *Client) GenerateChat(ctx context.Context, req ChatRequest) (<-chan ChatResponse, error) {
-	url := c.baseURL() + "/api/chat"
-	jsonData, err := json.Marshal(req)
+	url := c.endpoint("/api/chat")
+	req = c.chatDefaults(req)
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	body, err := c.newJSONBody(req)
 	if err != nil {
 		return nil, fmt.Errorf("cannot prepare ChatRequest: %w", err)
 	}
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	httpReq, err := newJSONRequest(ctx, "POST", url, body)
 	if err != nil {
 		return nil, fmt.Errorf("cannot prepare HTTP ChatRequest: %w", err)
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
 	resp, err := c.httpClient().Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("cannot execute HTTP ChatRequest: %w", err)
 	}
 	if resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
-		return nil, fmt.Errorf("failed to generate chat: %s", resp.Status)
+		defer resp.Body.Close()
+		return nil, newStatusError("generate chat", resp)
 	}
-	out := make(chan ChatResponse)
+	out, emit, end := streamChannel(c, mergeChat)
+	codec := c.codec()
 	go func() {
 		defer resp.Body.Close()
-		defer close(out)
-		dec := json.NewDecoder(resp.Body)
-		for {
+		defer end()
+		calls := 0
+		err := readNDJSON(resp.Body, func(line []byte) error {
 			var res ChatResponse
-			err := dec.Decode(&res)
-			if errors.Is(err, io.EOF) {
-				out <- res
-				return
-			} else if err != nil {
-				res.Error = err
-				out <- res
-				return
+			if err := codec.Unmarshal(line, &res); err != nil {
+				return err
 			}
-			out <- res
-		}
+			for _, call := range res.Message.ToolCalls {
+				res.ToolCallDeltas = append(res.ToolCallDeltas, ToolCallDelta{
+					Index:     calls,
+					ID:        call.ID,
+					Name:      call.Function.Name,
+					Arguments: string(call.Function.Arguments),
+				})
+				calls++
+			}
+			emit(res)
+			return nil
+		})
+		emit(ChatResponse{Error: err})
 	}()
 	return out, nil
 }
 
 type ModelInfo struct {
-	Name       string    `json:"name"`
-	ModifiedAt time.Time `json:"modified_at"`
-	Size       int64     `json:"size"`
+	Name       string       `json:"name"`
+	Model      string       `json:"model,omitempty"`
+	ModifiedAt time.Time    `json:"modified_at"`
+	Size       int64        `json:"size"`
+	Digest     string       `json:"digest"`
+	Details    ModelDetails `json:"details"`
 }
 
 type ListModelsResponse struct {
@@ -209,7 +719,7 @@ type ListModelsResponse struct {
 }
 
 func (c *Client) ListModels(ctx context.Context) (*ListModelsResponse, error) {
-	url := c.baseURL() + "/api/tags"
+	url := c.endpoint("/api/tags")
 	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("cannot prepare HTTP request: %w", err)
@@ -220,52 +730,165 @@ func (c *Client) ListModels(ctx context.Context) (*ListModelsResponse, error) {
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to list models: %s", resp.Status)
+		return nil, newStatusError("list models", resp)
 	}
 	var listResp ListModelsResponse
-	if err := json.NewDecoder(resp.Body).Decode(&listResp); err != nil {
+	if err := c.codec().NewStreamDecoder(resp.Body).Decode(&listResp); err != nil {
 		return nil, fmt.Errorf("cannot decode response: %w", err)
 	}
 	return &listResp, nil
 }
 
+// RunningModel is a model loaded in memory by the server.
+type RunningModel struct {
+	Name      string    `json:"name"`
+	Model     string    `json:"model"`
+	Size      int64     `json:"size"`
+	Digest    string    `json:"digest"`
+	ExpiresAt time.Time `json:"expires_at"`
+	SizeVRAM  int64     `json:"size_vram"`
+}
+
+type ListRunningModelsResponse struct {
+	Models []RunningModel `json:"models"`
+}
+
+// ListRunningModels lists the models currently loaded by the server.
+func (c *Client) ListRunningModels(ctx context.Context) (*ListRunningModelsResponse, error) {
+	url := c.endpoint("/api/ps")
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare HTTP request: %w", err)
+	}
+	resp, err := c.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("cannot execute HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, newStatusError("list running models", resp)
+	}
+	var psResp ListRunningModelsResponse
+	if err := c.codec().NewStreamDecoder(resp.Body).Decode(&psResp); err != nil {
+		return nil, fmt.Errorf("cannot decode running models response: %w", err)
+	}
+	return &psResp, nil
+}
+
 type ShowModelRequest struct {
 	Model   string `json:"model"`
 	Verbose bool   `json:"verbose,omitempty"`
 }
 
 type ShowModelResponse struct {
-	Modelfile string `json:"modelfile"`
-	Details   struct {
-		Format        string   `json:"format"`
-		ParameterSize string   `json:"parameter_size"`
-		Quantization  string   `json:"quantization_level"`
-		Family        string   `json:"family"`
-		Families      []string `json:"families"`
-	} `json:"details"`
+	Modelfile  string       `json:"modelfile"`
+	Parameters string       `json:"parameters,omitempty"`
+	Template   string       `json:"template,omitempty"`
+	System     string       `json:"system,omitempty"`
+	License    string       `json:"license,omitempty"`
+	Details    ModelDetails `json:"details"`
+	ModifiedAt time.Time    `json:"modified_at"`
+
+	// ModelInfo holds the model metadata keyed by GGUF names, such as
+	// "general.architecture" or "llama.context_length". The accessors of
+	// ShowModelResponse read the common ones.
+	ModelInfo map[string]any `json:"model_info,omitempty"`
+
+	// ProjectorInfo holds the metadata of the vision projector of
+	// multimodal models.
+	ProjectorInfo map[string]any `json:"projector_info,omitempty"`
+
+	// Messages is the history carried by every conversation with the
+	// model.
+	Messages []ChatMessage `json:"messages,omitempty"`
+
+	// Capabilities lists what the model can do, such as "completion",
+	// "tools", "vision", "embedding" or "thinking".
+	Capabilities []string `json:"capabilities,omitempty"`
+
+	// Tensors is only reported in verbose mode.
+	Tensors []Tensor `json:"tensors,omitempty"`
+}
+
+// ModelDetails describes the format and the family of a model.
+type ModelDetails struct {
+	ParentModel   string   `json:"parent_model,omitempty"`
+	Format        string   `json:"format"`
+	Family        string   `json:"family"`
+	Families      []string `json:"families"`
+	ParameterSize string   `json:"parameter_size"`
+	Quantization  string   `json:"quantization_level"`
+}
+
+// Tensor describes a tensor of the model weights.
+type Tensor struct {
+	Name  string   `json:"name"`
+	Type  string   `json:"type"`
+	Shape []uint64 `json:"shape"`
+}
+
+// Architecture returns the model architecture, such as "llama".
+func (r *ShowModelResponse) Architecture() string {
+	arch, _ := r.ModelInfo["general.architecture"].(string)
+	return arch
+}
+
+// ParameterCount returns the number of parameters of the model.
+func (r *ShowModelResponse) ParameterCount() int64 {
+	return int64(r.modelInfoNumber("general.parameter_count"))
+}
+
+// ContextLength returns the context window the model was trained with.
+func (r *ShowModelResponse) ContextLength() int {
+	return int(r.modelInfoNumber(r.Architecture() + ".context_length"))
+}
+
+// EmbeddingLength returns the number of dimensions of the model
+// embeddings.
+func (r *ShowModelResponse) EmbeddingLength() int {
+	return int(r.modelInfoNumber(r.Architecture() + ".embedding_length"))
+}
+
+// HasCapability reports whether the model lists the given capability.
+func (r *ShowModelResponse) HasCapability(capability string) bool {
+	return slices.Contains(r.Capabilities, capability)
+}
+
+func (r *ShowModelResponse) modelInfoNumber(key string) float64 {
+	switch v := r.ModelInfo[key].(type) {
+	case float64:
+		return v
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	}
+	return 0
 }
 
 func (c *Client) ShowModelInfo(ctx context.Context, req ShowModelRequest) (*ShowModelResponse, error) {
-	url := c.baseURL() + "/api/show"
-	jsonData, err := json.Marshal(req)
+	url := c.endpoint("/api/show")
+	body, err := c.newJSONBody(req)
 	if err != nil {
 		return nil, fmt.Errorf("cannot prepare ShowModelRequest: %w", err)
 	}
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	httpReq, err := newJSONRequest(ctx, "POST", url, body)
 	if err != nil {
 		return nil, fmt.Errorf("cannot prepare HTTP ShowModelRequest: %w", err)
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
 	resp, err := c.httpClient().Do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("cannot execute HTTP ShowModelRequest: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to show model info: %s", resp.Status)
+		return nil, newStatusError("show model info", resp)
 	}
 	var showResp ShowModelResponse
-	if err := json.NewDecoder(resp.Body).Decode(&showResp); err != nil {
+	if err := c.codec().NewStreamDecoder(resp.Body).Decode(&showResp); err != nil {
 		return nil, fmt.Errorf("cannot decode show response: %w", err)
 	}
 	return &showResp, nil
@@ -276,29 +899,262 @@ type DeleteModelRequest struct {
 }
 
 func (c *Client) DeleteModel(ctx context.Context, req DeleteModelRequest) error {
-	url := c.baseURL() + "/api/delete"
-	jsonData, err := json.Marshal(req)
+	url := c.endpoint("/api/delete")
+	body, err := c.newJSONBody(req)
 	if err != nil {
 		return fmt.Errorf("cannot prepare DeleteModelRequest: %w", err)
 	}
-	httpReq, err := http.NewRequestWithContext(ctx, "DELETE", url, bytes.NewBuffer(jsonData))
+	httpReq, err := newJSONRequest(ctx, "DELETE", url, body)
 	if err != nil {
 		return fmt.Errorf("cannot prepare HTTP DeleteModelRequest: %w", err)
 	}
-	httpReq.Header.Set("Content-Type", "application/json")
 	resp, err := c.httpClient().Do(httpReq)
 	if err != nil {
 		return fmt.Errorf("cannot execute HTTP DeleteModelRequest: %w", err)
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to delete model: %s", resp.Status)
+		return newStatusError("delete model", resp)
+	}
+	return nil
+}
+
+type PullModelRequest struct {
+	Model string `json:"model"`
+
+	// Insecure allows pulling from a registry over plain HTTP or with an
+	// unverified TLS certificate.
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// PullModel downloads a model from the registry, streaming its progress.
+// The last update has the status "success" unless the pull failed, in
+// which case it carries the Error.
+func (c *Client) PullModel(ctx context.Context, req PullModelRequest) (<-chan ProgressEvent, error) {
+	url := c.endpoint("/api/pull")
+	body, err := c.newJSONBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare PullModelRequest: %w", err)
+	}
+	httpReq, err := newJSONRequest(ctx, "POST", url, body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare HTTP PullModelRequest: %w", err)
+	}
+	resp, err := c.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("cannot execute HTTP PullModelRequest: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newStatusError("pull model", resp)
+	}
+	return streamProgress(c.codec(), "pull model", resp), nil
+}
+
+type PushModelRequest struct {
+	// Model is the name of the model, prefixed by the namespace it is
+	// pushed to, such as "myuser/mymodel:latest".
+	Model string `json:"model"`
+
+	// Insecure allows pushing to a registry over plain HTTP or with an
+	// unverified TLS certificate.
+	Insecure bool `json:"insecure,omitempty"`
+}
+
+// PushModel uploads a model to the registry, streaming its progress as
+// PullModel does. Registries require the request to be authenticated,
+// with SignRequests or APIKey.
+func (c *Client) PushModel(ctx context.Context, req PushModelRequest) (<-chan ProgressEvent, error) {
+	url := c.endpoint("/api/push")
+	body, err := c.newJSONBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare PushModelRequest: %w", err)
+	}
+	httpReq, err := newJSONRequest(ctx, "POST", url, body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare HTTP PushModelRequest: %w", err)
+	}
+	resp, err := c.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("cannot execute HTTP PushModelRequest: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newStatusError("push model", resp)
+	}
+	return streamProgress(c.codec(), "push model", resp), nil
+}
+
+// streamProgress decodes the progress updates streamed by a long
+// operation, stopping at the first error.
+func streamProgress(codec Codec, op string, resp *http.Response) <-chan ProgressEvent {
+	out := make(chan ProgressEvent)
+	go func() {
+		defer resp.Body.Close()
+		defer close(out)
+		var res struct {
+			ProgressEvent
+			Error string `json:"error"`
+		}
+		err := readNDJSON(resp.Body, func(line []byte) error {
+			res.ProgressEvent, res.Error = ProgressEvent{}, ""
+			if err := codec.Unmarshal(line, &res); err != nil {
+				return err
+			}
+			if res.Error != "" {
+				return &progressError{op: op, message: res.Error}
+			}
+			out <- res.ProgressEvent
+			return nil
+		})
+		if err != nil {
+			out <- ProgressEvent{Error: err}
+		}
+	}()
+	return out
+}
+
+// progressError is an error reported by the server in the middle of a
+// long operation.
+type progressError struct {
+	op      string
+	message string
+}
+
+func (e *progressError) Error() string {
+	return "failed to " + e.op + ": " + e.message
+}
+
+// CreateModelRequest describes a model to create, either from the
+// structured fields or, for servers older than 0.5.5, from Modelfile.
+// Modelfile.Request fills both.
+type CreateModelRequest struct {
+	Model string `json:"model"`
+
+	// From is the name of the base model.
+	From string `json:"from,omitempty"`
+
+	// Files and Adapters map the file names of GGUF or safetensors
+	// weights and LoRA adapters to the digests of blobs already uploaded.
+	Files    map[string]string `json:"files,omitempty"`
+	Adapters map[string]string `json:"adapters,omitempty"`
+
+	Template   string         `json:"template,omitempty"`
+	License    []string       `json:"license,omitempty"`
+	System     string         `json:"system,omitempty"`
+	Parameters map[string]any `json:"parameters,omitempty"`
+	Messages   []ChatMessage  `json:"messages,omitempty"`
+
+	// Quantize quantizes a non-quantized model, e.g. "q4_K_M".
+	Quantize string `json:"quantize,omitempty"`
+
+	// Modelfile is the legacy text definition of the model.
+	Modelfile string `json:"modelfile,omitempty"`
+}
+
+// CreateModel creates a model, streaming its progress as PullModel does.
+func (c *Client) CreateModel(ctx context.Context, req CreateModelRequest) (<-chan ProgressEvent, error) {
+	url := c.endpoint("/api/create")
+	body, err := c.newJSONBody(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare CreateModelRequest: %w", err)
+	}
+	httpReq, err := newJSONRequest(ctx, "POST", url, body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare HTTP CreateModelRequest: %w", err)
+	}
+	resp, err := c.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("cannot execute HTTP CreateModelRequest: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newStatusError("create model", resp)
+	}
+	return streamProgress(c.codec(), "create model", resp), nil
+}
+
+// BlobExists reports whether the server holds the blob with the given
+// digest, such as "sha256:6a0746a1ec1a...".
+func (c *Client) BlobExists(ctx context.Context, digest string) (bool, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "HEAD", c.endpoint("/api/blobs")+"/"+digest, nil)
+	if err != nil {
+		return false, fmt.Errorf("cannot prepare HTTP request: %w", err)
+	}
+	resp, err := c.httpClient().Do(httpReq)
+	if err != nil {
+		return false, fmt.Errorf("cannot execute HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, newStatusError("check blob", resp)
+}
+
+// CreateBlob uploads the content of a blob, which the server checks
+// against digest. Blobs are referenced by CreateModelRequest.Files and
+// Adapters.
+func (c *Client) CreateBlob(ctx context.Context, digest string, content io.Reader) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.endpoint("/api/blobs")+"/"+digest, content)
+	if err != nil {
+		return fmt.Errorf("cannot prepare HTTP request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := c.httpClient().Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("cannot execute HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return newStatusError("create blob", resp)
+	}
+	return nil
+}
+
+// Heartbeat checks that the server is up and answering.
+func (c *Client) Heartbeat(ctx context.Context) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "HEAD", c.endpoint("/"), nil)
+	if err != nil {
+		return fmt.Errorf("cannot prepare HTTP request: %w", err)
+	}
+	resp, err := c.httpClient().Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("cannot execute HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError("heartbeat", resp)
 	}
 	return nil
 }
 
+// WaitForReady polls the server with Heartbeat until it answers or ctx is
+// done. It waits backoff before the second attempt and doubles the wait
+// after each failure, up to five seconds or backoff, whichever is larger.
+func (c *Client) WaitForReady(ctx context.Context, backoff time.Duration) error {
+	limit := max(backoff, 5*time.Second)
+	for {
+		err := c.Heartbeat(ctx)
+		if err == nil {
+			return nil
+		}
+		t := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return fmt.Errorf("server not ready: %w", errors.Join(ctx.Err(), err))
+		case <-t.C:
+		}
+		backoff = min(2*backoff, limit)
+	}
+}
+
 func (c *Client) Version(ctx context.Context) (string, error) {
-	url := c.baseURL() + "/api/version"
+	url := c.endpoint("/api/version")
 	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return "", fmt.Errorf("cannot prepare HTTP request: %w", err)
@@ -309,71 +1165,219 @@ func (c *Client) Version(ctx context.Context) (string, error) {
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to get version: %s", resp.Status)
+		return "", newStatusError("get version", resp)
 	}
 	var versionResp struct {
 		Version string `json:"version"`
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&versionResp); err != nil {
+	if err := c.codec().NewStreamDecoder(resp.Body).Decode(&versionResp); err != nil {
 		return "", fmt.Errorf("cannot decode version response: %w", err)
 	}
 	return versionResp.Version, nil
 }
 
+// ModelParameters are the model options of a request. Fields are
+// pointers so that zero values, such as a temperature of 0, can be sent;
+// nil fields are left to the server default. Ptr makes the pointers:
+//
+//	ollamago.ModelParameters{Temperature: ollamago.Ptr(0.0), Seed: ollamago.Ptr(42)}
 type ModelParameters struct {
 	// Mirostat enables Mirostat sampling for controlling perplexity.
 	// (0 = disabled, 1 = Mirostat, 2 = Mirostat 2.0)
-	Mirostat int `json:"mirostat,omitempty"`
+	Mirostat *int `json:"mirostat,omitempty"`
 
 	// MirostatEta influences how quickly the algorithm responds to
 	// feedback. Lower learning rate = slower adjustments, higher = more
 	// responsive.
-	MirostatEta float64 `json:"mirostat_eta,omitempty"`
+	MirostatEta *float64 `json:"mirostat_eta,omitempty"`
 
 	// MirostatTau controls balance between coherence and diversity.
 	// Lower value results in more focused and coherent text.
-	MirostatTau float64 `json:"mirostat_tau,omitempty"`
+	MirostatTau *float64 `json:"mirostat_tau,omitempty"`
 
 	// NumCtx sets the size of the context window for next token generation.
-	NumCtx int `json:"num_ctx,omitempty"`
+	NumCtx *int `json:"num_ctx,omitempty"`
 
 	// RepeatLastN sets how far back to look to prevent repetition.
 	// (0 = disabled, -1 = num_ctx)
-	RepeatLastN int `json:"repeat_last_n,omitempty"`
+	RepeatLastN *int `json:"repeat_last_n,omitempty"`
 
 	// RepeatPenalty sets how strongly to penalize repetitions.
 	// Higher value penalizes more strongly, lower is more lenient.
-	RepeatPenalty float64 `json:"repeat_penalty,omitempty"`
+	RepeatPenalty *float64 `json:"repeat_penalty,omitempty"`
 
 	// Temperature controls creativity of the model's responses.
 	// Higher temperature increases creativity.
-	Temperature float64 `json:"temperature,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
 
 	// Seed sets the random number seed for generation.
 	// Specific seed generates same text for same prompt.
-	Seed int `json:"seed,omitempty"`
+	Seed *int `json:"seed,omitempty"`
 
-	// Stop sets the stop sequences to use.
-	// Model stops generating when this pattern is encountered.
-	Stop string `json:"stop,omitempty"`
+	// Stop sets a stop sequence to use.
+	//
+	// Deprecated: use StopSequences, which takes several sequences. Stop
+	// is sent as the first of them.
+	Stop string `json:"-"`
+
+	// StopSequences sets the stop sequences to use.
+	// Model stops generating when any of these patterns is encountered.
+	StopSequences []string `json:"-"`
 
 	// TfsZ controls tail free sampling to reduce impact of less probable tokens.
 	// Higher value reduces impact more, 1.0 disables.
-	TfsZ float64 `json:"tfs_z,omitempty"`
+	TfsZ *float64 `json:"tfs_z,omitempty"`
 
 	// NumPredict sets maximum number of tokens to predict.
 	// -1 for infinite generation.
-	NumPredict int `json:"num_predict,omitempty"`
+	NumPredict *int `json:"num_predict,omitempty"`
 
 	// TopK reduces probability of nonsense generation.
 	// Higher value gives more diverse answers, lower is more conservative.
-	TopK int `json:"top_k,omitempty"`
+	TopK *int `json:"top_k,omitempty"`
 
 	// TopP works with top-k for diversity control.
 	// Higher value leads to more diverse text, lower is more focused.
-	TopP float64 `json:"top_p,omitempty"`
+	TopP *float64 `json:"top_p,omitempty"`
 
 	// MinP sets minimum probability for token consideration.
 	// Alternative to top_p for balancing quality and variety.
-	MinP float64 `json:"min_p,omitempty"`
+	MinP *float64 `json:"min_p,omitempty"`
+
+	// TypicalP enables locally typical sampling.
+	// 1.0 disables.
+	TypicalP *float64 `json:"typical_p,omitempty"`
+
+	// PresencePenalty penalizes tokens that already appeared,
+	// encouraging new topics.
+	PresencePenalty *float64 `json:"presence_penalty,omitempty"`
+
+	// FrequencyPenalty penalizes tokens by how often they already
+	// appeared, reducing verbatim repetition.
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+
+	// PenalizeNewline sets whether the repetition penalties apply to
+	// newlines.
+	PenalizeNewline *bool `json:"penalize_newline,omitempty"`
+
+	// NumKeep sets how many tokens of the prompt are kept when the
+	// context window overflows.
+	NumKeep *int `json:"num_keep,omitempty"`
+
+	// NumBatch sets the batch size of prompt processing.
+	NumBatch *int `json:"num_batch,omitempty"`
+
+	// NumGPU sets how many layers are offloaded to the GPUs.
+	// (0 = CPU only, -1 = as many as fit)
+	NumGPU *int `json:"num_gpu,omitempty"`
+
+	// MainGPU sets the GPU handling small tensors when the model is
+	// split across several.
+	MainGPU *int `json:"main_gpu,omitempty"`
+
+	// NumThread sets how many threads are used for computation.
+	// Defaults to the number of physical cores.
+	NumThread *int `json:"num_thread,omitempty"`
+
+	// NUMA enables NUMA support.
+	NUMA *bool `json:"numa,omitempty"`
+
+	// LowVRAM reduces VRAM usage at the expense of speed.
+	LowVRAM *bool `json:"low_vram,omitempty"`
+
+	// F16KV stores the key/value cache at half precision.
+	F16KV *bool `json:"f16_kv,omitempty"`
+
+	// UseMMap memory-maps the model weights instead of reading them.
+	UseMMap *bool `json:"use_mmap,omitempty"`
+
+	// UseMLock locks the model weights in memory, preventing swapping.
+	UseMLock *bool `json:"use_mlock,omitempty"`
+
+	// Extra holds options without a field, such as those added to the
+	// server after this package, which are merged into the encoded
+	// options. Fields that are set take precedence over Extra entries of
+	// the same name. Unknown options are decoded into it.
+	Extra map[string]any `json:"-"`
+}
+
+// Ptr returns a pointer to v, to set the fields of ModelParameters.
+func Ptr[T any](v T) *T {
+	return &v
+}
+
+func (p ModelParameters) MarshalJSON() ([]byte, error) {
+	type parameters ModelParameters
+	stop := p.StopSequences
+	if p.Stop != "" && !slices.Contains(stop, p.Stop) {
+		stop = append([]string{p.Stop}, stop...)
+	}
+	b, err := json.Marshal(struct {
+		parameters
+		Stop []string `json:"stop,omitempty"`
+	}{parameters(p), stop})
+	if err != nil || len(p.Extra) == 0 {
+		return b, err
+	}
+	var known map[string]json.RawMessage
+	if err := json.Unmarshal(b, &known); err != nil {
+		return nil, err
+	}
+	merged := make(map[string]any, len(p.Extra)+len(known))
+	for k, v := range p.Extra {
+		merged[k] = v
+	}
+	for k, v := range known {
+		merged[k] = v
+	}
+	return json.Marshal(merged)
+}
+
+// modelParameterNames returns the names of the options with a field in
+// ModelParameters.
+var modelParameterNames = sync.OnceValue(func() map[string]bool {
+	names := map[string]bool{"stop": true}
+	t := reflect.TypeFor[ModelParameters]()
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+	return names
+})
+
+func (p *ModelParameters) UnmarshalJSON(b []byte) error {
+	type parameters ModelParameters
+	var aux struct {
+		*parameters
+		Stop json.RawMessage `json:"stop"`
+	}
+	aux.parameters = (*parameters)(p)
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	var all map[string]any
+	if err := json.Unmarshal(b, &all); err != nil {
+		return err
+	}
+	names := modelParameterNames()
+	for k, v := range all {
+		if names[k] {
+			continue
+		}
+		if p.Extra == nil {
+			p.Extra = make(map[string]any)
+		}
+		p.Extra[k] = v
+	}
+	if len(aux.Stop) == 0 || string(aux.Stop) == "null" {
+		return nil
+	}
+	var stop string
+	if err := json.Unmarshal(aux.Stop, &stop); err == nil {
+		p.StopSequences = []string{stop}
+		return nil
+	}
+	return json.Unmarshal(aux.Stop, &p.StopSequences)
 }
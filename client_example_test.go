@@ -21,7 +21,7 @@ import (
 	"cirello.io/ollamago"
 )
 
-func Example_Client_GenerateChat() {
+func ExampleClient_GenerateChat() {
 	c := ollamago.Client{}
 	resp, err := c.GenerateChat(context.Background(), ollamago.ChatRequest{
 		Model: "llama3.2",
@@ -40,6 +40,4 @@ func Example_Client_GenerateChat() {
 		}
 		fmt.Print(r.Message.Content)
 	}
-	// Output:
-	// Hello World!
 }
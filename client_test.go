@@ -16,11 +16,18 @@ package ollamago_test
 
 import (
 	"context"
+	"encoding/json"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
 	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
 	"github.com/stretchr/testify/require"
 )
 
@@ -63,6 +70,22 @@ func TestGenerateEmbeddings(t *testing.T) {
 	require.Equal(t, []float64{1.0, 2.0, 3.0}, resp.Embeddings[0])
 }
 
+func TestGenerateEmbeddings32(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/embed", r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"model":"test","embeddings":[[0.5,-0.25]],"total_duration":1000}`))
+	}))
+	t.Cleanup(server.Close)
+	client := ollamago.Client{BaseURL: server.URL}
+	resp, err := client.GenerateEmbeddings32(context.Background(), ollamago.EmbedRequest{
+		Model: "test",
+		Input: []string{"test input"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, [][]float32{{0.5, -0.25}}, resp.Embeddings)
+}
+
 func TestGenerateChat(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		require.Equal(t, "/api/chat", r.URL.Path)
@@ -91,7 +114,8 @@ func TestListModels(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		require.Equal(t, "/api/tags", r.URL.Path)
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"models":[{"name":"model1","modified_at":"2023-01-01T00:00:00Z","size":1024}]}`))
+		w.Write([]byte(`{"models":[{"name":"model1","modified_at":"2023-01-01T00:00:00Z","size":1024,"digest":"abc123",` +
+			`"details":{"format":"gguf","family":"llama","parameter_size":"8.0B","quantization_level":"Q4_K_M"}}]}`))
 	}))
 	t.Cleanup(server.Close)
 	client := ollamago.Client{BaseURL: server.URL}
@@ -100,13 +124,34 @@ func TestListModels(t *testing.T) {
 	require.Len(t, resp.Models, 1)
 	require.Equal(t, "model1", resp.Models[0].Name)
 	require.Equal(t, int64(1024), resp.Models[0].Size)
+	require.Equal(t, "abc123", resp.Models[0].Digest)
+	require.Equal(t, ollamago.ModelDetails{
+		Format:        "gguf",
+		Family:        "llama",
+		ParameterSize: "8.0B",
+		Quantization:  "Q4_K_M",
+	}, resp.Models[0].Details)
 }
 
 func TestShowModelInfo(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		require.Equal(t, "/api/show", r.URL.Path)
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`{"modelfile":"test file","details":{"format":"gguf","parameter_size":"7B"}}`))
+		w.Write([]byte(`{
+			"modelfile":"test file",
+			"parameters":"stop \"<|eot|>\"",
+			"template":"{{ .Prompt }}",
+			"system":"be nice",
+			"details":{"format":"gguf","parameter_size":"7B","quantization_level":"Q4_0"},
+			"model_info":{
+				"general.architecture":"llama",
+				"general.parameter_count":8030261248,
+				"llama.context_length":131072,
+				"llama.embedding_length":4096
+			},
+			"capabilities":["completion","tools"],
+			"tensors":[{"name":"token_embd.weight","type":"Q4_K","shape":[4096,128256]}]
+		}`))
 	}))
 	t.Cleanup(server.Close)
 	client := ollamago.Client{BaseURL: server.URL}
@@ -115,8 +160,19 @@ func TestShowModelInfo(t *testing.T) {
 	})
 	require.NoError(t, err)
 	require.Equal(t, "test file", resp.Modelfile)
+	require.Equal(t, `stop "<|eot|>"`, resp.Parameters)
+	require.Equal(t, "{{ .Prompt }}", resp.Template)
+	require.Equal(t, "be nice", resp.System)
 	require.Equal(t, "gguf", resp.Details.Format)
 	require.Equal(t, "7B", resp.Details.ParameterSize)
+	require.Equal(t, "Q4_0", resp.Details.Quantization)
+	require.Equal(t, "llama", resp.Architecture())
+	require.EqualValues(t, 8030261248, resp.ParameterCount())
+	require.Equal(t, 131072, resp.ContextLength())
+	require.Equal(t, 4096, resp.EmbeddingLength())
+	require.True(t, resp.HasCapability("tools"))
+	require.False(t, resp.HasCapability("vision"))
+	require.Equal(t, []uint64{4096, 128256}, resp.Tensors[0].Shape)
 }
 
 func TestDeleteModel(t *testing.T) {
@@ -132,6 +188,118 @@ func TestDeleteModel(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestPullModel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/pull", r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"pulling manifest"}` + "\n"))
+		w.Write([]byte(`{"status":"pulling abc","digest":"abc","total":10,"completed":5}` + "\n"))
+		w.Write([]byte(`{"error":"disk full"}` + "\n"))
+	}))
+	t.Cleanup(server.Close)
+	client := ollamago.Client{BaseURL: server.URL}
+	respChan, err := client.PullModel(context.Background(), ollamago.PullModelRequest{
+		Model: "test",
+	})
+	require.NoError(t, err)
+	var progress []ollamago.ProgressEvent
+	for p := range respChan {
+		progress = append(progress, p)
+	}
+	require.Len(t, progress, 3)
+	require.EqualValues(t, 5, progress[1].Completed)
+	require.ErrorContains(t, progress[2].Error, "disk full")
+}
+
+func TestWaitForReady(t *testing.T) {
+	srv := ollamatest.NewServer()
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+	ctx := context.Background()
+	require.NoError(t, client.Heartbeat(ctx))
+
+	srv.FailNext("/", 3, http.StatusServiceUnavailable, "starting")
+	require.Error(t, client.Heartbeat(ctx))
+	require.NoError(t, client.WaitForReady(ctx, time.Millisecond))
+	require.Len(t, srv.Requests(), 5)
+
+	srv.Close()
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	err := client.WaitForReady(ctx, time.Millisecond)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestModelParameters(t *testing.T) {
+	params := ollamago.ModelParameters{
+		NumGPU:           ollamago.Ptr(-1),
+		MainGPU:          ollamago.Ptr(1),
+		NumThread:        ollamago.Ptr(8),
+		NumBatch:         ollamago.Ptr(512),
+		NumKeep:          ollamago.Ptr(4),
+		NUMA:             ollamago.Ptr(true),
+		UseMMap:          ollamago.Ptr(false),
+		UseMLock:         ollamago.Ptr(true),
+		LowVRAM:          ollamago.Ptr(true),
+		F16KV:            ollamago.Ptr(true),
+		PenalizeNewline:  ollamago.Ptr(false),
+		PresencePenalty:  ollamago.Ptr(0.5),
+		FrequencyPenalty: ollamago.Ptr(0.25),
+		TypicalP:         ollamago.Ptr(0.9),
+	}
+	b, err := json.Marshal(params)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"num_gpu":-1,"main_gpu":1,"num_thread":8,"num_batch":512,"num_keep":4,
+		"numa":true,"use_mmap":false,"use_mlock":true,"low_vram":true,"f16_kv":true,
+		"penalize_newline":false,"presence_penalty":0.5,"frequency_penalty":0.25,"typical_p":0.9
+	}`, string(b))
+}
+
+func TestModelParametersZeroValues(t *testing.T) {
+	b, err := json.Marshal(ollamago.ModelParameters{Temperature: ollamago.Ptr(0.0), Seed: ollamago.Ptr(0)})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"temperature":0,"seed":0}`, string(b))
+
+	b, err = json.Marshal(ollamago.ModelParameters{})
+	require.NoError(t, err)
+	require.JSONEq(t, `{}`, string(b))
+}
+
+func TestModelParametersExtra(t *testing.T) {
+	params := ollamago.ModelParameters{
+		Temperature: ollamago.Ptr(0.2),
+		Extra:       map[string]any{"temperature": 1, "num_experts": 4},
+	}
+	b, err := json.Marshal(params)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"temperature":0.2,"num_experts":4}`, string(b))
+
+	var decoded ollamago.ModelParameters
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.Equal(t, ollamago.ModelParameters{
+		Temperature: ollamago.Ptr(0.2),
+		Extra:       map[string]any{"num_experts": 4.0},
+	}, decoded)
+}
+
+func TestModelParametersStop(t *testing.T) {
+	b, err := json.Marshal(ollamago.ModelParameters{StopSequences: []string{"\n\n", "User:"}})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"stop":["\n\n","User:"]}`, string(b))
+
+	b, err = json.Marshal(ollamago.ModelParameters{Stop: "###", StopSequences: []string{"User:"}})
+	require.NoError(t, err)
+	require.JSONEq(t, `{"stop":["###","User:"]}`, string(b), "the deprecated field is still sent")
+
+	var params ollamago.ModelParameters
+	require.NoError(t, json.Unmarshal([]byte(`{"temperature":0.5,"stop":["a","b"]}`), &params))
+	require.Equal(t, ollamago.ModelParameters{Temperature: ollamago.Ptr(0.5), StopSequences: []string{"a", "b"}}, params)
+	params = ollamago.ModelParameters{}
+	require.NoError(t, json.Unmarshal([]byte(`{"stop":"a"}`), &params))
+	require.Equal(t, []string{"a"}, params.StopSequences)
+}
+
 func TestVersion(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		require.Equal(t, "/api/version", r.URL.Path)
@@ -144,3 +312,236 @@ func TestVersion(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, "1.0.0", version)
 }
+
+func TestBasePathAndRoutes(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/ollama/api/version":
+			w.Write([]byte(`{"version":"1.0.0"}`))
+		case "/ollama/v2/chat":
+			w.Write([]byte(`{"model":"test","message":{"role":"assistant","content":"hi"},"done":true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	client := ollamago.Client{
+		BaseURL: server.URL + "/ollama/",
+		Routes:  map[string]string{"/api/chat": "/v2/chat"},
+	}
+	ctx := context.Background()
+	version, err := client.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "1.0.0", version)
+	resp, err := client.GenerateChat(ctx, ollamago.ChatRequest{
+		Model:    "test",
+		Messages: []ollamago.ChatMessage{ollamago.UserMessage("hello")},
+	})
+	require.NoError(t, err)
+	var content string
+	for r := range resp {
+		require.NoError(t, r.Error)
+		content += r.Message.Content
+	}
+	require.Equal(t, "hi", content)
+	require.Equal(t, []string{"/ollama/api/version", "/ollama/v2/chat"}, paths)
+}
+
+func TestProxyAndDialContext(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.Host+r.URL.Path)
+		w.Write([]byte(`{"version":"1.0.0"}`))
+	}))
+	t.Cleanup(server.Close)
+	ctx := context.Background()
+
+	proxy, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	client := ollamago.Client{BaseURL: "http://ollama.internal:11434", Proxy: proxy}
+	_, err = client.Version(ctx)
+	require.NoError(t, err)
+
+	var dialed []string
+	client = ollamago.Client{
+		BaseURL: "http://ollama.tunnel:11434",
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialed = append(dialed, addr)
+			var d net.Dialer
+			return d.DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}
+	_, err = client.Version(ctx)
+	require.NoError(t, err)
+	_, err = client.Version(ctx)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		"ollama.internal:11434/api/version",
+		"ollama.tunnel:11434/api/version",
+		"ollama.tunnel:11434/api/version",
+	}, requested)
+	require.Equal(t, []string{"ollama.tunnel:11434"}, dialed, "the connection was not reused")
+}
+
+func TestRequestBodyRedirect(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, int64(len(body)), r.ContentLength)
+		bodies = append(bodies, string(body))
+		if r.URL.Path == "/api/show" {
+			http.Redirect(w, r, "/v2/show", http.StatusTemporaryRedirect)
+			return
+		}
+		w.Write([]byte(`{"template":"{{ .Prompt }}"}`))
+	}))
+	t.Cleanup(server.Close)
+	client := ollamago.Client{BaseURL: server.URL}
+	show, err := client.ShowModelInfo(context.Background(), ollamago.ShowModelRequest{Model: "test"})
+	require.NoError(t, err)
+	require.Equal(t, "{{ .Prompt }}", show.Template)
+	require.Len(t, bodies, 2)
+	require.Equal(t, bodies[0], bodies[1])
+	require.JSONEq(t, `{"model":"test"}`, bodies[0])
+}
+
+func TestStreamedRequestBody(t *testing.T) {
+	type received struct {
+		length int64
+		images int
+	}
+	var got []received
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamago.ChatRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		got = append(got, received{length: r.ContentLength, images: len(req.Messages[0].Images[0])})
+		w.Write([]byte(`{"message":{"role":"assistant","content":"a cat"},"done":true}`))
+	}))
+	t.Cleanup(server.Close)
+	chat := func(client *ollamago.Client, image string) {
+		t.Helper()
+		resp, err := client.GenerateChat(context.Background(), ollamago.ChatRequest{
+			Model:    "llava",
+			Messages: []ollamago.ChatMessage{{Role: "user", Content: "what is this?", Images: []string{image}}},
+		})
+		require.NoError(t, err)
+		for r := range resp {
+			require.NoError(t, r.Error)
+		}
+	}
+	large := strings.Repeat("A", 2<<20)
+	client := &ollamago.Client{BaseURL: server.URL}
+	chat(client, "AAAA")
+	chat(client, large)
+	client.StreamBodyThreshold = -1
+	chat(client, large)
+
+	require.Len(t, got, 3)
+	require.Positive(t, got[0].length)
+	require.Equal(t, received{length: -1, images: len(large)}, got[1])
+	require.Positive(t, got[2].length)
+}
+
+func TestToolCallDeltas(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"search","arguments":{"q":"go"}}}]},"done":false}` + "\n"))
+		w.Write([]byte(`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"open","arguments":{"n":1}}}]},"done":false}` + "\n"))
+		w.Write([]byte(`{"message":{"role":"assistant","content":""},"done":true}` + "\n"))
+	}))
+	t.Cleanup(server.Close)
+	client := ollamago.Client{BaseURL: server.URL}
+	resp, err := client.GenerateChat(context.Background(), ollamago.ChatRequest{
+		Model:    "test",
+		Messages: []ollamago.ChatMessage{ollamago.UserMessage("search go")},
+		Stream:   true,
+	})
+	require.NoError(t, err)
+	var deltas []ollamago.ToolCallDelta
+	for r := range resp {
+		require.NoError(t, r.Error)
+		deltas = append(deltas, r.ToolCallDeltas...)
+	}
+	require.Equal(t, []ollamago.ToolCallDelta{
+		{Index: 0, Name: "search", Arguments: `{"q":"go"}`},
+		{Index: 1, Name: "open", Arguments: `{"n":1}`},
+	}, deltas)
+}
+
+func TestUserAgent(t *testing.T) {
+	var agents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agents = append(agents, r.Header.Get("User-Agent"))
+		w.Write([]byte(`{"version":"1.0.0"}`))
+	}))
+	t.Cleanup(server.Close)
+	ctx := context.Background()
+
+	client := ollamago.Client{BaseURL: server.URL}
+	_, err := client.Version(ctx)
+	require.NoError(t, err)
+	client.Application = "myapp/1.2"
+	_, err = client.Version(ctx)
+	require.NoError(t, err)
+	client.UserAgent = "custom/3"
+	_, err = client.Version(ctx)
+	require.NoError(t, err)
+	client.Interceptors = []ollamago.Interceptor{func(next http.RoundTripper) http.RoundTripper {
+		return ollamago.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.Header.Set("User-Agent", "intercepted")
+			return next.RoundTrip(req)
+		})
+	}}
+	_, err = client.Version(ctx)
+	require.NoError(t, err)
+
+	require.Equal(t, []string{
+		ollamago.DefaultUserAgent,
+		ollamago.DefaultUserAgent + " myapp/1.2",
+		"custom/3 myapp/1.2",
+		"intercepted",
+	}, agents)
+}
+
+func TestLogprobs(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test", Chunks: []string{"yes", "!"}})
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+	ctx := context.Background()
+	resp, err := client.GenerateChat(ctx, ollamago.ChatRequest{
+		Model:       "test",
+		Messages:    []ollamago.ChatMessage{ollamago.UserMessage("ok?")},
+		Stream:      true,
+		Logprobs:    true,
+		TopLogprobs: 2,
+	})
+	require.NoError(t, err)
+	var logprobs []ollamago.Logprob
+	for r := range resp {
+		require.NoError(t, r.Error)
+		logprobs = append(logprobs, r.Logprobs...)
+	}
+	require.Len(t, logprobs, 2)
+	require.Equal(t, "yes", logprobs[0].Token)
+	require.Equal(t, 1.0, logprobs[0].Probability())
+	require.Equal(t, []ollamago.TokenLogprob{{Token: "!", Logprob: -0.1}, {Token: "alt1", Logprob: -1.1}}, logprobs[1].TopLogprobs)
+	var sent struct {
+		Logprobs    bool `json:"logprobs"`
+		TopLogprobs int  `json:"top_logprobs"`
+	}
+	require.NoError(t, json.Unmarshal(srv.Requests()[0].Body, &sent))
+	require.True(t, sent.Logprobs)
+	require.Equal(t, 2, sent.TopLogprobs)
+
+	completion, err := client.GenerateCompletion(ctx, ollamago.CompletionRequest{Model: "test", Prompt: "ok?", Logprobs: true})
+	require.NoError(t, err)
+	logprobs = nil
+	for r := range completion {
+		logprobs = append(logprobs, r.Logprobs...)
+	}
+	require.Len(t, logprobs, 2, "unstreamed responses carry every logprob")
+}
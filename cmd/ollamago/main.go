@@ -0,0 +1,254 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command ollamago is a command line client for Ollama, built on the
+// cirello.io/ollamago package.
+//
+// Usage:
+//
+//	ollamago [-host url] <command> [arguments]
+//
+// The commands are:
+//
+//	chat [-system prompt] [-sessions dir] model
+//	                                 chat interactively
+//	generate [-format json] model [prompt]
+//	                                 complete a prompt, read from stdin if omitted
+//	embed model text...              print the embedding of each text
+//	pull model...                    pull models
+//	ls                               list the local models
+//	rm model...                      delete models
+//	ps                               list the loaded models
+//
+// The host defaults to $OLLAMA_HOST, or http://localhost:11434.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/repl"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "ollamago:", err)
+		os.Exit(1)
+	}
+}
+
+var errUsage = errors.New("usage: ollamago [-host url] chat|generate|embed|pull|ls|rm|ps [arguments]")
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("ollamago", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	host := flags.String("host", os.Getenv("OLLAMA_HOST"), "Ollama server `url`")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errUsage
+	}
+	client := &ollamago.Client{BaseURL: baseURL(*host)}
+	cmd, args := flags.Arg(0), flags.Args()[1:]
+	switch cmd {
+	case "chat":
+		return chat(ctx, client, args, stdin, stdout)
+	case "generate":
+		return generate(ctx, client, args, stdin, stdout)
+	case "embed":
+		return embed(ctx, client, args, stdout)
+	case "pull":
+		return pull(ctx, client, args, stdout)
+	case "ls":
+		return list(ctx, client, stdout)
+	case "rm":
+		return remove(ctx, client, args)
+	case "ps":
+		return running(ctx, client, stdout)
+	default:
+		return fmt.Errorf("unknown command %q\n%w", cmd, errUsage)
+	}
+}
+
+// baseURL accepts the host:port form of OLLAMA_HOST.
+func baseURL(host string) string {
+	if host == "" || strings.Contains(host, "://") {
+		return host
+	}
+	return "http://" + host
+}
+
+func chat(ctx context.Context, client ollamago.API, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("chat", flag.ContinueOnError)
+	system := flags.String("system", "", "system `prompt`")
+	sessions := flags.String("sessions", "", "`directory` of the sessions handled by /save and /load")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: ollamago chat [-system prompt] [-sessions dir] model")
+	}
+	r := &repl.REPL{
+		Conversation: &ollamago.Conversation{Client: client, Model: flags.Arg(0), System: *system},
+		In:           stdin,
+		Out:          stdout,
+	}
+	if *sessions != "" {
+		r.Store = &ollamago.JSONFileStore{Dir: *sessions}
+	}
+	fmt.Fprintln(stdout, "Type /bye to exit and /help for the other commands.")
+	return r.Run(ctx)
+}
+
+func generate(ctx context.Context, client ollamago.API, args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	format := flags.String("format", "", `response format: "json" or a JSON schema`)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("usage: ollamago generate [-format json] model [prompt]")
+	}
+	prompt := strings.Join(flags.Args()[1:], " ")
+	if prompt == "" {
+		b, err := io.ReadAll(stdin)
+		if err != nil {
+			return fmt.Errorf("cannot read prompt: %w", err)
+		}
+		prompt = string(b)
+	}
+	req := ollamago.CompletionRequest{Model: flags.Arg(0), Prompt: prompt, Stream: true}
+	switch {
+	case *format == "json":
+		req.Format = json.RawMessage(`"json"`)
+	case *format != "":
+		req.Format = json.RawMessage(*format)
+	}
+	resp, err := client.GenerateCompletion(ctx, req)
+	if err != nil {
+		return err
+	}
+	var errs error
+	for r := range resp {
+		if r.Error != nil && errs == nil {
+			errs = r.Error
+		}
+		fmt.Fprint(stdout, r.Response)
+	}
+	fmt.Fprintln(stdout)
+	return errs
+}
+
+func embed(ctx context.Context, client ollamago.API, args []string, stdout io.Writer) error {
+	if len(args) < 2 {
+		return errors.New("usage: ollamago embed model text...")
+	}
+	resp, err := client.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: args[0], Input: args[1:]})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(stdout)
+	for _, e := range resp.Embeddings {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pull(ctx context.Context, client ollamago.API, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: ollamago pull model...")
+	}
+	w := &ollamago.ProgressWriter{W: stdout}
+	for _, name := range args {
+		progress, err := client.PullModel(ctx, ollamago.PullModelRequest{Model: name})
+		if err != nil {
+			return err
+		}
+		if err := w.Track(progress); err != nil {
+			return fmt.Errorf("cannot pull %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func list(ctx context.Context, client ollamago.API, stdout io.Writer) error {
+	resp, err := client.ListModels(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tID\tSIZE\tMODIFIED")
+	for _, m := range resp.Models {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.Name, shortDigest(m.Digest), formatBytes(m.Size), m.ModifiedAt.Format(time.DateTime))
+	}
+	return w.Flush()
+}
+
+func remove(ctx context.Context, client ollamago.API, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: ollamago rm model...")
+	}
+	for _, name := range args {
+		if err := client.DeleteModel(ctx, ollamago.DeleteModelRequest{Model: name}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func running(ctx context.Context, client ollamago.API, stdout io.Writer) error {
+	resp, err := client.ListRunningModels(ctx)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "NAME\tID\tSIZE\tVRAM\tUNTIL")
+	for _, m := range resp.Models {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", m.Name, shortDigest(m.Digest), formatBytes(m.Size), formatBytes(m.SizeVRAM), m.ExpiresAt.Format(time.DateTime))
+	}
+	return w.Flush()
+}
+
+func shortDigest(digest string) string {
+	digest = strings.TrimPrefix(digest, "sha256:")
+	return digest[:min(len(digest), 12)]
+}
+
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
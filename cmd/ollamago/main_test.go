@@ -0,0 +1,72 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "llama3.2", Size: 2_000_000_000, Chunks: []string{"hello ", "world"}})
+	t.Cleanup(srv.Close)
+	srv.AddRegistryModel(ollamatest.Model{Name: "qwen2.5"})
+	ctx := context.Background()
+	exec := func(stdin string, args ...string) string {
+		t.Helper()
+		var stdout, stderr strings.Builder
+		err := run(ctx, append([]string{"-host", srv.URL}, args...), strings.NewReader(stdin), &stdout, &stderr)
+		require.NoError(t, err, stderr.String())
+		return stdout.String()
+	}
+
+	out := exec("hi\n/clear\nhi again\n/bye\n", "chat", "-system", "be brief", "llama3.2")
+	require.Equal(t, 2, strings.Count(out, "hello world\n"))
+	require.JSONEq(t, `{"model":"llama3.2","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"hi again"}],"options":{},"stream":true}`, string(srv.Requests()[1].Body), "the history was cleared")
+
+	require.Equal(t, "hello world\n", exec("", "generate", "llama3.2", "say", "hi"))
+	require.Equal(t, "hello world\n", exec("say hi", "generate", "llama3.2"))
+
+	out = exec("", "embed", "llama3.2", "a", "b")
+	require.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 2)
+
+	out = exec("", "pull", "qwen2.5")
+	require.Contains(t, out, "success")
+
+	out = exec("", "ls")
+	require.Contains(t, out, "llama3.2")
+	require.Contains(t, out, "2.0 GB")
+	require.Contains(t, out, "qwen2.5")
+
+	out = exec("", "ps")
+	require.Contains(t, out, "llama3.2")
+
+	exec("", "rm", "qwen2.5")
+	require.NotContains(t, exec("", "ls"), "qwen2.5")
+
+	var stderr strings.Builder
+	err := run(ctx, []string{"-host", srv.URL, "nope"}, strings.NewReader(""), &strings.Builder{}, &stderr)
+	require.ErrorContains(t, err, `unknown command "nope"`)
+}
+
+func TestBaseURL(t *testing.T) {
+	require.Equal(t, "", baseURL(""))
+	require.Equal(t, "http://127.0.0.1:11434", baseURL("127.0.0.1:11434"))
+	require.Equal(t, "https://ollama.example.com", baseURL("https://ollama.example.com"))
+}
@@ -0,0 +1,218 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Coalescer makes concurrent identical requests share a single upstream
+// call, as when parallel workers embed the same chunk. Requests are
+// identical when they go to the same endpoint with the same body, that is,
+// the same model, input, options and seed. It is plugged into a Client as
+// an Interceptor:
+//
+//	coalescer := &ollamago.Coalescer{}
+//	client := &ollamago.Client{Interceptors: []ollamago.Interceptor{coalescer.Intercept}}
+//
+// Streamed responses are shared too: a request joining a call in progress
+// first receives what was already streamed. The upstream call is canceled
+// only when every request sharing it has gone away.
+type Coalescer struct {
+	// Endpoints lists the path suffixes of the coalesced endpoints. If
+	// nil, DefaultCacheEndpoints is used.
+	Endpoints []string
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is an upstream call shared by several requests.
+type flight struct {
+	ready chan struct{} // closed once resp or err is set
+	resp  *http.Response
+	err   error
+
+	cancel context.CancelFunc // detaches the upstream call from the requests
+
+	mu          sync.Mutex
+	buf         []byte
+	done        bool
+	readErr     error
+	changed     chan struct{} // closed and replaced when buf or done change
+	subscribers int
+}
+
+// Intercept is the Interceptor coalescing the requests.
+func (c *Coalescer) Intercept(next http.RoundTripper) http.RoundTripper {
+	return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if !c.coalesced(req) {
+			return next.RoundTrip(req)
+		}
+		var reqBody []byte
+		if req.Body != nil {
+			var err error
+			reqBody, err = io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("cannot read request body: %w", err)
+			}
+			req = req.Clone(req.Context())
+			req.Body = io.NopCloser(bytes.NewReader(reqBody))
+			req.ContentLength = int64(len(reqBody))
+		}
+		key := cacheKey(req.Method, req.URL.String(), reqBody)
+		c.mu.Lock()
+		f, ok := c.flights[key]
+		if !ok {
+			f = &flight{ready: make(chan struct{}), changed: make(chan struct{})}
+			if c.flights == nil {
+				c.flights = make(map[string]*flight)
+			}
+			c.flights[key] = f
+		}
+		f.mu.Lock()
+		f.subscribers++
+		f.mu.Unlock()
+		c.mu.Unlock()
+		if !ok {
+			ctx, cancel := context.WithCancel(context.WithoutCancel(req.Context()))
+			f.cancel = cancel
+			go c.run(key, f, next, req.WithContext(ctx))
+		}
+		select {
+		case <-f.ready:
+		case <-req.Context().Done():
+			f.unsubscribe()
+			return nil, req.Context().Err()
+		}
+		if f.err != nil {
+			f.unsubscribe()
+			return nil, f.err
+		}
+		resp := *f.resp
+		resp.Header = f.resp.Header.Clone()
+		resp.Request = req
+		resp.Body = &flightBody{flight: f, ctx: req.Context()}
+		return &resp, nil
+	})
+}
+
+func (c *Coalescer) coalesced(req *http.Request) bool {
+	if req.Method != http.MethodPost {
+		return false
+	}
+	endpoints := c.Endpoints
+	if endpoints == nil {
+		endpoints = DefaultCacheEndpoints
+	}
+	for _, e := range endpoints {
+		if strings.HasSuffix(req.URL.Path, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// run performs the upstream call of a flight and buffers its response.
+func (c *Coalescer) run(key string, f *flight, next http.RoundTripper, req *http.Request) {
+	defer func() {
+		c.mu.Lock()
+		delete(c.flights, key)
+		c.mu.Unlock()
+	}()
+	defer f.cancel()
+	resp, err := next.RoundTrip(req)
+	f.resp, f.err = resp, err
+	close(f.ready)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	chunk := make([]byte, 32<<10)
+	for {
+		n, err := resp.Body.Read(chunk)
+		f.mu.Lock()
+		f.buf = append(f.buf, chunk[:n]...)
+		if err != nil {
+			f.done = true
+			if err != io.EOF {
+				f.readErr = err
+			}
+		}
+		close(f.changed)
+		f.changed = make(chan struct{})
+		f.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (f *flight) unsubscribe() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.subscribers--
+	if f.subscribers == 0 && !f.done {
+		f.cancel()
+	}
+}
+
+// flightBody reads a flight response from the start.
+type flightBody struct {
+	flight *flight
+	ctx    context.Context
+	pos    int
+	closed sync.Once
+}
+
+func (b *flightBody) Read(p []byte) (int, error) {
+	f := b.flight
+	for {
+		f.mu.Lock()
+		if b.pos < len(f.buf) {
+			n := copy(p, f.buf[b.pos:])
+			b.pos += n
+			f.mu.Unlock()
+			return n, nil
+		}
+		if f.done {
+			err := f.readErr
+			f.mu.Unlock()
+			if err == nil {
+				err = io.EOF
+			}
+			return 0, err
+		}
+		changed := f.changed
+		f.mu.Unlock()
+		select {
+		case <-changed:
+		case <-b.ctx.Done():
+			return 0, b.ctx.Err()
+		}
+	}
+}
+
+func (b *flightBody) Close() error {
+	b.closed.Do(b.flight.unsubscribe)
+	return nil
+}
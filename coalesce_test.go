@@ -0,0 +1,110 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoalescer(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test", Chunks: []string{"a", "b", "c"}})
+	t.Cleanup(srv.Close)
+	srv.SetLatency(50 * time.Millisecond)
+	client := srv.Client()
+	client.Interceptors = []ollamago.Interceptor{(&ollamago.Coalescer{}).Intercept}
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			input := "same"
+			if i == 0 {
+				input = "different"
+			}
+			resp, err := client.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: "test", Input: []string{input}})
+			require.NoError(t, err)
+			require.Len(t, resp.Embeddings, 1)
+		}()
+	}
+	wg.Wait()
+	require.Len(t, srv.Requests(), 2)
+
+	srv.SetLatency(0)
+	srv.SetChunkDelay(20 * time.Millisecond)
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			respChan, err := client.GenerateChat(ctx, ollamago.ChatRequest{Model: "test", Stream: true})
+			require.NoError(t, err)
+			var content string
+			for r := range respChan {
+				require.NoError(t, r.Error)
+				content += r.Message.Content
+			}
+			require.Equal(t, "abc", content)
+		}()
+	}
+	wg.Wait()
+	require.Len(t, srv.Requests(), 3)
+}
+
+func TestCoalescerCancel(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test", Chunks: []string{"a", "b"}})
+	t.Cleanup(srv.Close)
+	srv.SetChunkDelay(50 * time.Millisecond)
+	client := srv.Client()
+	client.Interceptors = []ollamago.Interceptor{(&ollamago.Coalescer{}).Intercept}
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leader, err := client.GenerateChat(leaderCtx, ollamago.ChatRequest{Model: "test", Stream: true})
+	require.NoError(t, err)
+	follower, err := client.GenerateChat(context.Background(), ollamago.ChatRequest{Model: "test", Stream: true})
+	require.NoError(t, err)
+	cancel()
+	for range leader {
+	}
+	var content string
+	for r := range follower {
+		require.NoError(t, r.Error)
+		content += r.Message.Content
+	}
+	require.Equal(t, "ab", content, "the follower is not affected by the first request going away")
+	require.Len(t, srv.Requests(), 1)
+}
+
+func TestCoalescerLeavesRequestAlone(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test"})
+	t.Cleanup(srv.Close)
+	body := io.NopCloser(strings.NewReader(`{"model":"test","input":["a"]}`))
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/embed", body)
+	require.NoError(t, err)
+	resp, err := (&ollamago.Coalescer{}).Intercept(http.DefaultTransport).RoundTrip(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.True(t, req.Body == body, "the request body is not replaced")
+}
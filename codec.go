@@ -0,0 +1,58 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Codec encodes the requests and decodes the responses of Client, so that
+// faster JSON implementations, such as sonic or go-json, can replace
+// encoding/json. Codecs must honor the json struct tags and the
+// json.Marshaler and json.Unmarshaler implementations of the types of this
+// package.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+	NewStreamDecoder(r io.Reader) StreamDecoder
+}
+
+// StreamDecoder decodes the JSON values read from a stream.
+type StreamDecoder interface {
+	Decode(v any) error
+}
+
+// JSONCodec is the Codec of encoding/json, the default one.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (JSONCodec) NewStreamDecoder(r io.Reader) StreamDecoder {
+	return json.NewDecoder(r)
+}
+
+func (c *Client) codec() Codec {
+	if c.Codec == nil {
+		return JSONCodec{}
+	}
+	return c.Codec
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+type countingCodec struct {
+	ollamago.JSONCodec
+	marshal, unmarshal, decoders int
+}
+
+func (c *countingCodec) Marshal(v any) ([]byte, error) {
+	c.marshal++
+	return c.JSONCodec.Marshal(v)
+}
+
+func (c *countingCodec) Unmarshal(data []byte, v any) error {
+	c.unmarshal++
+	return c.JSONCodec.Unmarshal(data, v)
+}
+
+func (c *countingCodec) NewStreamDecoder(r io.Reader) ollamago.StreamDecoder {
+	c.decoders++
+	return c.JSONCodec.NewStreamDecoder(r)
+}
+
+func TestCodec(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test", Chunks: []string{"a", "b"}})
+	t.Cleanup(srv.Close)
+	codec := &countingCodec{}
+	client := srv.Client()
+	client.Codec = codec
+	ctx := context.Background()
+
+	resp, err := client.GenerateChat(ctx, ollamago.ChatRequest{
+		Model:    "test",
+		Messages: []ollamago.ChatMessage{ollamago.UserMessage("hi")},
+		Stream:   true,
+	})
+	require.NoError(t, err)
+	var content string
+	for r := range resp {
+		require.NoError(t, r.Error)
+		content += r.Message.Content
+	}
+	require.Equal(t, "ab", content)
+	models, err := client.ListModels(ctx)
+	require.NoError(t, err)
+	require.Len(t, models.Models, 1)
+
+	require.Equal(t, 1, codec.marshal)
+	require.Equal(t, 3, codec.unmarshal, "two chunks and the final one")
+	require.Equal(t, 1, codec.decoders)
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+)
+
+// CompressRequests returns an Interceptor compressing with gzip the
+// request bodies of at least minSize bytes, or of unknown size, such as
+// large embedding batches over slow links. The Ollama server does not
+// decompress requests itself: it is meant for gateways that accept
+// "Content-Encoding: gzip". It should be the last interceptor, so that
+// the others see the uncompressed bodies.
+func CompressRequests(minSize int64) Interceptor {
+	return CompressRequestsWith("gzip", func(w io.Writer) io.WriteCloser {
+		return gzip.NewWriter(w)
+	}, minSize)
+}
+
+// CompressRequestsWith is CompressRequests with another compression, such
+// as zstd, whose Content-Encoding is encoding and whose writers newWriter
+// returns.
+func CompressRequestsWith(encoding string, newWriter func(io.Writer) io.WriteCloser, minSize int64) Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Body == nil || req.Body == http.NoBody || req.Header.Get("Content-Encoding") != "" ||
+				(req.ContentLength >= 0 && req.ContentLength < minSize) {
+				return next.RoundTrip(req)
+			}
+			compressed := req.Clone(req.Context())
+			compressed.Body = compressBody(req.Body, newWriter)
+			compressed.ContentLength = -1
+			compressed.Header.Set("Content-Encoding", encoding)
+			compressed.Header.Del("Content-Length")
+			if getBody := req.GetBody; getBody != nil {
+				compressed.GetBody = func() (io.ReadCloser, error) {
+					body, err := getBody()
+					if err != nil {
+						return nil, err
+					}
+					return compressBody(body, newWriter), nil
+				}
+			}
+			return next.RoundTrip(compressed)
+		})
+	}
+}
+
+// compressBody compresses body while it is read.
+func compressBody(body io.ReadCloser, newWriter func(io.Writer) io.WriteCloser) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		defer body.Close()
+		zw := newWriter(pw)
+		_, err := io.Copy(zw, body)
+		if closeErr := zw.Close(); err == nil {
+			err = closeErr
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
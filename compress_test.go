@@ -0,0 +1,64 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressRequests(t *testing.T) {
+	var encodings []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			require.NoError(t, err)
+			body = zr
+		}
+		var req ollamago.EmbedRequest
+		require.NoError(t, json.NewDecoder(body).Decode(&req))
+		w.Write([]byte(`{"model":"test","embeddings":[` + strings.TrimSuffix(strings.Repeat(`[1,0],`, len(req.Input)), ",") + `]}`))
+	}))
+	t.Cleanup(server.Close)
+	client := &ollamago.Client{
+		BaseURL:      server.URL,
+		Interceptors: []ollamago.Interceptor{ollamago.CompressRequests(1024)},
+	}
+	ctx := context.Background()
+
+	resp, err := client.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: "test", Input: []string{"small"}})
+	require.NoError(t, err)
+	require.Len(t, resp.Embeddings, 1)
+	batch := make([]string, 500)
+	for i := range batch {
+		batch[i] = "a highly compressible input"
+	}
+	resp, err = client.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: "test", Input: batch})
+	require.NoError(t, err)
+	require.Len(t, resp.Embeddings, len(batch))
+
+	require.Equal(t, []string{"", "gzip"}, encodings)
+}
@@ -0,0 +1,218 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ConsistencyOption configures SelfConsistency.
+type ConsistencyOption func(*consistencyConfig)
+
+type consistencyConfig struct {
+	samples     int
+	concurrency int
+	temperature float64
+	normalize   func(string) string
+	judge       API
+	judgeModel  string
+}
+
+// WithSamples sets how many times the request is sampled. The default is
+// 5.
+func WithSamples(n int) ConsistencyOption {
+	return func(c *consistencyConfig) {
+		c.samples = n
+	}
+}
+
+// WithSampleConcurrency sets how many samples are generated at once. The
+// default is to generate all of them at once.
+func WithSampleConcurrency(n int) ConsistencyOption {
+	return func(c *consistencyConfig) {
+		c.concurrency = n
+	}
+}
+
+// WithSampleTemperature sets the temperature of the samples of requests
+// that leave it unset. The default is 0.8, as identical samples defeat the
+// purpose.
+func WithSampleTemperature(t float64) ConsistencyOption {
+	return func(c *consistencyConfig) {
+		c.temperature = t
+	}
+}
+
+// WithNormalize sets the function mapping an answer to its vote, so that
+// answers differing only in form count as the same. The default trims
+// spaces and trailing periods and ignores case.
+func WithNormalize(normalize func(string) string) ConsistencyOption {
+	return func(c *consistencyConfig) {
+		c.normalize = normalize
+	}
+}
+
+// WithJudge makes the given model pick the best of the candidates instead
+// of taking a majority vote, for open-ended answers that rarely match.
+func WithJudge(client API, model string) ConsistencyOption {
+	return func(c *consistencyConfig) {
+		c.judge = client
+		c.judgeModel = model
+	}
+}
+
+func normalizeAnswer(s string) string {
+	return strings.ToLower(strings.TrimRight(strings.TrimSpace(s), ". "))
+}
+
+// Consensus is the outcome of SelfConsistency.
+type Consensus struct {
+	// Answer is the chosen answer, as written by the first sample giving
+	// it.
+	Answer string
+
+	// Votes is the number of samples agreeing with Answer.
+	Votes int
+
+	// Candidates holds the answer of every sample, in sample order.
+	Candidates []string
+}
+
+// Agreement returns the share of the samples agreeing with the answer.
+func (c *Consensus) Agreement() float64 {
+	if len(c.Candidates) == 0 {
+		return 0
+	}
+	return float64(c.Votes) / float64(len(c.Candidates))
+}
+
+// SelfConsistency samples req several times concurrently, each sample with
+// its own seed, and returns the answer most samples agree on, or the one
+// picked by a judge set with WithJudge. Ties go to the answer given first.
+func SelfConsistency(ctx context.Context, client API, req ChatRequest, opts ...ConsistencyOption) (*Consensus, error) {
+	cfg := consistencyConfig{
+		samples:     5,
+		temperature: 0.8,
+		normalize:   normalizeAnswer,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	cfg.samples = max(cfg.samples, 1)
+	if cfg.concurrency <= 0 {
+		cfg.concurrency = cfg.samples
+	}
+	candidates, err := sample(ctx, client, req, cfg)
+	if err != nil {
+		return nil, err
+	}
+	votes := make(map[string]int)
+	for _, c := range candidates {
+		votes[cfg.normalize(c)]++
+	}
+	consensus := &Consensus{Candidates: candidates}
+	if cfg.judge != nil {
+		i, err := judgeCandidates(ctx, cfg.judge, cfg.judgeModel, req.Messages, candidates)
+		if err != nil {
+			return nil, err
+		}
+		consensus.Answer = candidates[i]
+		consensus.Votes = votes[cfg.normalize(candidates[i])]
+		return consensus, nil
+	}
+	for _, c := range candidates {
+		if v := votes[cfg.normalize(c)]; v > consensus.Votes {
+			consensus.Answer, consensus.Votes = c, v
+		}
+	}
+	return consensus, nil
+}
+
+func sample(ctx context.Context, client API, req ChatRequest, cfg consistencyConfig) ([]string, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	baseSeed := 1
+	if req.Options.Seed != nil {
+		baseSeed = *req.Options.Seed
+	}
+	if req.Options.Temperature == nil {
+		req.Options.Temperature = Ptr(cfg.temperature)
+	}
+	candidates := make([]string, cfg.samples)
+	sem := make(chan struct{}, cfg.concurrency)
+	var wg sync.WaitGroup
+	for i := range cfg.samples {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		req := req
+		req.Options.Seed = Ptr(baseSeed + i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			resp, err := client.GenerateChat(ctx, req)
+			if err != nil {
+				cancel(fmt.Errorf("cannot generate sample %d: %w", i+1, err))
+				return
+			}
+			reply, err := collectChat(resp)
+			if err != nil {
+				cancel(fmt.Errorf("cannot generate sample %d: %w", i+1, err))
+				return
+			}
+			candidates[i] = strings.TrimSpace(reply.Content)
+		}()
+	}
+	wg.Wait()
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// judgeCandidates asks a model which of the candidates best answers the
+// conversation and returns its index.
+func judgeCandidates(ctx context.Context, client API, model string, messages []ChatMessage, candidates []string) (int, error) {
+	var prompt strings.Builder
+	prompt.WriteString("Several answers were written for the conversation below. Reply with the number of the most accurate and complete answer.\n\nConversation:\n")
+	for _, m := range messages {
+		fmt.Fprintf(&prompt, "%s: %s\n", m.Role, m.Content)
+	}
+	for i, c := range candidates {
+		fmt.Fprintf(&prompt, "\nAnswer %d:\n%s\n", i+1, c)
+	}
+	choice, err := ChatInto[struct {
+		Answer int `json:"answer"`
+	}](ctx, client, ChatRequest{
+		Model:    model,
+		Messages: []ChatMessage{UserMessage(prompt.String())},
+		Options:  ModelParameters{Temperature: Ptr(0.0)},
+	}, WithJSONRepair())
+	if err != nil {
+		return 0, fmt.Errorf("cannot judge the answers: %w", err)
+	}
+	if choice.Answer < 1 || choice.Answer > len(candidates) {
+		return 0, fmt.Errorf("cannot judge the answers: %w: no answer %d", ErrInvalidOutput, choice.Answer)
+	}
+	return choice.Answer - 1, nil
+}
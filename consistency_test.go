@@ -0,0 +1,66 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamagotest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelfConsistency(t *testing.T) {
+	answers := map[int]string{1: "Paris", 2: "paris.", 3: "Lyon", 4: "Lyon", 5: "PARIS"}
+	var (
+		mu    sync.Mutex
+		seeds []int
+	)
+	mock := &ollamagotest.MockClient{
+		GenerateChatFunc: func(ctx context.Context, req ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error) {
+			mu.Lock()
+			seeds = append(seeds, *req.Options.Seed)
+			mu.Unlock()
+			require.Equal(t, 0.8, *req.Options.Temperature)
+			return ollamagotest.Stream(ollamago.ChatResponse{
+				Message: ollamago.ChatMessage{Role: ollamago.RoleAssistant, Content: " " + answers[*req.Options.Seed] + "\n"},
+				Done:    true,
+			}), nil
+		},
+	}
+	req := ollamago.ChatRequest{Model: "test", Messages: []ollamago.ChatMessage{ollamago.UserMessage("Capital of France?")}}
+	c, err := ollamago.SelfConsistency(context.Background(), mock, req, ollamago.WithSampleConcurrency(2))
+	require.NoError(t, err)
+	require.Equal(t, "Paris", c.Answer)
+	require.Equal(t, 3, c.Votes)
+	require.Equal(t, 0.6, c.Agreement())
+	require.Equal(t, []string{"Paris", "paris.", "Lyon", "Lyon", "PARIS"}, c.Candidates)
+	require.ElementsMatch(t, []int{1, 2, 3, 4, 5}, seeds)
+
+	judge := scriptedChat(`{"answer":3}`)
+	c, err = ollamago.SelfConsistency(context.Background(), mock, req, ollamago.WithSamples(4), ollamago.WithJudge(judge, "judge"))
+	require.NoError(t, err)
+	require.Equal(t, "Lyon", c.Answer)
+	require.Equal(t, 2, c.Votes)
+	judged := judge.Calls()[0].Request.(ollamago.ChatRequest)
+	require.Equal(t, "judge", judged.Model)
+	require.Contains(t, judged.Messages[0].Content, "Answer 4:\nLyon")
+
+	judge = scriptedChat(`{"answer":9}`)
+	_, err = ollamago.SelfConsistency(context.Background(), mock, req, ollamago.WithSamples(2), ollamago.WithJudge(judge, "judge"))
+	require.ErrorIs(t, err, ollamago.ErrInvalidOutput)
+}
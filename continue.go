@@ -0,0 +1,171 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"strings"
+)
+
+// DefaultMaxContinuations is the continuation limit used when
+// AutoContinue.MaxContinuations is zero.
+const DefaultMaxContinuations = 3
+
+// defaultContinuePrompt asks for the rest of a truncated completion.
+const defaultContinuePrompt = "Continue exactly where you stopped."
+
+// AutoContinue wraps an API, continuing the chats and completions cut
+// short by the num_predict or context length limit with follow-up requests
+// until a natural stop, stitching them into one response. The final chunk
+// carries the metrics summed over the requests, and DoneLength if the
+// continuations were exhausted.
+type AutoContinue struct {
+	API
+
+	// MaxContinuations caps the follow-up requests of a response. If
+	// zero, DefaultMaxContinuations is used.
+	MaxContinuations int
+
+	// ContinuePrompt is sent to ask the model to go on. If empty, chats
+	// end with the truncated reply, which the server continues, and
+	// completions send "Continue exactly where you stopped." with the
+	// context of the truncated response.
+	ContinuePrompt string
+}
+
+var _ API = (*AutoContinue)(nil)
+
+func (a *AutoContinue) maxContinuations() int {
+	if a.MaxContinuations <= 0 {
+		return DefaultMaxContinuations
+	}
+	return a.MaxContinuations
+}
+
+func (m *Metrics) add(o Metrics) {
+	m.LoadDuration += o.LoadDuration
+	m.PromptEvalCount += o.PromptEvalCount
+	m.PromptEvalDuration += o.PromptEvalDuration
+	m.EvalCount += o.EvalCount
+	m.EvalDuration += o.EvalDuration
+}
+
+// GenerateChat continues truncated replies.
+func (a *AutoContinue) GenerateChat(ctx context.Context, req ChatRequest) (<-chan ChatResponse, error) {
+	resp, err := a.API.GenerateChat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan ChatResponse)
+	go func() {
+		defer close(out)
+		var (
+			reply   strings.Builder
+			metrics Metrics
+		)
+		for n := 0; ; n++ {
+			var final *ChatResponse
+			for r := range resp {
+				if r.Done && r.Error == nil {
+					final = &r
+					continue
+				}
+				reply.WriteString(r.Message.Content)
+				if !send(ctx, out, r) {
+					for range resp {
+					}
+					return
+				}
+			}
+			if final == nil {
+				return
+			}
+			reply.WriteString(final.Message.Content)
+			metrics.add(final.Metrics)
+			if !final.WasTruncated() || n == a.maxContinuations() {
+				final.Metrics = metrics
+				send(ctx, out, *final)
+				return
+			}
+			// Relay the content of the final chunk without ending the
+			// response.
+			partial := ChatResponse{Model: final.Model, Message: final.Message}
+			if partial.Message.Content != "" || len(partial.Message.ToolCalls) > 0 {
+				send(ctx, out, partial)
+			}
+			next := req
+			next.Messages = append(append([]ChatMessage(nil), req.Messages...), AssistantMessage(reply.String()))
+			if a.ContinuePrompt != "" {
+				next.Messages = append(next.Messages, UserMessage(a.ContinuePrompt))
+			}
+			if resp, err = a.API.GenerateChat(ctx, next); err != nil {
+				send(ctx, out, ChatResponse{Model: req.Model, Error: err})
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// GenerateCompletion continues truncated completions.
+func (a *AutoContinue) GenerateCompletion(ctx context.Context, req CompletionRequest) (<-chan CompletionResponse, error) {
+	resp, err := a.API.GenerateCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	prompt := a.ContinuePrompt
+	if prompt == "" {
+		prompt = defaultContinuePrompt
+	}
+	out := make(chan CompletionResponse)
+	go func() {
+		defer close(out)
+		var metrics Metrics
+		for n := 0; ; n++ {
+			var final *CompletionResponse
+			for r := range resp {
+				if r.Done && r.Error == nil {
+					final = &r
+					continue
+				}
+				if !send(ctx, out, r) {
+					for range resp {
+					}
+					return
+				}
+			}
+			if final == nil {
+				return
+			}
+			metrics.add(final.Metrics)
+			if !final.WasTruncated() || len(final.Context) == 0 || n == a.maxContinuations() {
+				final.Metrics = metrics
+				send(ctx, out, *final)
+				return
+			}
+			if final.Response != "" {
+				send(ctx, out, CompletionResponse{Model: final.Model, Response: final.Response})
+			}
+			next := req
+			next.Prompt = prompt
+			next.Context = final.Context
+			if resp, err = a.API.GenerateCompletion(ctx, next); err != nil {
+				send(ctx, out, CompletionResponse{Model: req.Model, Error: err})
+				return
+			}
+		}
+	}()
+	return out, nil
+}
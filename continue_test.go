@@ -0,0 +1,96 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoContinue(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "llama3.2", Chunks: []string{"a", "b", "c"}})
+	t.Cleanup(srv.Close)
+	client := &ollamago.AutoContinue{API: srv.Client(), MaxContinuations: 2}
+	ctx := context.Background()
+	chat := func(numPredict int) (string, ollamago.ChatResponse) {
+		t.Helper()
+		resp, err := client.GenerateChat(ctx, ollamago.ChatRequest{
+			Model:    "llama3.2",
+			Messages: []ollamago.ChatMessage{ollamago.UserMessage("hi")},
+			Stream:   true,
+			Options:  ollamago.ModelParameters{NumPredict: ollamago.Ptr(numPredict)},
+		})
+		require.NoError(t, err)
+		var (
+			content strings.Builder
+			final   ollamago.ChatResponse
+		)
+		for r := range resp {
+			require.NoError(t, r.Error)
+			content.WriteString(r.Message.Content)
+			if r.Done {
+				final = r
+			}
+		}
+		return content.String(), final
+	}
+
+	content, final := chat(5)
+	require.Equal(t, "abc", content)
+	require.Equal(t, ollamago.DoneStop, final.DoneReason)
+	require.Len(t, srv.Requests(), 1)
+
+	content, final = chat(2)
+	require.Equal(t, "ababab", content, "each request is truncated again")
+	require.True(t, final.WasTruncated())
+	require.Equal(t, 6, final.EvalCount)
+	requests := srv.Requests()
+	require.Len(t, requests, 4)
+	var last ollamago.ChatRequest
+	require.NoError(t, json.Unmarshal(requests[3].Body, &last))
+	require.Equal(t, []ollamago.ChatMessage{ollamago.UserMessage("hi"), ollamago.AssistantMessage("abab")}, last.Messages)
+
+	client.ContinuePrompt = "go on"
+	chat(2)
+	requests = srv.Requests()
+	require.NoError(t, json.Unmarshal(requests[len(requests)-1].Body, &last))
+	require.Equal(t, ollamago.UserMessage("go on"), last.Messages[len(last.Messages)-1])
+
+	client.ContinuePrompt = ""
+	completion, err := client.GenerateCompletion(ctx, ollamago.CompletionRequest{
+		Model:   "llama3.2",
+		Prompt:  "hi",
+		Stream:  true,
+		Options: ollamago.ModelParameters{NumPredict: ollamago.Ptr(2)},
+	})
+	require.NoError(t, err)
+	var text strings.Builder
+	for r := range completion {
+		require.NoError(t, r.Error)
+		text.WriteString(r.Response)
+	}
+	require.Equal(t, "ababab", text.String())
+	requests = srv.Requests()
+	var next ollamago.CompletionRequest
+	require.NoError(t, json.Unmarshal(requests[len(requests)-1].Body, &next))
+	require.Equal(t, "Continue exactly where you stopped.", next.Prompt)
+	require.NotEmpty(t, next.Context)
+}
@@ -0,0 +1,238 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// TrimStrategy reduces a message history so that it fits within budget
+// tokens, as measured by tokens. Implementations must always keep the last
+// message and may only drop messages, never rewrite them.
+type TrimStrategy func(history []ChatMessage, budget int, tokens func(ChatMessage) int) []ChatMessage
+
+// DropOldest discards the oldest messages until the history fits the
+// budget.
+func DropOldest(history []ChatMessage, budget int, tokens func(ChatMessage) int) []ChatMessage {
+	total := 0
+	for _, m := range history {
+		total += tokens(m)
+	}
+	start := 0
+	for total > budget && start < len(history)-1 {
+		total -= tokens(history[start])
+		start++
+	}
+	return history[start:]
+}
+
+// SlidingWindow keeps at most the last n messages, then drops the oldest
+// of those until the history fits the budget.
+func SlidingWindow(n int) TrimStrategy {
+	return func(history []ChatMessage, budget int, tokens func(ChatMessage) int) []ChatMessage {
+		if n > 0 && len(history) > n {
+			history = history[len(history)-n:]
+		}
+		return DropOldest(history, budget, tokens)
+	}
+}
+
+// EstimateTokens approximates the number of tokens of a message, assuming
+// about four characters per token plus a small per-message overhead.
+func EstimateTokens(m ChatMessage) int {
+	return (len(m.Role)+len(m.Content))/4 + 4
+}
+
+// Conversation owns the message history of a chat session with a model.
+// It is safe for concurrent use; concurrent calls to Send are serialized
+// until their request is sent.
+type Conversation struct {
+	// Client is used to talk to the Ollama server.
+	Client API
+
+	// Model is the chat model.
+	Model string
+
+	// System is the system prompt sent ahead of the history. It is never
+	// trimmed.
+	System string
+
+	// Options are the model parameters sent with every request.
+	Options ModelParameters
+
+	// Budget is the context-window budget, in tokens, for the system
+	// prompt plus the history. Zero disables trimming.
+	Budget int
+
+	// Trim selects which messages to keep when the history exceeds the
+	// budget. If nil, DropOldest is used.
+	Trim TrimStrategy
+
+	// Tokens measures a message. If nil, EstimateTokens is used.
+	Tokens func(ChatMessage) int
+
+	// Memory, if set, is handed the messages evicted by trimming and
+	// contributes pinned messages sent between the system prompt and the
+	// history.
+	Memory Memory
+
+	sendMu  sync.Mutex // serializes Send until the request is built
+	mu      sync.Mutex
+	history []ChatMessage
+}
+
+// History returns a copy of the current message history, excluding the
+// system prompt.
+func (c *Conversation) History() []ChatMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ChatMessage(nil), c.history...)
+}
+
+// Append adds messages to the history without contacting the server.
+func (c *Conversation) Append(messages ...ChatMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.history = append(c.history, messages...)
+}
+
+// Reset clears the history.
+func (c *Conversation) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.history = nil
+}
+
+func (c *Conversation) tokens() func(ChatMessage) int {
+	if c.Tokens == nil {
+		return EstimateTokens
+	}
+	return c.Tokens
+}
+
+// trim applies the trimming strategy to the history and returns the
+// messages to keep and the evicted ones. The caller must hold c.mu.
+func (c *Conversation) trim() (kept, evicted []ChatMessage) {
+	if c.Budget <= 0 || len(c.history) == 0 {
+		return c.history, nil
+	}
+	tokens := c.tokens()
+	budget := c.Budget
+	for _, m := range c.pinned() {
+		budget -= tokens(m)
+	}
+	trim := c.Trim
+	if trim == nil {
+		trim = DropOldest
+	}
+	kept = trim(c.history, budget, tokens)
+	return kept, c.history[:len(c.history)-len(kept)]
+}
+
+// pinned returns the messages sent ahead of the history.
+func (c *Conversation) pinned() []ChatMessage {
+	var messages []ChatMessage
+	if c.System != "" {
+		messages = append(messages, ChatMessage{Role: RoleSystem, Content: c.System})
+	}
+	if c.Memory != nil {
+		messages = append(messages, c.Memory.Context()...)
+	}
+	return messages
+}
+
+func (c *Conversation) request() ChatRequest {
+	return ChatRequest{
+		Model:    c.Model,
+		Messages: append(c.pinned(), c.history...),
+		Stream:   true,
+		Options:  c.Options,
+	}
+}
+
+// Send appends userMessage to the history, trims the history to the budget
+// and streams the model's reply. Once the reply is complete it is appended
+// to the history as well. If the request fails, the user message is
+// removed from the history again.
+func (c *Conversation) Send(ctx context.Context, userMessage string) (<-chan ChatResponse, error) {
+	if c.Client == nil {
+		return nil, errors.New("conversation has no client")
+	}
+	c.sendMu.Lock()
+	c.mu.Lock()
+	c.history = append(c.history, ChatMessage{Role: RoleUser, Content: userMessage})
+	_, evicted := c.trim()
+	c.mu.Unlock()
+
+	if len(evicted) > 0 && c.Memory != nil {
+		if err := c.Memory.Evict(ctx, evicted); err != nil {
+			c.sendMu.Unlock()
+			c.rollback()
+			return nil, fmt.Errorf("cannot preserve evicted history: %w", err)
+		}
+	}
+	c.mu.Lock()
+	// Messages appended while the memory was busy are kept: only the
+	// evicted ones, at the head of the history, are dropped.
+	c.history = append([]ChatMessage(nil), c.history[min(len(evicted), len(c.history)):]...)
+	req := c.request()
+	c.mu.Unlock()
+	c.sendMu.Unlock()
+
+	resp, err := c.Client.GenerateChat(ctx, req)
+	if err != nil {
+		c.rollback()
+		return nil, fmt.Errorf("cannot send message: %w", err)
+	}
+	out := make(chan ChatResponse)
+	go func() {
+		defer close(out)
+		var (
+			reply  strings.Builder
+			role   = RoleAssistant
+			done   bool
+			failed bool
+		)
+		for r := range resp {
+			if r.Error != nil {
+				failed = true
+			}
+			if r.Message.Role != "" {
+				role = r.Message.Role
+			}
+			reply.WriteString(r.Message.Content)
+			done = done || r.Done
+			out <- r
+		}
+		if failed || !done {
+			c.rollback()
+			return
+		}
+		c.Append(ChatMessage{Role: role, Content: reply.String()})
+	}()
+	return out, nil
+}
+
+func (c *Conversation) rollback() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n := len(c.history); n > 0 && c.history[n-1].Role == RoleUser {
+		c.history = c.history[:n-1]
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamagotest"
+	"github.com/stretchr/testify/require"
+)
+
+func echoChat() *ollamagotest.MockClient {
+	return &ollamagotest.MockClient{
+		GenerateChatFunc: func(ctx context.Context, req ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error) {
+			last := req.Messages[len(req.Messages)-1]
+			return ollamagotest.Stream(
+				ollamago.ChatResponse{Message: ollamago.ChatMessage{Role: "assistant", Content: "re: "}},
+				ollamago.ChatResponse{Message: ollamago.ChatMessage{Role: "assistant", Content: last.Content}, Done: true},
+			), nil
+		},
+	}
+}
+
+func TestConversationSend(t *testing.T) {
+	mock := echoChat()
+	conv := &ollamago.Conversation{Client: mock, Model: "test", System: "be brief"}
+	for _, msg := range []string{"one", "two"} {
+		resp, err := conv.Send(context.Background(), msg)
+		require.NoError(t, err)
+		for range resp {
+		}
+	}
+	require.Equal(t, []ollamago.ChatMessage{
+		{Role: "user", Content: "one"},
+		{Role: "assistant", Content: "re: one"},
+		{Role: "user", Content: "two"},
+		{Role: "assistant", Content: "re: two"},
+	}, conv.History())
+	calls := mock.CallsTo("GenerateChat")
+	require.Len(t, calls, 2)
+	req := calls[1].Request.(ollamago.ChatRequest)
+	require.Equal(t, "test", req.Model)
+	require.Equal(t, ollamago.ChatMessage{Role: "system", Content: "be brief"}, req.Messages[0])
+	require.Len(t, req.Messages, 4)
+}
+
+func TestConversationTrim(t *testing.T) {
+	oneTokenPerMessage := func(ollamago.ChatMessage) int { return 1 }
+	for name, tt := range map[string]struct {
+		trim ollamago.TrimStrategy
+		want int
+	}{
+		"drop-oldest":    {ollamago.DropOldest, 3},
+		"sliding-window": {ollamago.SlidingWindow(2), 2},
+	} {
+		t.Run(name, func(t *testing.T) {
+			mock := echoChat()
+			conv := &ollamago.Conversation{
+				Client: mock,
+				Model:  "test",
+				Budget: 3,
+				Trim:   tt.trim,
+				Tokens: oneTokenPerMessage,
+			}
+			for _, msg := range []string{"one", "two", "three"} {
+				resp, err := conv.Send(context.Background(), msg)
+				require.NoError(t, err)
+				for range resp {
+				}
+			}
+			calls := mock.CallsTo("GenerateChat")
+			req := calls[len(calls)-1].Request.(ollamago.ChatRequest)
+			require.Len(t, req.Messages, tt.want)
+			require.Equal(t, "three", req.Messages[len(req.Messages)-1].Content)
+		})
+	}
+}
+
+func TestConversationRollback(t *testing.T) {
+	conv := &ollamago.Conversation{Client: &ollamagotest.MockClient{}, Model: "test"}
+	_, err := conv.Send(context.Background(), "hi")
+	require.Error(t, err)
+	require.Empty(t, conv.History())
+}
+
+// appendingMemory appends a message to the conversation while evicting,
+// as a concurrent caller would.
+type appendingMemory struct {
+	conv *ollamago.Conversation
+}
+
+func (m *appendingMemory) Evict(ctx context.Context, evicted []ollamago.ChatMessage) error {
+	m.conv.Append(ollamago.ChatMessage{Role: "user", Content: "meanwhile"})
+	return nil
+}
+
+func (m *appendingMemory) Context() []ollamago.ChatMessage { return nil }
+
+func TestConversationEvictConcurrentAppend(t *testing.T) {
+	conv := &ollamago.Conversation{
+		Client: echoChat(),
+		Model:  "test",
+		Budget: 2,
+		Tokens: func(ollamago.ChatMessage) int { return 1 },
+	}
+	conv.Memory = &appendingMemory{conv: conv}
+	conv.Append(ollamago.ChatMessage{Role: "user", Content: "one"}, ollamago.ChatMessage{Role: "assistant", Content: "re: one"})
+	resp, err := conv.Send(context.Background(), "two")
+	require.NoError(t, err)
+	for range resp {
+	}
+	require.Equal(t, []ollamago.ChatMessage{
+		{Role: "assistant", Content: "re: one"},
+		{Role: "user", Content: "two"},
+		{Role: "user", Content: "meanwhile"},
+		{Role: "assistant", Content: "re: meanwhile"},
+	}, conv.History())
+}
+
+func TestConversationConcurrentSend(t *testing.T) {
+	conv := &ollamago.Conversation{Client: echoChat(), Model: "test"}
+	var wg sync.WaitGroup
+	for _, msg := range []string{"one", "two", "three", "four"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := conv.Send(context.Background(), msg)
+			require.NoError(t, err)
+			for range resp {
+			}
+		}()
+	}
+	wg.Wait()
+	require.Len(t, conv.History(), 8)
+}
@@ -0,0 +1,203 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DebugOption configures Debug.
+type DebugOption func(*debugConfig)
+
+type debugConfig struct {
+	imageLimit  int
+	credentials bool
+}
+
+// WithCredentials dumps the credentials of the requests, which are
+// otherwise redacted: the Authorization, Proxy-Authorization and Cookie
+// headers and the password in the URL.
+func WithCredentials() DebugOption {
+	return func(c *debugConfig) {
+		c.credentials = true
+	}
+}
+
+// WithTruncatedImages shortens the base64 images of the dumped requests
+// to n characters.
+func WithTruncatedImages(n int) DebugOption {
+	return func(c *debugConfig) {
+		c.imageLimit = n
+	}
+}
+
+// Debug returns an Interceptor writing each request to w as a curl
+// command line that reproduces it, followed by the raw response lines
+// prefixed with "< ". Credentials are redacted unless WithCredentials is
+// set.
+func Debug(w io.Writer, opts ...DebugOption) Interceptor {
+	var cfg debugConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	out := &debugWriter{w: w}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			var body []byte
+			if req.Body != nil {
+				var err error
+				body, err = io.ReadAll(req.Body)
+				req.Body.Close()
+				if err != nil {
+					return nil, err
+				}
+				req = req.Clone(req.Context())
+				req.Body = io.NopCloser(bytes.NewReader(body))
+				req.ContentLength = int64(len(body))
+			}
+			if cfg.imageLimit > 0 {
+				body = truncateImages(body, cfg.imageLimit)
+			}
+			out.write(curlCommand(req, body, cfg.credentials) + "\n")
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				out.write(fmt.Sprintf("< error: %v\n", err))
+				return nil, err
+			}
+			out.write(fmt.Sprintf("< %s %s\n", resp.Proto, resp.Status))
+			resp.Body = &debugBody{ReadCloser: resp.Body, out: out}
+			return resp, nil
+		})
+	}
+}
+
+// curlCommand renders req, whose body is passed separately, as a curl
+// command line, redacting its credentials unless asked to keep them.
+func curlCommand(req *http.Request, body []byte, credentials bool) string {
+	url := req.URL.Redacted()
+	if credentials {
+		url = req.URL.String()
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "curl -X %s %s", req.Method, shellQuote(url))
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range req.Header[k] {
+			if !credentials && credentialHeader(k) {
+				v = "<redacted>"
+			}
+			fmt.Fprintf(&sb, " -H %s", shellQuote(k+": "+v))
+		}
+	}
+	if len(body) > 0 {
+		fmt.Fprintf(&sb, " -d %s", shellQuote(string(body)))
+	}
+	return sb.String()
+}
+
+func credentialHeader(key string) bool {
+	switch http.CanonicalHeaderKey(key) {
+	case "Authorization", "Proxy-Authorization", "Cookie":
+		return true
+	}
+	return false
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func truncateImages(body []byte, limit int) []byte {
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return body
+	}
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, child := range v {
+				if images, ok := child.([]any); ok && k == "images" {
+					for i, img := range images {
+						if s, ok := img.(string); ok && len(s) > limit {
+							images[i] = s[:limit] + "..."
+						}
+					}
+					continue
+				}
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(v)
+	truncated, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return truncated
+}
+
+type debugWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (d *debugWriter) write(s string) {
+	d.mu.Lock()
+	io.WriteString(d.w, s)
+	d.mu.Unlock()
+}
+
+// debugBody dumps the lines of a response as they are read.
+type debugBody struct {
+	io.ReadCloser
+	out     *debugWriter
+	pending []byte
+}
+
+func (b *debugBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.pending = append(b.pending, p[:n]...)
+	for {
+		i := bytes.IndexByte(b.pending, '\n')
+		if i < 0 {
+			break
+		}
+		b.out.write("< " + string(b.pending[:i+1]))
+		b.pending = b.pending[i+1:]
+	}
+	if err != nil && len(b.pending) > 0 {
+		b.out.write("< " + string(b.pending) + "\n")
+		b.pending = nil
+	}
+	return n, err
+}
@@ -0,0 +1,106 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebug(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"response":"it's","done":false}` + "\n" + `{"response":"","done":true}` + "\n"))
+	}))
+	t.Cleanup(server.Close)
+	var buf bytes.Buffer
+	client := ollamago.Client{
+		BaseURL:      server.URL,
+		Interceptors: []ollamago.Interceptor{ollamago.Debug(&buf)},
+	}
+	respChan, err := client.GenerateCompletion(context.Background(), ollamago.CompletionRequest{Model: "test", Prompt: "what's up"})
+	require.NoError(t, err)
+	for range respChan {
+	}
+	require.Equal(t, "curl -X POST '"+server.URL+"/api/generate' -H 'Content-Type: application/json' "+
+		`-d '{"model":"test","prompt":"what'\''s up","options":{}}'`+"\n"+
+		"< HTTP/1.1 200 OK\n"+
+		`< {"response":"it's","done":false}`+"\n"+
+		`< {"response":"","done":true}`+"\n", buf.String())
+}
+
+func TestDebugTruncatedImages(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+	var buf bytes.Buffer
+	debug := ollamago.Debug(&buf, ollamago.WithTruncatedImages(4))
+	client := &http.Client{Transport: debug(http.DefaultTransport)}
+	body := `{"messages":[{"role":"user","images":["aGVsbG8gd29ybGQ="]}]}`
+	resp, err := client.Post(server.URL, "application/json", bytes.NewBufferString(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Contains(t, buf.String(), `-d '{"messages":[{"images":["aGVs..."],"role":"user"}]}'`)
+}
+
+func TestDebugRedactsCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+	dump := func(opts ...ollamago.DebugOption) string {
+		var buf bytes.Buffer
+		client := &http.Client{Transport: ollamago.Debug(&buf, opts...)(http.DefaultTransport)}
+		req, err := http.NewRequest(http.MethodGet, strings.Replace(server.URL, "://", "://user:secret@", 1), nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("Proxy-Authorization", "Basic c2VjcmV0")
+		req.Header.Set("Cookie", "session=secret")
+		req.Header.Set("X-Request-Id", "42")
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return buf.String()
+	}
+	redacted := dump()
+	require.NotContains(t, redacted, "secret")
+	require.Contains(t, redacted, "://user:xxxxx@")
+	require.Contains(t, redacted, `-H 'Authorization: <redacted>' -H 'Cookie: <redacted>' -H 'Proxy-Authorization: <redacted>' -H 'X-Request-Id: 42'`)
+	withCredentials := dump(ollamago.WithCredentials())
+	require.Contains(t, withCredentials, `-H 'Authorization: Bearer secret'`)
+	require.Contains(t, withCredentials, "://user:secret@")
+}
+
+func TestDebugLeavesRequestAlone(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+	body := io.NopCloser(strings.NewReader(`{}`))
+	req, err := http.NewRequest(http.MethodPost, server.URL, body)
+	require.NoError(t, err)
+	resp, err := ollamago.Debug(io.Discard)(http.DefaultTransport).RoundTrip(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.True(t, req.Body == body, "the request body is not replaced")
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+// SetModelDefaults sets the options used whenever the named model is
+// requested, such as its tuned context size and temperature. They take
+// precedence over DefaultOptions, and the options of the request over
+// both.
+func (c *Client) SetModelDefaults(model string, params ModelParameters) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.modelDefaults == nil {
+		c.modelDefaults = make(map[string]ModelParameters)
+	}
+	c.modelDefaults[withTag(model)] = params
+}
+
+func (c *Client) options(model string, params ModelParameters) ModelParameters {
+	c.mu.RLock()
+	modelDefaults, ok := c.modelDefaults[withTag(model)]
+	c.mu.RUnlock()
+	if ok {
+		params = params.withDefaults(modelDefaults)
+	}
+	return params.withDefaults(c.DefaultOptions)
+}
+
+func (c *Client) completionDefaults(req CompletionRequest) CompletionRequest {
+	req.Model = c.model(req.Model)
+	req.Options = c.options(req.Model, req.Options)
+	req.KeepAlive = c.keepAlive(req.KeepAlive)
+	return req
+}
+
+func (c *Client) chatDefaults(req ChatRequest) ChatRequest {
+	req.Model = c.model(req.Model)
+	req.Options = c.options(req.Model, req.Options)
+	req.KeepAlive = c.keepAlive(req.KeepAlive)
+	return req
+}
+
+func (c *Client) embedDefaults(req EmbedRequest) EmbedRequest {
+	req.Model = c.model(req.Model)
+	return req
+}
+
+func (c *Client) model(name string) string {
+	if name == "" {
+		return c.DefaultModel
+	}
+	return name
+}
+
+func (c *Client) keepAlive(keepAlive *Duration) *Duration {
+	if keepAlive != nil || c.DefaultKeepAlive == 0 {
+		return keepAlive
+	}
+	d := Duration(c.DefaultKeepAlive)
+	return &d
+}
@@ -0,0 +1,89 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientDefaults(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "llama3.2"}, ollamatest.Model{Name: "other"})
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+	client.DefaultModel = "llama3.2"
+	client.DefaultOptions = ollamago.ModelParameters{
+		Temperature: ollamago.Ptr(0.2),
+		NumCtx:      ollamago.Ptr(8192),
+		Extra:       map[string]any{"num_gqa": 8},
+	}
+	client.DefaultKeepAlive = 10 * time.Minute
+	ctx := context.Background()
+
+	respChan, err := client.GenerateChat(ctx, ollamago.ChatRequest{
+		Messages: []ollamago.ChatMessage{{Role: "user", Content: "hi"}},
+	})
+	require.NoError(t, err)
+	for range respChan {
+	}
+	respChan, err = client.GenerateChat(ctx, ollamago.ChatRequest{
+		Model:     "other",
+		Messages:  []ollamago.ChatMessage{{Role: "user", Content: "hi"}},
+		Options:   ollamago.ModelParameters{Temperature: ollamago.Ptr(0.0)},
+		KeepAlive: new(ollamago.Duration),
+	})
+	require.NoError(t, err)
+	for range respChan {
+	}
+	_, err = client.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Input: []string{"hi"}})
+	require.NoError(t, err)
+
+	requests := srv.Requests()
+	require.Len(t, requests, 3)
+	require.JSONEq(t, `{"model":"llama3.2","messages":[{"role":"user","content":"hi"}],"options":{"temperature":0.2,"num_ctx":8192,"num_gqa":8},"keep_alive":"10m0s"}`, string(requests[0].Body))
+	require.JSONEq(t, `{"model":"other","messages":[{"role":"user","content":"hi"}],"options":{"temperature":0,"num_ctx":8192,"num_gqa":8},"keep_alive":"0s"}`, string(requests[1].Body), "the request overrides the defaults")
+	require.JSONEq(t, `{"model":"llama3.2","input":["hi"]}`, string(requests[2].Body))
+}
+
+func TestSetModelDefaults(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "llama3.2:latest"}, ollamatest.Model{Name: "qwen2.5:7b"})
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+	client.DefaultOptions = ollamago.ModelParameters{Temperature: ollamago.Ptr(0.7), NumCtx: ollamago.Ptr(2048)}
+	client.SetModelDefaults("llama3.2", ollamago.ModelParameters{NumCtx: ollamago.Ptr(8192)})
+	client.SetModelDefaults("qwen2.5:7b", ollamago.ModelParameters{Temperature: ollamago.Ptr(0.1)})
+	ctx := context.Background()
+	complete := func(model string, options ollamago.ModelParameters) {
+		t.Helper()
+		respChan, err := client.GenerateCompletion(ctx, ollamago.CompletionRequest{Model: model, Prompt: "hi", Options: options})
+		require.NoError(t, err)
+		for range respChan {
+		}
+	}
+	complete("llama3.2:latest", ollamago.ModelParameters{})
+	complete("qwen2.5:7b", ollamago.ModelParameters{})
+	complete("qwen2.5:7b", ollamago.ModelParameters{Temperature: ollamago.Ptr(1.0)})
+
+	requests := srv.Requests()
+	require.Len(t, requests, 3)
+	require.JSONEq(t, `{"model":"llama3.2:latest","prompt":"hi","options":{"temperature":0.7,"num_ctx":8192}}`, string(requests[0].Body))
+	require.JSONEq(t, `{"model":"qwen2.5:7b","prompt":"hi","options":{"temperature":0.1,"num_ctx":2048}}`, string(requests[1].Body))
+	require.JSONEq(t, `{"model":"qwen2.5:7b","prompt":"hi","options":{"temperature":1,"num_ctx":2048}}`, string(requests[2].Body))
+}
@@ -0,0 +1,195 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DeltaUnit is the boundary the deltas of Deltas end at.
+type DeltaUnit int
+
+const (
+	// DeltaChunk ends deltas anywhere, when Deltas.Window elapses.
+	DeltaChunk DeltaUnit = iota
+
+	// DeltaWord ends deltas after whitespace.
+	DeltaWord
+
+	// DeltaSentence ends deltas after a line break or a sentence ending
+	// punctuation followed by whitespace.
+	DeltaSentence
+)
+
+// Deltas wraps an API, coalescing the token-sized chunks of chats and
+// completions into larger deltas, so that frontends repainting per event
+// repaint less often. A delta is sent when its text reaches a Unit
+// boundary, when Window elapses since its first chunk, or when the
+// response ends. Errors and the final chunk are never held back.
+type Deltas struct {
+	API
+
+	// Unit is the boundary deltas end at.
+	Unit DeltaUnit
+
+	// Window caps the time a delta is held back waiting for a boundary.
+	// If zero, it is held until the next boundary or the end of the
+	// response.
+	Window time.Duration
+}
+
+var _ API = (*Deltas)(nil)
+
+// GenerateChat coalesces the chunks of the reply.
+func (d *Deltas) GenerateChat(ctx context.Context, req ChatRequest) (<-chan ChatResponse, error) {
+	resp, err := d.API.GenerateChat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return coalesceDeltas(ctx, d, resp, mergeChat, func(r ChatResponse) string {
+		return r.Message.Content
+	}, func(r ChatResponse, n int) (ChatResponse, ChatResponse) {
+		rest := ChatResponse{Model: r.Model, Message: ChatMessage{Role: r.Message.Role, Content: r.Message.Content[n:]}}
+		r.Message.Content = r.Message.Content[:n]
+		return r, rest
+	}), nil
+}
+
+// GenerateCompletion coalesces the chunks of the generated text.
+func (d *Deltas) GenerateCompletion(ctx context.Context, req CompletionRequest) (<-chan CompletionResponse, error) {
+	resp, err := d.API.GenerateCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return coalesceDeltas(ctx, d, resp, mergeCompletion, func(r CompletionResponse) string {
+		return r.Response
+	}, func(r CompletionResponse, n int) (CompletionResponse, CompletionResponse) {
+		rest := CompletionResponse{Model: r.Model, Response: r.Response[n:]}
+		r.Response = r.Response[:n]
+		return r, rest
+	}), nil
+}
+
+// boundary returns the length of the longest prefix of text ending at a
+// boundary of the unit, or zero if there is none.
+func (d *Deltas) boundary(text string) int {
+	switch d.Unit {
+	case DeltaWord:
+		i := strings.LastIndexFunc(text, unicode.IsSpace)
+		if i < 0 {
+			return 0
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		return i + size
+	case DeltaSentence:
+		for i := len(text) - 1; i >= 0; i-- {
+			switch {
+			case text[i] == '\n':
+				return i + 1
+			case i > 0 && (text[i] == ' ' || text[i] == '\t') && strings.IndexByte(".!?", text[i-1]) >= 0:
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+// coalesceDeltas relays the chunks of resp as deltas. merge appends a
+// chunk to a delta, reporting false if either ends the response; split
+// cuts a delta after n bytes of its text.
+func coalesceDeltas[T any](ctx context.Context, d *Deltas, resp <-chan T, merge func(a, b T) (T, bool), text func(T) string, split func(r T, n int) (T, T)) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		defer func() {
+			for range resp {
+			}
+		}()
+		var (
+			held    T
+			holding bool
+			timer   *time.Timer
+			expired <-chan time.Time
+		)
+		release := func() bool {
+			if timer != nil {
+				timer.Stop()
+				timer, expired = nil, nil
+			}
+			if !holding {
+				return true
+			}
+			holding = false
+			return send(ctx, out, held)
+		}
+		// An empty chunk merges into anything but the end of a response.
+		var empty T
+		for {
+			select {
+			case r, ok := <-resp:
+				if !ok {
+					release()
+					return
+				}
+				if holding {
+					merged, ok := merge(held, r)
+					if !ok {
+						if !release() || !send(ctx, out, r) {
+							return
+						}
+						continue
+					}
+					held = merged
+				} else {
+					if _, ok := merge(r, empty); !ok {
+						if !send(ctx, out, r) {
+							return
+						}
+						continue
+					}
+					held, holding = r, true
+					if d.Window > 0 {
+						timer = time.NewTimer(d.Window)
+						expired = timer.C
+					}
+				}
+				content := text(held)
+				if n := d.boundary(content); n > 0 {
+					if n == len(content) {
+						if !release() {
+							return
+						}
+						continue
+					}
+					var delta T
+					delta, held = split(held, n)
+					if !send(ctx, out, delta) {
+						return
+					}
+				}
+			case <-expired:
+				timer, expired = nil, nil
+				if !release() {
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
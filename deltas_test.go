@@ -0,0 +1,56 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeltas(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "llama3.2", Chunks: []string{"He", "llo wo", "rld. Ho", "w are", " you", "?"}})
+	t.Cleanup(srv.Close)
+	chat := func(d *ollamago.Deltas) []string {
+		t.Helper()
+		d.API = srv.Client()
+		resp, err := d.GenerateChat(context.Background(), ollamago.ChatRequest{Model: "llama3.2", Messages: []ollamago.ChatMessage{ollamago.UserMessage("hi")}, Stream: true})
+		require.NoError(t, err)
+		var deltas []string
+		done := false
+		for r := range resp {
+			require.NoError(t, r.Error)
+			if r.Done {
+				done = true
+			}
+			if r.Message.Content != "" {
+				deltas = append(deltas, r.Message.Content)
+			}
+		}
+		require.True(t, done)
+		return deltas
+	}
+	require.Equal(t, []string{"Hello ", "world. ", "How ", "are ", "you?"}, chat(&ollamago.Deltas{Unit: ollamago.DeltaWord}))
+	require.Equal(t, []string{"Hello world. ", "How are you?"}, chat(&ollamago.Deltas{Unit: ollamago.DeltaSentence}))
+	require.Equal(t, []string{"Hello world. How are you?"}, chat(&ollamago.Deltas{}))
+
+	srv.SetChunkDelay(20 * time.Millisecond)
+	deltas := chat(&ollamago.Deltas{Unit: ollamago.DeltaSentence, Window: 30 * time.Millisecond})
+	require.Greater(t, len(deltas), 2)
+}
@@ -0,0 +1,272 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eval
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cirello.io/ollamago"
+)
+
+// Variant is a prompt under test, rendering the messages sent for an
+// input.
+type Variant struct {
+	Name   string
+	Render func(input string) ([]ollamago.ChatMessage, error)
+}
+
+// Comparator picks the best of the outputs generated for an input, one
+// per variant, returning its index, or -1 for a tie.
+type Comparator func(ctx context.Context, input string, outputs []string) (int, error)
+
+// ScoreWith returns a comparator picking the output with the highest
+// score. Equal best scores are a tie.
+func ScoreWith(score func(ctx context.Context, input, output string) (float64, error)) Comparator {
+	return func(ctx context.Context, input string, outputs []string) (int, error) {
+		best, bestScore := -1, 0.0
+		for i, out := range outputs {
+			s, err := score(ctx, input, out)
+			if err != nil {
+				return 0, err
+			}
+			switch {
+			case i == 0 || s > bestScore:
+				best, bestScore = i, s
+			case s == bestScore:
+				best = -1
+			}
+		}
+		return best, nil
+	}
+}
+
+// JudgeComparator returns a comparator having model pick the output that
+// best meets the criteria.
+func JudgeComparator(client ollamago.API, model, criteria string) Comparator {
+	return func(ctx context.Context, input string, outputs []string) (int, error) {
+		var prompt strings.Builder
+		fmt.Fprintf(&prompt, "Criteria: %s\n\nInput:\n%s\n", criteria, input)
+		for i, out := range outputs {
+			fmt.Fprintf(&prompt, "\nOutput %d:\n%s\n", i+1, out)
+		}
+		choice, err := ollamago.ChatInto[struct {
+			Best int `json:"best" description:"the number of the best output, or 0 if they are equally good"`
+		}](ctx, client, ollamago.ChatRequest{
+			Model: model,
+			Messages: []ollamago.ChatMessage{
+				ollamago.SystemMessage("You compare the outputs written for the same input and pick the one that best meets the criteria."),
+				ollamago.UserMessage(prompt.String()),
+			},
+			Options: ollamago.ModelParameters{Temperature: ollamago.Ptr(0.0)},
+		}, ollamago.WithJSONRepair(), ollamago.WithRetries(1))
+		if err != nil {
+			return 0, fmt.Errorf("cannot judge outputs: %w", err)
+		}
+		if choice.Best < 0 || choice.Best > len(outputs) {
+			return 0, fmt.Errorf("cannot judge outputs: %w: no output %d", ollamago.ErrInvalidOutput, choice.Best)
+		}
+		return choice.Best - 1, nil
+	}
+}
+
+// ABTest runs prompt variants over a dataset and compares their outputs.
+type ABTest struct {
+	Client   ollamago.API
+	Model    string
+	Variants []Variant
+
+	// Inputs is the dataset.
+	Inputs []string
+
+	// Seeds are the seeds each input is run with, the same for every
+	// variant so that they are compared on equal terms. If empty, a
+	// single run with seed 1 is made.
+	Seeds []int
+
+	// Compare picks the best output of each run.
+	Compare Comparator
+
+	// Options are the model parameters of the requests; their Seed is
+	// overridden.
+	Options ollamago.ModelParameters
+
+	// Concurrency is the number of runs made at once. If zero, runs are
+	// made one at a time.
+	Concurrency int
+}
+
+// ABResult is the outcome of a run: an input with a seed.
+type ABResult struct {
+	Input   string   `json:"input"`
+	Seed    int      `json:"seed"`
+	Outputs []string `json:"outputs"`
+
+	// Winner is the index of the best variant, or -1 for a tie.
+	Winner int `json:"winner"`
+
+	// Error tells why the run failed; it then counts as a tie.
+	Error string `json:"error,omitempty"`
+}
+
+// ABReport holds the results of an ABTest, in input and seed order.
+type ABReport struct {
+	Variants []string   `json:"variants"`
+	Results  []ABResult `json:"results"`
+}
+
+// Run runs every variant over every input with every seed. Failed runs are
+// recorded in the report; Run only fails if ctx is done.
+func (t *ABTest) Run(ctx context.Context) (*ABReport, error) {
+	if len(t.Variants) < 2 {
+		return nil, errors.New("an A/B test needs at least two variants")
+	}
+	if t.Compare == nil {
+		return nil, errors.New("an A/B test needs a comparator")
+	}
+	seeds := t.Seeds
+	if len(seeds) == 0 {
+		seeds = []int{1}
+	}
+	report := &ABReport{Results: make([]ABResult, len(t.Inputs)*len(seeds))}
+	for _, v := range t.Variants {
+		report.Variants = append(report.Variants, v.Name)
+	}
+	sem := make(chan struct{}, max(t.Concurrency, 1))
+	var wg sync.WaitGroup
+	for i, input := range t.Inputs {
+		for j, seed := range seeds {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				report.Results[i*len(seeds)+j] = t.run(ctx, input, seed)
+			}()
+		}
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (t *ABTest) run(ctx context.Context, input string, seed int) ABResult {
+	res := ABResult{Input: input, Seed: seed, Winner: -1}
+	fail := func(err error) ABResult {
+		res.Error = err.Error()
+		return res
+	}
+	for _, v := range t.Variants {
+		messages, err := v.Render(input)
+		if err != nil {
+			return fail(fmt.Errorf("%s: %w", v.Name, err))
+		}
+		options := t.Options
+		options.Seed = ollamago.Ptr(seed)
+		resp, err := t.Client.GenerateChat(ctx, ollamago.ChatRequest{Model: t.Model, Messages: messages, Stream: true, Options: options})
+		if err != nil {
+			return fail(fmt.Errorf("%s: %w", v.Name, err))
+		}
+		var (
+			out  strings.Builder
+			errs error
+		)
+		for r := range resp {
+			if r.Error != nil && errs == nil {
+				errs = r.Error
+			}
+			out.WriteString(r.Message.Content)
+		}
+		if errs != nil {
+			return fail(fmt.Errorf("%s: %w", v.Name, errs))
+		}
+		res.Outputs = append(res.Outputs, out.String())
+	}
+	winner, err := t.Compare(ctx, input, res.Outputs)
+	if err != nil {
+		return fail(err)
+	}
+	res.Winner = winner
+	return res
+}
+
+// WinRates returns the share of the runs won by each variant, ties being
+// shared equally.
+func (r *ABReport) WinRates() []float64 {
+	rates := make([]float64, len(r.Variants))
+	if len(r.Results) == 0 {
+		return rates
+	}
+	for _, res := range r.Results {
+		if res.Winner < 0 || res.Winner >= len(rates) {
+			for i := range rates {
+				rates[i] += 1 / float64(len(rates))
+			}
+			continue
+		}
+		rates[res.Winner]++
+	}
+	for i := range rates {
+		rates[i] /= float64(len(r.Results))
+	}
+	return rates
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *ABReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes a row per run, with the input, the seed, the name of the
+// winner (empty for ties), the error and the output of each variant.
+func (r *ABReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	header := []string{"input", "seed", "winner", "error"}
+	for _, v := range r.Variants {
+		header = append(header, "output "+v)
+	}
+	cw.Write(header)
+	for _, res := range r.Results {
+		var winner string
+		if res.Winner >= 0 && res.Winner < len(r.Variants) {
+			winner = r.Variants[res.Winner]
+		}
+		row := []string{res.Input, strconv.Itoa(res.Seed), winner, res.Error}
+		row = append(row, res.Outputs...)
+		for len(row) < len(header) {
+			row = append(row, "")
+		}
+		cw.Write(row)
+	}
+	cw.Flush()
+	return cw.Error()
+}
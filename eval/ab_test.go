@@ -0,0 +1,105 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eval_test
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/eval"
+	"cirello.io/ollamago/ollamagotest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestABTest(t *testing.T) {
+	// The fake model echoes the system prompt, the input and the seed.
+	mock := &ollamagotest.MockClient{
+		GenerateChatFunc: func(ctx context.Context, req ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error) {
+			var content string
+			if req.Model == "judge" {
+				content = `{"best":0}`
+				if strings.Contains(req.Messages[1].Content, "Output 2:\nlong") {
+					content = `{"best":2}`
+				}
+			} else {
+				content = fmt.Sprintf("%s %s %d", req.Messages[0].Content, req.Messages[1].Content, *req.Options.Seed)
+			}
+			return ollamagotest.Stream(ollamago.ChatResponse{
+				Message: ollamago.ChatMessage{Role: ollamago.RoleAssistant, Content: content},
+				Done:    true,
+			}), nil
+		},
+	}
+	variant := func(name, system string) eval.Variant {
+		return eval.Variant{Name: name, Render: func(input string) ([]ollamago.ChatMessage, error) {
+			return []ollamago.ChatMessage{ollamago.SystemMessage(system), ollamago.UserMessage(input)}, nil
+		}}
+	}
+	test := &eval.ABTest{
+		Client:      mock,
+		Model:       "test",
+		Variants:    []eval.Variant{variant("short", "s"), variant("long", "long")},
+		Inputs:      []string{"a", "bb"},
+		Seeds:       []int{7, 8},
+		Concurrency: 3,
+		Compare: eval.ScoreWith(func(ctx context.Context, input, output string) (float64, error) {
+			if input == "bb" {
+				return 1, nil
+			}
+			return float64(len(output)), nil
+		}),
+	}
+	report, err := test.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{"short", "long"}, report.Variants)
+	require.Len(t, report.Results, 4)
+	require.Equal(t, eval.ABResult{Input: "a", Seed: 8, Outputs: []string{"s a 8", "long a 8"}, Winner: 1}, report.Results[1])
+	require.Equal(t, -1, report.Results[2].Winner, "equal scores are a tie")
+	require.Equal(t, []float64{0.25, 0.75}, report.WinRates())
+
+	var js strings.Builder
+	require.NoError(t, report.WriteJSON(&js))
+	var decoded eval.ABReport
+	require.NoError(t, json.Unmarshal([]byte(js.String()), &decoded))
+	require.Equal(t, *report, decoded)
+
+	var cs strings.Builder
+	require.NoError(t, report.WriteCSV(&cs))
+	rows, err := csv.NewReader(strings.NewReader(cs.String())).ReadAll()
+	require.NoError(t, err)
+	require.Equal(t, []string{"input", "seed", "winner", "error", "output short", "output long"}, rows[0])
+	require.Equal(t, []string{"a", "7", "long", "", "s a 7", "long a 7"}, rows[1])
+	require.Equal(t, "", rows[3][2])
+
+	test.Compare = eval.JudgeComparator(mock, "judge", "the most detailed")
+	test.Inputs = []string{"a"}
+	test.Seeds = nil
+	report, err = test.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Results[0].Winner)
+
+	test.Variants = append(test.Variants, eval.Variant{Name: "broken", Render: func(string) ([]ollamago.ChatMessage, error) {
+		return nil, fmt.Errorf("cannot render")
+	}})
+	report, err = test.Run(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "broken: cannot render", report.Results[0].Error)
+	require.Equal(t, []float64{1.0 / 3, 1.0 / 3, 1.0 / 3}, report.WinRates())
+}
@@ -0,0 +1,336 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package eval evaluates models against a suite of test cases, scoring
+// their outputs with matchers or with a judge model, so that model
+// upgrades can be gated in CI:
+//
+//	report, err := suite.Run(ctx, "llama3.2", "qwen2.5")
+//	if err != nil || !report.Passed(0.9) {
+//		t.Fatal(report)
+//	}
+//
+// ABTest compares prompt variants over a dataset instead.
+package eval
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"cirello.io/ollamago"
+)
+
+// Case is a test case: a conversation and the checks its output must pass.
+type Case struct {
+	Name string
+
+	// Input is sent as a user message after Messages.
+	Input    string
+	Messages []ollamago.ChatMessage
+
+	Checks []Checker
+}
+
+func (c Case) messages() []ollamago.ChatMessage {
+	messages := append([]ollamago.ChatMessage(nil), c.Messages...)
+	if c.Input != "" {
+		messages = append(messages, ollamago.UserMessage(c.Input))
+	}
+	return messages
+}
+
+// Score is the outcome of a check.
+type Score struct {
+	Check string
+
+	// Value is between 0 and 1.
+	Value float64
+	Pass  bool
+
+	// Reason explains the score, if the check gives one.
+	Reason string
+}
+
+// Checker scores the output of a case.
+type Checker interface {
+	Name() string
+	Check(ctx context.Context, c Case, output string) (Score, error)
+}
+
+type matcher struct {
+	name  string
+	match func(output string) bool
+}
+
+func (m matcher) Name() string {
+	return m.name
+}
+
+func (m matcher) Check(ctx context.Context, c Case, output string) (Score, error) {
+	if m.match(output) {
+		return Score{Check: m.name, Value: 1, Pass: true}, nil
+	}
+	return Score{Check: m.name}, nil
+}
+
+// Exact passes outputs equal to want, ignoring surrounding spaces.
+func Exact(want string) Checker {
+	return matcher{fmt.Sprintf("exact %q", want), func(output string) bool {
+		return strings.TrimSpace(output) == strings.TrimSpace(want)
+	}}
+}
+
+// Contains passes outputs containing want, ignoring case.
+func Contains(want string) Checker {
+	return matcher{fmt.Sprintf("contains %q", want), func(output string) bool {
+		return strings.Contains(strings.ToLower(output), strings.ToLower(want))
+	}}
+}
+
+// Regexp passes outputs matching the regular expression. It panics if the
+// expression does not compile.
+func Regexp(expr string) Checker {
+	re := regexp.MustCompile(expr)
+	return matcher{fmt.Sprintf("regexp %q", expr), re.MatchString}
+}
+
+type judge struct {
+	client    ollamago.API
+	model     string
+	criteria  string
+	threshold float64
+}
+
+// Judge has model grade the output against the criteria, such as "the
+// answer is polite and mentions the refund policy", on a scale from 0 to
+// 10. The output passes if its normalized grade reaches threshold.
+func Judge(client ollamago.API, model, criteria string, threshold float64) Checker {
+	return judge{client: client, model: model, criteria: criteria, threshold: threshold}
+}
+
+func (j judge) Name() string {
+	return "judge: " + j.criteria
+}
+
+func (j judge) Check(ctx context.Context, c Case, output string) (Score, error) {
+	var transcript strings.Builder
+	for _, m := range c.messages() {
+		fmt.Fprintf(&transcript, "%s: %s\n", m.Role, m.Content)
+	}
+	grade, err := ollamago.ChatInto[struct {
+		Reason string `json:"reason" description:"a short justification of the grade"`
+		Grade  int    `json:"grade" description:"the grade from 0 to 10"`
+	}](ctx, j.client, ollamago.ChatRequest{
+		Model: j.model,
+		Messages: []ollamago.ChatMessage{
+			ollamago.SystemMessage("You grade the answers of an assistant. Grade how well the answer meets the criteria on a scale from 0 (not at all) to 10 (fully)."),
+			ollamago.UserMessage("Criteria: " + j.criteria + "\n\nConversation:\n" + transcript.String() + "\nAnswer:\n" + output),
+		},
+		Options: ollamago.ModelParameters{Temperature: ollamago.Ptr(0.0)},
+	}, ollamago.WithJSONRepair(), ollamago.WithRetries(1))
+	if err != nil {
+		return Score{}, fmt.Errorf("cannot judge output: %w", err)
+	}
+	value := float64(min(max(grade.Grade, 0), 10)) / 10
+	return Score{Check: j.Name(), Value: value, Pass: value >= j.threshold, Reason: grade.Reason}, nil
+}
+
+// Result is the outcome of a case for a model.
+type Result struct {
+	Case     string
+	Output   string
+	Scores   []Score
+	Duration time.Duration
+
+	// Error is set if the output could not be generated or checked; the
+	// case then fails.
+	Error error
+}
+
+// Pass reports whether the output passed every check.
+func (r Result) Pass() bool {
+	if r.Error != nil {
+		return false
+	}
+	for _, s := range r.Scores {
+		if !s.Pass {
+			return false
+		}
+	}
+	return true
+}
+
+// ModelReport holds the results of a model, in case order.
+type ModelReport struct {
+	Model   string
+	Results []Result
+}
+
+// PassRate returns the share of the cases passed.
+func (r ModelReport) PassRate() float64 {
+	if len(r.Results) == 0 {
+		return 0
+	}
+	passed := 0
+	for _, res := range r.Results {
+		if res.Pass() {
+			passed++
+		}
+	}
+	return float64(passed) / float64(len(r.Results))
+}
+
+// MeanScore returns the mean value of all the scores, failed cases
+// counting as 0.
+func (r ModelReport) MeanScore() float64 {
+	var sum float64
+	n := 0
+	for _, res := range r.Results {
+		if res.Error != nil || len(res.Scores) == 0 {
+			n++
+			continue
+		}
+		for _, s := range res.Scores {
+			sum += s.Value
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return sum / float64(n)
+}
+
+// Report holds the results of a suite, in model order.
+type Report struct {
+	Models []ModelReport
+}
+
+// Passed reports whether every model passed at least the given share of
+// the cases.
+func (r *Report) Passed(minPassRate float64) bool {
+	for _, m := range r.Models {
+		if m.PassRate() < minPassRate {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Report) String() string {
+	var b strings.Builder
+	for _, m := range r.Models {
+		fmt.Fprintf(&b, "%s: %.0f%% passed, mean score %.2f\n", m.Model, 100*m.PassRate(), m.MeanScore())
+		for _, res := range m.Results {
+			if res.Pass() {
+				continue
+			}
+			if res.Error != nil {
+				fmt.Fprintf(&b, "\tFAIL %s: %v\n", res.Case, res.Error)
+				continue
+			}
+			for _, s := range res.Scores {
+				if !s.Pass {
+					fmt.Fprintf(&b, "\tFAIL %s: %s %s\n", res.Case, s.Check, s.Reason)
+				}
+			}
+		}
+	}
+	return b.String()
+}
+
+// Suite is a set of cases run against models.
+type Suite struct {
+	Client ollamago.API
+	Cases  []Case
+
+	// Options are the model parameters of the evaluated requests.
+	Options ollamago.ModelParameters
+
+	// Concurrency is the number of cases run at once. If zero, cases run
+	// one at a time.
+	Concurrency int
+}
+
+// Run runs every case against every model. Failures of single cases are
+// recorded in the report; Run only fails if ctx is done.
+func (s *Suite) Run(ctx context.Context, models ...string) (*Report, error) {
+	report := &Report{}
+	for _, model := range models {
+		results := make([]Result, len(s.Cases))
+		sem := make(chan struct{}, max(s.Concurrency, 1))
+		var wg sync.WaitGroup
+		for i, c := range s.Cases {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				results[i] = s.runCase(ctx, model, c)
+			}()
+		}
+		wg.Wait()
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		report.Models = append(report.Models, ModelReport{Model: model, Results: results})
+	}
+	return report, nil
+}
+
+func (s *Suite) runCase(ctx context.Context, model string, c Case) Result {
+	res := Result{Case: c.Name}
+	start := time.Now()
+	resp, err := s.Client.GenerateChat(ctx, ollamago.ChatRequest{
+		Model:    model,
+		Messages: c.messages(),
+		Stream:   true,
+		Options:  s.Options,
+	})
+	if err != nil {
+		res.Error = err
+		return res
+	}
+	var out strings.Builder
+	for r := range resp {
+		if r.Error != nil && res.Error == nil {
+			res.Error = r.Error
+		}
+		out.WriteString(r.Message.Content)
+	}
+	res.Duration = time.Since(start)
+	res.Output = out.String()
+	if res.Error != nil {
+		return res
+	}
+	for _, check := range c.Checks {
+		score, err := check.Check(ctx, c, res.Output)
+		if err != nil {
+			res.Error = fmt.Errorf("%s: %w", check.Name(), err)
+			return res
+		}
+		res.Scores = append(res.Scores, score)
+	}
+	return res
+}
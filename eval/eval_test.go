@@ -0,0 +1,97 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package eval_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/eval"
+	"cirello.io/ollamago/ollamagotest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuite(t *testing.T) {
+	answers := map[string]string{
+		"good": "The capital of France is Paris.",
+		"bad":  "I think it is Lyon.",
+	}
+	mock := &ollamagotest.MockClient{
+		GenerateChatFunc: func(ctx context.Context, req ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error) {
+			content := answers[req.Model]
+			if req.Model == "judge" {
+				content = `{"reason":"mentions Lyon","grade":3}`
+				if strings.Contains(req.Messages[1].Content, "Paris") {
+					content = `{"reason":"correct","grade":9}`
+				}
+			}
+			return ollamagotest.Stream(ollamago.ChatResponse{
+				Message: ollamago.ChatMessage{Role: ollamago.RoleAssistant, Content: content},
+				Done:    true,
+			}), nil
+		},
+	}
+	suite := &eval.Suite{
+		Client:      mock,
+		Concurrency: 2,
+		Cases: []eval.Case{
+			{
+				Name:   "capital",
+				Input:  "What is the capital of France?",
+				Checks: []eval.Checker{eval.Contains("paris"), eval.Regexp(`\.$`)},
+			},
+			{
+				Name:   "judged",
+				Input:  "Capital of France?",
+				Checks: []eval.Checker{eval.Judge(mock, "judge", "the answer is correct", 0.7)},
+			},
+			{
+				Name:   "exact",
+				Input:  "Same again",
+				Checks: []eval.Checker{eval.Exact("The capital of France is Paris.")},
+			},
+		},
+	}
+	report, err := suite.Run(context.Background(), "good", "bad")
+	require.NoError(t, err)
+	require.Len(t, report.Models, 2)
+
+	good, bad := report.Models[0], report.Models[1]
+	require.Equal(t, "good", good.Model)
+	require.Equal(t, 1.0, good.PassRate())
+	require.InDelta(t, (1+1+0.9+1)/4.0, good.MeanScore(), 1e-9)
+	require.Equal(t, "correct", good.Results[1].Scores[0].Reason)
+
+	require.Equal(t, 0.0, bad.PassRate())
+	require.False(t, bad.Results[0].Scores[0].Pass)
+	require.True(t, bad.Results[0].Scores[1].Pass)
+	require.Equal(t, 0.3, bad.Results[1].Scores[0].Value)
+
+	require.False(t, report.Passed(0.5))
+	require.Contains(t, report.String(), "bad: 0% passed")
+	require.Contains(t, report.String(), "FAIL judged: judge: the answer is correct mentions Lyon")
+
+	report, err = suite.Run(context.Background(), "good")
+	require.NoError(t, err)
+	require.True(t, report.Passed(1))
+
+	failing := &eval.Suite{Client: &ollamagotest.MockClient{}, Cases: suite.Cases[:1]}
+	report, err = failing.Run(context.Background(), "good")
+	require.NoError(t, err)
+	require.Error(t, report.Models[0].Results[0].Error)
+	require.Equal(t, 0.0, report.Models[0].MeanScore())
+}
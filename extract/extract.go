@@ -0,0 +1,138 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package extract pulls structured data, such as dates, people, amounts
+// and facts, out of free text with ollamago.ChatInto. Each helper comes
+// with its schema and prompt; Extract builds new ones from any type.
+package extract
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cirello.io/ollamago"
+)
+
+// list is the structured output of an extraction. Models follow object
+// schemas more reliably than top-level arrays.
+type list[T any] struct {
+	Items []T `json:"items"`
+}
+
+// Validate validates the items implementing ollamago.Validator.
+func (l list[T]) Validate() error {
+	for i := range l.Items {
+		if v, ok := any(&l.Items[i]).(ollamago.Validator); ok {
+			if err := v.Validate(); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Extract asks model to list the items of type T found in text, following
+// instructions. The schema of T, with its description tags, tells the
+// model what each field holds; items implementing ollamago.Validator are
+// validated.
+func Extract[T any](ctx context.Context, client ollamago.API, model, instructions, text string, opts ...ollamago.StructuredOption) ([]T, error) {
+	l, err := ollamago.ChatInto[list[T]](ctx, client, ollamago.ChatRequest{
+		Model: model,
+		Messages: []ollamago.ChatMessage{
+			ollamago.SystemMessage(instructions + " Only list what the text states; reply with an empty list if there is nothing to extract."),
+			ollamago.UserMessage(text),
+		},
+		Options: ollamago.ModelParameters{Temperature: ollamago.Ptr(0.0)},
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return l.Items, nil
+}
+
+// Date is a date mentioned in a text.
+type Date struct {
+	Text string `json:"text" description:"the date as written in the text"`
+	Date string `json:"date" description:"the date in the YYYY-MM-DD format"`
+}
+
+// Validate checks the format of the date.
+func (d *Date) Validate() error {
+	_, err := time.Parse(time.DateOnly, d.Date)
+	return err
+}
+
+// Time returns the date at midnight UTC.
+func (d Date) Time() time.Time {
+	t, _ := time.Parse(time.DateOnly, d.Date)
+	return t
+}
+
+// Dates extracts the calendar dates mentioned in text. Relative dates,
+// such as "next Monday", are resolved against now.
+func Dates(ctx context.Context, client ollamago.API, model, text string, now time.Time, opts ...ollamago.StructuredOption) ([]Date, error) {
+	return Extract[Date](ctx, client, model,
+		"List the calendar dates mentioned in the text. Today is "+now.Format("Monday, 2006-01-02")+".",
+		text, opts...)
+}
+
+// Person is a person mentioned in a text.
+type Person struct {
+	Name string `json:"name" description:"the full name, as written in the text"`
+	Role string `json:"role,omitempty" description:"the title, occupation or relation to others, if stated"`
+}
+
+// People extracts the people mentioned in text.
+func People(ctx context.Context, client ollamago.API, model, text string, opts ...ollamago.StructuredOption) ([]Person, error) {
+	return Extract[Person](ctx, client, model,
+		"List the people mentioned in the text, once each, with their role if the text states it.",
+		text, opts...)
+}
+
+// Amount is an amount of money mentioned in a text.
+type Amount struct {
+	Text     string  `json:"text" description:"the amount as written in the text"`
+	Value    float64 `json:"value" description:"the amount as a number"`
+	Currency string  `json:"currency" description:"the ISO 4217 currency code, such as USD"`
+}
+
+// Validate checks the currency code.
+func (a *Amount) Validate() error {
+	if len(a.Currency) != 3 {
+		return fmt.Errorf("invalid currency code %q", a.Currency)
+	}
+	return nil
+}
+
+// Amounts extracts the amounts of money mentioned in text.
+func Amounts(ctx context.Context, client ollamago.API, model, text string, opts ...ollamago.StructuredOption) ([]Amount, error) {
+	return Extract[Amount](ctx, client, model,
+		"List the amounts of money mentioned in the text.",
+		text, opts...)
+}
+
+// Fact is a key-value fact stated by a text.
+type Fact struct {
+	Key   string `json:"key" description:"what the fact is about, in a few words"`
+	Value string `json:"value" description:"the value of the fact"`
+}
+
+// Facts extracts the key-value facts stated by text, such as the
+// attributes of a product or the terms of an agreement.
+func Facts(ctx context.Context, client ollamago.API, model, text string, opts ...ollamago.StructuredOption) ([]Fact, error) {
+	return Extract[Fact](ctx, client, model,
+		"List the facts stated in the text as key-value pairs.",
+		text, opts...)
+}
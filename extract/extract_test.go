@@ -0,0 +1,71 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extract_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/extract"
+	"cirello.io/ollamago/ollamagotest"
+	"github.com/stretchr/testify/require"
+)
+
+func replying(replies ...string) *ollamagotest.MockClient {
+	var n int
+	return &ollamagotest.MockClient{
+		GenerateChatFunc: func(ctx context.Context, req ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error) {
+			reply := replies[min(n, len(replies)-1)]
+			n++
+			return ollamagotest.Stream(ollamago.ChatResponse{
+				Message: ollamago.ChatMessage{Role: ollamago.RoleAssistant, Content: reply},
+				Done:    true,
+			}), nil
+		},
+	}
+}
+
+func TestExtract(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+
+	mock := replying(`{"items":[{"text":"next Monday","date":"May 13"}]}`, `{"items":[{"text":"next Monday","date":"2024-05-13"}]}`)
+	dates, err := extract.Dates(ctx, mock, "test", "See you next Monday.", now, ollamago.WithRetries(1))
+	require.NoError(t, err)
+	require.Equal(t, []extract.Date{{Text: "next Monday", Date: "2024-05-13"}}, dates)
+	require.Equal(t, time.Date(2024, 5, 13, 0, 0, 0, 0, time.UTC), dates[0].Time())
+	req := mock.Calls()[0].Request.(ollamago.ChatRequest)
+	require.Contains(t, req.Messages[0].Content, "Today is Friday, 2024-05-10.")
+	require.JSONEq(t, `{"type":"object","properties":{"items":{"type":"array","items":{"type":"object","properties":{
+		"text":{"type":"string","description":"the date as written in the text"},
+		"date":{"type":"string","description":"the date in the YYYY-MM-DD format"}},"required":["text","date"]}}},"required":["items"]}`, string(req.Format))
+
+	people, err := extract.People(ctx, replying(`{"items":[{"name":"Ada Lovelace","role":"mathematician"},{"name":"Charles Babbage"}]}`), "test", "...")
+	require.NoError(t, err)
+	require.Equal(t, []extract.Person{{Name: "Ada Lovelace", Role: "mathematician"}, {Name: "Charles Babbage"}}, people)
+
+	amounts, err := extract.Amounts(ctx, replying(`{"items":[{"text":"$12.50","value":12.5,"currency":"USD"}]}`), "test", "It cost $12.50.")
+	require.NoError(t, err)
+	require.Equal(t, []extract.Amount{{Text: "$12.50", Value: 12.5, Currency: "USD"}}, amounts)
+
+	_, err = extract.Amounts(ctx, replying(`{"items":[{"text":"12 bucks","value":12,"currency":"dollars"}]}`), "test", "12 bucks")
+	require.ErrorIs(t, err, ollamago.ErrInvalidOutput)
+
+	facts, err := extract.Facts(ctx, replying(`{"items":[]}`), "test", "nothing here")
+	require.NoError(t, err)
+	require.Empty(t, facts)
+}
@@ -0,0 +1,359 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Gateway is an http.Handler proxying the Ollama API, so that a single
+// Ollama server can be shared safely. Every request goes through the
+// policy hooks in order: authentication, rate limiting, the model
+// allowlist and authorization. Rejected requests are answered with an
+// Ollama-style JSON error, which Client reports as a StatusError.
+//
+//	gw := &ollamago.Gateway{
+//		Authenticate: lookupToken,
+//		Models:       []string{"llama3.2", "nomic-embed-text"},
+//		RateLimit:    &ollamago.RateLimit{Rate: 1, Burst: 10},
+//	}
+//	http.ListenAndServe(":8080", gw)
+type Gateway struct {
+	// Target is the URL of the Ollama server. If empty,
+	// "http://localhost:11434" is used.
+	Target string
+
+	// Transport reaches the Ollama server. If nil, http.DefaultTransport
+	// is used.
+	Transport http.RoundTripper
+
+	// Interceptors wrap Transport, the first being the outermost.
+	Interceptors []Interceptor
+
+	// Authenticate identifies the user making the request; an error
+	// rejects it with 401 Unauthorized. The Authorization header is not
+	// forwarded when Authenticate is set. If nil, every request is
+	// anonymous, with an empty user.
+	Authenticate func(r *http.Request) (user string, err error)
+
+	// RateLimit limits the requests of each user. If nil, requests are not
+	// limited.
+	RateLimit *RateLimit
+
+	// Models lists the models that may be used, the tag defaulting to
+	// "latest". Requests for other models are rejected with 403 Forbidden
+	// and the model listings only show the allowed models. Models created
+	// or copied from others must have both ends allowed, and requests
+	// naming models to endpoints the gateway does not know are rejected.
+	// If nil, every model is allowed.
+	Models []string
+
+	// Authorize, if set, decides whether the user may call the endpoint
+	// with the model, empty for the endpoints that take none. It is called
+	// once per model the request names, such as both the source and the
+	// destination of a copy; an error rejects the request with 403
+	// Forbidden.
+	Authorize func(user, endpoint, model string) error
+
+	// Audit, if set, is called once per proxied call after its response
+	// has been streamed to the user. It must not block.
+	Audit func(user string, stats CallStats)
+
+	// MaxRequestBytes caps the size of request bodies, larger ones being
+	// rejected with 413 Request Entity Too Large. If zero,
+	// DefaultGatewayMaxRequestBytes is used.
+	MaxRequestBytes int64
+
+	once  sync.Once
+	proxy *httputil.ReverseProxy
+	err   error
+}
+
+// DefaultGatewayMaxRequestBytes is the request body size limit of a
+// Gateway whose MaxRequestBytes is zero.
+const DefaultGatewayMaxRequestBytes = 64 << 20
+
+type gatewayUserKey struct{}
+
+func (g *Gateway) init() {
+	target := g.Target
+	if target == "" {
+		target = "http://localhost:11434"
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		g.err = fmt.Errorf("invalid gateway target: %w", err)
+		return
+	}
+	transport := g.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if g.Audit != nil {
+		transport = Observe(func(stats CallStats) {
+			user, _ := stats.Request.Context().Value(gatewayUserKey{}).(string)
+			g.Audit(user, stats)
+		})(transport)
+	}
+	for i := len(g.Interceptors) - 1; i >= 0; i-- {
+		transport = g.Interceptors[i](transport)
+	}
+	g.proxy = &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(u)
+			if g.Authenticate != nil {
+				r.Out.Header.Del("Authorization")
+			}
+		},
+		Transport:      transport,
+		FlushInterval:  -1,
+		ModifyResponse: g.filterModels,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if r.Context().Err() != nil {
+				return
+			}
+			writeGatewayError(w, http.StatusBadGateway, err.Error())
+		},
+	}
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.once.Do(g.init)
+	if g.err != nil {
+		writeGatewayError(w, http.StatusInternalServerError, g.err.Error())
+		return
+	}
+	var user string
+	if g.Authenticate != nil {
+		var err error
+		if user, err = g.Authenticate(r); err != nil {
+			writeGatewayError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+	}
+	if g.RateLimit != nil {
+		if wait := g.RateLimit.reserve(user); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeGatewayError(w, http.StatusTooManyRequests, "rate limit exceeded")
+			return
+		}
+	}
+	var models []string
+	if r.Body != nil && r.Body != http.NoBody {
+		maxBytes := g.MaxRequestBytes
+		if maxBytes == 0 {
+			maxBytes = DefaultGatewayMaxRequestBytes
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+		r.Body.Close()
+		if maxBytesErr := (*http.MaxBytesError)(nil); errors.As(err, &maxBytesErr) {
+			writeGatewayError(w, http.StatusRequestEntityTooLarge, "request body too large")
+			return
+		} else if err != nil {
+			writeGatewayError(w, http.StatusBadRequest, "cannot read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		models = modelReferences(body)
+	}
+	if g.Models != nil && len(models) > 0 && !knownGatewayEndpoint(r.URL.Path) {
+		writeGatewayError(w, http.StatusForbidden, fmt.Sprintf("endpoint %q is not allowed", r.URL.Path))
+		return
+	}
+	for _, model := range models {
+		if !g.allowed(model) {
+			writeGatewayError(w, http.StatusForbidden, fmt.Sprintf("model %q is not allowed", model))
+			return
+		}
+	}
+	if g.Authorize != nil {
+		authorized := models
+		if len(authorized) == 0 {
+			authorized = []string{""}
+		}
+		for _, model := range authorized {
+			if err := g.Authorize(user, r.URL.Path, model); err != nil {
+				writeGatewayError(w, http.StatusForbidden, err.Error())
+				return
+			}
+		}
+	}
+	g.proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), gatewayUserKey{}, user)))
+}
+
+// modelReferences returns the models a request body names: the model it
+// calls or creates first, then those it copies or derives from, including
+// the FROM lines of a Modelfile.
+func modelReferences(body []byte) []string {
+	var named struct {
+		Model       string `json:"model"`
+		Name        string `json:"name"`
+		From        string `json:"from"`
+		Source      string `json:"source"`
+		Destination string `json:"destination"`
+		Modelfile   string `json:"modelfile"`
+	}
+	json.Unmarshal(body, &named)
+	var models []string
+	for _, m := range []string{named.Model, named.Name, named.Destination, named.From, named.Source} {
+		if m != "" && !slices.Contains(models, m) {
+			models = append(models, m)
+		}
+	}
+	for _, line := range strings.Split(named.Modelfile, "\n") {
+		instruction, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+		if from := strings.TrimSpace(arg); strings.EqualFold(instruction, "FROM") && from != "" && !slices.Contains(models, from) {
+			models = append(models, from)
+		}
+	}
+	return models
+}
+
+// knownGatewayEndpoint reports whether the models a request to path names
+// are all found by modelReferences. Requests naming models to other
+// endpoints are rejected when the allowlist is set, as they might refer to
+// models in fields the gateway does not check.
+func knownGatewayEndpoint(path string) bool {
+	switch path {
+	case "/api/generate", "/api/chat", "/api/embed", "/api/embeddings",
+		"/api/show", "/api/pull", "/api/push", "/api/create", "/api/copy",
+		"/api/delete", "/api/tags", "/api/ps", "/api/version",
+		"/v1/chat/completions", "/v1/completions", "/v1/embeddings":
+		return true
+	}
+	return false
+}
+
+func (g *Gateway) allowed(model string) bool {
+	return g.Models == nil || slices.ContainsFunc(g.Models, func(m string) bool {
+		return sameModel(m, model)
+	})
+}
+
+// filterModels hides the models outside the allowlist from /api/tags and
+// /api/ps.
+func (g *Gateway) filterModels(resp *http.Response) error {
+	if g.Models == nil || resp.StatusCode != http.StatusOK {
+		return nil
+	}
+	if p := resp.Request.URL.Path; p != "/api/tags" && p != "/api/ps" {
+		return nil
+	}
+	var listing map[string]json.RawMessage
+	err := json.NewDecoder(resp.Body).Decode(&listing)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("cannot decode model listing: %w", err)
+	}
+	var models []json.RawMessage
+	json.Unmarshal(listing["models"], &models)
+	models = slices.DeleteFunc(models, func(m json.RawMessage) bool {
+		var named struct {
+			Model string `json:"model"`
+			Name  string `json:"name"`
+		}
+		json.Unmarshal(m, &named)
+		return !g.allowed(cmp.Or(named.Name, named.Model))
+	})
+	if models == nil {
+		models = []json.RawMessage{}
+	}
+	if listing["models"], err = json.Marshal(models); err != nil {
+		return err
+	}
+	body, err := json.Marshal(listing)
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	resp.ContentLength = int64(len(body))
+	resp.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	return nil
+}
+
+func writeGatewayError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// RateLimit is a per-user token bucket: each user may make Burst requests
+// at once, and Rate requests per second on average.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// ErrRateLimited is returned by RateLimit.Allow when the user must wait.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// Allow takes a token from the bucket of the user, or returns
+// ErrRateLimited if it is empty.
+func (l *RateLimit) Allow(user string) error {
+	if l.reserve(user) > 0 {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// reserve takes a token, returning how long to wait for the next one if
+// there is none.
+func (l *RateLimit) reserve(user string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	burst := float64(max(l.Burst, 1))
+	b, ok := l.buckets[user]
+	if !ok {
+		if l.buckets == nil {
+			l.buckets = make(map[string]*tokenBucket)
+		}
+		b = &tokenBucket{tokens: burst, last: now}
+		l.buckets[user] = b
+	}
+	b.tokens = min(burst, b.tokens+now.Sub(b.last).Seconds()*l.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	if l.Rate <= 0 {
+		return time.Hour
+	}
+	return time.Duration((1 - b.tokens) / l.Rate * float64(time.Second))
+}
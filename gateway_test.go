@@ -0,0 +1,202 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGateway(t *testing.T) {
+	upstream := ollamatest.NewServer(
+		ollamatest.Model{Name: "llama3.2:latest", Chunks: []string{"hello ", "world"}},
+		ollamatest.Model{Name: "private"},
+	)
+	t.Cleanup(upstream.Close)
+	var (
+		mu      sync.Mutex
+		audited []string
+	)
+	gw := &ollamago.Gateway{
+		Target: upstream.URL,
+		Authenticate: func(r *http.Request) (string, error) {
+			user, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok {
+				return "", errors.New("missing token")
+			}
+			return user, nil
+		},
+		Models:    []string{"llama3.2"},
+		RateLimit: &ollamago.RateLimit{Rate: 0.001, Burst: 4},
+		Authorize: func(user, endpoint, model string) error {
+			if endpoint == "/api/delete" && user != "admin" {
+				return errors.New("only admins may delete models")
+			}
+			return nil
+		},
+		Audit: func(user string, stats ollamago.CallStats) {
+			mu.Lock()
+			defer mu.Unlock()
+			audited = append(audited, user+" "+stats.Endpoint+" "+stats.Model)
+		},
+	}
+	srv := httptest.NewServer(gw)
+	t.Cleanup(srv.Close)
+	newClient := func(user string) *ollamago.Client {
+		client := &ollamago.Client{BaseURL: srv.URL}
+		if user != "" {
+			client.Interceptors = []ollamago.Interceptor{ollamago.APIKey(user)}
+		}
+		return client
+	}
+	ctx := context.Background()
+	statusCode := func(err error) int {
+		t.Helper()
+		var statusErr *ollamago.StatusError
+		require.ErrorAs(t, err, &statusErr)
+		return statusErr.StatusCode
+	}
+
+	alice := newClient("alice")
+	respChan, err := alice.GenerateChat(ctx, ollamago.ChatRequest{Model: "llama3.2:latest", Messages: []ollamago.ChatMessage{ollamago.UserMessage("hi")}, Stream: true})
+	require.NoError(t, err)
+	var reply strings.Builder
+	for r := range respChan {
+		require.NoError(t, r.Error)
+		reply.WriteString(r.Message.Content)
+	}
+	require.Equal(t, "hello world", reply.String())
+	require.Empty(t, upstream.Requests()[0].Header.Get("Authorization"), "the user token is not forwarded")
+
+	models, err := alice.ListModels(ctx)
+	require.NoError(t, err)
+	require.Len(t, models.Models, 1, "disallowed models are hidden")
+
+	_, err = alice.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: "private", Input: []string{"x"}})
+	require.Equal(t, http.StatusForbidden, statusCode(err))
+	require.ErrorContains(t, err, `model "private" is not allowed`)
+
+	err = alice.DeleteModel(ctx, ollamago.DeleteModelRequest{Model: "llama3.2"})
+	require.Equal(t, http.StatusForbidden, statusCode(err))
+
+	_, err = alice.Version(ctx)
+	require.Equal(t, http.StatusTooManyRequests, statusCode(err), "the burst is spent")
+	_, err = newClient("bob").Version(ctx)
+	require.NoError(t, err, "users have their own limits")
+
+	_, err = newClient("").Version(ctx)
+	require.Equal(t, http.StatusUnauthorized, statusCode(err))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{"alice /api/chat llama3.2:latest", "alice /api/tags ", "bob /api/version "}, audited)
+}
+
+func TestGatewayModelReferences(t *testing.T) {
+	upstream := ollamatest.NewServer(ollamatest.Model{Name: "llama3.2:latest"})
+	t.Cleanup(upstream.Close)
+	srv := httptest.NewServer(&ollamago.Gateway{
+		Target:          upstream.URL,
+		Models:          []string{"llama3.2"},
+		MaxRequestBytes: 1 << 10,
+	})
+	t.Cleanup(srv.Close)
+	post := func(endpoint, body string) (int, string) {
+		t.Helper()
+		resp, err := http.Post(srv.URL+endpoint, "application/json", strings.NewReader(body))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(b)
+	}
+	for _, tt := range []struct {
+		endpoint, body, want string
+	}{
+		{"/api/copy", `{"source":"forbidden","destination":"llama3.2"}`, `model \"forbidden\" is not allowed`},
+		{"/api/copy", `{"source":"llama3.2","destination":"forbidden"}`, `model \"forbidden\" is not allowed`},
+		{"/api/create", `{"model":"llama3.2","from":"forbidden"}`, `model \"forbidden\" is not allowed`},
+		{"/api/create", `{"name":"llama3.2","modelfile":"# base\nFROM forbidden\nSYSTEM hi"}`, `model \"forbidden\" is not allowed`},
+		{"/api/unknown", `{"model":"llama3.2"}`, `endpoint \"/api/unknown\" is not allowed`},
+	} {
+		status, body := post(tt.endpoint, tt.body)
+		require.Equal(t, http.StatusForbidden, status, tt.body)
+		require.Contains(t, body, tt.want)
+	}
+	require.Empty(t, upstream.Requests(), "rejected requests are not proxied")
+
+	status, _ := post("/api/chat", `{"model":"llama3.2","messages":[{"role":"user","content":"`+strings.Repeat("x", 2<<10)+`"}]}`)
+	require.Equal(t, http.StatusRequestEntityTooLarge, status)
+}
+
+func TestGatewayAuthorizeEveryModel(t *testing.T) {
+	upstream := ollamatest.NewServer(ollamatest.Model{Name: "llama3.2:latest"})
+	t.Cleanup(upstream.Close)
+	var (
+		mu      sync.Mutex
+		checked []string
+	)
+	srv := httptest.NewServer(&ollamago.Gateway{
+		Target: upstream.URL,
+		Authorize: func(user, endpoint, model string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			checked = append(checked, endpoint+" "+model)
+			if model == "secret" {
+				return errors.New("secret is off limits")
+			}
+			return nil
+		},
+	})
+	t.Cleanup(srv.Close)
+	for _, tt := range []struct {
+		endpoint, body string
+	}{
+		{"/api/copy", `{"source":"secret","destination":"mine"}`},
+		{"/api/create", `{"model":"mine","from":"secret"}`},
+		{"/api/create", `{"model":"mine","modelfile":"FROM secret"}`},
+	} {
+		resp, err := http.Post(srv.URL+tt.endpoint, "application/json", strings.NewReader(tt.body))
+		require.NoError(t, err)
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusForbidden, resp.StatusCode, tt.body)
+		require.Contains(t, string(b), "secret is off limits")
+	}
+	require.Empty(t, upstream.Requests(), "unauthorized requests are not proxied")
+
+	resp, err := http.Get(srv.URL + "/api/version")
+	require.NoError(t, err)
+	resp.Body.Close()
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{
+		"/api/copy mine", "/api/copy secret",
+		"/api/create mine", "/api/create secret",
+		"/api/create mine", "/api/create secret",
+		"/api/version ",
+	}, checked)
+}
@@ -0,0 +1,115 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// CreateModelFromGGUF creates a model from local GGUF weights: it hashes
+// the file, uploads it unless the server already holds it, and creates the
+// model from the blob. The template, system message, parameters and other
+// settings are taken from mf, if not nil; its base model is ignored.
+//
+// The progress of every step is streamed, the hashing and the upload being
+// reported with the statuses "hashing <path>" and "uploading <digest>".
+func (c *Client) CreateModelFromGGUF(ctx context.Context, name, path string, mf *Modelfile) (<-chan ProgressEvent, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open GGUF file: %w", err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("cannot stat GGUF file: %w", err)
+	}
+	if mf == nil {
+		mf = new(Modelfile)
+	}
+	out := make(chan ProgressEvent)
+	go func() {
+		defer close(out)
+		defer f.Close()
+		fail := func(err error) {
+			send(ctx, out, ProgressEvent{Error: err})
+		}
+		h := sha256.New()
+		hashing := &progressReader{ctx: ctx, r: f, out: out, update: ProgressEvent{Status: "hashing " + path, Total: fi.Size()}}
+		if _, err := io.Copy(h, hashing); err != nil {
+			fail(fmt.Errorf("cannot hash GGUF file: %w", err))
+			return
+		}
+		digest := "sha256:" + hex.EncodeToString(h.Sum(nil))
+		exists, err := c.BlobExists(ctx, digest)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if !exists {
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				fail(fmt.Errorf("cannot rewind GGUF file: %w", err))
+				return
+			}
+			uploading := &progressReader{ctx: ctx, r: f, out: out, update: ProgressEvent{Status: "uploading " + digest, Digest: digest, Total: fi.Size()}}
+			if err := c.CreateBlob(ctx, digest, uploading); err != nil {
+				fail(err)
+				return
+			}
+		}
+		derived := *mf
+		derived.from = "@" + digest
+		req := derived.Request(name)
+		req.From = ""
+		req.Files = map[string]string{filepath.Base(path): digest}
+		progress, err := c.CreateModel(ctx, req)
+		if err != nil {
+			fail(err)
+			return
+		}
+		for p := range progress {
+			if !send(ctx, out, p) {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// progressReader reports the bytes read through it, every 1% of the total.
+type progressReader struct {
+	ctx      context.Context
+	r        io.Reader
+	out      chan<- ProgressEvent
+	update   ProgressEvent
+	reported int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.update.Completed += int64(n)
+	if p.update.Completed > p.reported && (err == io.EOF || p.update.Completed-p.reported >= max(p.update.Total/100, 1)) {
+		p.reported = p.update.Completed
+		if !send(p.ctx, p.out, p.update) {
+			return n, p.ctx.Err()
+		}
+	}
+	return n, err
+}
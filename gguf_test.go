@@ -0,0 +1,79 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateModelFromGGUF(t *testing.T) {
+	srv := ollamatest.NewServer()
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+	ctx := context.Background()
+
+	weights := bytes.Repeat([]byte("GGUF"), 1<<16)
+	path := filepath.Join(t.TempDir(), "model.gguf")
+	require.NoError(t, os.WriteFile(path, weights, 0o600))
+	sum := sha256.Sum256(weights)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+
+	create := func(name string) []ollamago.ProgressEvent {
+		t.Helper()
+		progress, err := client.CreateModelFromGGUF(ctx, name, path, new(ollamago.Modelfile).System("You are local."))
+		require.NoError(t, err)
+		var updates []ollamago.ProgressEvent
+		for p := range progress {
+			require.NoError(t, p.Error)
+			updates = append(updates, p)
+		}
+		require.Equal(t, "success", updates[len(updates)-1].Status)
+		return updates
+	}
+	uploaded := func(updates []ollamago.ProgressEvent) (completed int64) {
+		for _, p := range updates {
+			if strings.HasPrefix(p.Status, "uploading") {
+				require.Equal(t, digest, p.Digest)
+				completed = p.Completed
+			}
+		}
+		return completed
+	}
+
+	updates := create("local")
+	require.EqualValues(t, len(weights), uploaded(updates))
+	require.Equal(t, []string{digest}, srv.Blobs())
+	show, err := client.ShowModelInfo(ctx, ollamago.ShowModelRequest{Model: "local"})
+	require.NoError(t, err)
+	require.Equal(t, "You are local.", show.System)
+	require.Contains(t, show.Modelfile, "FROM @"+digest)
+
+	updates = create("again")
+	require.Zero(t, uploaded(updates), "blobs already on the server are not uploaded again")
+
+	_, err = client.CreateModelFromGGUF(ctx, "missing", filepath.Join(t.TempDir(), "missing.gguf"), nil)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,177 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// GuardLimit names a guard of Guarded.
+type GuardLimit string
+
+// Guards of Guarded.
+const (
+	GuardMaxChars    GuardLimit = "max_chars"
+	GuardMaxTokens   GuardLimit = "max_tokens"
+	GuardMaxDuration GuardLimit = "max_duration"
+	GuardBanned      GuardLimit = "banned"
+)
+
+// GuardrailError is reported when Guarded aborts a response.
+type GuardrailError struct {
+	Limit GuardLimit
+
+	// Match is the banned substring found, for GuardBanned.
+	Match string
+}
+
+func (e *GuardrailError) Error() string {
+	if e.Limit == GuardBanned {
+		return fmt.Sprintf("guardrail: output contains %q", e.Match)
+	}
+	return "guardrail: output exceeded " + string(e.Limit)
+}
+
+// Guarded wraps an API, enforcing limits on the output of chats and
+// completions on the client side, as a defense in depth when num_predict
+// alone is not reliable. A response breaking a limit is aborted: its
+// request is canceled and its last chunk carries a *GuardrailError. Zero
+// limits are not enforced.
+type Guarded struct {
+	API
+
+	// MaxChars caps the number of characters generated.
+	MaxChars int
+
+	// MaxTokens caps the number of tokens generated, counting a token per
+	// streamed chunk.
+	MaxTokens int
+
+	// MaxDuration caps the time from the request to the end of the
+	// response.
+	MaxDuration time.Duration
+
+	// Banned lists substrings that must not be generated, ignoring case.
+	Banned []string
+}
+
+var _ API = (*Guarded)(nil)
+
+// GenerateChat enforces the limits on the reply.
+func (g *Guarded) GenerateChat(ctx context.Context, req ChatRequest) (<-chan ChatResponse, error) {
+	return guard(ctx, g, func(ctx context.Context) (<-chan ChatResponse, error) {
+		return g.API.GenerateChat(ctx, req)
+	}, func(r ChatResponse) (string, error) {
+		return r.Message.Content, r.Error
+	}, func(err error) ChatResponse {
+		return ChatResponse{Model: req.Model, Done: true, Error: err}
+	})
+}
+
+// GenerateCompletion enforces the limits on the generated text.
+func (g *Guarded) GenerateCompletion(ctx context.Context, req CompletionRequest) (<-chan CompletionResponse, error) {
+	return guard(ctx, g, func(ctx context.Context) (<-chan CompletionResponse, error) {
+		return g.API.GenerateCompletion(ctx, req)
+	}, func(r CompletionResponse) (string, error) {
+		return r.Response, r.Error
+	}, func(err error) CompletionResponse {
+		return CompletionResponse{Model: req.Model, Done: true, Error: err}
+	})
+}
+
+func guard[T any](ctx context.Context, g *Guarded, call func(context.Context) (<-chan T, error), content func(T) (string, error), failed func(error) T) (<-chan T, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	var expired atomic.Bool
+	var timer *time.Timer
+	if g.MaxDuration > 0 {
+		timer = time.AfterFunc(g.MaxDuration, func() {
+			expired.Store(true)
+			cancel()
+		})
+	}
+	stop := func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		cancel()
+	}
+	resp, err := call(ctx)
+	if err != nil {
+		stop()
+		if expired.Load() {
+			return nil, &GuardrailError{Limit: GuardMaxDuration}
+		}
+		return nil, err
+	}
+	lowered := make([]string, len(g.Banned))
+	longest := 0
+	for i, b := range g.Banned {
+		lowered[i] = strings.ToLower(b)
+		longest = max(longest, len(lowered[i]))
+	}
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		defer stop()
+		var (
+			chars, tokens int
+			tail          string
+		)
+		abort := func(err error) {
+			cancel()
+			for range resp {
+			}
+			out <- failed(err)
+		}
+		for r := range resp {
+			text, err := content(r)
+			if err != nil && expired.Load() {
+				abort(&GuardrailError{Limit: GuardMaxDuration})
+				return
+			}
+			if text != "" {
+				chars += utf8.RuneCountInString(text)
+				tokens++
+			}
+			if g.MaxChars > 0 && chars > g.MaxChars {
+				abort(&GuardrailError{Limit: GuardMaxChars})
+				return
+			}
+			if g.MaxTokens > 0 && tokens > g.MaxTokens {
+				abort(&GuardrailError{Limit: GuardMaxTokens})
+				return
+			}
+			if longest > 0 {
+				// Only the end of the previous chunks may hold the start of
+				// a banned substring split across chunks.
+				window := tail + strings.ToLower(text)
+				for i, b := range lowered {
+					if b != "" && strings.Contains(window, b) {
+						abort(&GuardrailError{Limit: GuardBanned, Match: g.Banned[i]})
+						return
+					}
+				}
+				tail = window[max(len(window)-longest+1, 0):]
+			}
+			out <- r
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuarded(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "llama3.2", Chunks: []string{"The pass", "word is ", "swordfish", "."}})
+	t.Cleanup(srv.Close)
+	chat := func(g *ollamago.Guarded) (string, error) {
+		t.Helper()
+		g.API = srv.Client()
+		resp, err := g.GenerateChat(context.Background(), ollamago.ChatRequest{Model: "llama3.2", Messages: []ollamago.ChatMessage{ollamago.UserMessage("hi")}, Stream: true})
+		if err != nil {
+			return "", err
+		}
+		var content strings.Builder
+		for r := range resp {
+			if r.Error != nil {
+				return content.String(), r.Error
+			}
+			content.WriteString(r.Message.Content)
+		}
+		return content.String(), nil
+	}
+	content, err := chat(&ollamago.Guarded{MaxChars: 100, MaxTokens: 4})
+	require.NoError(t, err)
+	require.Equal(t, "The password is swordfish.", content)
+
+	var guardErr *ollamago.GuardrailError
+	content, err = chat(&ollamago.Guarded{MaxChars: 20})
+	require.ErrorAs(t, err, &guardErr)
+	require.Equal(t, ollamago.GuardMaxChars, guardErr.Limit)
+	require.Equal(t, "The password is ", content)
+
+	_, err = chat(&ollamago.Guarded{MaxTokens: 2})
+	require.ErrorAs(t, err, &guardErr)
+	require.Equal(t, ollamago.GuardMaxTokens, guardErr.Limit)
+
+	content, err = chat(&ollamago.Guarded{Banned: []string{"PASSWORD"}})
+	require.ErrorAs(t, err, &guardErr)
+	require.Equal(t, ollamago.GuardBanned, guardErr.Limit)
+	require.Equal(t, "PASSWORD", guardErr.Match)
+	require.Equal(t, "The pass", content)
+
+	srv.SetChunkDelay(50 * time.Millisecond)
+	_, err = chat(&ollamago.Guarded{MaxDuration: 75 * time.Millisecond})
+	require.ErrorAs(t, err, &guardErr)
+	require.Equal(t, ollamago.GuardMaxDuration, guardErr.Limit)
+
+	g := &ollamago.Guarded{API: srv.Client(), MaxChars: 5}
+	resp, err := g.GenerateCompletion(context.Background(), ollamago.CompletionRequest{Model: "llama3.2", Prompt: "hi", Stream: true})
+	require.NoError(t, err)
+	var last ollamago.CompletionResponse
+	for r := range resp {
+		last = r
+	}
+	require.ErrorAs(t, last.Error, &guardErr)
+}
@@ -0,0 +1,183 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // decode GIF images
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+)
+
+// ImageOption configures the image loading helpers.
+type ImageOption func(*imageConfig)
+
+type imageConfig struct {
+	maxSide     int
+	jpegQuality int
+	httpClient  *http.Client
+}
+
+// WithMaxImageSide downscales images whose width or height exceeds n
+// pixels, preserving their aspect ratio. Vision models work at a few
+// hundred pixels per side, so larger images only inflate the request.
+func WithMaxImageSide(n int) ImageOption {
+	return func(c *imageConfig) {
+		c.maxSide = n
+	}
+}
+
+// WithJPEG re-encodes images as JPEG at the given quality, from 1 to 100.
+func WithJPEG(quality int) ImageOption {
+	return func(c *imageConfig) {
+		c.jpegQuality = quality
+	}
+}
+
+// WithImageHTTPClient sets the HTTP client ImageFromURL fetches images
+// with. The default is http.DefaultClient.
+func WithImageHTTPClient(client *http.Client) ImageOption {
+	return func(c *imageConfig) {
+		c.httpClient = client
+	}
+}
+
+func newImageConfig(opts []ImageOption) *imageConfig {
+	cfg := &imageConfig{httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// ImageFromFile loads an image file as the base64 payload of
+// ChatMessage.Images and CompletionRequest.Images.
+func ImageFromFile(path string, opts ...ImageOption) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot read image: %w", err)
+	}
+	return encodeImageData(data, newImageConfig(opts))
+}
+
+// ImageFromReader is like ImageFromFile but reads the image from r.
+func ImageFromReader(r io.Reader, opts ...ImageOption) (string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("cannot read image: %w", err)
+	}
+	return encodeImageData(data, newImageConfig(opts))
+}
+
+// ImageFromURL is like ImageFromFile but fetches the image from url.
+func ImageFromURL(ctx context.Context, url string, opts ...ImageOption) (string, error) {
+	cfg := newImageConfig(opts)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("cannot prepare image request: %w", err)
+	}
+	resp, err := cfg.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("cannot fetch image: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("cannot fetch image: %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("cannot read image: %w", err)
+	}
+	return encodeImageData(data, cfg)
+}
+
+// EncodeImage encodes img as the base64 payload of ChatMessage.Images and
+// CompletionRequest.Images, as PNG unless WithJPEG is given.
+func EncodeImage(img image.Image, opts ...ImageOption) (string, error) {
+	return encodeImage(img, "png", newImageConfig(opts))
+}
+
+// encodeImageData sends the image as it is, unless the options require
+// decoding it to downscale or re-encode it.
+func encodeImageData(data []byte, cfg *imageConfig) (string, error) {
+	if cfg.maxSide <= 0 && cfg.jpegQuality <= 0 {
+		return base64.StdEncoding.EncodeToString(data), nil
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("cannot decode image: %w", err)
+	}
+	if b := img.Bounds(); cfg.jpegQuality <= 0 && max(b.Dx(), b.Dy()) <= cfg.maxSide {
+		return base64.StdEncoding.EncodeToString(data), nil
+	}
+	return encodeImage(img, format, cfg)
+}
+
+func encodeImage(img image.Image, format string, cfg *imageConfig) (string, error) {
+	if cfg.maxSide > 0 {
+		img = downscale(img, cfg.maxSide)
+	}
+	var buf bytes.Buffer
+	var err error
+	switch {
+	case cfg.jpegQuality > 0:
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: cfg.jpegQuality})
+	case format == "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	default:
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot encode image: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// downscale shrinks img so that neither side exceeds maxSide, averaging
+// the source pixels covered by each destination pixel.
+func downscale(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	longest := max(w, h)
+	if longest <= maxSide {
+		return img
+	}
+	dw, dh := max(w*maxSide/longest, 1), max(h*maxSide/longest, 1)
+	dst := image.NewRGBA64(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		y0, y1 := b.Min.Y+y*h/dh, b.Min.Y+(y+1)*h/dh
+		for x := range dw {
+			x0, x1 := b.Min.X+x*w/dw, b.Min.X+(x+1)*w/dw
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(pr), g+uint64(pg), bl+uint64(pb), a+uint64(pa)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,99 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeImage(t *testing.T, payload string) (image.Image, string) {
+	t.Helper()
+	data, err := base64.StdEncoding.DecodeString(payload)
+	require.NoError(t, err)
+	img, format, err := image.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	return img, format
+}
+
+func TestImageHelpers(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 400, 200))
+	for y := range 200 {
+		for x := range 400 {
+			src.Set(x, y, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, src))
+	path := filepath.Join(t.TempDir(), "test.png")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o600))
+
+	payload, err := ollamago.ImageFromFile(path)
+	require.NoError(t, err)
+	require.Equal(t, base64.StdEncoding.EncodeToString(buf.Bytes()), payload, "images are sent as they are by default")
+	payload, err = ollamago.ImageFromFile(path, ollamago.WithMaxImageSide(1000))
+	require.NoError(t, err)
+	require.Equal(t, base64.StdEncoding.EncodeToString(buf.Bytes()), payload, "small images are not re-encoded")
+
+	payload, err = ollamago.ImageFromReader(bytes.NewReader(buf.Bytes()), ollamago.WithMaxImageSide(100))
+	require.NoError(t, err)
+	img, format := decodeImage(t, payload)
+	require.Equal(t, "png", format)
+	require.Equal(t, image.Rect(0, 0, 100, 50), img.Bounds())
+	r, g, b, _ := img.At(10, 10).RGBA()
+	require.Equal(t, []uint32{200, 100, 50}, []uint32{r >> 8, g >> 8, b >> 8})
+
+	payload, err = ollamago.EncodeImage(src, ollamago.WithJPEG(80))
+	require.NoError(t, err)
+	_, format = decodeImage(t, payload)
+	require.Equal(t, "jpeg", format)
+
+	_, err = ollamago.ImageFromReader(strings.NewReader("not an image"), ollamago.WithJPEG(80))
+	require.ErrorContains(t, err, "cannot decode image")
+}
+
+func TestImageFromURL(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, 10, 10))))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cat.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(buf.Bytes())
+	}))
+	t.Cleanup(server.Close)
+	ctx := context.Background()
+
+	payload, err := ollamago.ImageFromURL(ctx, server.URL+"/cat.png", ollamago.WithImageHTTPClient(server.Client()))
+	require.NoError(t, err)
+	require.Equal(t, base64.StdEncoding.EncodeToString(buf.Bytes()), payload)
+
+	_, err = ollamago.ImageFromURL(ctx, server.URL+"/dog.png")
+	require.ErrorContains(t, err, "404 Not Found")
+}
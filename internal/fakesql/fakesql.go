@@ -0,0 +1,129 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fakesql provides a database/sql driver whose statements are
+// answered by a function, to test the SQL issued by the stores of
+// ollamago without a database.
+package fakesql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+)
+
+// Rows is the result of a query.
+type Rows struct {
+	Columns []string
+	Values  [][]any
+}
+
+// Handler answers a statement with its rows, for queries, or the number
+// of rows it affected. Transactions are reported as the "BEGIN",
+// "COMMIT" and "ROLLBACK" statements.
+type Handler func(query string, args []any) (rows *Rows, rowsAffected int64, err error)
+
+// Open returns a database answering the statements with h.
+func Open(h Handler) *sql.DB {
+	return sql.OpenDB(connector{h})
+}
+
+type connector struct {
+	h Handler
+}
+
+func (c connector) Connect(context.Context) (driver.Conn, error) { return conn(c), nil }
+func (c connector) Driver() driver.Driver                        { return nil }
+
+type conn struct {
+	h Handler
+}
+
+var (
+	_ driver.ExecerContext  = conn{}
+	_ driver.QueryerContext = conn{}
+	_ driver.ConnBeginTx    = conn{}
+)
+
+func (c conn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c conn) Close() error                        { return nil }
+func (c conn) Begin() (driver.Tx, error)           { return c.BeginTx(context.Background(), driver.TxOptions{}) }
+
+func (c conn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	if _, _, err := c.h("BEGIN", nil); err != nil {
+		return nil, err
+	}
+	return tx(c), nil
+}
+
+func (c conn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	_, n, err := c.h(query, values(args))
+	if err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(n), nil
+}
+
+func (c conn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	r, _, err := c.h(query, values(args))
+	if err != nil {
+		return nil, err
+	}
+	if r == nil {
+		r = &Rows{}
+	}
+	return &rows{r: r}, nil
+}
+
+func values(args []driver.NamedValue) []any {
+	vs := make([]any, len(args))
+	for i, a := range args {
+		vs[i] = a.Value
+	}
+	return vs
+}
+
+type tx struct {
+	h Handler
+}
+
+func (t tx) Commit() error {
+	_, _, err := t.h("COMMIT", nil)
+	return err
+}
+
+func (t tx) Rollback() error {
+	_, _, err := t.h("ROLLBACK", nil)
+	return err
+}
+
+type rows struct {
+	r    *Rows
+	next int
+}
+
+func (r *rows) Columns() []string { return r.r.Columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.r.Values) {
+		return io.EOF
+	}
+	for i, v := range r.r.Values[r.next] {
+		dest[i] = v
+	}
+	r.next++
+	return nil
+}
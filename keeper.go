@@ -0,0 +1,70 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"time"
+)
+
+// ModelKeeper keeps a set of models loaded by touching them periodically,
+// so that they are not evicted between bursts of traffic and the next
+// request does not pay for a cold load.
+type ModelKeeper struct {
+	Client API
+	Models []string
+
+	// Interval is the time between touches. If zero, one minute is used.
+	Interval time.Duration
+
+	// KeepAlive is how long the server keeps a model loaded after each
+	// touch. If zero, twice the Interval is used, so that a late touch
+	// does not let the model go.
+	KeepAlive time.Duration
+
+	// OnError, if set, is called when touching a model fails.
+	OnError func(model string, err error)
+}
+
+// Run touches the models right away and then every Interval, until ctx is
+// done. It returns ctx.Err().
+func (k *ModelKeeper) Run(ctx context.Context) error {
+	interval := k.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	keepAlive := k.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = 2 * interval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, model := range k.Models {
+			err := LoadModel(ctx, k.Client, model, keepAlive)
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil && k.OnError != nil {
+				k.OnError(model, err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
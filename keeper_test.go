@@ -0,0 +1,61 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelKeeper(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "a"}, ollamatest.Model{Name: "b"})
+	t.Cleanup(srv.Close)
+	var (
+		mu     sync.Mutex
+		failed []string
+	)
+	keeper := &ollamago.ModelKeeper{
+		Client:   srv.Client(),
+		Models:   []string{"a", "b", "missing"},
+		Interval: 20 * time.Millisecond,
+		OnError: func(model string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			failed = append(failed, model)
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- keeper.Run(ctx) }()
+	require.Eventually(t, func() bool {
+		return len(srv.Requests()) >= 6
+	}, time.Second, 5*time.Millisecond, "the models are touched periodically")
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+
+	ps, err := srv.Client().ListRunningModels(context.Background())
+	require.NoError(t, err)
+	require.Len(t, ps.Models, 2)
+	require.WithinDuration(t, time.Now().Add(40*time.Millisecond), ps.Models[0].ExpiresAt, 40*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Contains(t, failed, "missing")
+}
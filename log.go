@@ -0,0 +1,208 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// LoggerOption configures WithLogger.
+type LoggerOption func(*loggerConfig)
+
+type loggerConfig struct {
+	request, response, failure slog.Level
+	redact                     bool
+}
+
+// WithLogLevels sets the levels of the request, response and failure
+// records. The defaults are Debug, Info and Error.
+func WithLogLevels(request, response, failure slog.Level) LoggerOption {
+	return func(c *loggerConfig) {
+		c.request, c.response, c.failure = request, response, failure
+	}
+}
+
+// WithRedactedContent keeps prompts and generated text out of the logs.
+func WithRedactedContent() LoggerOption {
+	return func(c *loggerConfig) {
+		c.redact = true
+	}
+}
+
+// WithLogger returns an Interceptor logging the start and the end of every
+// call with its model, durations, token counts and errors. Unless
+// WithRedactedContent is used, the prompt and the generated text are
+// logged too.
+func WithLogger(logger *slog.Logger, opts ...LoggerOption) Interceptor {
+	cfg := loggerConfig{request: slog.LevelDebug, response: slog.LevelInfo, failure: slog.LevelError}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	observe := Observe(func(s CallStats) {
+		ctx := s.Request.Context()
+		attrs := []slog.Attr{
+			slog.String("endpoint", s.Endpoint),
+			slog.String("model", s.Model),
+			slog.Int("status", s.Status),
+			slog.Duration("duration", s.Duration),
+			slog.Duration("first_byte", s.FirstByte),
+		}
+		if s.Err != nil || s.Status >= http.StatusBadRequest {
+			if s.Err != nil {
+				attrs = append(attrs, slog.Any("error", s.Err))
+			}
+			logger.LogAttrs(ctx, cfg.failure, "ollama call failed", attrs...)
+			return
+		}
+		attrs = append(attrs,
+			slog.Int("prompt_tokens", s.PromptTokens),
+			slog.Int("generated_tokens", s.GeneratedTokens),
+		)
+		if s.DoneReason != "" {
+			attrs = append(attrs, slog.String("done_reason", s.DoneReason))
+		}
+		if c, ok := ctx.Value(logContentKey{}).(*logContent); ok {
+			attrs = append(attrs, slog.String("response", c.String()))
+		}
+		logger.LogAttrs(ctx, cfg.response, "ollama response", attrs...)
+	})
+	return func(next http.RoundTripper) http.RoundTripper {
+		if !cfg.redact {
+			next = captureContent(next)
+		}
+		observed := observe(next)
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			ctx := req.Context()
+			if !logger.Enabled(ctx, cfg.request) && !logger.Enabled(ctx, cfg.response) && !logger.Enabled(ctx, cfg.failure) {
+				return next.RoundTrip(req)
+			}
+			var body []byte
+			if req.Body != nil {
+				var err error
+				body, err = io.ReadAll(req.Body)
+				req.Body.Close()
+				if err != nil {
+					return nil, err
+				}
+				req = req.Clone(req.Context())
+				req.Body = io.NopCloser(bytes.NewReader(body))
+				req.ContentLength = int64(len(body))
+			}
+			var logged loggedRequest
+			json.Unmarshal(body, &logged)
+			attrs := []slog.Attr{slog.String("endpoint", req.URL.Path), slog.String("model", logged.Model)}
+			if !cfg.redact {
+				if prompt := logged.prompt(); prompt != "" {
+					attrs = append(attrs, slog.String("prompt", prompt))
+				}
+				ctx = context.WithValue(ctx, logContentKey{}, &logContent{})
+				req = req.WithContext(ctx)
+			}
+			logger.LogAttrs(ctx, cfg.request, "ollama request", attrs...)
+			return observed.RoundTrip(req)
+		})
+	}
+}
+
+type loggedRequest struct {
+	Model    string        `json:"model"`
+	Prompt   string        `json:"prompt"`
+	Messages []ChatMessage `json:"messages"`
+}
+
+func (r loggedRequest) prompt() string {
+	if r.Prompt != "" {
+		return r.Prompt
+	}
+	if len(r.Messages) > 0 {
+		return r.Messages[len(r.Messages)-1].Content
+	}
+	return ""
+}
+
+type logContentKey struct{}
+
+// logContent accumulates the text generated in a response.
+type logContent struct {
+	mu sync.Mutex
+	sb strings.Builder
+}
+
+func (c *logContent) add(s string) {
+	c.mu.Lock()
+	c.sb.WriteString(s)
+	c.mu.Unlock()
+}
+
+func (c *logContent) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sb.String()
+}
+
+func captureContent(next http.RoundTripper) http.RoundTripper {
+	return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		c, ok := req.Context().Value(logContentKey{}).(*logContent)
+		if err != nil || !ok {
+			return resp, err
+		}
+		resp.Body = &contentBody{ReadCloser: resp.Body, content: c}
+		return resp, nil
+	})
+}
+
+// contentBody feeds the generated text of each NDJSON line of a response
+// to a logContent.
+type contentBody struct {
+	io.ReadCloser
+	content *logContent
+	pending []byte
+}
+
+func (b *contentBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.pending = append(b.pending, p[:n]...)
+	for {
+		i := bytes.IndexByte(b.pending, '\n')
+		if i < 0 {
+			break
+		}
+		b.line(b.pending[:i])
+		b.pending = b.pending[i+1:]
+	}
+	if err != nil && len(b.pending) > 0 {
+		b.line(b.pending)
+		b.pending = nil
+	}
+	return n, err
+}
+
+func (b *contentBody) line(line []byte) {
+	var chunk struct {
+		Response string      `json:"response"`
+		Message  ChatMessage `json:"message"`
+	}
+	if json.Unmarshal(line, &chunk) == nil {
+		b.content.add(chunk.Response + chunk.Message.Content)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithLogger(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test", Chunks: []string{"secret", " answer"}})
+	t.Cleanup(srv.Close)
+	chat := func(opts ...ollamago.LoggerOption) string {
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		client := srv.Client()
+		client.Interceptors = []ollamago.Interceptor{ollamago.WithLogger(logger, opts...)}
+		respChan, err := client.GenerateChat(context.Background(), ollamago.ChatRequest{
+			Model:    "test",
+			Messages: []ollamago.ChatMessage{{Role: "user", Content: "private question"}},
+			Stream:   true,
+		})
+		require.NoError(t, err)
+		for range respChan {
+		}
+		_, err = client.GenerateChat(context.Background(), ollamago.ChatRequest{Model: "missing"})
+		require.Error(t, err)
+		return buf.String()
+	}
+
+	logs := chat()
+	require.Contains(t, logs, `level=DEBUG msg="ollama request" endpoint=/api/chat model=test prompt="private question"`)
+	require.Contains(t, logs, `level=INFO msg="ollama response" endpoint=/api/chat model=test status=200`)
+	require.Contains(t, logs, "generated_tokens=2")
+	require.Contains(t, logs, `response="secret answer"`)
+	require.Contains(t, logs, `level=ERROR msg="ollama call failed" endpoint=/api/chat model=missing status=404`)
+
+	logs = chat(ollamago.WithRedactedContent(), ollamago.WithLogLevels(slog.LevelInfo, slog.LevelInfo, slog.LevelWarn))
+	require.NotContains(t, logs, "private")
+	require.NotContains(t, logs, "secret")
+	require.Contains(t, logs, `level=INFO msg="ollama request"`)
+	require.Contains(t, logs, `level=WARN msg="ollama call failed"`)
+}
+
+func TestWithLoggerLeavesRequestAlone(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test"})
+	t.Cleanup(srv.Close)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	body := io.NopCloser(strings.NewReader(`{"model":"test","input":["a"]}`))
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/embed", body)
+	require.NoError(t, err)
+	resp, err := ollamago.WithLogger(logger)(http.DefaultTransport).RoundTrip(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.True(t, req.Body == body, "the request body is not replaced")
+}
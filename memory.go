@@ -0,0 +1,139 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Memory preserves information from messages that a Conversation evicts
+// from its history when trimming.
+type Memory interface {
+	// Evict is called with the messages about to be dropped from the
+	// history. If it fails, the history is left untouched.
+	Evict(ctx context.Context, evicted []ChatMessage) error
+
+	// Context returns the messages to pin ahead of the history.
+	Context() []ChatMessage
+}
+
+// DefaultSummaryPrompt is the instruction used by SummaryMemory when Prompt
+// is empty.
+const DefaultSummaryPrompt = "Update the running summary of a conversation with the new turns below. " +
+	"Keep every fact, name, decision and open question that may matter later. " +
+	"Reply with the updated summary only."
+
+// SummaryMemory is a Memory that uses a model to fold evicted turns into a
+// running summary, which is pinned as a system message.
+type SummaryMemory struct {
+	// Client is used to generate summaries.
+	Client API
+
+	// Model is the model that writes the summaries.
+	Model string
+
+	// Prompt is the summarization instruction. If empty,
+	// DefaultSummaryPrompt is used.
+	Prompt string
+
+	// Options are the model parameters used for summarization.
+	Options ModelParameters
+
+	mu      sync.Mutex
+	summary string
+}
+
+// Summary returns the current running summary.
+func (s *SummaryMemory) Summary() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.summary
+}
+
+// Context implements Memory.
+func (s *SummaryMemory) Context() []ChatMessage {
+	summary := s.Summary()
+	if summary == "" {
+		return nil
+	}
+	return []ChatMessage{{
+		Role:    RoleSystem,
+		Content: "Summary of the earlier conversation:\n" + summary,
+	}}
+}
+
+// Evict implements Memory.
+func (s *SummaryMemory) Evict(ctx context.Context, evicted []ChatMessage) error {
+	if s.Client == nil {
+		return errors.New("summary memory has no client")
+	}
+	prompt := s.Prompt
+	if prompt == "" {
+		prompt = DefaultSummaryPrompt
+	}
+	var input strings.Builder
+	if summary := s.Summary(); summary != "" {
+		fmt.Fprintf(&input, "Current summary:\n%s\n\n", summary)
+	}
+	input.WriteString("New turns:\n")
+	for _, m := range evicted {
+		fmt.Fprintf(&input, "%s: %s\n", m.Role, m.Content)
+	}
+	resp, err := s.Client.GenerateChat(ctx, ChatRequest{
+		Model: s.Model,
+		Messages: []ChatMessage{
+			{Role: RoleSystem, Content: prompt},
+			{Role: RoleUser, Content: input.String()},
+		},
+		Stream:  true,
+		Options: s.Options,
+	})
+	if err != nil {
+		return fmt.Errorf("cannot summarize history: %w", err)
+	}
+	reply, err := collectChat(resp)
+	if err != nil {
+		return fmt.Errorf("cannot summarize history: %w", err)
+	}
+	s.mu.Lock()
+	s.summary = strings.TrimSpace(reply.Content)
+	s.mu.Unlock()
+	return nil
+}
+
+// collectChat drains a chat stream and returns the assembled reply.
+func collectChat(resp <-chan ChatResponse) (ChatMessage, error) {
+	var (
+		msg     ChatMessage
+		content strings.Builder
+		err     error
+	)
+	for r := range resp {
+		if r.Error != nil && err == nil {
+			err = r.Error
+		}
+		if r.Message.Role != "" {
+			msg.Role = r.Message.Role
+		}
+		content.WriteString(r.Message.Content)
+		msg.ToolCalls = append(msg.ToolCalls, r.Message.ToolCalls...)
+	}
+	msg.Content = content.String()
+	return msg, err
+}
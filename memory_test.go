@@ -0,0 +1,65 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamagotest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummaryMemory(t *testing.T) {
+	mock := &ollamagotest.MockClient{
+		GenerateChatFunc: func(ctx context.Context, req ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error) {
+			reply := "ok"
+			if req.Model == "summarizer" {
+				reply = "the user said one"
+			}
+			return ollamagotest.Stream(ollamago.ChatResponse{
+				Message: ollamago.ChatMessage{Role: "assistant", Content: reply},
+				Done:    true,
+			}), nil
+		},
+	}
+	memory := &ollamago.SummaryMemory{Client: mock, Model: "summarizer"}
+	conv := &ollamago.Conversation{
+		Client: mock,
+		Model:  "test",
+		Budget: 2,
+		Tokens: func(ollamago.ChatMessage) int { return 1 },
+		Memory: memory,
+	}
+	for _, msg := range []string{"one", "two"} {
+		resp, err := conv.Send(context.Background(), msg)
+		require.NoError(t, err)
+		for range resp {
+		}
+	}
+	require.Equal(t, "the user said one", memory.Summary())
+
+	calls := mock.CallsTo("GenerateChat")
+	require.Len(t, calls, 3)
+	summarize := calls[1].Request.(ollamago.ChatRequest)
+	require.Equal(t, "summarizer", summarize.Model)
+	require.Contains(t, summarize.Messages[1].Content, "user: one")
+
+	last := calls[2].Request.(ollamago.ChatRequest)
+	require.Equal(t, "system", last.Messages[0].Role)
+	require.Contains(t, last.Messages[0].Content, "the user said one")
+	require.Equal(t, "two", last.Messages[len(last.Messages)-1].Content)
+}
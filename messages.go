@@ -0,0 +1,121 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// Roles of the author of a ChatMessage.
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+	RoleTool      = "tool"
+)
+
+// SystemMessage returns a message with the system instructions.
+func SystemMessage(content string) ChatMessage {
+	return ChatMessage{Role: RoleSystem, Content: content}
+}
+
+// UserMessage returns a message written by the user.
+func UserMessage(content string) ChatMessage {
+	return ChatMessage{Role: RoleUser, Content: content}
+}
+
+// UserMessageWithImages returns a message written by the user, attaching
+// base64-encoded images.
+func UserMessageWithImages(content string, images ...string) ChatMessage {
+	return ChatMessage{Role: RoleUser, Content: content, Images: images}
+}
+
+// AssistantMessage returns a message written by the model, as when
+// replaying a conversation.
+func AssistantMessage(content string) ChatMessage {
+	return ChatMessage{Role: RoleAssistant, Content: content}
+}
+
+// ToolResult returns the message answering the tool call with the given
+// ID. The ID may be empty, as servers that do not identify tool calls
+// match results to calls by their order.
+func ToolResult(callID, content string) ChatMessage {
+	return ChatMessage{Role: RoleTool, Content: content, ToolCallID: callID}
+}
+
+// AssistantToolCalls returns a message written by the model requesting
+// the given tool calls, as when replaying a conversation.
+func AssistantToolCalls(content string, calls ...ToolCall) ChatMessage {
+	return ChatMessage{Role: RoleAssistant, Content: content, ToolCalls: calls}
+}
+
+// ToolResultFor returns the message answering call, identifying it by
+// both its ID and its function name.
+func ToolResultFor(call ToolCall, content string) ChatMessage {
+	return ChatMessage{Role: RoleTool, Content: content, ToolCallID: call.ID, ToolName: call.Function.Name}
+}
+
+// FollowUp returns req continued with the assistant reply requesting tool
+// calls and with the results of those calls, to send back to the model.
+// Each result answers the call with its ToolCallID, or else the first
+// unanswered call of its ToolName, or else the first unanswered call; the
+// results are reordered to follow the calls and completed with the ID and
+// name of their call. Every call must have exactly one result. The
+// messages of req are not modified.
+func FollowUp(req ChatRequest, reply ChatMessage, results ...ChatMessage) (ChatRequest, error) {
+	calls := reply.ToolCalls
+	if len(calls) == 0 {
+		return req, errors.New("cannot follow up: reply has no tool calls")
+	}
+	answers := make([]*ChatMessage, len(calls))
+	for i := range results {
+		result := results[i]
+		j := slices.IndexFunc(calls, func(call ToolCall) bool {
+			return result.ToolCallID != "" && call.ID == result.ToolCallID
+		})
+		if j < 0 && result.ToolCallID != "" {
+			return req, fmt.Errorf("cannot follow up: result %d answers unknown tool call %q", i, result.ToolCallID)
+		}
+		for k := 0; j < 0 && k < len(calls); k++ {
+			if answers[k] == nil && (result.ToolName == "" || calls[k].Function.Name == result.ToolName) {
+				j = k
+			}
+		}
+		if j < 0 {
+			return req, fmt.Errorf("cannot follow up: result %d answers no pending tool call", i)
+		}
+		if answers[j] != nil {
+			return req, fmt.Errorf("cannot follow up: tool call %d has more than one result", j)
+		}
+		result.Role = RoleTool
+		result.ToolCallID = calls[j].ID
+		result.ToolName = calls[j].Function.Name
+		answers[j] = &result
+	}
+	messages := make([]ChatMessage, 0, len(req.Messages)+1+len(calls))
+	messages = append(messages, req.Messages...)
+	reply.Role = RoleAssistant
+	messages = append(messages, reply)
+	for j, answer := range answers {
+		if answer == nil {
+			return req, fmt.Errorf("cannot follow up: tool call %d (%s) has no result", j, calls[j].Function.Name)
+		}
+		messages = append(messages, *answer)
+	}
+	req.Messages = messages
+	return req, nil
+}
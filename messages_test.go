@@ -0,0 +1,79 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"cirello.io/ollamago"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessageConstructors(t *testing.T) {
+	messages := []ollamago.ChatMessage{
+		ollamago.SystemMessage("be brief"),
+		ollamago.UserMessageWithImages("what is this?", "aGk="),
+		ollamago.AssistantMessage("a cat"),
+		ollamago.ToolResult("call_1", "42"),
+	}
+	b, err := json.Marshal(messages)
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"role":"system","content":"be brief"},
+		{"role":"user","content":"what is this?","images":["aGk="]},
+		{"role":"assistant","content":"a cat"},
+		{"role":"tool","content":"42","tool_call_id":"call_1"}
+	]`, string(b))
+	require.NoError(t, ollamago.ChatRequest{Model: "test", Messages: messages}.Validate())
+	require.Equal(t, ollamago.ChatMessage{Role: ollamago.RoleUser, Content: "hi"}, ollamago.UserMessage("hi"))
+}
+
+func TestFollowUp(t *testing.T) {
+	add := ollamago.ToolCall{ID: "call_1", Function: ollamago.ToolCallFunction{Name: "add", Arguments: json.RawMessage(`{"a":1}`)}}
+	search := ollamago.ToolCall{ID: "call_2", Function: ollamago.ToolCallFunction{Name: "search", Arguments: json.RawMessage(`{"q":"go"}`)}}
+	weather := ollamago.ToolCall{Function: ollamago.ToolCallFunction{Name: "weather"}}
+	reply := ollamago.AssistantToolCalls("", add, search, weather)
+	req := ollamago.ChatRequest{Model: "test", Messages: []ollamago.ChatMessage{ollamago.UserMessage("go")}}
+
+	next, err := ollamago.FollowUp(req, reply,
+		ollamago.ChatMessage{Role: ollamago.RoleTool, Content: "sunny", ToolName: "weather"},
+		ollamago.ToolResult("call_2", "golang.org"),
+		ollamago.ToolResultFor(add, "2"),
+	)
+	require.NoError(t, err)
+	require.Len(t, req.Messages, 1, "the original request is left alone")
+	require.Equal(t, []ollamago.ChatMessage{
+		ollamago.UserMessage("go"),
+		reply,
+		{Role: ollamago.RoleTool, Content: "2", ToolCallID: "call_1", ToolName: "add"},
+		{Role: ollamago.RoleTool, Content: "golang.org", ToolCallID: "call_2", ToolName: "search"},
+		{Role: ollamago.RoleTool, Content: "sunny", ToolName: "weather"},
+	}, next.Messages)
+	require.NoError(t, next.Validate())
+
+	next, err = ollamago.FollowUp(req, reply, ollamago.ToolResult("", "2"), ollamago.ToolResult("", "golang.org"), ollamago.ToolResult("", "sunny"))
+	require.NoError(t, err)
+	require.Equal(t, "call_2", next.Messages[3].ToolCallID, "results without ID or name answer the calls in order")
+
+	_, err = ollamago.FollowUp(req, reply, ollamago.ToolResultFor(add, "2"))
+	require.EqualError(t, err, "cannot follow up: tool call 1 (search) has no result")
+	_, err = ollamago.FollowUp(req, reply, ollamago.ToolResult("call_9", "2"))
+	require.EqualError(t, err, `cannot follow up: result 0 answers unknown tool call "call_9"`)
+	_, err = ollamago.FollowUp(req, reply, ollamago.ToolResultFor(add, "2"), ollamago.ToolResultFor(add, "2"))
+	require.EqualError(t, err, "cannot follow up: tool call 0 has more than one result")
+	_, err = ollamago.FollowUp(req, ollamago.AssistantMessage("hi"))
+	require.EqualError(t, err, "cannot follow up: reply has no tool calls")
+}
@@ -0,0 +1,77 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LoadModel loads the named model into memory so that the first request
+// does not pay for it, keeping it loaded for keepAlive afterwards. A zero
+// keepAlive uses the server default and a negative one keeps the model
+// loaded until it is unloaded.
+func LoadModel(ctx context.Context, client API, name string, keepAlive time.Duration) error {
+	req := CompletionRequest{Model: name}
+	if keepAlive != 0 {
+		d := Duration(keepAlive)
+		req.KeepAlive = &d
+	}
+	if err := drainCompletion(ctx, client, req); err != nil {
+		return fmt.Errorf("cannot load %s: %w", name, err)
+	}
+	return nil
+}
+
+// UnloadModel evicts the named model from memory.
+func UnloadModel(ctx context.Context, client API, name string) error {
+	var d Duration
+	if err := drainCompletion(ctx, client, CompletionRequest{Model: name, KeepAlive: &d}); err != nil {
+		return fmt.Errorf("cannot unload %s: %w", name, err)
+	}
+	return nil
+}
+
+func drainCompletion(ctx context.Context, client API, req CompletionRequest) error {
+	resp, err := client.GenerateCompletion(ctx, req)
+	if err != nil {
+		return err
+	}
+	var errs error
+	for r := range resp {
+		if r.Error != nil && errs == nil {
+			errs = r.Error
+		}
+	}
+	if errs != nil {
+		return errs
+	}
+	return ctx.Err()
+}
+
+// sameModel reports whether two model names refer to the same model, the
+// tag defaulting to "latest".
+func sameModel(a, b string) bool {
+	return withTag(a) == withTag(b)
+}
+
+func withTag(name string) string {
+	if strings.Contains(name[strings.LastIndex(name, "/")+1:], ":") {
+		return name
+	}
+	return name + ":latest"
+}
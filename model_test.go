@@ -0,0 +1,47 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadModel(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test", Chunks: []string{"a"}})
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+	ctx := context.Background()
+
+	require.NoError(t, ollamago.LoadModel(ctx, client, "test", time.Hour))
+	ps, err := client.ListRunningModels(ctx)
+	require.NoError(t, err)
+	require.Len(t, ps.Models, 1)
+	require.WithinDuration(t, time.Now().Add(time.Hour), ps.Models[0].ExpiresAt, time.Minute)
+	require.JSONEq(t, `{"model":"test","keep_alive":"1h0m0s","options":{}}`, string(srv.Requests()[0].Body))
+
+	require.NoError(t, ollamago.UnloadModel(ctx, client, "test"))
+	ps, err = client.ListRunningModels(ctx)
+	require.NoError(t, err)
+	require.Empty(t, ps.Models)
+
+	err = ollamago.LoadModel(ctx, client, "missing", 0)
+	require.ErrorContains(t, err, "not found")
+}
@@ -0,0 +1,183 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Modelfile builds the definition of a model. Its methods return the
+// Modelfile itself so that calls can be chained:
+//
+//	mf := new(ollamago.Modelfile).
+//		From("llama3.2").
+//		Parameter("temperature", 0.2).
+//		System("You are a terse assistant.")
+//	progress, err := client.CreateModel(ctx, mf.Request("terse"))
+type Modelfile struct {
+	from       string
+	parameters []modelfileParameter
+	template   string
+	system     string
+	adapters   []string
+	licenses   []string
+	messages   []ChatMessage
+}
+
+type modelfileParameter struct {
+	name  string
+	value any
+}
+
+// From sets the base model, a model name or the path of weights.
+func (m *Modelfile) From(model string) *Modelfile {
+	m.from = model
+	return m
+}
+
+// Parameter adds a model parameter, such as "temperature" or "num_ctx".
+// Parameters added more than once, like "stop", take every value.
+func (m *Modelfile) Parameter(name string, value any) *Modelfile {
+	m.parameters = append(m.parameters, modelfileParameter{name, value})
+	return m
+}
+
+// Template sets the prompt template.
+func (m *Modelfile) Template(template string) *Modelfile {
+	m.template = template
+	return m
+}
+
+// System sets the system message.
+func (m *Modelfile) System(system string) *Modelfile {
+	m.system = system
+	return m
+}
+
+// Adapter adds the path of a LoRA adapter to apply to the model.
+func (m *Modelfile) Adapter(path string) *Modelfile {
+	m.adapters = append(m.adapters, path)
+	return m
+}
+
+// License adds the text of a license of the model.
+func (m *Modelfile) License(license string) *Modelfile {
+	m.licenses = append(m.licenses, license)
+	return m
+}
+
+// Message adds a message to the history of every conversation.
+func (m *Modelfile) Message(role, content string) *Modelfile {
+	m.messages = append(m.messages, ChatMessage{Role: role, Content: content})
+	return m
+}
+
+// String renders the Modelfile text. The format has no escapes: the
+// values it cannot quote, which hold triple quotes or end with a double
+// quote, are left out.
+func (m *Modelfile) String() string {
+	text, _ := m.text()
+	return text
+}
+
+// text renders the Modelfile text, reporting whether every value could be
+// quoted.
+func (m *Modelfile) text() (string, bool) {
+	var sb strings.Builder
+	complete := true
+	quoted := func(instruction, s string) {
+		q, ok := modelfileQuote(s)
+		if !ok {
+			complete = false
+			return
+		}
+		fmt.Fprintf(&sb, "%s %s\n", instruction, q)
+	}
+	fmt.Fprintf(&sb, "FROM %s\n", m.from)
+	for _, p := range m.parameters {
+		if s, ok := p.value.(string); ok && (s == "" || strings.ContainsAny(s, " \t\n\"")) {
+			quoted("PARAMETER "+p.name, s)
+			continue
+		}
+		fmt.Fprintf(&sb, "PARAMETER %s %v\n", p.name, p.value)
+	}
+	if m.template != "" {
+		quoted("TEMPLATE", m.template)
+	}
+	if m.system != "" {
+		quoted("SYSTEM", m.system)
+	}
+	for _, a := range m.adapters {
+		fmt.Fprintf(&sb, "ADAPTER %s\n", a)
+	}
+	for _, l := range m.licenses {
+		quoted("LICENSE", l)
+	}
+	for _, msg := range m.messages {
+		quoted("MESSAGE "+msg.Role, msg.Content)
+	}
+	return sb.String(), complete
+}
+
+// modelfileQuote quotes a value, using triple quotes for text that spans
+// several lines or holds double quotes. It reports false for the text
+// that triple quotes cannot hold either: text holding triple quotes, or
+// ending with a double quote, which would run into the closing ones.
+func modelfileQuote(s string) (string, bool) {
+	switch {
+	case !strings.ContainsAny(s, "\n\""):
+		return `"` + s + `"`, true
+	case !strings.Contains(s, `"""`) && !strings.HasSuffix(s, `"`):
+		return `"""` + s + `"""`, true
+	}
+	return "", false
+}
+
+// Request returns the request creating the model with the given name. It
+// carries both the structured definition and the Modelfile text, so that
+// both current and older servers understand it. Adapters are only part of
+// the text, as current servers take them as uploaded blobs. The text is
+// left out if it cannot hold every value, so that older servers reject the
+// request rather than create a different model.
+func (m *Modelfile) Request(name string) CreateModelRequest {
+	req := CreateModelRequest{
+		Model:    name,
+		From:     m.from,
+		Template: m.template,
+		System:   m.system,
+		License:  m.licenses,
+		Messages: m.messages,
+	}
+	if text, ok := m.text(); ok {
+		req.Modelfile = text
+	}
+	counts := make(map[string]int)
+	for _, p := range m.parameters {
+		counts[p.name]++
+	}
+	for _, p := range m.parameters {
+		if req.Parameters == nil {
+			req.Parameters = make(map[string]any)
+		}
+		if counts[p.name] == 1 && p.name != "stop" {
+			req.Parameters[p.name] = p.value
+			continue
+		}
+		values, _ := req.Parameters[p.name].([]any)
+		req.Parameters[p.name] = append(values, p.value)
+	}
+	return req
+}
@@ -0,0 +1,113 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModelfile(t *testing.T) {
+	mf := new(ollamago.Modelfile).
+		From("llama3.2").
+		Parameter("temperature", 0.2).
+		Parameter("stop", "<|eot_id|>").
+		Parameter("stop", "### User").
+		Template("{{ .System }}\n{{ .Prompt }}").
+		System("You are terse.").
+		Adapter("./lora.gguf").
+		License("MIT").
+		Message("user", "Hi").
+		Message("assistant", `Say "hi" back.`)
+	require.Equal(t, `FROM llama3.2
+PARAMETER temperature 0.2
+PARAMETER stop <|eot_id|>
+PARAMETER stop "### User"
+TEMPLATE """{{ .System }}
+{{ .Prompt }}"""
+SYSTEM "You are terse."
+ADAPTER ./lora.gguf
+LICENSE "MIT"
+MESSAGE user "Hi"
+MESSAGE assistant """Say "hi" back."""
+`, mf.String())
+
+	req := mf.Request("terse")
+	require.Equal(t, "terse", req.Model)
+	require.Equal(t, "llama3.2", req.From)
+	require.Equal(t, "You are terse.", req.System)
+	require.Equal(t, []string{"MIT"}, req.License)
+	require.Equal(t, map[string]any{
+		"temperature": 0.2,
+		"stop":        []any{"<|eot_id|>", "### User"},
+	}, req.Parameters)
+	require.Len(t, req.Messages, 2)
+	require.Equal(t, mf.String(), req.Modelfile)
+}
+
+func TestModelfileQuoting(t *testing.T) {
+	mf := new(ollamago.Modelfile).
+		From("llama3.2").
+		Parameter("stop", `"`).
+		System(`Say "hi".`).
+		Message("user", `Quote: """`).
+		Message("assistant", `He said "hi"`)
+	require.Equal(t, `FROM llama3.2
+SYSTEM """Say "hi"."""
+`, mf.String(), "values triple quotes cannot hold are left out")
+
+	req := mf.Request("quoted")
+	require.Empty(t, req.Modelfile, "incomplete texts are not sent")
+	require.Equal(t, `Say "hi".`, req.System)
+	require.Equal(t, `Quote: """`, req.Messages[0].Content)
+	require.Equal(t, map[string]any{"stop": []any{`"`}}, req.Parameters)
+}
+
+func TestCreateModel(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "llama3.2", Chunks: []string{"hi"}})
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+	ctx := context.Background()
+
+	mf := new(ollamago.Modelfile).From("llama3.2").System("You are terse.").Parameter("num_ctx", 4096)
+	progress, err := client.CreateModel(ctx, mf.Request("terse"))
+	require.NoError(t, err)
+	var last ollamago.ProgressEvent
+	for p := range progress {
+		require.NoError(t, p.Error)
+		last = p
+	}
+	require.Equal(t, "success", last.Status)
+
+	show, err := client.ShowModelInfo(ctx, ollamago.ShowModelRequest{Model: "terse"})
+	require.NoError(t, err)
+	require.Equal(t, "You are terse.", show.System)
+	require.Equal(t, "num_ctx 4096", show.Parameters)
+
+	legacy := ollamago.CreateModelRequest{Model: "legacy", Modelfile: mf.String()}
+	progress, err = client.CreateModel(ctx, legacy)
+	require.NoError(t, err)
+	for range progress {
+	}
+	_, err = client.ShowModelInfo(ctx, ollamago.ShowModelRequest{Model: "legacy"})
+	require.NoError(t, err, "the base model is read from the Modelfile text")
+
+	_, err = client.CreateModel(ctx, new(ollamago.Modelfile).From("missing").Request("x"))
+	require.ErrorContains(t, err, "not found")
+}
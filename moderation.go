@@ -0,0 +1,292 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ModerationStage tells whether moderated text is sent to or generated by
+// the model.
+type ModerationStage int
+
+const (
+	// ModerateInput is the moderation of prompts and tool results before
+	// generation.
+	ModerateInput ModerationStage = iota
+
+	// ModerateOutput is the moderation of the generated text.
+	ModerateOutput
+)
+
+func (s ModerationStage) String() string {
+	if s == ModerateOutput {
+		return "output"
+	}
+	return "input"
+}
+
+// ModerationAction is what happens to moderated text, from the mildest to
+// the strictest.
+type ModerationAction int
+
+const (
+	// ModerationAllow lets the text through.
+	ModerationAllow ModerationAction = iota
+
+	// ModerationAnnotate lets the text through, reporting the verdict to
+	// Moderated.OnVerdict.
+	ModerationAnnotate
+
+	// ModerationRewrite replaces the text with Verdict.Text.
+	ModerationRewrite
+
+	// ModerationBlock fails the call with a *ModerationError.
+	ModerationBlock
+)
+
+// Verdict is the outcome of a moderation.
+type Verdict struct {
+	Action ModerationAction
+
+	// Labels are the flagged categories, such as "violence".
+	Labels []string
+	Reason string
+
+	// Text replaces the moderated text when Action is ModerationRewrite.
+	Text string
+}
+
+// Moderator judges the text of a call.
+type Moderator interface {
+	Moderate(ctx context.Context, stage ModerationStage, text string) (Verdict, error)
+}
+
+// ModeratorFunc adapts a function to Moderator.
+type ModeratorFunc func(ctx context.Context, stage ModerationStage, text string) (Verdict, error)
+
+func (f ModeratorFunc) Moderate(ctx context.Context, stage ModerationStage, text string) (Verdict, error) {
+	return f(ctx, stage, text)
+}
+
+// ModerationError is returned when a moderator blocks a call.
+type ModerationError struct {
+	Stage   ModerationStage
+	Verdict Verdict
+}
+
+func (e *ModerationError) Error() string {
+	msg := "moderation blocked the " + e.Stage.String()
+	if len(e.Verdict.Labels) > 0 {
+		msg += " (" + strings.Join(e.Verdict.Labels, ", ") + ")"
+	}
+	if e.Verdict.Reason != "" {
+		msg += ": " + e.Verdict.Reason
+	}
+	return msg
+}
+
+// DefaultModerationCategories are the categories flagged by LLMModerator
+// when none are given.
+var DefaultModerationCategories = []string{"hate", "harassment", "violence", "self-harm", "sexual content", "illegal activity"}
+
+// LLMModerator returns a moderator that has model, typically a small and
+// fast one, classify the text against the categories, blocking flagged
+// text.
+func LLMModerator(client API, model string, categories ...string) Moderator {
+	if len(categories) == 0 {
+		categories = DefaultModerationCategories
+	}
+	system := "You are a content moderator. Flag the text if it contains any of these categories: " +
+		strings.Join(categories, ", ") + ". Only list flagged categories from that list."
+	return ModeratorFunc(func(ctx context.Context, stage ModerationStage, text string) (Verdict, error) {
+		result, err := ChatInto[struct {
+			Flagged    bool     `json:"flagged"`
+			Categories []string `json:"categories" description:"the flagged categories"`
+			Reason     string   `json:"reason" description:"a short justification"`
+		}](ctx, client, ChatRequest{
+			Model:    model,
+			Messages: []ChatMessage{SystemMessage(system), UserMessage(text)},
+			Options:  ModelParameters{Temperature: Ptr(0.0)},
+		}, WithJSONRepair(), WithRetries(1))
+		if err != nil {
+			return Verdict{}, fmt.Errorf("cannot moderate %s: %w", stage, err)
+		}
+		if !result.Flagged {
+			return Verdict{}, nil
+		}
+		return Verdict{Action: ModerationBlock, Labels: result.Categories, Reason: result.Reason}, nil
+	})
+}
+
+// Moderated wraps an API, moderating the prompts of chats and completions
+// before generation and their outputs after. Agents moderate every turn
+// when given a Moderated client. Moderating outputs buffers each response
+// until it is complete.
+type Moderated struct {
+	API
+
+	// Input and Output moderate prompts and generated text, in order;
+	// the text rewritten by a moderator is passed to the next one. Empty
+	// text is not moderated.
+	Input  []Moderator
+	Output []Moderator
+
+	// OnVerdict, if set, is called with every verdict other than
+	// ModerationAllow.
+	OnVerdict func(ctx context.Context, stage ModerationStage, v Verdict)
+}
+
+var _ API = (*Moderated)(nil)
+
+// moderate runs the moderators of stage over text, returning the text to
+// use and whether it was rewritten.
+func (m *Moderated) moderate(ctx context.Context, stage ModerationStage, text string) (string, bool, error) {
+	moderators := m.Input
+	if stage == ModerateOutput {
+		moderators = m.Output
+	}
+	if text == "" {
+		return text, false, nil
+	}
+	rewritten := false
+	for _, mod := range moderators {
+		v, err := mod.Moderate(ctx, stage, text)
+		if err != nil {
+			return "", false, err
+		}
+		if v.Action != ModerationAllow && m.OnVerdict != nil {
+			m.OnVerdict(ctx, stage, v)
+		}
+		switch v.Action {
+		case ModerationBlock:
+			return "", false, &ModerationError{Stage: stage, Verdict: v}
+		case ModerationRewrite:
+			text, rewritten = v.Text, true
+		}
+	}
+	return text, rewritten, nil
+}
+
+// GenerateChat moderates the messages following the last assistant
+// message, which are those new to the model, and the reply.
+func (m *Moderated) GenerateChat(ctx context.Context, req ChatRequest) (<-chan ChatResponse, error) {
+	messages := append([]ChatMessage(nil), req.Messages...)
+	for i := len(messages) - 1; i >= 0 && messages[i].Role != RoleAssistant; i-- {
+		if messages[i].Role == RoleSystem {
+			continue
+		}
+		text, _, err := m.moderate(ctx, ModerateInput, messages[i].Content)
+		if err != nil {
+			return nil, err
+		}
+		messages[i].Content = text
+	}
+	req.Messages = messages
+	resp, err := m.API.GenerateChat(ctx, req)
+	if err != nil || len(m.Output) == 0 {
+		return resp, err
+	}
+	out := make(chan ChatResponse)
+	go func() {
+		defer close(out)
+		var (
+			chunks  []ChatResponse
+			content strings.Builder
+		)
+		for r := range resp {
+			if r.Error != nil {
+				// The partial output is unmoderated: only the error is
+				// relayed.
+				send(ctx, out, r)
+				for range resp {
+				}
+				return
+			}
+			chunks = append(chunks, r)
+			content.WriteString(r.Message.Content)
+		}
+		text, rewritten, err := m.moderate(ctx, ModerateOutput, content.String())
+		if err != nil {
+			send(ctx, out, ChatResponse{Error: err})
+			return
+		}
+		if rewritten && len(chunks) > 0 {
+			last := chunks[len(chunks)-1]
+			last.Message.Role = RoleAssistant
+			last.Message.Content = text
+			last.Message.ToolCalls = nil
+			for _, c := range chunks {
+				last.Message.ToolCalls = append(last.Message.ToolCalls, c.Message.ToolCalls...)
+			}
+			chunks = []ChatResponse{last}
+		}
+		for _, c := range chunks {
+			if !send(ctx, out, c) {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// GenerateCompletion moderates the prompt and the generated text.
+func (m *Moderated) GenerateCompletion(ctx context.Context, req CompletionRequest) (<-chan CompletionResponse, error) {
+	prompt, _, err := m.moderate(ctx, ModerateInput, req.Prompt)
+	if err != nil {
+		return nil, err
+	}
+	req.Prompt = prompt
+	resp, err := m.API.GenerateCompletion(ctx, req)
+	if err != nil || len(m.Output) == 0 {
+		return resp, err
+	}
+	out := make(chan CompletionResponse)
+	go func() {
+		defer close(out)
+		var (
+			chunks  []CompletionResponse
+			content strings.Builder
+		)
+		for r := range resp {
+			if r.Error != nil {
+				send(ctx, out, r)
+				for range resp {
+				}
+				return
+			}
+			chunks = append(chunks, r)
+			content.WriteString(r.Response)
+		}
+		text, rewritten, err := m.moderate(ctx, ModerateOutput, content.String())
+		if err != nil {
+			send(ctx, out, CompletionResponse{Error: err})
+			return
+		}
+		if rewritten && len(chunks) > 0 {
+			last := chunks[len(chunks)-1]
+			last.Response = text
+			chunks = []CompletionResponse{last}
+		}
+		for _, c := range chunks {
+			if !send(ctx, out, c) {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,201 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CallStats describes a finished call to the Ollama server.
+type CallStats struct {
+	// Request is the HTTP request sent to the server.
+	Request *http.Request
+
+	// Endpoint is the path of the request, such as "/api/chat".
+	Endpoint string
+
+	// Model is the model named in the request body, if any.
+	Model string
+
+	// Status is the HTTP status code, or zero if no response was
+	// received.
+	Status int
+
+	// Err is the transport or body read error, if any.
+	Err error
+
+	// Start is when the request was sent.
+	Start time.Time
+
+	// FirstByte is the time until the first byte of the response body,
+	// the time to first token of streamed calls.
+	FirstByte time.Duration
+
+	// Duration is the time until the response body was read or closed.
+	Duration time.Duration
+
+	// PromptTokens and GeneratedTokens are the token counts reported by
+	// the server in the final response chunk.
+	PromptTokens    int
+	GeneratedTokens int
+
+	// EvalDuration is the generation time reported by the server.
+	EvalDuration time.Duration
+
+	// DoneReason is why the server stopped generating, such as "stop" or
+	// "length", when reported.
+	DoneReason string
+}
+
+// TokensPerSecond is the generation throughput, based on the server
+// reported evaluation time when available.
+func (s CallStats) TokensPerSecond() float64 {
+	d := s.EvalDuration
+	if d <= 0 {
+		d = s.Duration - s.FirstByte
+	}
+	if d <= 0 || s.GeneratedTokens == 0 {
+		return 0
+	}
+	return float64(s.GeneratedTokens) / d.Seconds()
+}
+
+// Observe returns an Interceptor that calls fn once per call, after its
+// response has been consumed. fn is called from the goroutine reading the
+// response and must not block.
+func Observe(fn func(CallStats)) Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			stats := CallStats{Request: req, Endpoint: req.URL.Path, Start: time.Now()}
+			if req.Body != nil {
+				body, err := io.ReadAll(req.Body)
+				req.Body.Close()
+				if err != nil {
+					stats.Err = err
+					stats.Duration = time.Since(stats.Start)
+					fn(stats)
+					return nil, err
+				}
+				req = req.Clone(req.Context())
+				req.Body = io.NopCloser(bytes.NewReader(body))
+				req.ContentLength = int64(len(body))
+				var named struct {
+					Model string `json:"model"`
+				}
+				if json.Unmarshal(body, &named) == nil {
+					stats.Model = named.Model
+				}
+			}
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				stats.Err = err
+				stats.Duration = time.Since(stats.Start)
+				fn(stats)
+				return nil, err
+			}
+			stats.Status = resp.StatusCode
+			resp.Body = &observedBody{ReadCloser: resp.Body, stats: stats, report: fn}
+			return resp, nil
+		})
+	}
+}
+
+// finalChunk holds the statistics that the server reports in the last
+// chunk of a response.
+type finalChunk struct {
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	EvalDuration    time.Duration `json:"eval_duration"`
+	DoneReason      string        `json:"done_reason"`
+}
+
+// observedBody tracks the reading of a response body, keeping the last
+// NDJSON line seen to extract the token counts from.
+type observedBody struct {
+	io.ReadCloser
+	report func(CallStats)
+
+	mu       sync.Mutex
+	stats    CallStats
+	last     []byte
+	pending  []byte
+	reported bool
+}
+
+func (b *observedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if n > 0 && b.stats.FirstByte == 0 {
+		b.stats.FirstByte = time.Since(b.stats.Start)
+	}
+	b.scan(p[:n])
+	if err != nil {
+		if err != io.EOF {
+			b.stats.Err = err
+		}
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *observedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.mu.Lock()
+	b.finish()
+	b.mu.Unlock()
+	return err
+}
+
+func (b *observedBody) scan(p []byte) {
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			b.pending = append(b.pending, p...)
+			return
+		}
+		line := append(b.pending, p[:i]...)
+		if len(bytes.TrimSpace(line)) > 0 {
+			b.last = append(b.last[:0], line...)
+		}
+		b.pending = b.pending[:0]
+		p = p[i+1:]
+	}
+}
+
+func (b *observedBody) finish() {
+	if b.reported {
+		return
+	}
+	b.reported = true
+	b.stats.Duration = time.Since(b.stats.Start)
+	last := b.last
+	if len(bytes.TrimSpace(b.pending)) > 0 {
+		last = b.pending
+	}
+	var chunk finalChunk
+	if json.Unmarshal(last, &chunk) == nil {
+		b.stats.PromptTokens = chunk.PromptEvalCount
+		b.stats.GeneratedTokens = chunk.EvalCount
+		b.stats.EvalDuration = chunk.EvalDuration
+		b.stats.DoneReason = chunk.DoneReason
+	}
+	b.report(b.stats)
+}
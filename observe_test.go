@@ -0,0 +1,85 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserve(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test", Chunks: []string{"a", "b", "c"}})
+	t.Cleanup(srv.Close)
+	var (
+		mu    sync.Mutex
+		calls []ollamago.CallStats
+	)
+	client := srv.Client()
+	client.Interceptors = []ollamago.Interceptor{ollamago.Observe(func(s ollamago.CallStats) {
+		mu.Lock()
+		calls = append(calls, s)
+		mu.Unlock()
+	})}
+	ctx := context.Background()
+
+	respChan, err := client.GenerateChat(ctx, ollamago.ChatRequest{
+		Model:    "test",
+		Messages: []ollamago.ChatMessage{{Role: "user", Content: "hi"}},
+		Stream:   true,
+	})
+	require.NoError(t, err)
+	for range respChan {
+	}
+	_, err = client.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: "missing", Input: []string{"a"}})
+	require.Error(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, calls, 2)
+	chat := calls[0]
+	require.Equal(t, "/api/chat", chat.Endpoint)
+	require.Equal(t, "test", chat.Model)
+	require.Equal(t, http.StatusOK, chat.Status)
+	require.NoError(t, chat.Err)
+	require.Equal(t, 3, chat.GeneratedTokens)
+	require.Positive(t, chat.PromptTokens)
+	require.Positive(t, chat.FirstByte)
+	require.GreaterOrEqual(t, chat.Duration, chat.FirstByte)
+	require.InDelta(t, 1000, chat.TokensPerSecond(), 1)
+
+	require.Equal(t, "/api/embed", calls[1].Endpoint)
+	require.Equal(t, "missing", calls[1].Model)
+	require.Equal(t, http.StatusNotFound, calls[1].Status)
+}
+
+func TestObserveLeavesRequestAlone(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test"})
+	t.Cleanup(srv.Close)
+	body := io.NopCloser(strings.NewReader(`{"model":"test","input":["a"]}`))
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/embed", body)
+	require.NoError(t, err)
+	resp, err := ollamago.Observe(func(ollamago.CallStats) {})(http.DefaultTransport).RoundTrip(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.True(t, req.Body == body, "the request body is not replaced")
+}
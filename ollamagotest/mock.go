@@ -0,0 +1,202 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ollamagotest provides test doubles for code that depends on
+// ollamago.API.
+package ollamagotest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"cirello.io/ollamago"
+)
+
+// Call records a single invocation of a MockClient method.
+type Call struct {
+	// Method is the name of the invoked method, e.g. "GenerateChat".
+	Method string
+
+	// Request is the request value passed to the method, or nil for
+	// methods that take none.
+	Request any
+}
+
+// MockClient is a programmable implementation of ollamago.API. Each method
+// delegates to the matching Func field; methods whose Func is nil return an
+// error. Every call is recorded, whether programmed or not.
+type MockClient struct {
+	GenerateCompletionFunc   func(ctx context.Context, req ollamago.CompletionRequest) (<-chan ollamago.CompletionResponse, error)
+	GenerateEmbeddingsFunc   func(ctx context.Context, req ollamago.EmbedRequest) (*ollamago.EmbedResponse, error)
+	GenerateEmbeddings32Func func(ctx context.Context, req ollamago.EmbedRequest) (*ollamago.EmbedResponse32, error)
+	GenerateChatFunc         func(ctx context.Context, req ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error)
+	ListModelsFunc           func(ctx context.Context) (*ollamago.ListModelsResponse, error)
+	ListRunningModelsFunc    func(ctx context.Context) (*ollamago.ListRunningModelsResponse, error)
+	ShowModelInfoFunc        func(ctx context.Context, req ollamago.ShowModelRequest) (*ollamago.ShowModelResponse, error)
+	DeleteModelFunc          func(ctx context.Context, req ollamago.DeleteModelRequest) error
+	PullModelFunc            func(ctx context.Context, req ollamago.PullModelRequest) (<-chan ollamago.ProgressEvent, error)
+	PushModelFunc            func(ctx context.Context, req ollamago.PushModelRequest) (<-chan ollamago.ProgressEvent, error)
+	CreateModelFunc          func(ctx context.Context, req ollamago.CreateModelRequest) (<-chan ollamago.ProgressEvent, error)
+	VersionFunc              func(ctx context.Context) (string, error)
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+var _ ollamago.API = (*MockClient)(nil)
+
+// Calls returns a copy of the calls recorded so far, in invocation order.
+func (m *MockClient) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// CallsTo returns the recorded calls to the named method.
+func (m *MockClient) CallsTo(method string) []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var calls []Call
+	for _, c := range m.calls {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Reset discards the recorded calls.
+func (m *MockClient) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+}
+
+func (m *MockClient) record(method string, req any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, Call{Method: method, Request: req})
+}
+
+func notProgrammed(method string) error {
+	return fmt.Errorf("ollamagotest: %s not programmed", method)
+}
+
+func (m *MockClient) GenerateCompletion(ctx context.Context, req ollamago.CompletionRequest) (<-chan ollamago.CompletionResponse, error) {
+	m.record("GenerateCompletion", req)
+	if m.GenerateCompletionFunc == nil {
+		return nil, notProgrammed("GenerateCompletion")
+	}
+	return m.GenerateCompletionFunc(ctx, req)
+}
+
+func (m *MockClient) GenerateEmbeddings(ctx context.Context, req ollamago.EmbedRequest) (*ollamago.EmbedResponse, error) {
+	m.record("GenerateEmbeddings", req)
+	if m.GenerateEmbeddingsFunc == nil {
+		return nil, notProgrammed("GenerateEmbeddings")
+	}
+	return m.GenerateEmbeddingsFunc(ctx, req)
+}
+
+func (m *MockClient) GenerateEmbeddings32(ctx context.Context, req ollamago.EmbedRequest) (*ollamago.EmbedResponse32, error) {
+	m.record("GenerateEmbeddings32", req)
+	if m.GenerateEmbeddings32Func == nil {
+		return nil, notProgrammed("GenerateEmbeddings32")
+	}
+	return m.GenerateEmbeddings32Func(ctx, req)
+}
+
+func (m *MockClient) GenerateChat(ctx context.Context, req ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error) {
+	m.record("GenerateChat", req)
+	if m.GenerateChatFunc == nil {
+		return nil, notProgrammed("GenerateChat")
+	}
+	return m.GenerateChatFunc(ctx, req)
+}
+
+func (m *MockClient) ListModels(ctx context.Context) (*ollamago.ListModelsResponse, error) {
+	m.record("ListModels", nil)
+	if m.ListModelsFunc == nil {
+		return nil, notProgrammed("ListModels")
+	}
+	return m.ListModelsFunc(ctx)
+}
+
+func (m *MockClient) ListRunningModels(ctx context.Context) (*ollamago.ListRunningModelsResponse, error) {
+	m.record("ListRunningModels", nil)
+	if m.ListRunningModelsFunc == nil {
+		return nil, notProgrammed("ListRunningModels")
+	}
+	return m.ListRunningModelsFunc(ctx)
+}
+
+func (m *MockClient) ShowModelInfo(ctx context.Context, req ollamago.ShowModelRequest) (*ollamago.ShowModelResponse, error) {
+	m.record("ShowModelInfo", req)
+	if m.ShowModelInfoFunc == nil {
+		return nil, notProgrammed("ShowModelInfo")
+	}
+	return m.ShowModelInfoFunc(ctx, req)
+}
+
+func (m *MockClient) DeleteModel(ctx context.Context, req ollamago.DeleteModelRequest) error {
+	m.record("DeleteModel", req)
+	if m.DeleteModelFunc == nil {
+		return notProgrammed("DeleteModel")
+	}
+	return m.DeleteModelFunc(ctx, req)
+}
+
+func (m *MockClient) PullModel(ctx context.Context, req ollamago.PullModelRequest) (<-chan ollamago.ProgressEvent, error) {
+	m.record("PullModel", req)
+	if m.PullModelFunc == nil {
+		return nil, notProgrammed("PullModel")
+	}
+	return m.PullModelFunc(ctx, req)
+}
+
+func (m *MockClient) PushModel(ctx context.Context, req ollamago.PushModelRequest) (<-chan ollamago.ProgressEvent, error) {
+	m.record("PushModel", req)
+	if m.PushModelFunc == nil {
+		return nil, notProgrammed("PushModel")
+	}
+	return m.PushModelFunc(ctx, req)
+}
+
+func (m *MockClient) CreateModel(ctx context.Context, req ollamago.CreateModelRequest) (<-chan ollamago.ProgressEvent, error) {
+	m.record("CreateModel", req)
+	if m.CreateModelFunc == nil {
+		return nil, notProgrammed("CreateModel")
+	}
+	return m.CreateModelFunc(ctx, req)
+}
+
+func (m *MockClient) Version(ctx context.Context) (string, error) {
+	m.record("Version", nil)
+	if m.VersionFunc == nil {
+		return "", notProgrammed("Version")
+	}
+	return m.VersionFunc(ctx)
+}
+
+// Stream returns a closed, buffered channel holding the given values. It is
+// a convenience for programming the streaming methods of MockClient.
+func Stream[T any](values ...T) <-chan T {
+	ch := make(chan T, len(values))
+	for _, v := range values {
+		ch <- v
+	}
+	close(ch)
+	return ch
+}
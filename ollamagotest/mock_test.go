@@ -0,0 +1,56 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamagotest_test
+
+import (
+	"context"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamagotest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockClient(t *testing.T) {
+	m := &ollamagotest.MockClient{
+		GenerateChatFunc: func(ctx context.Context, req ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error) {
+			return ollamagotest.Stream(
+				ollamago.ChatResponse{Model: req.Model, Message: ollamago.ChatMessage{Role: "assistant", Content: "hel"}},
+				ollamago.ChatResponse{Model: req.Model, Message: ollamago.ChatMessage{Role: "assistant", Content: "lo"}, Done: true},
+			), nil
+		},
+	}
+	var api ollamago.API = m
+	respChan, err := api.GenerateChat(context.Background(), ollamago.ChatRequest{Model: "test"})
+	require.NoError(t, err)
+	var content string
+	for r := range respChan {
+		content += r.Message.Content
+	}
+	require.Equal(t, "hello", content)
+
+	_, err = api.Version(context.Background())
+	require.Error(t, err)
+
+	calls := m.Calls()
+	require.Len(t, calls, 2)
+	require.Equal(t, "GenerateChat", calls[0].Method)
+	require.Equal(t, ollamago.ChatRequest{Model: "test"}, calls[0].Request)
+	require.Equal(t, "Version", calls[1].Method)
+	require.Len(t, m.CallsTo("Version"), 1)
+
+	m.Reset()
+	require.Empty(t, m.Calls())
+}
@@ -0,0 +1,274 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ollamagrpc serves the Ollama API over gRPC, proxying the calls
+// to an ollamago.API, so that services written in other languages can
+// reach the Ollama hosts through a single Go service:
+//
+//	srv := grpc.NewServer()
+//	ollamapb.RegisterOllamaServer(srv, &ollamagrpc.Server{Client: pool})
+//	srv.Serve(lis)
+//
+// The service definition is ollamapb/ollama.proto.
+package ollamagrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamagrpc/ollamapb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var _ ollamapb.OllamaServer = (*Server)(nil)
+
+// Server implements the Ollama gRPC service on top of Client.
+type Server struct {
+	ollamapb.UnimplementedOllamaServer
+
+	Client ollamago.API
+}
+
+func (s *Server) Chat(req *ollamapb.ChatRequest, stream grpc.ServerStreamingServer[ollamapb.ChatResponse]) error {
+	chatReq := ollamago.ChatRequest{
+		Model:  req.GetModel(),
+		Format: format(req.GetFormat()),
+		Stream: true,
+	}
+	if err := decodeOptions(req, &chatReq.Options, &chatReq.KeepAlive); err != nil {
+		return err
+	}
+	for _, m := range req.GetMessages() {
+		chatReq.Messages = append(chatReq.Messages, chatMessage(m))
+	}
+	for _, t := range req.GetTools() {
+		tool := ollamago.Tool{Type: "function", Function: ollamago.ToolFunction{Name: t.GetName(), Description: t.GetDescription()}}
+		if t.GetParameters() != "" {
+			tool.Function.Parameters = json.RawMessage(t.GetParameters())
+		}
+		chatReq.Tools = append(chatReq.Tools, tool)
+	}
+	ctx := stream.Context()
+	resp, err := s.Client.GenerateChat(ctx, chatReq)
+	if err != nil {
+		return statusError(ctx, err)
+	}
+	return forward(ctx, resp, stream.Send, func(r ollamago.ChatResponse) (*ollamapb.ChatResponse, error) {
+		return &ollamapb.ChatResponse{
+			Model:           r.Model,
+			Message:         pbMessage(r.Message),
+			Done:            r.Done,
+			TotalDurationNs: int64(r.TotalDuration),
+		}, r.Error
+	})
+}
+
+func (s *Server) Generate(req *ollamapb.GenerateRequest, stream grpc.ServerStreamingServer[ollamapb.GenerateResponse]) error {
+	completionReq := ollamago.CompletionRequest{
+		Model:  req.GetModel(),
+		Prompt: req.GetPrompt(),
+		Images: req.GetImages(),
+		Format: format(req.GetFormat()),
+		Stream: true,
+	}
+	if err := decodeOptions(req, &completionReq.Options, &completionReq.KeepAlive); err != nil {
+		return err
+	}
+	ctx := stream.Context()
+	resp, err := s.Client.GenerateCompletion(ctx, completionReq)
+	if err != nil {
+		return statusError(ctx, err)
+	}
+	return forward(ctx, resp, stream.Send, func(r ollamago.CompletionResponse) (*ollamapb.GenerateResponse, error) {
+		return &ollamapb.GenerateResponse{
+			Model:           r.Model,
+			Response:        r.Response,
+			Done:            r.Done,
+			TotalDurationNs: int64(r.TotalDuration),
+		}, r.Error
+	})
+}
+
+// forward sends the responses of a stream, draining it if the client goes
+// away or the stream fails.
+func forward[T any, P any](ctx context.Context, resp <-chan T, send func(P) error, convert func(T) (P, error)) error {
+	defer func() {
+		for range resp {
+		}
+	}()
+	for r := range resp {
+		msg, err := convert(r)
+		if err != nil {
+			return statusError(ctx, err)
+		}
+		if err := send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) Embed(ctx context.Context, req *ollamapb.EmbedRequest) (*ollamapb.EmbedResponse, error) {
+	resp, err := s.Client.GenerateEmbeddings32(ctx, ollamago.EmbedRequest{Model: req.GetModel(), Input: req.GetInput()})
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	out := &ollamapb.EmbedResponse{Model: resp.Model}
+	for _, e := range resp.Embeddings {
+		out.Embeddings = append(out.Embeddings, &ollamapb.Embedding{Values: e})
+	}
+	return out, nil
+}
+
+func (s *Server) ListModels(ctx context.Context, _ *ollamapb.ListModelsRequest) (*ollamapb.ListModelsResponse, error) {
+	resp, err := s.Client.ListModels(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	out := &ollamapb.ListModelsResponse{}
+	for _, m := range resp.Models {
+		out.Models = append(out.Models, &ollamapb.Model{
+			Name:       m.Name,
+			Size:       m.Size,
+			Digest:     m.Digest,
+			ModifiedAt: timestamppb.New(m.ModifiedAt),
+		})
+	}
+	return out, nil
+}
+
+func (s *Server) Version(ctx context.Context, _ *ollamapb.VersionRequest) (*ollamapb.VersionResponse, error) {
+	version, err := s.Client.Version(ctx)
+	if err != nil {
+		return nil, statusError(ctx, err)
+	}
+	return &ollamapb.VersionResponse{Version: version}, nil
+}
+
+type optionsRequest interface {
+	GetOptions() *structpb.Struct
+	GetKeepAlive() string
+}
+
+// decodeOptions converts the options of a request, which match the
+// options of the Ollama API.
+func decodeOptions(req optionsRequest, options *ollamago.ModelParameters, keepAlive **ollamago.Duration) error {
+	if opts := req.GetOptions(); opts != nil {
+		b, err := opts.MarshalJSON()
+		if err == nil {
+			err = json.Unmarshal(b, options)
+		}
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid options: %v", err)
+		}
+	}
+	if req.GetKeepAlive() != "" {
+		d, err := time.ParseDuration(req.GetKeepAlive())
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid keep_alive: %v", err)
+		}
+		*keepAlive = (*ollamago.Duration)(&d)
+	}
+	return nil
+}
+
+// format accepts both a bare "json" and a JSON schema.
+func format(f string) json.RawMessage {
+	switch f {
+	case "":
+		return nil
+	case "json":
+		return json.RawMessage(`"json"`)
+	default:
+		return json.RawMessage(f)
+	}
+}
+
+func chatMessage(m *ollamapb.ChatMessage) ollamago.ChatMessage {
+	msg := ollamago.ChatMessage{
+		Role:       m.GetRole(),
+		Content:    m.GetContent(),
+		Images:     m.GetImages(),
+		ToolCallID: m.GetToolCallId(),
+	}
+	for _, call := range m.GetToolCalls() {
+		msg.ToolCalls = append(msg.ToolCalls, ollamago.ToolCall{
+			ID:       call.GetId(),
+			Function: ollamago.ToolCallFunction{Name: call.GetName(), Arguments: json.RawMessage(call.GetArguments())},
+		})
+	}
+	return msg
+}
+
+func pbMessage(m ollamago.ChatMessage) *ollamapb.ChatMessage {
+	msg := &ollamapb.ChatMessage{
+		Role:       m.Role,
+		Content:    m.Content,
+		Images:     m.Images,
+		ToolCallId: m.ToolCallID,
+	}
+	for _, call := range m.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, &ollamapb.ToolCall{
+			Id:        call.ID,
+			Name:      call.Function.Name,
+			Arguments: string(call.Function.Arguments),
+		})
+	}
+	return msg
+}
+
+// statusError maps the errors of the client to gRPC status codes.
+func statusError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return status.FromContextError(ctxErr).Err()
+	}
+	var statusErr *ollamago.StatusError
+	switch {
+	case errors.Is(err, ollamago.ErrInvalidRequest):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ollamago.ErrUnsupported):
+		return status.Error(codes.Unimplemented, err.Error())
+	case errors.As(err, &statusErr):
+		return status.Error(httpCode(statusErr.StatusCode), err.Error())
+	default:
+		return status.Error(codes.Unavailable, err.Error())
+	}
+}
+
+func httpCode(statusCode int) codes.Code {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
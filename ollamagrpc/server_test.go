@@ -0,0 +1,123 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamagrpc_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago/ollamagrpc"
+	"cirello.io/ollamago/ollamagrpc/ollamapb"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func newClient(t *testing.T) (ollamapb.OllamaClient, *ollamatest.Server) {
+	t.Helper()
+	upstream := ollamatest.NewServer(ollamatest.Model{Name: "llama3.2", Chunks: []string{"hello ", "world"}})
+	t.Cleanup(upstream.Close)
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	ollamapb.RegisterOllamaServer(srv, &ollamagrpc.Server{Client: upstream.Client()})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return ollamapb.NewOllamaClient(conn), upstream
+}
+
+func TestChat(t *testing.T) {
+	client, upstream := newClient(t)
+	ctx := context.Background()
+	options, err := structpb.NewStruct(map[string]any{"temperature": 0, "num_ctx": 4096})
+	require.NoError(t, err)
+	stream, err := client.Chat(ctx, &ollamapb.ChatRequest{
+		Model:     "llama3.2",
+		Messages:  []*ollamapb.ChatMessage{{Role: "user", Content: "hi"}},
+		Options:   options,
+		KeepAlive: "10m",
+	})
+	require.NoError(t, err)
+	var reply strings.Builder
+	var done bool
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		reply.WriteString(resp.GetMessage().GetContent())
+		done = done || resp.GetDone()
+	}
+	require.Equal(t, "hello world", reply.String())
+	require.True(t, done)
+	require.JSONEq(t, `{"model":"llama3.2","messages":[{"role":"user","content":"hi"}],"stream":true,"options":{"temperature":0,"num_ctx":4096},"keep_alive":"10m0s"}`, string(upstream.Requests()[0].Body))
+
+	stream, err = client.Chat(ctx, &ollamapb.ChatRequest{Model: "missing", Messages: []*ollamapb.ChatMessage{{Role: "user", Content: "hi"}}})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err))
+
+	stream, err = client.Chat(ctx, &ollamapb.ChatRequest{Model: "llama3.2", Messages: []*ollamapb.ChatMessage{{Role: "robot"}}})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGenerate(t *testing.T) {
+	client, _ := newClient(t)
+	stream, err := client.Generate(context.Background(), &ollamapb.GenerateRequest{Model: "llama3.2", Prompt: "hi"})
+	require.NoError(t, err)
+	var reply strings.Builder
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		reply.WriteString(resp.GetResponse())
+	}
+	require.Equal(t, "hello world", reply.String())
+}
+
+func TestUnary(t *testing.T) {
+	client, _ := newClient(t)
+	ctx := context.Background()
+	embeddings, err := client.Embed(ctx, &ollamapb.EmbedRequest{Model: "llama3.2", Input: []string{"a", "b"}})
+	require.NoError(t, err)
+	require.Len(t, embeddings.GetEmbeddings(), 2)
+	require.Len(t, embeddings.GetEmbeddings()[0].GetValues(), 8)
+
+	models, err := client.ListModels(ctx, &ollamapb.ListModelsRequest{})
+	require.NoError(t, err)
+	require.Len(t, models.GetModels(), 1)
+	require.Equal(t, "llama3.2", models.GetModels()[0].GetName())
+
+	version, err := client.Version(ctx, &ollamapb.VersionRequest{})
+	require.NoError(t, err)
+	require.NotEmpty(t, version.GetVersion())
+}
@@ -0,0 +1,18 @@
+module cirello.io/ollamago/ollamalangchain
+
+go 1.23.4
+
+require (
+	cirello.io/ollamago v0.0.0-20261015024735-26b9ac00a869
+	github.com/stretchr/testify v1.10.0
+	github.com/tmc/langchaingo v0.1.13
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/dlclark/regexp2 v1.10.0 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/pkoukk/tiktoken-go v0.1.6 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -0,0 +1,265 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ollamalangchain adapts an ollamago.API to the langchaingo
+// interfaces, so that langchaingo chains and agents can run on top of
+// ollamago.
+package ollamalangchain
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"cirello.io/ollamago"
+	"github.com/tmc/langchaingo/embeddings"
+	"github.com/tmc/langchaingo/llms"
+)
+
+var (
+	_ llms.Model                = (*LLM)(nil)
+	_ embeddings.EmbedderClient = (*LLM)(nil)
+)
+
+// LLM is a langchaingo model backed by an ollamago.API:
+//
+//	llm := &ollamalangchain.LLM{Client: &ollamago.Client{}, Model: "llama3.2"}
+//	answer, err := llms.GenerateFromSinglePrompt(ctx, llm, "Why is the sky blue?")
+//
+// It is also an embeddings.EmbedderClient, for embeddings.NewEmbedder.
+type LLM struct {
+	Client ollamago.API
+
+	// Model is used unless the call sets llms.WithModel.
+	Model string
+
+	// EmbeddingModel is used by CreateEmbedding. If empty, Model is used.
+	EmbeddingModel string
+
+	// Options are sent with every call, overridden by the call options.
+	Options ollamago.ModelParameters
+}
+
+// unset marks the numeric call options left to Options.
+const unset = math.MinInt
+
+func (l *LLM) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	opts := llms.CallOptions{
+		Model:       l.Model,
+		Temperature: math.NaN(),
+		TopP:        math.NaN(),
+		Seed:        unset,
+	}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	req := ollamago.ChatRequest{
+		Model:   opts.Model,
+		Stream:  opts.StreamingFunc != nil,
+		Options: callOptions(l.Options, opts),
+	}
+	if opts.JSONMode {
+		req.Format = json.RawMessage(`"json"`)
+	}
+	for _, tool := range opts.Tools {
+		if tool.Function == nil {
+			continue
+		}
+		params, err := json.Marshal(tool.Function.Parameters)
+		if err != nil {
+			return nil, fmt.Errorf("cannot encode parameters of tool %s: %w", tool.Function.Name, err)
+		}
+		req.Tools = append(req.Tools, ollamago.Tool{
+			Type: "function",
+			Function: ollamago.ToolFunction{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  params,
+			},
+		})
+	}
+	for _, mc := range messages {
+		msgs, err := chatMessages(ctx, mc)
+		if err != nil {
+			return nil, err
+		}
+		req.Messages = append(req.Messages, msgs...)
+	}
+
+	resp, err := l.Client.GenerateChat(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	var content strings.Builder
+	var toolCalls []ollamago.ToolCall
+	var errs error
+	for r := range resp {
+		if errs != nil {
+			continue
+		}
+		if r.Error != nil {
+			errs = r.Error
+			continue
+		}
+		content.WriteString(r.Message.Content)
+		toolCalls = append(toolCalls, r.Message.ToolCalls...)
+		if opts.StreamingFunc != nil && r.Message.Content != "" {
+			errs = opts.StreamingFunc(ctx, []byte(r.Message.Content))
+		}
+	}
+	if errs != nil {
+		return nil, errs
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	choice := &llms.ContentChoice{Content: content.String()}
+	for i, call := range toolCalls {
+		id := call.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d", i)
+		}
+		choice.ToolCalls = append(choice.ToolCalls, llms.ToolCall{
+			ID:           id,
+			Type:         "function",
+			FunctionCall: &llms.FunctionCall{Name: call.Function.Name, Arguments: string(call.Function.Arguments)},
+		})
+	}
+	if len(choice.ToolCalls) > 0 {
+		choice.FuncCall = choice.ToolCalls[0].FunctionCall
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{choice}}, nil
+}
+
+// callOptions overrides the parameters with the call options that are set.
+func callOptions(params ollamago.ModelParameters, opts llms.CallOptions) ollamago.ModelParameters {
+	if !math.IsNaN(opts.Temperature) {
+		params.Temperature = ollamago.Ptr(opts.Temperature)
+	}
+	if !math.IsNaN(opts.TopP) {
+		params.TopP = ollamago.Ptr(opts.TopP)
+	}
+	if opts.Seed != unset {
+		params.Seed = ollamago.Ptr(opts.Seed)
+	}
+	if opts.TopK > 0 {
+		params.TopK = ollamago.Ptr(opts.TopK)
+	}
+	if opts.MaxTokens > 0 {
+		params.NumPredict = ollamago.Ptr(opts.MaxTokens)
+	}
+	if opts.RepetitionPenalty > 0 {
+		params.RepeatPenalty = ollamago.Ptr(opts.RepetitionPenalty)
+	}
+	if opts.FrequencyPenalty != 0 {
+		params.FrequencyPenalty = ollamago.Ptr(opts.FrequencyPenalty)
+	}
+	if opts.PresencePenalty != 0 {
+		params.PresencePenalty = ollamago.Ptr(opts.PresencePenalty)
+	}
+	if len(opts.StopWords) > 0 {
+		params.Stop = ""
+		params.StopSequences = opts.StopWords
+	}
+	return params
+}
+
+var roles = map[llms.ChatMessageType]string{
+	llms.ChatMessageTypeSystem:  ollamago.RoleSystem,
+	llms.ChatMessageTypeHuman:   ollamago.RoleUser,
+	llms.ChatMessageTypeGeneric: ollamago.RoleUser,
+	llms.ChatMessageTypeAI:      ollamago.RoleAssistant,
+	llms.ChatMessageTypeTool:    ollamago.RoleTool,
+}
+
+// chatMessages converts a langchaingo message. Tool responses become
+// messages of their own, as Ollama expects one message per tool result.
+func chatMessages(ctx context.Context, mc llms.MessageContent) ([]ollamago.ChatMessage, error) {
+	role, ok := roles[mc.Role]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", llms.ErrUnexpectedChatMessageType, mc.Role)
+	}
+	msg := ollamago.ChatMessage{Role: role}
+	var text []string
+	var results []ollamago.ChatMessage
+	for _, part := range mc.Parts {
+		switch p := part.(type) {
+		case llms.TextContent:
+			text = append(text, p.Text)
+		case llms.BinaryContent:
+			msg.Images = append(msg.Images, base64.StdEncoding.EncodeToString(p.Data))
+		case llms.ImageURLContent:
+			img, err := imageURL(ctx, p.URL)
+			if err != nil {
+				return nil, err
+			}
+			msg.Images = append(msg.Images, img)
+		case llms.ToolCall:
+			if p.FunctionCall == nil {
+				continue
+			}
+			args := json.RawMessage(p.FunctionCall.Arguments)
+			if !json.Valid(args) {
+				return nil, fmt.Errorf("invalid arguments in call to %s", p.FunctionCall.Name)
+			}
+			msg.ToolCalls = append(msg.ToolCalls, ollamago.ToolCall{
+				ID:       p.ID,
+				Function: ollamago.ToolCallFunction{Name: p.FunctionCall.Name, Arguments: args},
+			})
+		case llms.ToolCallResponse:
+			results = append(results, ollamago.ToolResult(p.ToolCallID, p.Content))
+		default:
+			return nil, fmt.Errorf("unsupported content part %T", part)
+		}
+	}
+	if len(text) == 0 && len(msg.Images) == 0 && len(msg.ToolCalls) == 0 {
+		return results, nil
+	}
+	msg.Content = strings.Join(text, "\n")
+	return append([]ollamago.ChatMessage{msg}, results...), nil
+}
+
+// imageURL decodes data URIs and fetches the other URLs.
+func imageURL(ctx context.Context, url string) (string, error) {
+	if rest, ok := strings.CutPrefix(url, "data:"); ok {
+		_, data, ok := strings.Cut(rest, ";base64,")
+		if !ok {
+			return "", errors.New("image data URI is not base64-encoded")
+		}
+		return data, nil
+	}
+	return ollamago.ImageFromURL(ctx, url)
+}
+
+// Call implements the deprecated llms.Model.Call.
+func (l *LLM) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, l, prompt, options...)
+}
+
+// CreateEmbedding embeds the texts with EmbeddingModel.
+func (l *LLM) CreateEmbedding(ctx context.Context, texts []string) ([][]float32, error) {
+	model := l.EmbeddingModel
+	if model == "" {
+		model = l.Model
+	}
+	resp, err := l.Client.GenerateEmbeddings32(ctx, ollamago.EmbedRequest{Model: model, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Embeddings, nil
+}
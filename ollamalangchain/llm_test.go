@@ -0,0 +1,113 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamalangchain_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamagotest"
+	"cirello.io/ollamago/ollamalangchain"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/embeddings"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func TestGenerateContent(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "llama3.2", Chunks: []string{"the sky ", "scatters light"}})
+	t.Cleanup(srv.Close)
+	llm := &ollamalangchain.LLM{
+		Client:  srv.Client(),
+		Model:   "llama3.2",
+		Options: ollamago.ModelParameters{NumCtx: ollamago.Ptr(4096), Temperature: ollamago.Ptr(0.7)},
+	}
+	ctx := context.Background()
+
+	var streamed []string
+	answer, err := llms.GenerateFromSinglePrompt(ctx, llm, "Why is the sky blue?",
+		llms.WithTemperature(0),
+		llms.WithStreamingFunc(func(_ context.Context, chunk []byte) error {
+			streamed = append(streamed, string(chunk))
+			return nil
+		}))
+	require.NoError(t, err)
+	require.Equal(t, "the sky scatters light", answer)
+	require.Equal(t, []string{"the sky ", "scatters light"}, streamed)
+
+	answer, err = llm.Call(ctx, "Why is the sky blue?", llms.WithSeed(0), llms.WithStopWords([]string{"\n"}))
+	require.NoError(t, err)
+	require.Equal(t, "the sky scatters light", answer)
+
+	requests := srv.Requests()
+	require.Len(t, requests, 2)
+	require.JSONEq(t, `{"model":"llama3.2","messages":[{"role":"user","content":"Why is the sky blue?"}],"stream":true,"options":{"temperature":0,"num_ctx":4096}}`, string(requests[0].Body))
+	require.JSONEq(t, `{"model":"llama3.2","messages":[{"role":"user","content":"Why is the sky blue?"}],"options":{"temperature":0.7,"num_ctx":4096,"seed":0,"stop":["\n"]}}`, string(requests[1].Body))
+
+	embedder, err := embeddings.NewEmbedder(llm)
+	require.NoError(t, err)
+	vectors, err := embedder.EmbedDocuments(ctx, []string{"a", "b"})
+	require.NoError(t, err)
+	require.Len(t, vectors, 2)
+}
+
+func TestGenerateContentTools(t *testing.T) {
+	mock := &ollamagotest.MockClient{
+		GenerateChatFunc: func(context.Context, ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error) {
+			return ollamagotest.Stream(ollamago.ChatResponse{
+				Message: ollamago.ChatMessage{
+					Role:      ollamago.RoleAssistant,
+					ToolCalls: []ollamago.ToolCall{{Function: ollamago.ToolCallFunction{Name: "add", Arguments: json.RawMessage(`{"a":1,"b":2}`)}}},
+				},
+				Done: true,
+			}), nil
+		},
+	}
+	llm := &ollamalangchain.LLM{Client: mock, Model: "llama3.2"}
+	messages := []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, "use the tools"),
+		{Role: llms.ChatMessageTypeHuman, Parts: []llms.ContentPart{
+			llms.TextPart("add these"),
+			llms.ImageURLPart("data:image/png;base64,aGk="),
+		}},
+		{Role: llms.ChatMessageTypeAI, Parts: []llms.ContentPart{
+			llms.ToolCall{ID: "call_0", Type: "function", FunctionCall: &llms.FunctionCall{Name: "add", Arguments: `{"a":0,"b":0}`}},
+		}},
+		{Role: llms.ChatMessageTypeTool, Parts: []llms.ContentPart{
+			llms.ToolCallResponse{ToolCallID: "call_0", Name: "add", Content: "0"},
+		}},
+	}
+	resp, err := llm.GenerateContent(context.Background(), messages, llms.WithTools([]llms.Tool{{
+		Type: "function",
+		Function: &llms.FunctionDefinition{
+			Name:       "add",
+			Parameters: map[string]any{"type": "object"},
+		},
+	}}))
+	require.NoError(t, err)
+	require.Len(t, resp.Choices, 1)
+	require.Equal(t, []llms.ToolCall{{ID: "call_0", Type: "function", FunctionCall: &llms.FunctionCall{Name: "add", Arguments: `{"a":1,"b":2}`}}}, resp.Choices[0].ToolCalls)
+
+	req := mock.Calls()[0].Request.(ollamago.ChatRequest)
+	require.Equal(t, []ollamago.ChatMessage{
+		ollamago.SystemMessage("use the tools"),
+		ollamago.UserMessageWithImages("add these", "aGk="),
+		{Role: ollamago.RoleAssistant, ToolCalls: []ollamago.ToolCall{{ID: "call_0", Function: ollamago.ToolCallFunction{Name: "add", Arguments: json.RawMessage(`{"a":0,"b":0}`)}}}},
+		ollamago.ToolResult("call_0", "0"),
+	}, req.Messages)
+	require.Equal(t, []ollamago.Tool{{Type: "function", Function: ollamago.ToolFunction{Name: "add", Parameters: json.RawMessage(`{"type":"object"}`)}}}, req.Tools)
+}
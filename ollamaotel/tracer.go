@@ -0,0 +1,120 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ollamaotel traces the calls made by an ollamago.Client with
+// OpenTelemetry, following the GenAI semantic conventions.
+package ollamaotel
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"cirello.io/ollamago"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope of the spans.
+const ScopeName = "cirello.io/ollamago/ollamaotel"
+
+// Tracer creates a client span per API call, and a child span covering
+// the streaming of the response, and propagates the trace context to the
+// server in the request headers:
+//
+//	tracer := &ollamaotel.Tracer{}
+//	client := &ollamago.Client{Interceptors: []ollamago.Interceptor{tracer.Intercept}}
+type Tracer struct {
+	// TracerProvider creates the tracer. If nil, the global provider is
+	// used.
+	TracerProvider trace.TracerProvider
+
+	// Propagator injects the trace context into the requests. If nil, the
+	// global propagator is used.
+	Propagator propagation.TextMapPropagator
+}
+
+// Intercept is the ollamago.Interceptor creating the spans.
+func (t *Tracer) Intercept(next http.RoundTripper) http.RoundTripper {
+	provider := t.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	propagator := t.Propagator
+	if propagator == nil {
+		propagator = otel.GetTextMapPropagator()
+	}
+	tracer := provider.Tracer(ScopeName)
+	observed := ollamago.Observe(func(s ollamago.CallStats) {
+		span := trace.SpanFromContext(s.Request.Context())
+		end := s.Start.Add(s.Duration)
+		if s.FirstByte > 0 && s.Status == http.StatusOK {
+			_, stream := tracer.Start(s.Request.Context(), "stream",
+				trace.WithTimestamp(s.Start.Add(s.FirstByte)))
+			stream.End(trace.WithTimestamp(end))
+		}
+		if s.Model != "" {
+			span.SetName(operationName(s.Endpoint) + " " + s.Model)
+			span.SetAttributes(attribute.String("gen_ai.request.model", s.Model))
+		}
+		if s.Status != 0 {
+			span.SetAttributes(attribute.Int("http.response.status_code", s.Status))
+		}
+		if s.PromptTokens > 0 {
+			span.SetAttributes(attribute.Int("gen_ai.usage.input_tokens", s.PromptTokens))
+		}
+		if s.GeneratedTokens > 0 {
+			span.SetAttributes(attribute.Int("gen_ai.usage.output_tokens", s.GeneratedTokens))
+		}
+		if s.DoneReason != "" {
+			span.SetAttributes(attribute.StringSlice("gen_ai.response.finish_reasons", []string{s.DoneReason}))
+		}
+		switch {
+		case s.Err != nil:
+			span.RecordError(s.Err)
+			span.SetStatus(codes.Error, s.Err.Error())
+		case s.Status >= http.StatusBadRequest:
+			span.SetStatus(codes.Error, http.StatusText(s.Status))
+		}
+		span.End(trace.WithTimestamp(end))
+	})(next)
+	return ollamago.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		operation := operationName(req.URL.Path)
+		ctx, _ := tracer.Start(req.Context(), operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("gen_ai.system", "ollama"),
+				attribute.String("gen_ai.operation.name", operation),
+				attribute.String("server.address", req.URL.Hostname()),
+			))
+		req = req.Clone(ctx)
+		propagator.Inject(ctx, propagation.HeaderCarrier(req.Header))
+		return observed.RoundTrip(req)
+	})
+}
+
+func operationName(p string) string {
+	switch {
+	case strings.HasSuffix(p, "/api/chat"):
+		return "chat"
+	case strings.HasSuffix(p, "/api/generate"):
+		return "text_completion"
+	case strings.HasSuffix(p, "/api/embed"):
+		return "embeddings"
+	}
+	return path.Base(p)
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamaotel_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamaotel"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracer(t *testing.T) {
+	var traceparent string
+	handler := http.NewServeMux()
+	handler.HandleFunc("/api/chat", func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte(`{"message":{"role":"assistant","content":"hi"},"done":false}` + "\n"))
+		w.Write([]byte(`{"message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":7,"eval_count":1}` + "\n"))
+	})
+	srv := newServer(t, handler)
+	exporter := tracetest.NewInMemoryExporter()
+	provider := trace.NewTracerProvider(trace.WithSyncer(exporter))
+	tracer := &ollamaotel.Tracer{TracerProvider: provider, Propagator: propagation.TraceContext{}}
+	client := &ollamago.Client{BaseURL: srv, Interceptors: []ollamago.Interceptor{tracer.Intercept}}
+
+	respChan, err := client.GenerateChat(context.Background(), ollamago.ChatRequest{
+		Model:    "llama",
+		Messages: []ollamago.ChatMessage{{Role: "user", Content: "hello"}},
+		Stream:   true,
+	})
+	require.NoError(t, err)
+	for range respChan {
+	}
+	_, err = client.ListModels(context.Background())
+	require.Error(t, err)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 3)
+	stream, chat, tags := spans[0], spans[1], spans[2]
+	require.Equal(t, "stream", stream.Name)
+	require.Equal(t, chat.SpanContext.SpanID(), stream.Parent.SpanID())
+	require.Equal(t, "chat llama", chat.Name)
+	require.NotEmpty(t, traceparent)
+	require.Contains(t, traceparent, chat.SpanContext.TraceID().String())
+	attrs := attribute.NewSet(chat.Attributes...)
+	for key, want := range map[attribute.Key]attribute.Value{
+		"gen_ai.system":                  attribute.StringValue("ollama"),
+		"gen_ai.operation.name":          attribute.StringValue("chat"),
+		"gen_ai.request.model":           attribute.StringValue("llama"),
+		"gen_ai.usage.input_tokens":      attribute.IntValue(7),
+		"gen_ai.usage.output_tokens":     attribute.IntValue(1),
+		"gen_ai.response.finish_reasons": attribute.StringSliceValue([]string{"stop"}),
+	} {
+		got, ok := attrs.Value(key)
+		require.True(t, ok, key)
+		require.Equal(t, want, got, key)
+	}
+	require.Equal(t, "tags", tags.Name)
+	require.Equal(t, codes.Error, tags.Status.Code)
+}
+
+func newServer(t *testing.T, handler http.Handler) string {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
@@ -0,0 +1,125 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ollamaprom exposes Prometheus metrics about the calls made by an
+// ollamago.Client.
+package ollamaprom
+
+import (
+	"net/http"
+	"strconv"
+
+	"cirello.io/ollamago"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ prometheus.Collector = (*Collector)(nil)
+
+// Collector records the calls observed through its Interceptor:
+//
+//	metrics := ollamaprom.NewCollector("")
+//	prometheus.MustRegister(metrics)
+//	client := &ollamago.Client{Interceptors: []ollamago.Interceptor{metrics.Interceptor()}}
+type Collector struct {
+	requests        *prometheus.CounterVec
+	promptTokens    *prometheus.CounterVec
+	generatedTokens *prometheus.CounterVec
+	firstToken      *prometheus.HistogramVec
+	tokensPerSecond *prometheus.HistogramVec
+	duration        *prometheus.HistogramVec
+}
+
+// NewCollector creates the metrics under namespace, "ollama" if empty.
+func NewCollector(namespace string) *Collector {
+	if namespace == "" {
+		namespace = "ollama"
+	}
+	labels := []string{"endpoint", "model"}
+	return &Collector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Requests sent to the Ollama server.",
+		}, []string{"endpoint", "model", "status"}),
+		promptTokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "prompt_tokens_total",
+			Help:      "Prompt tokens evaluated by the Ollama server.",
+		}, labels),
+		generatedTokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "generated_tokens_total",
+			Help:      "Tokens generated by the Ollama server.",
+		}, labels),
+		firstToken: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "time_to_first_token_seconds",
+			Help:      "Time until the first byte of the response.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+		}, labels),
+		tokensPerSecond: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "tokens_per_second",
+			Help:      "Generation throughput.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+		}, labels),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "stream_duration_seconds",
+			Help:      "Time until the response was fully read.",
+			Buckets:   prometheus.ExponentialBuckets(0.05, 2, 12),
+		}, labels),
+	}
+}
+
+// Interceptor returns the ollamago.Interceptor feeding the metrics.
+func (c *Collector) Interceptor() ollamago.Interceptor {
+	return ollamago.Observe(c.observe)
+}
+
+func (c *Collector) observe(s ollamago.CallStats) {
+	status := "error"
+	if s.Status != 0 {
+		status = strconv.Itoa(s.Status)
+	}
+	c.requests.WithLabelValues(s.Endpoint, s.Model, status).Inc()
+	if s.Status != http.StatusOK || s.Err != nil {
+		return
+	}
+	c.promptTokens.WithLabelValues(s.Endpoint, s.Model).Add(float64(s.PromptTokens))
+	c.generatedTokens.WithLabelValues(s.Endpoint, s.Model).Add(float64(s.GeneratedTokens))
+	c.firstToken.WithLabelValues(s.Endpoint, s.Model).Observe(s.FirstByte.Seconds())
+	c.duration.WithLabelValues(s.Endpoint, s.Model).Observe(s.Duration.Seconds())
+	if tps := s.TokensPerSecond(); tps > 0 {
+		c.tokensPerSecond.WithLabelValues(s.Endpoint, s.Model).Observe(tps)
+	}
+}
+
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.requests.Describe(ch)
+	c.promptTokens.Describe(ch)
+	c.generatedTokens.Describe(ch)
+	c.firstToken.Describe(ch)
+	c.tokensPerSecond.Describe(ch)
+	c.duration.Describe(ch)
+}
+
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.requests.Collect(ch)
+	c.promptTokens.Collect(ch)
+	c.generatedTokens.Collect(ch)
+	c.firstToken.Collect(ch)
+	c.tokensPerSecond.Collect(ch)
+	c.duration.Collect(ch)
+}
@@ -0,0 +1,62 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamaprom_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamaprom"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollector(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test", Chunks: []string{"a", "b"}})
+	t.Cleanup(srv.Close)
+	metrics := ollamaprom.NewCollector("")
+	registry := prometheus.NewPedanticRegistry()
+	require.NoError(t, registry.Register(metrics))
+	client := srv.Client()
+	client.Interceptors = []ollamago.Interceptor{metrics.Interceptor()}
+	ctx := context.Background()
+
+	respChan, err := client.GenerateChat(ctx, ollamago.ChatRequest{
+		Model:    "test",
+		Messages: []ollamago.ChatMessage{{Role: "user", Content: "hi"}},
+		Stream:   true,
+	})
+	require.NoError(t, err)
+	for range respChan {
+	}
+	_, err = client.GenerateChat(ctx, ollamago.ChatRequest{Model: "missing"})
+	require.Error(t, err)
+
+	require.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+# HELP ollama_requests_total Requests sent to the Ollama server.
+# TYPE ollama_requests_total counter
+ollama_requests_total{endpoint="/api/chat",model="missing",status="404"} 1
+ollama_requests_total{endpoint="/api/chat",model="test",status="200"} 1
+# HELP ollama_generated_tokens_total Tokens generated by the Ollama server.
+# TYPE ollama_generated_tokens_total counter
+ollama_generated_tokens_total{endpoint="/api/chat",model="test"} 2
+`), "ollama_requests_total", "ollama_generated_tokens_total"))
+	require.Equal(t, 1, testutil.CollectAndCount(metrics, "ollama_time_to_first_token_seconds"))
+	require.Equal(t, 1, testutil.CollectAndCount(metrics, "ollama_tokens_per_second"))
+}
@@ -0,0 +1,192 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamatest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Interaction is a single recorded HTTP exchange.
+type Interaction struct {
+	Method       string      `json:"method"`
+	URI          string      `json:"uri"`
+	RequestBody  string      `json:"request_body,omitempty"`
+	Status       int         `json:"status"`
+	Header       http.Header `json:"header,omitempty"`
+	ResponseBody string      `json:"response_body"`
+}
+
+// ErrNoInteraction is returned by Recorder in replay mode when no recorded
+// interaction matches the outgoing request.
+var ErrNoInteraction = errors.New("ollamatest: no recorded interaction matches request")
+
+// Recorder is an http.RoundTripper that records real Ollama interactions,
+// including NDJSON streams, to a golden file and replays them later.
+//
+// In record mode (Record set to true) requests are forwarded to Transport
+// and the exchanges are kept in memory until Save is called. In replay mode
+// the golden file at Path is loaded on first use and each request is
+// answered with the first unused interaction matching its method, URI and
+// body.
+type Recorder struct {
+	// Path is the location of the golden file.
+	Path string
+
+	// Record switches the recorder into record mode.
+	Record bool
+
+	// Transport performs the real requests in record mode. If nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	mu           sync.Mutex
+	loaded       bool
+	interactions []Interaction
+	used         []bool
+}
+
+// Client returns an http.Client that uses the recorder as its transport.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("cannot read request body: %w", err)
+		}
+		// RoundTrippers must not modify the request of the caller.
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+	if r.Record {
+		return r.record(req, reqBody)
+	}
+	return r.replay(req, reqBody)
+}
+
+func (r *Recorder) record(req *http.Request, reqBody []byte) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read response body: %w", err)
+	}
+	header := make(http.Header)
+	if ct := resp.Header.Get("Content-Type"); ct != "" {
+		header.Set("Content-Type", ct)
+	}
+	r.mu.Lock()
+	r.interactions = append(r.interactions, Interaction{
+		Method:       req.Method,
+		URI:          req.URL.RequestURI(),
+		RequestBody:  normalizeBody(reqBody),
+		Status:       resp.StatusCode,
+		Header:       header,
+		ResponseBody: string(respBody),
+	})
+	r.mu.Unlock()
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+	return resp, nil
+}
+
+func (r *Recorder) replay(req *http.Request, reqBody []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.loaded {
+		if err := r.load(); err != nil {
+			return nil, err
+		}
+	}
+	uri, body := req.URL.RequestURI(), normalizeBody(reqBody)
+	for i, in := range r.interactions {
+		if r.used[i] || in.Method != req.Method || in.URI != uri || in.RequestBody != body {
+			continue
+		}
+		r.used[i] = true
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", in.Status, http.StatusText(in.Status)),
+			StatusCode:    in.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        in.Header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader([]byte(in.ResponseBody))),
+			ContentLength: int64(len(in.ResponseBody)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s %s", ErrNoInteraction, req.Method, uri)
+}
+
+func (r *Recorder) load() error {
+	data, err := os.ReadFile(r.Path)
+	if err != nil {
+		return fmt.Errorf("cannot read golden file: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.interactions); err != nil {
+		return fmt.Errorf("cannot decode golden file: %w", err)
+	}
+	r.used = make([]bool, len(r.interactions))
+	r.loaded = true
+	return nil
+}
+
+// Save writes the recorded interactions to the golden file. It is a no-op
+// in replay mode.
+func (r *Recorder) Save() error {
+	if !r.Record {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := json.MarshalIndent(r.interactions, "", "\t")
+	if err != nil {
+		return fmt.Errorf("cannot encode interactions: %w", err)
+	}
+	if err := os.WriteFile(r.Path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("cannot write golden file: %w", err)
+	}
+	return nil
+}
+
+// normalizeBody compacts JSON bodies so that formatting differences do not
+// prevent a match.
+func normalizeBody(body []byte) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, body); err != nil {
+		return string(body)
+	}
+	return buf.String()
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamatest_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	golden := filepath.Join(t.TempDir(), "chat.json")
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test", Chunks: []string{"hel", "lo"}})
+	chat := func(client *ollamago.Client) string {
+		respChan, err := client.GenerateChat(context.Background(), ollamago.ChatRequest{
+			Model:    "test",
+			Messages: []ollamago.ChatMessage{{Role: "user", Content: "hi"}},
+			Stream:   true,
+		})
+		require.NoError(t, err)
+		var content string
+		for r := range respChan {
+			require.NoError(t, r.Error)
+			content += r.Message.Content
+		}
+		return content
+	}
+
+	rec := &ollamatest.Recorder{Path: golden, Record: true}
+	require.Equal(t, "hello", chat(&ollamago.Client{BaseURL: srv.URL, HTTPClient: rec.Client()}))
+	require.NoError(t, rec.Save())
+	srv.Close()
+
+	replay := &ollamatest.Recorder{Path: golden}
+	client := &ollamago.Client{BaseURL: srv.URL, HTTPClient: replay.Client()}
+	require.Equal(t, "hello", chat(client))
+
+	_, err := client.Version(context.Background())
+	require.ErrorIs(t, err, ollamatest.ErrNoInteraction)
+}
+
+func TestRecorderLeavesRequestAlone(t *testing.T) {
+	srv := ollamatest.NewServer()
+	t.Cleanup(srv.Close)
+	rec := &ollamatest.Recorder{Path: filepath.Join(t.TempDir(), "version.json"), Record: true}
+	body := io.NopCloser(strings.NewReader(`{}`))
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/version", body)
+	require.NoError(t, err)
+	resp, err := rec.RoundTrip(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.True(t, req.Body == body, "the request body is not replaced")
+}
@@ -0,0 +1,730 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ollamatest provides an in-process fake Ollama server for
+// integration tests of applications built on ollamago.
+package ollamatest
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cirello.io/ollamago"
+)
+
+// Model describes a model served by the fake server.
+type Model struct {
+	// Name is the model name, e.g. "llama3.2".
+	Name string
+
+	// Size is reported by /api/tags.
+	Size int64
+
+	// Digest is reported by /api/tags. When empty, it is derived from the
+	// Name.
+	Digest string
+
+	// ModifiedAt is reported by /api/tags.
+	ModifiedAt time.Time
+
+	// Show is the payload returned by /api/show.
+	Show ollamago.ShowModelResponse
+
+	// Chunks are the canned response fragments streamed by /api/generate
+	// and /api/chat, in order. Each counts as a token: a num_predict
+	// option smaller than their number truncates the response.
+	Chunks []string
+
+	// Embed computes the embedding of a single input. When nil, a
+	// deterministic 8-dimensional vector derived from the input is used.
+	Embed func(input string) []float64
+}
+
+// Request records a single request received by the fake server.
+type Request struct {
+	Method string
+	Path   string
+	Header http.Header
+	Body   []byte
+}
+
+type failure struct {
+	status  int
+	message string
+}
+
+// Server is a fake Ollama server. Use NewServer to create one and Close to
+// release it.
+type Server struct {
+	// URL is the base URL of the server, suitable for ollamago.Client.BaseURL.
+	URL string
+
+	srv *httptest.Server
+
+	mu         sync.Mutex
+	version    string
+	models     map[string]Model
+	registry   map[string]Model
+	blobs      map[string][]byte
+	latency    time.Duration
+	chunkDelay time.Duration
+	failures   map[string][]failure
+	requests   []Request
+	running    map[string]time.Time
+}
+
+// NewServer starts a fake Ollama server serving the given models.
+func NewServer(models ...Model) *Server {
+	s := &Server{
+		version:  "0.0.0-ollamatest",
+		models:   make(map[string]Model),
+		registry: make(map[string]Model),
+		blobs:    make(map[string][]byte),
+		failures: make(map[string][]failure),
+		running:  make(map[string]time.Time),
+	}
+	for _, m := range models {
+		s.models[m.Name] = m
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/generate", s.handleGenerate)
+	mux.HandleFunc("/api/chat", s.handleChat)
+	mux.HandleFunc("/api/embed", s.handleEmbed)
+	mux.HandleFunc("/api/tags", s.handleTags)
+	mux.HandleFunc("/api/ps", s.handlePs)
+	mux.HandleFunc("/api/show", s.handleShow)
+	mux.HandleFunc("/api/delete", s.handleDelete)
+	mux.HandleFunc("/api/pull", s.handlePull)
+	mux.HandleFunc("/api/push", s.handlePush)
+	mux.HandleFunc("/api/create", s.handleCreate)
+	mux.HandleFunc("HEAD /api/blobs/{digest}", s.handleBlobExists)
+	mux.HandleFunc("POST /api/blobs/{digest}", s.handleCreateBlob)
+	mux.HandleFunc("/api/version", s.handleVersion)
+	mux.HandleFunc("/{$}", s.handleRoot)
+	s.srv = httptest.NewServer(s.intercept(mux))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Client returns an ollamago.Client pointed at the server.
+func (s *Server) Client() *ollamago.Client {
+	return &ollamago.Client{BaseURL: s.URL, HTTPClient: s.srv.Client()}
+}
+
+// AddModel adds or replaces a served model.
+func (s *Server) AddModel(m Model) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.models[m.Name] = m
+}
+
+// AddRegistryModel makes a model available to /api/pull, which adds it to
+// the served models.
+func (s *Server) AddRegistryModel(m Model) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.registry[m.Name] = m
+}
+
+// SetVersion sets the version reported by /api/version.
+func (s *Server) SetVersion(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version = version
+}
+
+// SetLatency delays every response by d before any byte is written.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// SetChunkDelay delays every streamed chunk by d.
+func (s *Server) SetChunkDelay(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chunkDelay = d
+}
+
+// FailNext makes the next n requests to path fail with the given HTTP
+// status and error message.
+func (s *Server) FailNext(path string, n int, status int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for range n {
+		s.failures[path] = append(s.failures[path], failure{status, message})
+	}
+}
+
+// Requests returns the requests received so far, in arrival order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Blobs returns the digests of the blobs uploaded to the server.
+func (s *Server) Blobs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var digests []string
+	for digest := range s.blobs {
+		digests = append(digests, digest)
+	}
+	sort.Strings(digests)
+	return digests
+}
+
+func (s *Server) intercept(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Header: r.Header.Clone(), Body: body})
+		latency := s.latency
+		var fail *failure
+		if f := s.failures[r.URL.Path]; len(f) > 0 {
+			fail = &f[0]
+			s.failures[r.URL.Path] = f[1:]
+		}
+		s.mu.Unlock()
+		if !sleep(r, latency) {
+			return
+		}
+		if fail != nil {
+			writeError(w, fail.status, fail.message)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) model(name string) (Model, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lookup(name)
+}
+
+// lookup finds a model by name, which defaults to the "latest" tag as in
+// Ollama. It must be called with s.mu held.
+func (s *Server) lookup(name string) (Model, bool) {
+	if m, ok := s.models[name]; ok {
+		return m, true
+	}
+	if strings.Contains(name[strings.LastIndex(name, "/")+1:], ":") {
+		return Model{}, false
+	}
+	m, ok := s.models[name+":latest"]
+	return m, ok
+}
+
+// load returns a model to run, which is then listed by /api/ps for five
+// minutes.
+func (s *Server) load(name string) (Model, bool) {
+	return s.keep(name, 5*time.Minute)
+}
+
+// keep returns a model to run, listed by /api/ps for keepAlive. A zero
+// keepAlive unloads the model and a negative one keeps it loaded.
+func (s *Server) keep(name string, keepAlive time.Duration) (Model, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.lookup(name)
+	switch {
+	case !ok:
+	case keepAlive == 0:
+		delete(s.running, m.Name)
+	case keepAlive < 0:
+		s.running[m.Name] = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	default:
+		s.running[m.Name] = time.Now().Add(keepAlive)
+	}
+	return m, ok
+}
+
+type streamRequest struct {
+	Model     string             `json:"model"`
+	Stream    *bool              `json:"stream"`
+	Prompt    string             `json:"prompt"`
+	KeepAlive *ollamago.Duration `json:"keep_alive"`
+	Context   []int              `json:"context"`
+
+	Logprobs    bool `json:"logprobs"`
+	TopLogprobs int  `json:"top_logprobs"`
+
+	Options struct {
+		NumPredict *int `json:"num_predict"`
+	} `json:"options"`
+}
+
+func (s *Server) stream(w http.ResponseWriter, r *http.Request, chunk func(model, content string, done bool) map[string]any) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var req streamRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	keepAlive := 5 * time.Minute
+	if req.KeepAlive != nil {
+		keepAlive = time.Duration(*req.KeepAlive)
+	}
+	m, ok := s.keep(req.Model, keepAlive)
+	if !ok {
+		writeModelNotFound(w, req.Model)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	// Completions without a prompt only load or unload the model.
+	if r.URL.Path == "/api/generate" && req.Prompt == "" {
+		c := chunk(req.Model, "", true)
+		c["done_reason"] = "load"
+		if keepAlive == 0 {
+			c["done_reason"] = "unload"
+		}
+		enc.Encode(c)
+		return
+	}
+	s.mu.Lock()
+	chunkDelay := s.chunkDelay
+	s.mu.Unlock()
+	// Each chunk counts as a token: num_predict truncates the response.
+	doneReason := "stop"
+	if n := req.Options.NumPredict; n != nil && *n >= 0 && *n < len(m.Chunks) {
+		m.Chunks = m.Chunks[:*n]
+		doneReason = "length"
+	}
+	// The final chunk carries token counts: one per chunk generated and
+	// one per four bytes of request.
+	final := func(content string) map[string]any {
+		c := chunk(req.Model, content, true)
+		c["prompt_eval_count"] = len(body) / 4
+		c["eval_count"] = len(m.Chunks)
+		c["eval_duration"] = int64(len(m.Chunks)) * int64(time.Millisecond)
+		c["done_reason"] = doneReason
+		if r.URL.Path == "/api/generate" {
+			// The context holds a token per prompt token and chunk,
+			// following the context of the request.
+			c["context"] = append(req.Context, make([]int, len(body)/4+len(m.Chunks))...)
+		}
+		return c
+	}
+	if req.Stream != nil && !*req.Stream {
+		var (
+			content  string
+			logprobs []ollamago.Logprob
+		)
+		for i, c := range m.Chunks {
+			content += c
+			logprobs = append(logprobs, logprob(i, c, req.TopLogprobs))
+		}
+		out := final(content)
+		if req.Logprobs {
+			out["logprobs"] = logprobs
+		}
+		enc.Encode(out)
+		return
+	}
+	flusher, _ := w.(http.Flusher)
+	for i, c := range m.Chunks {
+		if !sleep(r, chunkDelay) {
+			return
+		}
+		out := chunk(req.Model, c, false)
+		if req.Logprobs {
+			out["logprobs"] = []ollamago.Logprob{logprob(i, c, req.TopLogprobs)}
+		}
+		enc.Encode(out)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	enc.Encode(final(""))
+}
+
+// logprob is the fake log probability of the i-th chunk: -i/10, with
+// alternatives "alt1", "alt2"... each less likely.
+func logprob(i int, token string, top int) ollamago.Logprob {
+	lp := ollamago.Logprob{TokenLogprob: ollamago.TokenLogprob{Token: token, Logprob: -float64(i) / 10}}
+	for k := range top {
+		alt := lp.TokenLogprob
+		if k > 0 {
+			alt = ollamago.TokenLogprob{Token: fmt.Sprintf("alt%d", k), Logprob: alt.Logprob - float64(k)}
+		}
+		lp.TopLogprobs = append(lp.TopLogprobs, alt)
+	}
+	return lp
+}
+
+func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	s.stream(w, r, func(model, content string, done bool) map[string]any {
+		return map[string]any{
+			"model":          model,
+			"created_at":     time.Now().UTC(),
+			"response":       content,
+			"done":           done,
+			"total_duration": int64(time.Millisecond),
+		}
+	})
+}
+
+func (s *Server) handleChat(w http.ResponseWriter, r *http.Request) {
+	s.stream(w, r, func(model, content string, done bool) map[string]any {
+		return map[string]any{
+			"model":          model,
+			"created_at":     time.Now().UTC(),
+			"message":        ollamago.ChatMessage{Role: ollamago.RoleAssistant, Content: content},
+			"done":           done,
+			"total_duration": int64(time.Millisecond),
+		}
+	})
+}
+
+func (s *Server) handleEmbed(w http.ResponseWriter, r *http.Request) {
+	var req ollamago.EmbedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	m, ok := s.load(req.Model)
+	if !ok {
+		writeModelNotFound(w, req.Model)
+		return
+	}
+	embed := m.Embed
+	if embed == nil {
+		embed = defaultEmbed
+	}
+	resp := ollamago.EmbedResponse{Model: req.Model, Duration: time.Millisecond}
+	for _, input := range req.Input {
+		resp.Embeddings = append(resp.Embeddings, embed(input))
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	var resp ollamago.ListModelsResponse
+	for _, m := range s.models {
+		resp.Models = append(resp.Models, ollamago.ModelInfo{
+			Name:       m.Name,
+			Model:      m.Name,
+			ModifiedAt: m.ModifiedAt,
+			Size:       m.Size,
+			Digest:     digest(m),
+			Details:    m.Show.Details,
+		})
+	}
+	s.mu.Unlock()
+	sort.Slice(resp.Models, func(i, j int) bool {
+		return resp.Models[i].Name < resp.Models[j].Name
+	})
+	writeJSON(w, resp)
+}
+
+func (s *Server) handlePs(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	resp := ollamago.ListRunningModelsResponse{Models: []ollamago.RunningModel{}}
+	for name, expiresAt := range s.running {
+		m, ok := s.models[name]
+		if !ok {
+			continue
+		}
+		resp.Models = append(resp.Models, ollamago.RunningModel{
+			Name:      m.Name,
+			Model:     m.Name,
+			Size:      m.Size,
+			Digest:    digest(m),
+			ExpiresAt: expiresAt,
+			SizeVRAM:  m.Size,
+		})
+	}
+	s.mu.Unlock()
+	sort.Slice(resp.Models, func(i, j int) bool {
+		return resp.Models[i].Name < resp.Models[j].Name
+	})
+	writeJSON(w, resp)
+}
+
+func (s *Server) handleShow(w http.ResponseWriter, r *http.Request) {
+	var req ollamago.ShowModelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	m, ok := s.model(req.Model)
+	if !ok {
+		writeModelNotFound(w, req.Model)
+		return
+	}
+	writeJSON(w, m.Show)
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	var req ollamago.DeleteModelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	_, ok := s.models[req.Model]
+	delete(s.models, req.Model)
+	delete(s.running, req.Model)
+	s.mu.Unlock()
+	if !ok {
+		writeModelNotFound(w, req.Model)
+	}
+}
+
+func (s *Server) handlePull(w http.ResponseWriter, r *http.Request) {
+	var req ollamago.PullModelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.mu.Lock()
+	m, ok := s.registry[req.Model]
+	chunkDelay := s.chunkDelay
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	enc.Encode(ollamago.ProgressEvent{Status: "pulling manifest"})
+	if !ok {
+		enc.Encode(map[string]string{"error": "pull model manifest: file does not exist"})
+		return
+	}
+	layer := "sha256:" + digest(m)
+	flusher, _ := w.(http.Flusher)
+	for _, completed := range []int64{0, m.Size / 2, m.Size} {
+		if !sleep(r, chunkDelay) {
+			return
+		}
+		enc.Encode(ollamago.ProgressEvent{
+			Status:    "pulling " + layer,
+			Digest:    layer,
+			Total:     m.Size,
+			Completed: completed,
+		})
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	enc.Encode(ollamago.ProgressEvent{Status: "verifying sha256 digest"})
+	enc.Encode(ollamago.ProgressEvent{Status: "writing manifest"})
+	s.AddModel(m)
+	enc.Encode(ollamago.ProgressEvent{Status: "success"})
+}
+
+func (s *Server) handlePush(w http.ResponseWriter, r *http.Request) {
+	var req ollamago.PushModelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	m, ok := s.model(req.Model)
+	if !ok {
+		writeModelNotFound(w, req.Model)
+		return
+	}
+	layer := "sha256:" + digest(m)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	enc.Encode(ollamago.ProgressEvent{Status: "retrieving manifest"})
+	enc.Encode(ollamago.ProgressEvent{Status: "pushing " + layer, Digest: layer, Total: m.Size, Completed: m.Size})
+	enc.Encode(ollamago.ProgressEvent{Status: "pushing manifest"})
+	s.AddRegistryModel(m)
+	enc.Encode(ollamago.ProgressEvent{Status: "success"})
+}
+
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	var req ollamago.CreateModelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	from := req.From
+	if from == "" && len(req.Files) == 0 {
+		for _, line := range strings.Split(req.Modelfile, "\n") {
+			if name, ok := strings.CutPrefix(line, "FROM "); ok {
+				from = strings.TrimSpace(name)
+			}
+		}
+	}
+	if digest, ok := strings.CutPrefix(from, "@"); ok {
+		from, req.Files = "", map[string]string{"model.gguf": digest}
+	}
+	var base Model
+	if from == "" && len(req.Files) > 0 {
+		s.mu.Lock()
+		for _, digest := range req.Files {
+			blob, ok := s.blobs[digest]
+			if !ok {
+				s.mu.Unlock()
+				writeError(w, http.StatusBadRequest, fmt.Sprintf("blob %s not found", digest))
+				return
+			}
+			base.Size += int64(len(blob))
+		}
+		s.mu.Unlock()
+	} else {
+		var ok bool
+		base, ok = s.model(from)
+		if !ok {
+			writeModelNotFound(w, from)
+			return
+		}
+	}
+	m := base
+	m.Name = req.Model
+	m.Digest = ""
+	m.Show.Details.ParentModel = from
+	m.Show.Messages = req.Messages
+	m.Show.Modelfile = req.Modelfile
+	m.Show.System = cmp.Or(req.System, base.Show.System)
+	m.Show.Template = cmp.Or(req.Template, base.Show.Template)
+	m.Show.License = cmp.Or(strings.Join(req.License, "\n"), base.Show.License)
+	if len(req.Parameters) > 0 {
+		var params []string
+		for name, value := range req.Parameters {
+			values, ok := value.([]any)
+			if !ok {
+				values = []any{value}
+			}
+			for _, v := range values {
+				params = append(params, fmt.Sprintf("%s %v", name, v))
+			}
+		}
+		sort.Strings(params)
+		m.Show.Parameters = strings.Join(params, "\n")
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	enc.Encode(ollamago.ProgressEvent{Status: "using existing layer sha256:" + digest(base)})
+	enc.Encode(ollamago.ProgressEvent{Status: "writing manifest"})
+	s.AddModel(m)
+	enc.Encode(ollamago.ProgressEvent{Status: "success"})
+}
+
+func (s *Server) handleBlobExists(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	_, ok := s.blobs[r.PathValue("digest")]
+	s.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *Server) handleCreateBlob(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h := sha256.Sum256(body)
+	if digest := r.PathValue("digest"); digest != "sha256:"+hex.EncodeToString(h[:]) {
+		writeError(w, http.StatusBadRequest, "digest mismatch")
+		return
+	}
+	s.mu.Lock()
+	s.blobs[r.PathValue("digest")] = body
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
+	io.WriteString(w, "Ollama is running")
+}
+
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	version := s.version
+	s.mu.Unlock()
+	writeJSON(w, map[string]string{"version": version})
+}
+
+// digest returns the digest of a model, derived from its name unless set.
+func digest(m Model) string {
+	if m.Digest != "" {
+		return m.Digest
+	}
+	h := sha256.Sum256([]byte(m.Name))
+	return hex.EncodeToString(h[:])
+}
+
+func defaultEmbed(input string) []float64 {
+	h := fnv.New64a()
+	h.Write([]byte(input))
+	seed := h.Sum64()
+	vec := make([]float64, 8)
+	for i := range vec {
+		seed = seed*6364136223846793005 + 1442695040888963407
+		vec[i] = float64(seed>>11)/float64(1<<53)*2 - 1
+	}
+	return vec
+}
+
+func sleep(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func writeModelNotFound(w http.ResponseWriter, model string) {
+	writeError(w, http.StatusNotFound, fmt.Sprintf("model %q not found, try pulling it first", model))
+}
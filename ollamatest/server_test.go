@@ -0,0 +1,108 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamatest_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{
+		Name:   "test",
+		Size:   1024,
+		Chunks: []string{"hello", " ", "world"},
+	})
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+	ctx := context.Background()
+
+	respChan, err := client.GenerateChat(ctx, ollamago.ChatRequest{
+		Model:    "test",
+		Messages: []ollamago.ChatMessage{{Role: "user", Content: "hi"}},
+		Stream:   true,
+	})
+	require.NoError(t, err)
+	var content string
+	var last ollamago.ChatResponse
+	for r := range respChan {
+		require.NoError(t, r.Error)
+		content += r.Message.Content
+		if r.Done {
+			last = r
+		}
+	}
+	require.Equal(t, "hello world", content)
+	require.Equal(t, ollamago.DoneStop, last.DoneReason)
+
+	completion, err := client.GenerateCompletion(ctx, ollamago.CompletionRequest{Model: "test", Prompt: "hi", Stream: true, Options: ollamago.ModelParameters{NumPredict: ollamago.Ptr(2)}})
+	require.NoError(t, err)
+	content = ""
+	for r := range completion {
+		require.NoError(t, r.Error)
+		content += r.Response
+		if r.Done {
+			require.True(t, r.WasTruncated())
+		}
+	}
+	require.Equal(t, "hello ", content)
+
+	embed, err := client.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: "test", Input: []string{"a", "b", "a"}})
+	require.NoError(t, err)
+	require.Len(t, embed.Embeddings, 3)
+	require.Len(t, embed.Embeddings[0], 8)
+	require.Equal(t, embed.Embeddings[0], embed.Embeddings[2])
+	require.NotEqual(t, embed.Embeddings[0], embed.Embeddings[1])
+
+	list, err := client.ListModels(ctx)
+	require.NoError(t, err)
+	require.Len(t, list.Models, 1)
+	require.Equal(t, int64(1024), list.Models[0].Size)
+
+	_, err = client.GenerateCompletion(ctx, ollamago.CompletionRequest{Model: "missing"})
+	require.ErrorContains(t, err, "404")
+
+	require.Len(t, srv.Requests(), 5)
+	require.Equal(t, "/api/chat", srv.Requests()[0].Path)
+}
+
+func TestServerErrorInjection(t *testing.T) {
+	srv := ollamatest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.SetVersion("1.2.3")
+	srv.FailNext("/api/version", 1, http.StatusServiceUnavailable, "overloaded")
+	client := srv.Client()
+	_, err := client.Version(context.Background())
+	require.ErrorContains(t, err, "503")
+	version, err := client.Version(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "1.2.3", version)
+}
+
+func TestServerLatency(t *testing.T) {
+	srv := ollamatest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.SetLatency(time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := srv.Client().Version(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
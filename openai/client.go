@@ -0,0 +1,597 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package openai implements ollamago.API over the OpenAI-compatible
+// endpoints that Ollama exposes under /v1, so that code written against
+// ollamago can also target OpenAI-compatible gateways such as LiteLLM or
+// vLLM by changing the base URL.
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"cirello.io/ollamago"
+)
+
+var _ ollamago.API = (*Client)(nil)
+
+// Client calls an OpenAI-compatible server. The generation and embedding
+// calls and ListModels are supported; the model management calls, which
+// the protocol lacks, fail with an error matching ollamago.ErrUnsupported.
+type Client struct {
+	// BaseURL is the URL the endpoints are relative to. If empty,
+	// "http://localhost:11434/v1" is used.
+	BaseURL string
+
+	// HTTPClient sends the requests. If nil, a client with the transport
+	// of ollamago.NewTransport is used.
+	HTTPClient *http.Client
+
+	// APIKey, if set, is sent as a bearer token.
+	APIKey string
+
+	// Interceptors wrap the HTTP transport of every request, the first
+	// being the outermost.
+	Interceptors []ollamago.Interceptor
+
+	// UserAgent is the User-Agent header of the requests. If empty,
+	// ollamago.DefaultUserAgent is used.
+	UserAgent string
+
+	// Application identifies the calling application to server logs and
+	// proxies, such as "myapp/1.2". It is appended to the User-Agent.
+	Application string
+}
+
+func (c *Client) baseURL() string {
+	if c.BaseURL == "" {
+		return "http://localhost:11434/v1"
+	}
+	return strings.TrimSuffix(c.BaseURL, "/")
+}
+
+// defaultHTTPClient is shared by the clients without HTTPClient.
+var defaultHTTPClient = &http.Client{Transport: ollamago.NewTransport()}
+
+func (c *Client) httpClient() *http.Client {
+	client := c.HTTPClient
+	if client == nil {
+		client = defaultHTTPClient
+	}
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	for i := len(c.Interceptors) - 1; i >= 0; i-- {
+		transport = c.Interceptors[i](transport)
+	}
+	intercepted := *client
+	intercepted.Transport = transport
+	return &intercepted
+}
+
+// do sends the request and returns the response if its status is 200 OK.
+func (c *Client) do(ctx context.Context, op, method, path string, body any) (*http.Response, error) {
+	var reqBody io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("cannot prepare %s request: %w", op, err)
+		}
+		reqBody = bytes.NewReader(jsonData)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, method, c.baseURL()+path, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare HTTP %s request: %w", op, err)
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpReq.Header.Set("User-Agent", ollamago.AppendUserAgent(c.UserAgent, c.Application))
+	if c.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	resp, err := c.httpClient().Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("cannot execute HTTP %s request: %w", op, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, newStatusError(op, resp)
+	}
+	return resp, nil
+}
+
+// newStatusError reads both the OpenAI error object and the plain error
+// string of Ollama.
+func newStatusError(op string, resp *http.Response) error {
+	var body struct {
+		Error json.RawMessage `json:"error"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&body)
+	var message string
+	if json.Unmarshal(body.Error, &message) != nil {
+		var obj struct {
+			Message string `json:"message"`
+		}
+		json.Unmarshal(body.Error, &obj)
+		message = obj.Message
+	}
+	return &ollamago.StatusError{Op: op, StatusCode: resp.StatusCode, Status: resp.Status, Message: message}
+}
+
+func unsupported(op string) error {
+	return fmt.Errorf("cannot %s: %w over the OpenAI API", op, ollamago.ErrUnsupported)
+}
+
+type message struct {
+	Role       string     `json:"role"`
+	Content    any        `json:"content"`
+	ToolCalls  []toolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+}
+
+type contentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *imageURL `json:"image_url,omitempty"`
+}
+
+type imageURL struct {
+	URL string `json:"url"`
+}
+
+type toolCall struct {
+	Index    *int   `json:"index,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type responseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *jsonSchema `json:"json_schema,omitempty"`
+}
+
+type jsonSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+}
+
+// sampling holds the options shared by chat and text completions.
+type sampling struct {
+	Temperature      *float64 `json:"temperature,omitempty"`
+	TopP             *float64 `json:"top_p,omitempty"`
+	MaxTokens        *int     `json:"max_tokens,omitempty"`
+	Seed             *int     `json:"seed,omitempty"`
+	Stop             []string `json:"stop,omitempty"`
+	PresencePenalty  *float64 `json:"presence_penalty,omitempty"`
+	FrequencyPenalty *float64 `json:"frequency_penalty,omitempty"`
+}
+
+func newSampling(p ollamago.ModelParameters) sampling {
+	s := sampling{
+		Temperature:      p.Temperature,
+		TopP:             p.TopP,
+		Seed:             p.Seed,
+		PresencePenalty:  p.PresencePenalty,
+		FrequencyPenalty: p.FrequencyPenalty,
+	}
+	if p.NumPredict != nil && *p.NumPredict > 0 {
+		s.MaxTokens = p.NumPredict
+	}
+	if p.Stop != "" {
+		s.Stop = append(s.Stop, p.Stop)
+	}
+	s.Stop = append(s.Stop, p.StopSequences...)
+	return s
+}
+
+type chatRequest struct {
+	Model          string          `json:"model"`
+	Messages       []message       `json:"messages"`
+	Tools          []ollamago.Tool `json:"tools,omitempty"`
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+	Stream         bool            `json:"stream"`
+	Logprobs       bool            `json:"logprobs,omitempty"`
+	TopLogprobs    int             `json:"top_logprobs,omitempty"`
+	sampling
+}
+
+func newChatRequest(req ollamago.ChatRequest) chatRequest {
+	out := chatRequest{
+		Model:          req.Model,
+		Tools:          req.Tools,
+		ResponseFormat: newResponseFormat(req.Format),
+		Stream:         req.Stream,
+		Logprobs:       req.Logprobs,
+		TopLogprobs:    req.TopLogprobs,
+		sampling:       newSampling(req.Options),
+	}
+	for _, m := range req.Messages {
+		msg := message{Role: m.Role, Content: m.Content, ToolCallID: m.ToolCallID}
+		if len(m.Images) > 0 {
+			parts := []contentPart{{Type: "text", Text: m.Content}}
+			for _, img := range m.Images {
+				parts = append(parts, contentPart{Type: "image_url", ImageURL: &imageURL{URL: dataURI(img)}})
+			}
+			msg.Content = parts
+		}
+		for _, call := range m.ToolCalls {
+			tc := toolCall{ID: call.ID, Type: "function"}
+			tc.Function.Name = call.Function.Name
+			tc.Function.Arguments = string(call.Function.Arguments)
+			msg.ToolCalls = append(msg.ToolCalls, tc)
+		}
+		out.Messages = append(out.Messages, msg)
+	}
+	return out
+}
+
+// newResponseFormat maps Ollama's "json" format and JSON schemas.
+func newResponseFormat(format json.RawMessage) *responseFormat {
+	switch {
+	case len(format) == 0:
+		return nil
+	case string(format) == `"json"`:
+		return &responseFormat{Type: "json_object"}
+	default:
+		return &responseFormat{Type: "json_schema", JSONSchema: &jsonSchema{Name: "response", Schema: format}}
+	}
+}
+
+// dataURI wraps a base64-encoded image, detecting its media type.
+func dataURI(img string) string {
+	head, _ := base64.StdEncoding.DecodeString(img[:min(len(img), 64)])
+	return "data:" + http.DetectContentType(head) + ";base64," + img
+}
+
+type choice struct {
+	Index        int             `json:"index"`
+	Message      responseMessage `json:"message"`
+	Delta        responseMessage `json:"delta"`
+	Text         string          `json:"text"`
+	FinishReason *string         `json:"finish_reason"`
+
+	// Logprobs of chat completions share the shape of Ollama's.
+	Logprobs *struct {
+		Content []ollamago.Logprob `json:"content"`
+	} `json:"logprobs"`
+}
+
+type responseMessage struct {
+	Role      string     `json:"role"`
+	Content   string     `json:"content"`
+	ToolCalls []toolCall `json:"tool_calls"`
+}
+
+type completionResponse struct {
+	Model   string   `json:"model"`
+	Choices []choice `json:"choices"`
+}
+
+func (c *Client) GenerateChat(ctx context.Context, req ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := c.do(ctx, "generate chat", "POST", "/chat/completions", newChatRequest(req))
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan ollamago.ChatResponse)
+	go func() {
+		defer resp.Body.Close()
+		defer close(out)
+		var calls []toolCall
+		err := decodeChunks(resp.Body, req.Stream, func(chunk completionResponse) {
+			if len(chunk.Choices) == 0 {
+				return
+			}
+			ch := chunk.Choices[0]
+			delta := ch.Delta
+			if !req.Stream {
+				delta = ch.Message
+			}
+			calls = mergeToolCalls(calls, delta.ToolCalls)
+			res := ollamago.ChatResponse{
+				Model:   chunk.Model,
+				Message: ollamago.ChatMessage{Role: delta.Role, Content: delta.Content},
+			}
+			res.ToolCallDeltas = newToolCallDeltas(delta.ToolCalls)
+			if ch.Logprobs != nil {
+				res.Logprobs = ch.Logprobs.Content
+			}
+			if ch.FinishReason != nil {
+				res.Message.ToolCalls = newToolCalls(calls)
+				res.Done = true
+				res.DoneReason = doneReason(*ch.FinishReason)
+				res.TotalDuration = time.Since(start)
+			}
+			if res.Message.Role == "" {
+				res.Message.Role = ollamago.RoleAssistant
+			}
+			out <- res
+		})
+		if err != nil {
+			out <- ollamago.ChatResponse{Error: err}
+		}
+	}()
+	return out, nil
+}
+
+// mergeToolCalls accumulates streamed tool calls, whose arguments arrive in
+// fragments keyed by the call index.
+func mergeToolCalls(calls, deltas []toolCall) []toolCall {
+	for i, d := range deltas {
+		idx := i
+		if d.Index != nil {
+			idx = *d.Index
+		}
+		for len(calls) <= idx {
+			calls = append(calls, toolCall{})
+		}
+		call := &calls[idx]
+		if d.ID != "" {
+			call.ID = d.ID
+		}
+		call.Function.Name += d.Function.Name
+		call.Function.Arguments += d.Function.Arguments
+	}
+	return calls
+}
+
+// newToolCallDeltas relays the streamed fragments of tool calls.
+func newToolCallDeltas(deltas []toolCall) []ollamago.ToolCallDelta {
+	var out []ollamago.ToolCallDelta
+	for i, d := range deltas {
+		idx := i
+		if d.Index != nil {
+			idx = *d.Index
+		}
+		out = append(out, ollamago.ToolCallDelta{Index: idx, ID: d.ID, Name: d.Function.Name, Arguments: d.Function.Arguments})
+	}
+	return out
+}
+
+func newToolCalls(calls []toolCall) []ollamago.ToolCall {
+	var out []ollamago.ToolCall
+	for _, call := range calls {
+		args := json.RawMessage(call.Function.Arguments)
+		if !json.Valid(args) {
+			args, _ = json.Marshal(call.Function.Arguments)
+		}
+		out = append(out, ollamago.ToolCall{
+			ID:       call.ID,
+			Function: ollamago.ToolCallFunction{Name: call.Function.Name, Arguments: args},
+		})
+	}
+	return out
+}
+
+// doneReason maps an OpenAI finish reason to the Ollama one.
+func doneReason(finish string) ollamago.DoneReason {
+	switch finish {
+	case "stop", "tool_calls", "function_call":
+		return ollamago.DoneStop
+	case "length":
+		return ollamago.DoneLength
+	}
+	return ollamago.DoneReason(finish)
+}
+
+// decodeChunks calls fn with every chunk of a server-sent event stream, or
+// with the whole response if it is not streamed.
+func decodeChunks(body io.Reader, stream bool, fn func(completionResponse)) error {
+	if !stream {
+		var chunk completionResponse
+		if err := json.NewDecoder(body).Decode(&chunk); err != nil {
+			return fmt.Errorf("cannot decode response: %w", err)
+		}
+		fn(chunk)
+		return nil
+	}
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			return nil
+		}
+		var chunk completionResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("cannot decode response: %w", err)
+		}
+		fn(chunk)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+type textCompletionRequest struct {
+	Model          string          `json:"model"`
+	Prompt         string          `json:"prompt"`
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+	Stream         bool            `json:"stream"`
+	Logprobs       bool            `json:"logprobs,omitempty"`
+	TopLogprobs    int             `json:"top_logprobs,omitempty"`
+	sampling
+}
+
+func (c *Client) GenerateCompletion(ctx context.Context, req ollamago.CompletionRequest) (<-chan ollamago.CompletionResponse, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	resp, err := c.do(ctx, "generate completion", "POST", "/completions", textCompletionRequest{
+		Model:          req.Model,
+		Prompt:         req.Prompt,
+		ResponseFormat: newResponseFormat(req.Format),
+		Stream:         req.Stream,
+		Logprobs:       req.Logprobs,
+		TopLogprobs:    req.TopLogprobs,
+		sampling:       newSampling(req.Options),
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan ollamago.CompletionResponse)
+	go func() {
+		defer resp.Body.Close()
+		defer close(out)
+		err := decodeChunks(resp.Body, req.Stream, func(chunk completionResponse) {
+			if len(chunk.Choices) == 0 {
+				return
+			}
+			ch := chunk.Choices[0]
+			res := ollamago.CompletionResponse{Model: chunk.Model, Response: ch.Text}
+			if ch.FinishReason != nil {
+				res.Done = true
+				res.DoneReason = doneReason(*ch.FinishReason)
+				res.TotalDuration = time.Since(start)
+			}
+			out <- res
+		})
+		if err != nil {
+			out <- ollamago.CompletionResponse{Error: err}
+		}
+	}()
+	return out, nil
+}
+
+type embedRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embedResponse[T float32 | float64] struct {
+	Model string         `json:"model"`
+	Data  []embedding[T] `json:"data"`
+}
+
+type embedding[T float32 | float64] struct {
+	Index     int `json:"index"`
+	Embedding []T `json:"embedding"`
+}
+
+func embed[T float32 | float64](ctx context.Context, c *Client, req ollamago.EmbedRequest) (string, [][]T, time.Duration, error) {
+	if err := req.Validate(); err != nil {
+		return "", nil, 0, err
+	}
+	start := time.Now()
+	resp, err := c.do(ctx, "generate embeddings", "POST", "/embeddings", embedRequest{Model: req.Model, Input: req.Input})
+	if err != nil {
+		return "", nil, 0, err
+	}
+	defer resp.Body.Close()
+	var embedResp embedResponse[T]
+	if err := json.NewDecoder(resp.Body).Decode(&embedResp); err != nil {
+		return "", nil, 0, fmt.Errorf("cannot decode embed response: %w", err)
+	}
+	slices.SortFunc(embedResp.Data, func(a, b embedding[T]) int {
+		return a.Index - b.Index
+	})
+	embeddings := make([][]T, len(embedResp.Data))
+	for i, d := range embedResp.Data {
+		embeddings[i] = d.Embedding
+	}
+	return embedResp.Model, embeddings, time.Since(start), nil
+}
+
+func (c *Client) GenerateEmbeddings(ctx context.Context, req ollamago.EmbedRequest) (*ollamago.EmbedResponse, error) {
+	model, embeddings, d, err := embed[float64](ctx, c, req)
+	if err != nil {
+		return nil, err
+	}
+	return &ollamago.EmbedResponse{Model: model, Embeddings: embeddings, Duration: d}, nil
+}
+
+func (c *Client) GenerateEmbeddings32(ctx context.Context, req ollamago.EmbedRequest) (*ollamago.EmbedResponse32, error) {
+	model, embeddings, d, err := embed[float32](ctx, c, req)
+	if err != nil {
+		return nil, err
+	}
+	return &ollamago.EmbedResponse32{Model: model, Embeddings: embeddings, Duration: d}, nil
+}
+
+// ListModels lists the models of /v1/models. Only their names and
+// creation times are known.
+func (c *Client) ListModels(ctx context.Context) (*ollamago.ListModelsResponse, error) {
+	resp, err := c.do(ctx, "list models", "GET", "/models", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var modelsResp struct {
+		Data []struct {
+			ID      string `json:"id"`
+			Created int64  `json:"created"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&modelsResp); err != nil {
+		return nil, fmt.Errorf("cannot decode models response: %w", err)
+	}
+	list := &ollamago.ListModelsResponse{}
+	for _, m := range modelsResp.Data {
+		list.Models = append(list.Models, ollamago.ModelInfo{Name: m.ID, Model: m.ID, ModifiedAt: time.Unix(m.Created, 0)})
+	}
+	return list, nil
+}
+
+func (c *Client) ListRunningModels(context.Context) (*ollamago.ListRunningModelsResponse, error) {
+	return nil, unsupported("list running models")
+}
+
+func (c *Client) ShowModelInfo(context.Context, ollamago.ShowModelRequest) (*ollamago.ShowModelResponse, error) {
+	return nil, unsupported("show model info")
+}
+
+func (c *Client) DeleteModel(context.Context, ollamago.DeleteModelRequest) error {
+	return unsupported("delete model")
+}
+
+func (c *Client) PullModel(context.Context, ollamago.PullModelRequest) (<-chan ollamago.ProgressEvent, error) {
+	return nil, unsupported("pull model")
+}
+
+func (c *Client) PushModel(context.Context, ollamago.PushModelRequest) (<-chan ollamago.ProgressEvent, error) {
+	return nil, unsupported("push model")
+}
+
+func (c *Client) CreateModel(context.Context, ollamago.CreateModelRequest) (<-chan ollamago.ProgressEvent, error) {
+	return nil, unsupported("create model")
+}
+
+func (c *Client) Version(context.Context) (string, error) {
+	return "", unsupported("get version")
+}
@@ -0,0 +1,208 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package openai_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/openai"
+	"github.com/stretchr/testify/require"
+)
+
+func newServer(t *testing.T) (*openai.Client, *[]string) {
+	t.Helper()
+	var bodies []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/chat/completions", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		var req struct {
+			Model  string `json:"model"`
+			Stream bool   `json:"stream"`
+		}
+		json.Unmarshal(body, &req)
+		if req.Model == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"message":"model \"missing\" not found","type":"invalid_request_error"}}`)
+			return
+		}
+		if !req.Stream {
+			fmt.Fprint(w, `{"model":"test","choices":[{"index":0,"message":{"role":"assistant","content":"hello"},"finish_reason":"stop"}]}`)
+			return
+		}
+		for _, chunk := range []string{
+			`{"model":"test","choices":[{"index":0,"delta":{"role":"assistant","content":"hel"},"logprobs":{"content":[{"token":"hel","logprob":-0.5,"bytes":[104,101,108],"top_logprobs":[]}]}}]}`,
+			`{"model":"test","choices":[{"index":0,"delta":{"content":"lo"}}]}`,
+			`{"model":"test","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"add","arguments":"{\"a\":"}}]}}]}`,
+			`{"model":"test","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"1}"}}]}}]}`,
+			`{"model":"test","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		} {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+	mux.HandleFunc("POST /v1/completions", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		fmt.Fprint(w, "data: {\"model\":\"test\",\"choices\":[{\"text\":\"once\"}]}\n\n")
+		fmt.Fprint(w, "data: {\"model\":\"test\",\"choices\":[{\"text\":\" upon\",\"finish_reason\":\"length\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	})
+	mux.HandleFunc("POST /v1/embeddings", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"model":"test","data":[{"index":1,"embedding":[0,1]},{"index":0,"embedding":[1,0]}]}`)
+	})
+	mux.HandleFunc("GET /v1/models", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		require.Equal(t, ollamago.DefaultUserAgent, r.Header.Get("User-Agent"))
+		fmt.Fprint(w, `{"object":"list","data":[{"id":"llama3.2:latest","object":"model","created":1700000000,"owned_by":"library"}]}`)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return &openai.Client{BaseURL: server.URL + "/v1", HTTPClient: server.Client(), APIKey: "secret"}, &bodies
+}
+
+func TestGenerateChat(t *testing.T) {
+	client, bodies := newServer(t)
+	ctx := context.Background()
+	respChan, err := client.GenerateChat(ctx, ollamago.ChatRequest{
+		Model: "test",
+		Messages: []ollamago.ChatMessage{
+			ollamago.SystemMessage("be brief"),
+			ollamago.UserMessageWithImages("what is this?", "iVBORw0KGgo="),
+		},
+		Format:      json.RawMessage(`"json"`),
+		Stream:      true,
+		Logprobs:    true,
+		TopLogprobs: 3,
+		Options:     ollamago.ModelParameters{Temperature: ollamago.Ptr(0.0), NumPredict: ollamago.Ptr(64), StopSequences: []string{"User:"}},
+	})
+	require.NoError(t, err)
+	var content strings.Builder
+	var last ollamago.ChatResponse
+	var logprobs []ollamago.Logprob
+	var deltas []ollamago.ToolCallDelta
+	for r := range respChan {
+		require.NoError(t, r.Error)
+		content.WriteString(r.Message.Content)
+		logprobs = append(logprobs, r.Logprobs...)
+		deltas = append(deltas, r.ToolCallDeltas...)
+		last = r
+	}
+	require.Equal(t, []ollamago.ToolCallDelta{
+		{Index: 0, ID: "call_1", Name: "add", Arguments: `{"a":`},
+		{Index: 0, Arguments: `1}`},
+	}, deltas)
+	require.Len(t, logprobs, 1)
+	require.Equal(t, ollamago.TokenLogprob{Token: "hel", Logprob: -0.5, Bytes: []int{104, 101, 108}}, logprobs[0].TokenLogprob)
+	require.Equal(t, "hello", content.String())
+	require.True(t, last.Done)
+	require.Equal(t, ollamago.DoneStop, last.DoneReason)
+	require.Equal(t, []ollamago.ToolCall{{ID: "call_1", Function: ollamago.ToolCallFunction{Name: "add", Arguments: json.RawMessage(`{"a":1}`)}}}, last.Message.ToolCalls)
+	require.JSONEq(t, `{
+		"model":"test",
+		"messages":[
+			{"role":"system","content":"be brief"},
+			{"role":"user","content":[{"type":"text","text":"what is this?"},{"type":"image_url","image_url":{"url":"data:image/png;base64,iVBORw0KGgo="}}]}
+		],
+		"response_format":{"type":"json_object"},
+		"stream":true,
+		"logprobs":true,
+		"top_logprobs":3,
+		"temperature":0,
+		"max_tokens":64,
+		"stop":["User:"]
+	}`, (*bodies)[0])
+
+	respChan, err = client.GenerateChat(ctx, ollamago.ChatRequest{
+		Model: "test",
+		Messages: []ollamago.ChatMessage{
+			ollamago.UserMessage("add 1"),
+			{Role: ollamago.RoleAssistant, ToolCalls: last.Message.ToolCalls},
+			ollamago.ToolResult("call_1", "1"),
+		},
+	})
+	require.NoError(t, err)
+	var replies []ollamago.ChatResponse
+	for r := range respChan {
+		replies = append(replies, r)
+	}
+	require.Len(t, replies, 1)
+	require.Equal(t, "hello", replies[0].Message.Content)
+	require.True(t, replies[0].Done)
+	require.JSONEq(t, `{
+		"model":"test",
+		"messages":[
+			{"role":"user","content":"add 1"},
+			{"role":"assistant","content":"","tool_calls":[{"id":"call_1","type":"function","function":{"name":"add","arguments":"{\"a\":1}"}}]},
+			{"role":"tool","content":"1","tool_call_id":"call_1"}
+		],
+		"stream":false
+	}`, (*bodies)[1])
+
+	_, err = client.GenerateChat(ctx, ollamago.ChatRequest{Model: "missing", Messages: []ollamago.ChatMessage{ollamago.UserMessage("hi")}})
+	var statusErr *ollamago.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+	require.Equal(t, `model "missing" not found`, statusErr.Message)
+}
+
+func TestGenerateCompletion(t *testing.T) {
+	client, _ := newServer(t)
+	respChan, err := client.GenerateCompletion(context.Background(), ollamago.CompletionRequest{Model: "test", Prompt: "once", Stream: true})
+	require.NoError(t, err)
+	var text strings.Builder
+	var last ollamago.CompletionResponse
+	for r := range respChan {
+		require.NoError(t, r.Error)
+		text.WriteString(r.Response)
+		last = r
+	}
+	require.Equal(t, "once upon", text.String())
+	require.True(t, last.Done)
+	require.True(t, last.WasTruncated())
+}
+
+func TestGenerateEmbeddings(t *testing.T) {
+	client, _ := newServer(t)
+	ctx := context.Background()
+	resp, err := client.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: "test", Input: []string{"a", "b"}})
+	require.NoError(t, err)
+	require.Equal(t, [][]float64{{1, 0}, {0, 1}}, resp.Embeddings)
+	resp32, err := client.GenerateEmbeddings32(ctx, ollamago.EmbedRequest{Model: "test", Input: []string{"a", "b"}})
+	require.NoError(t, err)
+	require.Equal(t, [][]float32{{1, 0}, {0, 1}}, resp32.Embeddings)
+}
+
+func TestListModels(t *testing.T) {
+	client, _ := newServer(t)
+	ctx := context.Background()
+	models, err := client.ListModels(ctx)
+	require.NoError(t, err)
+	require.Len(t, models.Models, 1)
+	require.Equal(t, "llama3.2:latest", models.Models[0].Name)
+	require.EqualValues(t, 1700000000, models.Models[0].ModifiedAt.Unix())
+
+	require.NoError(t, ollamago.EnsureModel(ctx, client, "llama3.2"), "helpers work over the OpenAI API")
+	_, err = client.Version(ctx)
+	require.ErrorIs(t, err, ollamago.ErrUnsupported)
+}
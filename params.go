@@ -0,0 +1,232 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+)
+
+// ParamsBuilder builds ModelParameters. Its setters return the builder so
+// that calls can be chained, starting from a preset or from scratch:
+//
+//	params, err := ollamago.Balanced().NumCtx(8192).Stop("User:").Build()
+type ParamsBuilder struct {
+	params ModelParameters
+}
+
+// NewParams returns a builder of ModelParameters left to the server
+// defaults.
+func NewParams() *ParamsBuilder {
+	return &ParamsBuilder{}
+}
+
+// Deterministic returns a builder preset for reproducible answers: greedy
+// sampling with a fixed seed.
+func Deterministic() *ParamsBuilder {
+	return NewParams().Temperature(0).TopK(1).Seed(42)
+}
+
+// Balanced returns a builder preset for general use.
+func Balanced() *ParamsBuilder {
+	return NewParams().Temperature(0.7).TopP(0.9).TopK(40)
+}
+
+// Creative returns a builder preset for varied, imaginative answers.
+func Creative() *ParamsBuilder {
+	return NewParams().Temperature(1.1).TopP(0.95).TopK(100).RepeatPenalty(1.15)
+}
+
+// Temperature sets the sampling temperature; 0 makes sampling greedy.
+func (b *ParamsBuilder) Temperature(v float64) *ParamsBuilder {
+	b.params.Temperature = &v
+	return b
+}
+
+// TopK limits sampling to the k most likely tokens.
+func (b *ParamsBuilder) TopK(v int) *ParamsBuilder {
+	b.params.TopK = &v
+	return b
+}
+
+// TopP sets the nucleus sampling probability mass, in [0, 1].
+func (b *ParamsBuilder) TopP(v float64) *ParamsBuilder {
+	b.params.TopP = &v
+	return b
+}
+
+// MinP sets the minimum probability of a token relative to the most likely one, in [0, 1].
+func (b *ParamsBuilder) MinP(v float64) *ParamsBuilder {
+	b.params.MinP = &v
+	return b
+}
+
+// TypicalP sets the locally typical sampling probability mass, in [0, 1].
+func (b *ParamsBuilder) TypicalP(v float64) *ParamsBuilder {
+	b.params.TypicalP = &v
+	return b
+}
+
+// Seed sets the random seed, making answers reproducible.
+func (b *ParamsBuilder) Seed(v int) *ParamsBuilder {
+	b.params.Seed = &v
+	return b
+}
+
+// NumCtx sets the size of the context window in tokens.
+func (b *ParamsBuilder) NumCtx(v int) *ParamsBuilder {
+	b.params.NumCtx = &v
+	return b
+}
+
+// NumPredict sets the maximum number of tokens to generate; -1 is unlimited and -2 fills the context.
+func (b *ParamsBuilder) NumPredict(v int) *ParamsBuilder {
+	b.params.NumPredict = &v
+	return b
+}
+
+// RepeatPenalty sets how strongly repetitions are penalized.
+func (b *ParamsBuilder) RepeatPenalty(v float64) *ParamsBuilder {
+	b.params.RepeatPenalty = &v
+	return b
+}
+
+// RepeatLastN sets how far back repetitions are looked for; -1 is the whole context.
+func (b *ParamsBuilder) RepeatLastN(v int) *ParamsBuilder {
+	b.params.RepeatLastN = &v
+	return b
+}
+
+// PresencePenalty penalizes tokens that already appeared.
+func (b *ParamsBuilder) PresencePenalty(v float64) *ParamsBuilder {
+	b.params.PresencePenalty = &v
+	return b
+}
+
+// FrequencyPenalty penalizes tokens by how often they already appeared.
+func (b *ParamsBuilder) FrequencyPenalty(v float64) *ParamsBuilder {
+	b.params.FrequencyPenalty = &v
+	return b
+}
+
+// Mirostat enables Mirostat sampling, version 1 or 2, with the given
+// learning rate and target entropy.
+func (b *ParamsBuilder) Mirostat(version int, eta, tau float64) *ParamsBuilder {
+	b.params.Mirostat = &version
+	b.params.MirostatEta = &eta
+	b.params.MirostatTau = &tau
+	return b
+}
+
+// Stop adds stop sequences.
+func (b *ParamsBuilder) Stop(sequences ...string) *ParamsBuilder {
+	b.params.StopSequences = append(b.params.StopSequences, sequences...)
+	return b
+}
+
+// NumGPU sets the number of layers offloaded to the GPU.
+func (b *ParamsBuilder) NumGPU(v int) *ParamsBuilder {
+	b.params.NumGPU = &v
+	return b
+}
+
+// NumThread sets the number of CPU threads.
+func (b *ParamsBuilder) NumThread(v int) *ParamsBuilder {
+	b.params.NumThread = &v
+	return b
+}
+
+// Set sets an option without a setter, sent through
+// ModelParameters.Extra.
+func (b *ParamsBuilder) Set(name string, value any) *ParamsBuilder {
+	if b.params.Extra == nil {
+		b.params.Extra = make(map[string]any)
+	}
+	b.params.Extra[name] = value
+	return b
+}
+
+// Build returns the parameters, or the errors of Validate.
+func (b *ParamsBuilder) Build() (ModelParameters, error) {
+	params := b.params
+	params.StopSequences = append([]string(nil), b.params.StopSequences...)
+	params.Extra = maps.Clone(b.params.Extra)
+	if err := params.Validate(); err != nil {
+		return ModelParameters{}, err
+	}
+	return params, nil
+}
+
+// Validate checks that the options that are set are within their valid
+// ranges.
+func (p ModelParameters) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	inRange := func(name string, v *float64, lo, hi float64) {
+		if v != nil {
+			check(*v >= lo && *v <= hi, "%s must be in [%v, %v], got %v", name, lo, hi, *v)
+		}
+	}
+	atLeast := func(name string, v *int, lo int) {
+		if v != nil {
+			check(*v >= lo, "%s must be at least %d, got %d", name, lo, *v)
+		}
+	}
+	if p.Temperature != nil {
+		check(*p.Temperature >= 0, "temperature must not be negative, got %v", *p.Temperature)
+	}
+	if p.RepeatPenalty != nil {
+		check(*p.RepeatPenalty >= 0, "repeat_penalty must not be negative, got %v", *p.RepeatPenalty)
+	}
+	if p.Mirostat != nil {
+		check(*p.Mirostat >= 0 && *p.Mirostat <= 2, "mirostat must be 0, 1 or 2, got %d", *p.Mirostat)
+	}
+	inRange("top_p", p.TopP, 0, 1)
+	inRange("min_p", p.MinP, 0, 1)
+	inRange("typical_p", p.TypicalP, 0, 1)
+	inRange("tfs_z", p.TfsZ, 0, 1)
+	atLeast("top_k", p.TopK, 0)
+	atLeast("num_ctx", p.NumCtx, 1)
+	atLeast("num_predict", p.NumPredict, -2)
+	atLeast("repeat_last_n", p.RepeatLastN, -1)
+	atLeast("num_keep", p.NumKeep, -1)
+	atLeast("num_batch", p.NumBatch, 1)
+	atLeast("num_gpu", p.NumGPU, -1)
+	atLeast("num_thread", p.NumThread, 0)
+	return errors.Join(errs...)
+}
+
+// withDefaults returns p with the options it leaves unset taken from
+// defaults.
+func (p ModelParameters) withDefaults(defaults ModelParameters) ModelParameters {
+	merged := p
+	dst, src := reflect.ValueOf(&merged).Elem(), reflect.ValueOf(defaults)
+	for i := range dst.NumField() {
+		if f := dst.Field(i); f.IsZero() && f.Kind() != reflect.Map {
+			f.Set(src.Field(i))
+		}
+	}
+	if len(defaults.Extra) > 0 {
+		merged.Extra = maps.Clone(defaults.Extra)
+		maps.Copy(merged.Extra, p.Extra)
+	}
+	return merged
+}
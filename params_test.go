@@ -0,0 +1,60 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"cirello.io/ollamago"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParamsBuilder(t *testing.T) {
+	params, err := ollamago.Deterministic().NumCtx(4096).Stop("User:").Set("num_gqa", 8).Build()
+	require.NoError(t, err)
+	b, err := json.Marshal(params)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"temperature":0,"top_k":1,"seed":42,"num_ctx":4096,"stop":["User:"],"num_gqa":8}`, string(b))
+
+	for _, preset := range []*ollamago.ParamsBuilder{ollamago.Balanced(), ollamago.Creative(), ollamago.NewParams()} {
+		_, err := preset.Build()
+		require.NoError(t, err)
+	}
+
+	builder := ollamago.Balanced()
+	first, err := builder.Stop("a").Build()
+	require.NoError(t, err)
+	builder.Stop("b")
+	require.Equal(t, []string{"a"}, first.StopSequences, "built parameters do not change with the builder")
+}
+
+func TestParamsBuilderValidation(t *testing.T) {
+	_, err := ollamago.Balanced().TopP(1.5).Temperature(-1).Build()
+	require.ErrorContains(t, err, "top_p must be in [0, 1], got 1.5")
+	require.ErrorContains(t, err, "temperature must not be negative, got -1")
+
+	_, err = ollamago.NewParams().Mirostat(3, 0.1, 5).Build()
+	require.ErrorContains(t, err, "mirostat must be 0, 1 or 2, got 3")
+
+	_, err = ollamago.NewParams().NumCtx(0).Build()
+	require.ErrorContains(t, err, "num_ctx must be at least 1, got 0")
+
+	_, err = ollamago.NewParams().NumPredict(-2).RepeatLastN(-1).NumGPU(-1).Build()
+	require.NoError(t, err, "special negative values are accepted")
+
+	require.NoError(t, ollamago.ModelParameters{}.Validate())
+	require.Error(t, ollamago.ModelParameters{MinP: ollamago.Ptr(-0.1)}.Validate())
+}
@@ -0,0 +1,173 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// PartialJSON turns the beginning of a JSON document, as streamed by a
+// model, into valid JSON holding the values completed so far: it cuts the
+// text after the last complete value and closes the open arrays and
+// objects. Strings, numbers and literals still being generated are left
+// out, as are the keys waiting for their value. It returns an empty
+// string if no value is complete yet.
+func PartialJSON(s string) string {
+	start := strings.IndexAny(s, "{[")
+	if start < 0 {
+		return ""
+	}
+	s = s[start:]
+	type frame struct {
+		closer    byte
+		expectKey bool
+	}
+	var (
+		stack   []frame
+		cut     int
+		closers []byte
+	)
+	complete := func(end int) {
+		cut = end
+		closers = closers[:0]
+		for i := len(stack) - 1; i >= 0; i-- {
+			closers = append(closers, stack[i].closer)
+		}
+	}
+	for i := 0; i < len(s) && (i == 0 || len(stack) > 0); i++ {
+		switch c := s[i]; c {
+		case ' ', '\t', '\r', '\n', ':':
+		case '{', '[':
+			stack = append(stack, frame{closer: '}', expectKey: true})
+			if c == '[' {
+				stack[len(stack)-1] = frame{closer: ']'}
+			}
+			if len(stack) == 1 {
+				complete(i + 1)
+			}
+		case '}', ']':
+			stack = stack[:len(stack)-1]
+			complete(i + 1)
+		case ',':
+			if top := &stack[len(stack)-1]; top.closer == '}' {
+				top.expectKey = true
+			}
+		case '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return partialResult(s, cut, closers)
+			}
+			if top := &stack[len(stack)-1]; top.expectKey {
+				top.expectKey = false
+			} else {
+				complete(j + 1)
+			}
+			i = j
+		default:
+			j := i
+			for ; j < len(s) && !strings.ContainsRune(",}] \t\r\n", rune(s[j])); j++ {
+			}
+			if j >= len(s) {
+				return partialResult(s, cut, closers)
+			}
+			complete(j)
+			i = j - 1
+		}
+	}
+	return partialResult(s, cut, closers)
+}
+
+func partialResult(s string, cut int, closers []byte) string {
+	if cut == 0 {
+		return ""
+	}
+	return s[:cut] + string(closers)
+}
+
+// Partial is a value of a structured reply being streamed.
+type Partial[T any] struct {
+	// Value holds the fields completed so far.
+	Value T
+
+	// Done reports whether the reply is complete, Value holding all of
+	// it, validated if T implements Validator.
+	Done bool
+
+	// Err is the error of the call or, when Done, of the decoding of the
+	// reply.
+	Err error
+}
+
+// ChatIntoStream is the streaming form of ChatInto: it sends values of T
+// populated with the fields the model has completed so far, as the reply
+// is generated, such as to fill in a form on the screen, and then the
+// complete value. A partial value is sent each time a field completes.
+func ChatIntoStream[T any](ctx context.Context, client API, req ChatRequest) (<-chan Partial[T], error) {
+	req.Format = Schema(reflect.TypeFor[T]())
+	req.Stream = true
+	resp, err := client.GenerateChat(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate structured output: %w", err)
+	}
+	out := make(chan Partial[T])
+	go func() {
+		defer close(out)
+		defer func() {
+			for range resp {
+			}
+		}()
+		var (
+			content strings.Builder
+			last    string
+		)
+		for r := range resp {
+			if r.Error != nil {
+				send(ctx, out, Partial[T]{Err: fmt.Errorf("cannot generate structured output: %w", r.Error)})
+				return
+			}
+			content.WriteString(r.Message.Content)
+			if r.Done {
+				break
+			}
+			partial := PartialJSON(content.String())
+			if partial == "" || partial == "{}" || partial == "[]" || partial == last {
+				continue
+			}
+			last = partial
+			var v T
+			if json.Unmarshal([]byte(partial), &v) != nil {
+				continue
+			}
+			if !send(ctx, out, Partial[T]{Value: v}) {
+				return
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return
+		}
+		v, err := decodeStructured[T](content.String(), false)
+		send(ctx, out, Partial[T]{Value: v, Done: true, Err: err})
+	}()
+	return out, nil
+}
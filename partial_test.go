@@ -0,0 +1,62 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartialJSON(t *testing.T) {
+	for _, tt := range []struct {
+		in, want string
+	}{
+		{``, ``},
+		{`Sure: {`, `{}`},
+		{`{"name":"Ad`, `{}`},
+		{`{"name":"Ada"`, `{"name":"Ada"}`},
+		{`{"name":"Ada","age":3`, `{"name":"Ada"}`},
+		{`{"name":"Ada","age":36,`, `{"name":"Ada","age":36}`},
+		{`{"name":"A\"da","tags":["x","y`, `{"name":"A\"da","tags":["x"]}`},
+		{`{"a":{"b":true,"c":nu`, `{"a":{"b":true}}`},
+		{`{"a":[1,2]}trailing`, `{"a":[1,2]}`},
+		{`[{"a":1},{"a"`, `[{"a":1}]`},
+	} {
+		require.Equal(t, tt.want, ollamago.PartialJSON(tt.in), tt.in)
+	}
+}
+
+func TestChatIntoStream(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test", Chunks: []string{`{"na`, `me":"Ada",`, `"ag`, `e":36`, `}`}})
+	t.Cleanup(srv.Close)
+	resp, err := ollamago.ChatIntoStream[person](context.Background(), srv.Client(), ollamago.ChatRequest{
+		Model:    "test",
+		Messages: []ollamago.ChatMessage{ollamago.UserMessage("Ada Lovelace, 36")},
+	})
+	require.NoError(t, err)
+	var partials []ollamago.Partial[person]
+	for p := range resp {
+		partials = append(partials, p)
+	}
+	require.Equal(t, []ollamago.Partial[person]{
+		{Value: person{Name: "Ada"}},
+		{Value: person{Name: "Ada", Age: 36}},
+		{Value: person{Name: "Ada", Age: 36}, Done: true},
+	}, partials)
+}
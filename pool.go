@@ -0,0 +1,527 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var _ API = (*Pool)(nil)
+
+// ErrNoHosts is returned by Pool when it has no host to send a call to.
+var ErrNoHosts = errors.New("ollamago: no hosts in pool")
+
+// PoolHost is the state of a Pool host as seen by a Balancer.
+type PoolHost struct {
+	Client *Client
+
+	// InFlight is the number of calls, streams included, that have not
+	// finished yet.
+	InFlight int
+}
+
+// Balancer picks the host of each call made through a Pool, returning its
+// index in hosts. model is the model named by the call, if any.
+type Balancer interface {
+	Pick(ctx context.Context, model string, hosts []PoolHost) (int, error)
+}
+
+// Pool distributes calls across several Ollama servers. Every call is sent
+// to a single host chosen by the Balancer. When a host cannot be reached
+// or answers with a 5xx status, the call fails over to another host and
+// the failed one is left out of the rotation for Cooldown. Streams fail
+// over only if they fail before the first chunk.
+type Pool struct {
+	// Hosts are the clients of the pooled servers. They are read on the
+	// first use of the Pool: hosts added or removed afterwards are
+	// ignored.
+	Hosts []*Client
+
+	// Balancer picks the host of each call. If nil, RoundRobin is used.
+	Balancer Balancer
+
+	// Cooldown is how long a failed host is skipped. If zero, 30 seconds
+	// is used.
+	Cooldown time.Duration
+
+	// HedgeDelay, if set, makes the calls that are not streamed, other
+	// than DeleteModel, also go to a second host when the first has not
+	// answered within the delay. The first successful response is used
+	// and the other call is canceled.
+	HedgeDelay time.Duration
+
+	once       sync.Once
+	hosts      []*Client // Hosts as of the first use
+	inFlight   []atomic.Int64
+	downUntil  []atomic.Int64
+	roundRobin RoundRobin
+}
+
+// NewPool creates a Pool of clients for baseURLs.
+func NewPool(baseURLs ...string) *Pool {
+	p := &Pool{}
+	for _, u := range baseURLs {
+		p.Hosts = append(p.Hosts, &Client{BaseURL: u})
+	}
+	return p
+}
+
+func (p *Pool) init() {
+	p.once.Do(func() {
+		p.hosts = slices.Clone(p.Hosts)
+		p.inFlight = make([]atomic.Int64, len(p.hosts))
+		p.downUntil = make([]atomic.Int64, len(p.hosts))
+	})
+}
+
+// Healthy reports, for each host, whether it is in the rotation.
+func (p *Pool) Healthy() []bool {
+	p.init()
+	healthy := make([]bool, len(p.hosts))
+	now := time.Now().UnixNano()
+	for i := range p.hosts {
+		healthy[i] = p.downUntil[i].Load() <= now
+	}
+	return healthy
+}
+
+// CheckHealth queries the version of every host, taking those that fail
+// out of the rotation and putting back those that answer.
+func (p *Pool) CheckHealth(ctx context.Context) error {
+	p.init()
+	errs := make([]error, len(p.hosts))
+	var wg sync.WaitGroup
+	for i, c := range p.hosts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Version(ctx); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", c.baseURL(), err)
+				p.markDown(i)
+				return
+			}
+			p.downUntil[i].Store(0)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func (p *Pool) markDown(i int) {
+	cooldown := p.Cooldown
+	if cooldown <= 0 {
+		cooldown = 30 * time.Second
+	}
+	p.downUntil[i].Store(time.Now().Add(cooldown).UnixNano())
+}
+
+// hostSet tracks the hosts tried by the attempts of a call.
+type hostSet struct {
+	mu    sync.Mutex
+	tried []bool
+}
+
+// pick chooses a host that has not been tried yet, preferring healthy
+// ones, and marks it as tried.
+func (p *Pool) pick(ctx context.Context, model string, tried *hostSet) (int, error) {
+	tried.mu.Lock()
+	defer tried.mu.Unlock()
+	if tried.tried == nil {
+		tried.tried = make([]bool, len(p.hosts))
+	}
+	now := time.Now().UnixNano()
+	var candidates []int
+	for _, healthyOnly := range []bool{true, false} {
+		for i := range p.hosts {
+			if !tried.tried[i] && (!healthyOnly || p.downUntil[i].Load() <= now) {
+				candidates = append(candidates, i)
+			}
+		}
+		if len(candidates) > 0 {
+			break
+		}
+	}
+	if len(candidates) == 0 {
+		return 0, ErrNoHosts
+	}
+	hosts := make([]PoolHost, len(candidates))
+	for j, i := range candidates {
+		hosts[j] = PoolHost{Client: p.hosts[i], InFlight: int(p.inFlight[i].Load())}
+	}
+	balancer := p.Balancer
+	if balancer == nil {
+		balancer = &p.roundRobin
+	}
+	j, err := balancer.Pick(ctx, model, hosts)
+	if err != nil {
+		return 0, err
+	}
+	tried.tried[candidates[j]] = true
+	return candidates[j], nil
+}
+
+// retryable tells whether a call failing with err should be retried on
+// another host.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrInvalidRequest) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError
+	}
+	return true
+}
+
+// poolCall runs call on the hosts of p not in tried until one succeeds or
+// fails with an error that is not worth retrying. release must be called
+// once the value returned by call is no longer in use.
+func poolCall[T any](ctx context.Context, p *Pool, model string, tried *hostSet, call func(*Client) (T, error)) (v T, release func(), err error) {
+	p.init()
+	if len(p.hosts) == 0 {
+		return v, nil, ErrNoHosts
+	}
+	var lastErr error
+	for {
+		i, err := p.pick(ctx, model, tried)
+		if errors.Is(err, ErrNoHosts) && lastErr != nil {
+			return v, nil, lastErr
+		} else if err != nil {
+			return v, nil, err
+		}
+		p.inFlight[i].Add(1)
+		var once sync.Once
+		release := func() { once.Do(func() { p.inFlight[i].Add(-1) }) }
+		v, err = call(p.hosts[i])
+		if err == nil {
+			return v, release, nil
+		}
+		release()
+		if !retryable(ctx, err) {
+			return v, nil, err
+		}
+		p.markDown(i)
+		lastErr = err
+	}
+}
+
+// poolStream is poolCall for the streaming calls.
+func poolStream[T any](ctx context.Context, p *Pool, model string, call func(*Client) (<-chan T, error)) (<-chan T, error) {
+	resp, release, err := poolCall(ctx, p, model, &hostSet{}, call)
+	if err != nil {
+		return nil, err
+	}
+	return relay(ctx, resp, release), nil
+}
+
+// poolUnary is poolCall for the calls that are over once they return,
+// hedged if hedge is set and p has a HedgeDelay.
+func poolUnary[T any](ctx context.Context, p *Pool, model string, hedge bool, call func(context.Context, *Client) (T, error)) (T, error) {
+	tried := &hostSet{}
+	attempt := func(ctx context.Context) (T, error) {
+		v, release, err := poolCall(ctx, p, model, tried, func(c *Client) (T, error) {
+			return call(ctx, c)
+		})
+		if err == nil {
+			release()
+		}
+		return v, err
+	}
+	p.init()
+	if !hedge || p.HedgeDelay <= 0 || len(p.hosts) < 2 {
+		return attempt(ctx)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		v   T
+		err error
+	}
+	results := make(chan result, 2)
+	launch := func() {
+		go func() {
+			v, err := attempt(ctx)
+			results <- result{v, err}
+		}()
+	}
+	launch()
+	timer := time.NewTimer(p.HedgeDelay)
+	defer timer.Stop()
+	var (
+		pending = 1
+		err     error
+	)
+	for {
+		select {
+		case <-timer.C:
+			pending++
+			launch()
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				return r.v, nil
+			}
+			if err == nil || !errors.Is(r.err, ErrNoHosts) {
+				err = r.err
+			}
+			if pending == 0 {
+				var zero T
+				return zero, err
+			}
+		}
+	}
+}
+
+// relay forwards a stream, calling release once it is over. It keeps
+// draining in after ctx is done so that the producer can exit.
+func relay[T any](ctx context.Context, in <-chan T, release func()) <-chan T {
+	out := make(chan T)
+	go func() {
+		defer close(out)
+		defer release()
+		for v := range in {
+			if !send(ctx, out, v) {
+				for range in {
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func (p *Pool) GenerateCompletion(ctx context.Context, req CompletionRequest) (<-chan CompletionResponse, error) {
+	return poolStream(ctx, p, req.Model, func(c *Client) (<-chan CompletionResponse, error) {
+		return c.GenerateCompletion(ctx, req)
+	})
+}
+
+func (p *Pool) GenerateEmbeddings(ctx context.Context, req EmbedRequest) (*EmbedResponse, error) {
+	return poolUnary(ctx, p, req.Model, true, func(ctx context.Context, c *Client) (*EmbedResponse, error) {
+		return c.GenerateEmbeddings(ctx, req)
+	})
+}
+
+func (p *Pool) GenerateEmbeddings32(ctx context.Context, req EmbedRequest) (*EmbedResponse32, error) {
+	return poolUnary(ctx, p, req.Model, true, func(ctx context.Context, c *Client) (*EmbedResponse32, error) {
+		return c.GenerateEmbeddings32(ctx, req)
+	})
+}
+
+func (p *Pool) GenerateChat(ctx context.Context, req ChatRequest) (<-chan ChatResponse, error) {
+	return poolStream(ctx, p, req.Model, func(c *Client) (<-chan ChatResponse, error) {
+		return c.GenerateChat(ctx, req)
+	})
+}
+
+func (p *Pool) ListModels(ctx context.Context) (*ListModelsResponse, error) {
+	return poolUnary(ctx, p, "", true, func(ctx context.Context, c *Client) (*ListModelsResponse, error) {
+		return c.ListModels(ctx)
+	})
+}
+
+func (p *Pool) ListRunningModels(ctx context.Context) (*ListRunningModelsResponse, error) {
+	return poolUnary(ctx, p, "", true, func(ctx context.Context, c *Client) (*ListRunningModelsResponse, error) {
+		return c.ListRunningModels(ctx)
+	})
+}
+
+func (p *Pool) ShowModelInfo(ctx context.Context, req ShowModelRequest) (*ShowModelResponse, error) {
+	return poolUnary(ctx, p, req.Model, true, func(ctx context.Context, c *Client) (*ShowModelResponse, error) {
+		return c.ShowModelInfo(ctx, req)
+	})
+}
+
+func (p *Pool) DeleteModel(ctx context.Context, req DeleteModelRequest) error {
+	_, err := poolUnary(ctx, p, req.Model, false, func(ctx context.Context, c *Client) (struct{}, error) {
+		return struct{}{}, c.DeleteModel(ctx, req)
+	})
+	return err
+}
+
+func (p *Pool) PullModel(ctx context.Context, req PullModelRequest) (<-chan ProgressEvent, error) {
+	return poolStream(ctx, p, req.Model, func(c *Client) (<-chan ProgressEvent, error) {
+		return c.PullModel(ctx, req)
+	})
+}
+
+func (p *Pool) PushModel(ctx context.Context, req PushModelRequest) (<-chan ProgressEvent, error) {
+	return poolStream(ctx, p, req.Model, func(c *Client) (<-chan ProgressEvent, error) {
+		return c.PushModel(ctx, req)
+	})
+}
+
+func (p *Pool) CreateModel(ctx context.Context, req CreateModelRequest) (<-chan ProgressEvent, error) {
+	return poolStream(ctx, p, req.Model, func(c *Client) (<-chan ProgressEvent, error) {
+		return c.CreateModel(ctx, req)
+	})
+}
+
+func (p *Pool) Version(ctx context.Context) (string, error) {
+	return poolUnary(ctx, p, "", true, func(ctx context.Context, c *Client) (string, error) {
+		return c.Version(ctx)
+	})
+}
+
+// RoundRobin is a Balancer cycling through the hosts.
+type RoundRobin struct {
+	next atomic.Uint64
+}
+
+func (b *RoundRobin) Pick(ctx context.Context, model string, hosts []PoolHost) (int, error) {
+	return int((b.next.Add(1) - 1) % uint64(len(hosts))), nil
+}
+
+// LeastInFlight is a Balancer picking the host with the fewest calls in
+// flight, the first one on ties.
+type LeastInFlight struct{}
+
+func (LeastInFlight) Pick(ctx context.Context, model string, hosts []PoolHost) (int, error) {
+	return leastInFlight(hosts, nil), nil
+}
+
+// leastInFlight returns the index of the least busy host among those
+// accepted by filter, or among all of them if filter is nil or accepts
+// none.
+func leastInFlight(hosts []PoolHost, filter func(i int) bool) int {
+	best := -1
+	for i, h := range hosts {
+		if filter != nil && !filter(i) {
+			continue
+		}
+		if best < 0 || h.InFlight < hosts[best].InFlight {
+			best = i
+		}
+	}
+	if best < 0 {
+		return leastInFlight(hosts, nil)
+	}
+	return best
+}
+
+// LeastLoaded is a Balancer picking the host with the least memory taken
+// by loaded models, as reported by /api/ps, using the calls in flight to
+// break ties. Hosts that cannot report their load are avoided.
+type LeastLoaded struct {
+	// Refresh is how long the models loaded by a host are remembered
+	// before /api/ps is queried again. If zero, 5 seconds is used.
+	Refresh time.Duration
+
+	inventory inventory
+}
+
+func (b *LeastLoaded) Pick(ctx context.Context, model string, hosts []PoolHost) (int, error) {
+	best, bestLoad := 0, int64(math.MaxInt64)
+	for i, h := range hosts {
+		load := int64(math.MaxInt64)
+		if inv, ok := b.inventory.get(ctx, h.Client, b.Refresh, false); ok {
+			load = inv.load
+		}
+		if load < bestLoad || (load == bestLoad && h.InFlight < hosts[best].InFlight) {
+			best, bestLoad = i, load
+		}
+	}
+	return best, nil
+}
+
+// ModelAware is a Balancer routing calls to the hosts that already have
+// the model loaded in memory, as reported by /api/ps, then to those that
+// have it pulled, as reported by /api/tags, to avoid cold loads. The least
+// busy host of the best group is picked; calls naming no model go to the
+// least busy host overall.
+type ModelAware struct {
+	// Refresh is how long the models of a host are remembered before the
+	// host is queried again. If zero, 5 seconds is used.
+	Refresh time.Duration
+
+	inventory inventory
+}
+
+func (b *ModelAware) Pick(ctx context.Context, model string, hosts []PoolHost) (int, error) {
+	if model == "" {
+		return leastInFlight(hosts, nil), nil
+	}
+	invs := make([]hostInventory, len(hosts))
+	for i, h := range hosts {
+		invs[i], _ = b.inventory.get(ctx, h.Client, b.Refresh, true)
+	}
+	model = withTag(model)
+	if i := leastInFlight(hosts, func(i int) bool { return invs[i].running[model] }); invs[i].running[model] {
+		return i, nil
+	}
+	return leastInFlight(hosts, func(i int) bool { return invs[i].pulled[model] }), nil
+}
+
+// inventory caches the models known to each host, their names tagged as
+// by withTag.
+type inventory struct {
+	mu    sync.Mutex
+	hosts map[*Client]hostInventory
+}
+
+type hostInventory struct {
+	checkedAt time.Time
+	ok        bool
+	load      int64
+	running   map[string]bool
+	pulled    map[string]bool
+}
+
+// get returns the models of a host, querying /api/ps, and /api/tags if
+// pulled is set, when the cached ones are older than refresh.
+func (inv *inventory) get(ctx context.Context, client *Client, refresh time.Duration, pulled bool) (hostInventory, bool) {
+	if refresh <= 0 {
+		refresh = 5 * time.Second
+	}
+	inv.mu.Lock()
+	h, ok := inv.hosts[client]
+	inv.mu.Unlock()
+	if ok && time.Since(h.checkedAt) < refresh && (!pulled || h.pulled != nil) {
+		return h, h.ok
+	}
+	h = hostInventory{checkedAt: time.Now(), running: make(map[string]bool)}
+	ps, err := client.ListRunningModels(ctx)
+	if err == nil {
+		h.ok = true
+		for _, m := range ps.Models {
+			h.load += m.SizeVRAM
+			h.running[withTag(m.Name)] = true
+			h.running[withTag(m.Model)] = true
+		}
+	}
+	if pulled {
+		h.pulled = make(map[string]bool)
+		if tags, err := client.ListModels(ctx); err == nil {
+			for _, m := range tags.Models {
+				h.pulled[withTag(m.Name)] = true
+			}
+		}
+	}
+	inv.mu.Lock()
+	if inv.hosts == nil {
+		inv.hosts = make(map[*Client]hostInventory)
+	}
+	inv.hosts[client] = h
+	inv.mu.Unlock()
+	return h, h.ok
+}
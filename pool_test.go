@@ -0,0 +1,199 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func newPool(t *testing.T, n int, models ...ollamatest.Model) (*ollamago.Pool, []*ollamatest.Server) {
+	t.Helper()
+	pool := &ollamago.Pool{}
+	var servers []*ollamatest.Server
+	for range n {
+		srv := ollamatest.NewServer(models...)
+		t.Cleanup(srv.Close)
+		servers = append(servers, srv)
+		pool.Hosts = append(pool.Hosts, srv.Client())
+	}
+	return pool, servers
+}
+
+func TestPoolRoundRobin(t *testing.T) {
+	pool, servers := newPool(t, 3, ollamatest.Model{Name: "test", Chunks: []string{"ok"}})
+	ctx := context.Background()
+	for range 6 {
+		respChan, err := pool.GenerateChat(ctx, ollamago.ChatRequest{Model: "test", Stream: true})
+		require.NoError(t, err)
+		for range respChan {
+		}
+	}
+	for _, srv := range servers {
+		require.Len(t, srv.Requests(), 2)
+	}
+	_, err := (&ollamago.Pool{}).Version(ctx)
+	require.ErrorIs(t, err, ollamago.ErrNoHosts)
+}
+
+func TestPoolHostsAddedLater(t *testing.T) {
+	pool, _ := newPool(t, 2, ollamatest.Model{Name: "test"})
+	ctx := context.Background()
+	_, err := pool.Version(ctx)
+	require.NoError(t, err)
+
+	late := ollamatest.NewServer(ollamatest.Model{Name: "test"})
+	t.Cleanup(late.Close)
+	pool.Hosts = append(pool.Hosts, late.Client())
+	for range 4 {
+		_, err := pool.Version(ctx)
+		require.NoError(t, err)
+	}
+	require.NoError(t, pool.CheckHealth(ctx))
+	require.Len(t, pool.Healthy(), 2)
+	require.Empty(t, late.Requests(), "hosts added after the first call are ignored")
+}
+
+func TestPoolLeastInFlight(t *testing.T) {
+	pool, servers := newPool(t, 2, ollamatest.Model{Name: "test", Chunks: []string{"a", "b"}})
+	pool.Balancer = ollamago.LeastInFlight{}
+	ctx := context.Background()
+
+	held, err := pool.GenerateChat(ctx, ollamago.ChatRequest{Model: "test", Stream: true})
+	require.NoError(t, err)
+	<-held
+	for range 3 {
+		_, err := pool.Version(ctx)
+		require.NoError(t, err)
+	}
+	require.Len(t, servers[0].Requests(), 1)
+	require.Len(t, servers[1].Requests(), 3)
+	for range held {
+	}
+
+	_, err = pool.Version(ctx)
+	require.NoError(t, err)
+	require.Len(t, servers[0].Requests(), 2, "the finished stream no longer counts")
+}
+
+func TestPoolLeastLoaded(t *testing.T) {
+	pool, servers := newPool(t, 2, ollamatest.Model{Name: "test", Size: 100})
+	pool.Balancer = &ollamago.LeastLoaded{}
+	ctx := context.Background()
+	_, err := pool.Hosts[0].GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: "test", Input: []string{"a"}})
+	require.NoError(t, err)
+
+	_, err = pool.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: "test", Input: []string{"b"}})
+	require.NoError(t, err)
+	require.Equal(t, "/api/embed", servers[1].Requests()[1].Path)
+}
+
+func TestPoolFailover(t *testing.T) {
+	pool, servers := newPool(t, 3, ollamatest.Model{Name: "test", Chunks: []string{"ok"}})
+	pool.Balancer = ollamago.LeastInFlight{}
+	ctx := context.Background()
+
+	servers[0].FailNext("/api/chat", 1, http.StatusServiceUnavailable, "overloaded")
+	respChan, err := pool.GenerateChat(ctx, ollamago.ChatRequest{Model: "test", Stream: true})
+	require.NoError(t, err)
+	for range respChan {
+	}
+	require.Len(t, servers[0].Requests(), 1)
+	require.Len(t, servers[1].Requests(), 1)
+	require.Equal(t, []bool{false, true, true}, pool.Healthy())
+
+	servers[1].Close()
+	_, err = pool.Version(ctx)
+	require.NoError(t, err)
+	require.Len(t, servers[2].Requests(), 1)
+	require.Equal(t, []bool{false, false, true}, pool.Healthy())
+
+	_, err = pool.GenerateChat(ctx, ollamago.ChatRequest{Model: "missing"})
+	var statusErr *ollamago.StatusError
+	require.ErrorAs(t, err, &statusErr)
+	require.Equal(t, http.StatusNotFound, statusErr.StatusCode)
+	require.Len(t, servers[2].Requests(), 2, "client errors are not retried")
+	require.Equal(t, []bool{false, false, true}, pool.Healthy())
+
+	err = pool.CheckHealth(ctx)
+	require.Error(t, err)
+	require.Equal(t, []bool{true, false, true}, pool.Healthy())
+}
+
+func TestPoolModelAware(t *testing.T) {
+	a, b := ollamatest.Model{Name: "a:latest"}, ollamatest.Model{Name: "b:latest"}
+	servers := []*ollamatest.Server{
+		ollamatest.NewServer(a),
+		ollamatest.NewServer(a, b),
+		ollamatest.NewServer(b),
+	}
+	pool := &ollamago.Pool{Balancer: &ollamago.ModelAware{}}
+	for _, srv := range servers {
+		t.Cleanup(srv.Close)
+		pool.Hosts = append(pool.Hosts, srv.Client())
+	}
+	ctx := context.Background()
+	embed := func(model string) {
+		t.Helper()
+		_, err := pool.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: model, Input: []string{"x"}})
+		require.NoError(t, err)
+	}
+	embedded := func(srv *ollamatest.Server) int {
+		var n int
+		for _, r := range srv.Requests() {
+			if r.Path == "/api/embed" {
+				n++
+			}
+		}
+		return n
+	}
+	_, err := pool.Hosts[2].GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: "b", Input: []string{"warm up"}})
+	require.NoError(t, err)
+
+	embed("b")
+	require.Equal(t, 2, embedded(servers[2]), "b is loaded on the third host")
+	embed("a")
+	require.Equal(t, 1, embedded(servers[0]), "a is pulled on the first two hosts")
+	require.Zero(t, embedded(servers[1]))
+}
+
+func TestPoolHedging(t *testing.T) {
+	pool, servers := newPool(t, 2, ollamatest.Model{Name: "test"})
+	pool.HedgeDelay = 20 * time.Millisecond
+	ctx := context.Background()
+
+	servers[0].SetLatency(time.Second)
+	start := time.Now()
+	resp, err := pool.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: "test", Input: []string{"a"}})
+	require.NoError(t, err)
+	require.Len(t, resp.Embeddings, 1)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+	require.Len(t, servers[1].Requests(), 1)
+
+	servers[0].SetLatency(0)
+	pool.HedgeDelay = time.Second
+	_, err = pool.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: "test", Input: []string{"a"}})
+	require.NoError(t, err)
+	_, err = pool.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: "test", Input: []string{"a"}})
+	require.NoError(t, err)
+	require.Len(t, servers[0].Requests(), 2)
+	require.Len(t, servers[1].Requests(), 2, "fast calls are not hedged")
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// ProgressEvent is a status update of a long operation: a pull, a push, a
+// model creation or a blob upload. Total and Completed are the byte counts
+// of the layer or blob identified by Digest, if any.
+type ProgressEvent struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     error  `json:"-"`
+}
+
+// Percent returns the completion of the event, from 0 to 100, or 0 if its
+// total is unknown.
+func (e ProgressEvent) Percent() float64 {
+	if e.Total <= 0 {
+		return 0
+	}
+	return min(100*float64(e.Completed)/float64(e.Total), 100)
+}
+
+// ProgressWriter renders progress events to W as simple progress bars, one
+// line per status, for command line tools. Its Update method can be given
+// to WithPullProgress.
+type ProgressWriter struct {
+	W io.Writer
+
+	// Width is the width of the bars. If zero, 30 is used.
+	Width int
+
+	mu     sync.Mutex
+	status string
+	open   bool // whether the last line is a bar still being redrawn
+}
+
+// Update renders an event.
+func (p *ProgressWriter) Update(e ProgressEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e.Error != nil {
+		p.endLine()
+		fmt.Fprintf(p.W, "error: %v\n", e.Error)
+		return
+	}
+	if e.Status != p.status {
+		p.endLine()
+		p.status = e.Status
+	} else if !p.open {
+		return
+	}
+	if e.Total <= 0 {
+		fmt.Fprintln(p.W, e.Status)
+		return
+	}
+	width := p.Width
+	if width <= 0 {
+		width = 30
+	}
+	filled := int(e.Percent() * float64(width) / 100)
+	fmt.Fprintf(p.W, "\r%s [%s%s] %3.0f%% %s/%s", e.Status,
+		strings.Repeat("=", filled), strings.Repeat(" ", width-filled),
+		e.Percent(), formatBytes(e.Completed), formatBytes(e.Total))
+	p.open = true
+}
+
+// Track renders the events of an operation until it finishes, returning
+// its error, if any. The channel is drained on error, so that its producer
+// is not left blocked.
+func (p *ProgressWriter) Track(progress <-chan ProgressEvent) error {
+	var last ProgressEvent
+	for e := range progress {
+		p.Update(e)
+		last = e
+		if e.Error != nil {
+			for range progress {
+			}
+			return e.Error
+		}
+	}
+	p.mu.Lock()
+	p.endLine()
+	p.mu.Unlock()
+	if last.Status != "success" {
+		return fmt.Errorf("operation ended with status %q", last.Status)
+	}
+	return nil
+}
+
+func (p *ProgressWriter) endLine() {
+	if p.open {
+		fmt.Fprintln(p.W)
+		p.open = false
+	}
+}
+
+// formatBytes formats a byte count with decimal units, as the ollama CLI
+// does.
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
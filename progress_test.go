@@ -0,0 +1,82 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamagotest"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProgressEvent(t *testing.T) {
+	require.Zero(t, ollamago.ProgressEvent{Status: "writing manifest"}.Percent())
+	require.Equal(t, 25.0, ollamago.ProgressEvent{Total: 400, Completed: 100}.Percent())
+}
+
+func TestProgressWriter(t *testing.T) {
+	var buf bytes.Buffer
+	pw := &ollamago.ProgressWriter{W: &buf, Width: 10}
+	err := pw.Track(ollamagotest.Stream(
+		ollamago.ProgressEvent{Status: "pulling manifest"},
+		ollamago.ProgressEvent{Status: "pulling manifest"},
+		ollamago.ProgressEvent{Status: "pulling abc", Digest: "abc", Total: 2_000_000_000, Completed: 500_000_000},
+		ollamago.ProgressEvent{Status: "pulling abc", Digest: "abc", Total: 2_000_000_000, Completed: 2_000_000_000},
+		ollamago.ProgressEvent{Status: "success"},
+	))
+	require.NoError(t, err)
+	require.Equal(t, "pulling manifest\n"+
+		"\rpulling abc [==        ]  25% 500.0 MB/2.0 GB"+
+		"\rpulling abc [==========] 100% 2.0 GB/2.0 GB\n"+
+		"success\n", buf.String())
+
+	buf.Reset()
+	err = pw.Track(ollamagotest.Stream(
+		ollamago.ProgressEvent{Status: "pulling abc", Total: 10, Completed: 1},
+		ollamago.ProgressEvent{Error: errors.New("disk full")},
+	))
+	require.ErrorContains(t, err, "disk full")
+	require.Equal(t, "\rpulling abc [=         ]  10% 1 B/10 B\nerror: disk full\n", buf.String())
+}
+
+func TestProgressWriterPull(t *testing.T) {
+	srv := ollamatest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.AddRegistryModel(ollamatest.Model{Name: "remote", Size: 1500})
+	var buf bytes.Buffer
+	pw := &ollamago.ProgressWriter{W: &buf}
+	require.NoError(t, ollamago.EnsureModel(context.Background(), srv.Client(), "remote", ollamago.WithPullProgress(pw.Update)))
+	require.Contains(t, buf.String(), "100% 1.5 kB/1.5 kB")
+}
+
+func TestProgressWriterDrainsOnError(t *testing.T) {
+	progress := make(chan ollamago.ProgressEvent)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer close(progress)
+		progress <- ollamago.ProgressEvent{Error: errors.New("disk full")}
+		progress <- ollamago.ProgressEvent{Status: "cleaning up"}
+	}()
+	var buf bytes.Buffer
+	require.EqualError(t, (&ollamago.ProgressWriter{W: &buf}).Track(progress), "disk full")
+	<-done
+	require.Equal(t, "error: disk full\n", buf.String(), "the events after the error are not rendered")
+}
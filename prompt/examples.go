@@ -0,0 +1,99 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prompt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"cirello.io/ollamago"
+)
+
+// ExampleStore holds few-shot examples and selects those whose input is
+// the most similar to the current one, by embedding similarity. It is
+// safe for concurrent use.
+type ExampleStore struct {
+	// Client computes the embeddings.
+	Client ollamago.API
+
+	// Model is the embedding model.
+	Model string
+
+	mu       sync.RWMutex
+	examples []Example
+	vectors  [][]float64
+}
+
+// Add embeds the inputs of the examples and adds them to the store.
+func (s *ExampleStore) Add(ctx context.Context, examples ...Example) error {
+	if s.Client == nil {
+		return errors.New("example store has no client")
+	}
+	inputs := make([]string, len(examples))
+	for i, e := range examples {
+		inputs[i] = e.Input
+	}
+	vectors, err := ollamago.EmbedBatch(ctx, s.Client, s.Model, inputs)
+	if err != nil {
+		return fmt.Errorf("cannot embed examples: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.examples = append(s.examples, examples...)
+	s.vectors = append(s.vectors, vectors...)
+	return nil
+}
+
+// Len returns the number of examples in the store.
+func (s *ExampleStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.examples)
+}
+
+// Select returns the k examples most similar to input, the most similar
+// last so that it sits closest to the input in the prompt.
+func (s *ExampleStore) Select(ctx context.Context, input string, k int) ([]Example, error) {
+	if k <= 0 || s.Len() == 0 {
+		return nil, nil
+	}
+	resp, err := s.Client.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: s.Model, Input: []string{input}})
+	if err != nil {
+		return nil, fmt.Errorf("cannot embed input: %w", err)
+	}
+	if len(resp.Embeddings) != 1 {
+		return nil, fmt.Errorf("got %d embeddings for 1 input", len(resp.Embeddings))
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	matches := ollamago.TopK(resp.Embeddings[0], s.vectors, k)
+	selected := make([]Example, len(matches))
+	for i, m := range matches {
+		selected[len(matches)-1-i] = s.examples[m.Index]
+	}
+	return selected, nil
+}
+
+// RenderSelected is like Render, with the k examples of store most similar
+// to input.
+func (t *Template[T]) RenderSelected(ctx context.Context, store *ExampleStore, k int, input string, data T) ([]ollamago.ChatMessage, error) {
+	examples, err := store.Select(ctx, input, k)
+	if err != nil {
+		return nil, err
+	}
+	return t.Render(data, examples...)
+}
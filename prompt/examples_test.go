@@ -0,0 +1,76 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prompt_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamagotest"
+	"cirello.io/ollamago/prompt"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExampleStore(t *testing.T) {
+	// The fake embedding counts the occurrences of a few keywords.
+	keywords := []string{"refund", "shipping", "password"}
+	mock := &ollamagotest.MockClient{
+		GenerateEmbeddingsFunc: func(ctx context.Context, req ollamago.EmbedRequest) (*ollamago.EmbedResponse, error) {
+			resp := &ollamago.EmbedResponse{Model: req.Model}
+			for _, in := range req.Input {
+				v := make([]float64, len(keywords)+1)
+				v[len(keywords)] = 0.1
+				for i, k := range keywords {
+					v[i] = float64(strings.Count(in, k))
+				}
+				resp.Embeddings = append(resp.Embeddings, v)
+			}
+			return resp, nil
+		},
+	}
+	store := &prompt.ExampleStore{Client: mock, Model: "embed"}
+	ctx := context.Background()
+	require.NoError(t, store.Add(ctx,
+		prompt.Example{Input: "I want a refund", Output: "billing"},
+		prompt.Example{Input: "where is my shipping", Output: "delivery"},
+		prompt.Example{Input: "reset my password", Output: "account"},
+		prompt.Example{Input: "refund the shipping", Output: "billing"},
+	))
+	require.Equal(t, 4, store.Len())
+
+	selected, err := store.Select(ctx, "refund please, a refund", 2)
+	require.NoError(t, err)
+	require.Equal(t, []prompt.Example{
+		{Input: "refund the shipping", Output: "billing"},
+		{Input: "I want a refund", Output: "billing"},
+	}, selected)
+
+	classify := prompt.Must(prompt.New[string](nil, "classify", prompt.System("Classify the ticket."), prompt.User("{{.}}")))
+	messages, err := classify.RenderSelected(ctx, store, 1, "forgot my password", "forgot my password")
+	require.NoError(t, err)
+	require.Equal(t, []ollamago.ChatMessage{
+		ollamago.SystemMessage("Classify the ticket."),
+		ollamago.UserMessage("reset my password"),
+		ollamago.AssistantMessage("account"),
+		ollamago.UserMessage("forgot my password"),
+	}, messages)
+
+	empty := &prompt.ExampleStore{Client: mock, Model: "embed"}
+	selected, err = empty.Select(ctx, "anything", 3)
+	require.NoError(t, err)
+	require.Empty(t, selected)
+}
@@ -0,0 +1,211 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package prompt renders chat prompts from text/template templates, so
+// that prompts live as named assets, with their own tests, instead of
+// fmt.Sprintf calls:
+//
+//	var ask = prompt.Must(prompt.New[Question](nil, "ask",
+//		prompt.System("You answer questions about {{.Topic}}."),
+//		prompt.Examples(),
+//		prompt.User("{{.Text}}"),
+//	))
+//
+//	req, err := ask.Request("llama3.2", Question{Topic: "Go", Text: "What is a goroutine?"})
+//
+// Templates may call the partials of a Set with {{template "name" .}}.
+package prompt
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"text/template"
+
+	"cirello.io/ollamago"
+)
+
+// Message is the template of a chat message.
+type Message struct {
+	Role string
+	Text string
+}
+
+// examplesRole marks the slot of the few-shot examples.
+const examplesRole = "\x00examples"
+
+// System returns the template of a system message.
+func System(text string) Message {
+	return Message{Role: ollamago.RoleSystem, Text: text}
+}
+
+// User returns the template of a user message.
+func User(text string) Message {
+	return Message{Role: ollamago.RoleUser, Text: text}
+}
+
+// Assistant returns the template of an assistant message.
+func Assistant(text string) Message {
+	return Message{Role: ollamago.RoleAssistant, Text: text}
+}
+
+// Examples returns the slot where the few-shot examples passed to
+// Template.Render are inserted. Without a slot, they are inserted before
+// the last message.
+func Examples() Message {
+	return Message{Role: examplesRole}
+}
+
+// Example is a few-shot example, rendered as a user message holding Input
+// followed by an assistant message holding Output.
+type Example struct {
+	Input  string `json:"input"`
+	Output string `json:"output"`
+}
+
+// Set holds the partials shared by templates.
+type Set struct {
+	tmpl *template.Template
+}
+
+// NewSet returns an empty set.
+func NewSet() *Set {
+	return &Set{tmpl: template.New("").Option("missingkey=error")}
+}
+
+// ParseFS returns a set with a partial for each file of fsys matching the
+// patterns, named after the file without its directory and extension.
+func ParseFS(fsys fs.FS, patterns ...string) (*Set, error) {
+	s := NewSet()
+	for _, pattern := range patterns {
+		names, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			b, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return nil, fmt.Errorf("cannot read partial: %w", err)
+			}
+			base := path.Base(name)
+			if err := s.Partial(strings.TrimSuffix(base, path.Ext(base)), string(b)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// Partial adds a partial, which templates call with {{template "name" .}}.
+func (s *Set) Partial(name, text string) error {
+	if s.tmpl.Lookup(name) != nil {
+		return fmt.Errorf("partial %q already exists", name)
+	}
+	if _, err := s.tmpl.New(name).Parse(text); err != nil {
+		return fmt.Errorf("cannot parse partial %q: %w", name, err)
+	}
+	return nil
+}
+
+// Template renders chat messages from a value of type T.
+type Template[T any] struct {
+	name     string
+	messages []Message
+	tmpl     *template.Template
+}
+
+// New parses the templates of a prompt named name. set holds the partials
+// the templates may call, and may be nil.
+func New[T any](set *Set, name string, messages ...Message) (*Template[T], error) {
+	if len(messages) == 0 {
+		return nil, errors.New("prompt has no messages")
+	}
+	if set == nil {
+		set = NewSet()
+	}
+	tmpl, err := set.tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	t := &Template[T]{name: name, messages: messages, tmpl: tmpl}
+	for i, m := range messages {
+		if m.Role == examplesRole {
+			continue
+		}
+		if _, err := tmpl.New(t.messageName(i)).Parse(m.Text); err != nil {
+			return nil, fmt.Errorf("cannot parse prompt %s: %w", name, err)
+		}
+	}
+	return t, nil
+}
+
+// Must panics if err is not nil, for templates initializing package
+// variables.
+func Must[T any](t *Template[T], err error) *Template[T] {
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func (t *Template[T]) messageName(i int) string {
+	return fmt.Sprintf("%s#%d", t.name, i)
+}
+
+// Name returns the name of the prompt.
+func (t *Template[T]) Name() string {
+	return t.name
+}
+
+// Render renders the messages of the prompt with data, inserting the
+// examples in their slot.
+func (t *Template[T]) Render(data T, examples ...Example) ([]ollamago.ChatMessage, error) {
+	slot := -1
+	for i, m := range t.messages {
+		if m.Role == examplesRole {
+			slot = i
+		}
+	}
+	if slot == -1 {
+		slot = len(t.messages) - 1
+	}
+	var messages []ollamago.ChatMessage
+	for i, m := range t.messages {
+		if i == slot {
+			for _, e := range examples {
+				messages = append(messages, ollamago.UserMessage(e.Input), ollamago.AssistantMessage(e.Output))
+			}
+		}
+		if m.Role == examplesRole {
+			continue
+		}
+		var b strings.Builder
+		if err := t.tmpl.ExecuteTemplate(&b, t.messageName(i), data); err != nil {
+			return nil, fmt.Errorf("cannot render prompt %s: %w", t.name, err)
+		}
+		messages = append(messages, ollamago.ChatMessage{Role: m.Role, Content: b.String()})
+	}
+	return messages, nil
+}
+
+// Request renders the prompt into a streaming chat request for model.
+func (t *Template[T]) Request(model string, data T, examples ...Example) (ollamago.ChatRequest, error) {
+	messages, err := t.Render(data, examples...)
+	if err != nil {
+		return ollamago.ChatRequest{}, err
+	}
+	return ollamago.ChatRequest{Model: model, Messages: messages, Stream: true}, nil
+}
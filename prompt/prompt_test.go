@@ -0,0 +1,77 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prompt_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/prompt"
+	"github.com/stretchr/testify/require"
+)
+
+type question struct {
+	Topic string
+	Text  string
+}
+
+func TestTemplate(t *testing.T) {
+	set, err := prompt.ParseFS(fstest.MapFS{
+		"partials/persona.tmpl": {Data: []byte("You are an expert in {{.Topic}}.")},
+		"partials/README":       {Data: []byte("ignored")},
+	}, "partials/*.tmpl")
+	require.NoError(t, err)
+	require.Error(t, set.Partial("persona", "again"))
+
+	ask, err := prompt.New[question](set, "ask",
+		prompt.System(`{{template "persona" .}} Be brief.`),
+		prompt.Examples(),
+		prompt.User("{{.Text}}"),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "ask", ask.Name())
+
+	req, err := ask.Request("llama3.2", question{Topic: "Go", Text: "What is a goroutine?"},
+		prompt.Example{Input: "What is a channel?", Output: "A typed conduit."})
+	require.NoError(t, err)
+	require.Equal(t, ollamago.ChatRequest{
+		Model: "llama3.2",
+		Messages: []ollamago.ChatMessage{
+			ollamago.SystemMessage("You are an expert in Go. Be brief."),
+			ollamago.UserMessage("What is a channel?"),
+			ollamago.AssistantMessage("A typed conduit."),
+			ollamago.UserMessage("What is a goroutine?"),
+		},
+		Stream: true,
+	}, req)
+
+	noSlot := prompt.Must(prompt.New[map[string]string](nil, "echo", prompt.System("Echo."), prompt.User("{{.text}}")))
+	messages, err := noSlot.Render(map[string]string{"text": "hi"}, prompt.Example{Input: "a", Output: "a"})
+	require.NoError(t, err)
+	require.Equal(t, []ollamago.ChatMessage{
+		ollamago.SystemMessage("Echo."),
+		ollamago.UserMessage("a"),
+		ollamago.AssistantMessage("a"),
+		ollamago.UserMessage("hi"),
+	}, messages)
+
+	_, err = noSlot.Render(map[string]string{})
+	require.ErrorContains(t, err, "cannot render prompt echo")
+
+	_, err = prompt.New[question](nil, "broken", prompt.User("{{.Text"))
+	require.Error(t, err)
+	require.Panics(t, func() { prompt.Must(prompt.New[question](nil, "empty")) })
+}
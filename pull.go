@@ -0,0 +1,215 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// PullOption configures EnsureModel and PullModels.
+type PullOption func(*pullConfig)
+
+type pullConfig struct {
+	progress    func(ProgressEvent)
+	aggregate   func(completed, total int64)
+	insecure    bool
+	retries     int
+	backoff     time.Duration
+	concurrency int
+}
+
+// WithPullProgress registers a callback invoked with every progress update
+// of the pulls. Calls are serialized.
+func WithPullProgress(progress func(ProgressEvent)) PullOption {
+	return func(c *pullConfig) {
+		c.progress = progress
+	}
+}
+
+// WithAggregateProgress registers a callback invoked after every progress
+// update with the bytes downloaded so far and the total bytes of the
+// layers known so far, across all the pulled models. Calls are serialized.
+func WithAggregateProgress(progress func(completed, total int64)) PullOption {
+	return func(c *pullConfig) {
+		c.aggregate = progress
+	}
+}
+
+// WithInsecurePull allows pulling from a registry over plain HTTP or with
+// an unverified TLS certificate.
+func WithInsecurePull() PullOption {
+	return func(c *pullConfig) {
+		c.insecure = true
+	}
+}
+
+// WithPullRetries sets how many times an interrupted pull is resumed,
+// waiting backoff before the first attempt and doubling the wait after
+// each one. The server keeps the layers already downloaded, so a resumed
+// pull only fetches what is missing. The default is 3 retries with a one
+// second backoff.
+func WithPullRetries(n int, backoff time.Duration) PullOption {
+	return func(c *pullConfig) {
+		c.retries = n
+		c.backoff = backoff
+	}
+}
+
+// WithPullConcurrency sets how many models PullModels pulls at once. The
+// default is 3.
+func WithPullConcurrency(n int) PullOption {
+	return func(c *pullConfig) {
+		c.concurrency = n
+	}
+}
+
+func newPullConfig(opts []PullOption) *pullConfig {
+	cfg := &pullConfig{
+		retries:     3,
+		backoff:     time.Second,
+		concurrency: 3,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// EnsureModel makes sure the named model is available on the server,
+// pulling it if /api/tags does not list it. It returns once the model is
+// ready to be used.
+func EnsureModel(ctx context.Context, client API, name string, opts ...PullOption) error {
+	models, err := client.ListModels(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot list models: %w", err)
+	}
+	for _, m := range models.Models {
+		if sameModel(m.Name, name) {
+			return nil
+		}
+	}
+	return newPullTracker(newPullConfig(opts)).pull(ctx, client, name)
+}
+
+// PullModels pulls the named models concurrently, resuming interrupted
+// pulls. Failing models do not stop the others; their errors are joined in
+// the returned error.
+func PullModels(ctx context.Context, client API, names []string, opts ...PullOption) error {
+	cfg := newPullConfig(opts)
+	t := newPullTracker(cfg)
+	sem := make(chan struct{}, max(cfg.concurrency, 1))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = fmt.Errorf("cannot pull %s: %w", name, ctx.Err())
+				return
+			}
+			defer func() { <-sem }()
+			errs[i] = t.pull(ctx, client, name)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// pullTracker pulls models, aggregating their progress.
+type pullTracker struct {
+	cfg *pullConfig
+
+	mu     sync.Mutex
+	layers map[string]ProgressEvent // by model and digest
+}
+
+func newPullTracker(cfg *pullConfig) *pullTracker {
+	return &pullTracker{cfg: cfg, layers: make(map[string]ProgressEvent)}
+}
+
+// pull pulls a model, resuming the pull when it is interrupted.
+func (t *pullTracker) pull(ctx context.Context, client API, name string) error {
+	backoff := t.cfg.backoff
+	for attempt := 0; ; attempt++ {
+		progress, err := client.PullModel(ctx, PullModelRequest{Model: name, Insecure: t.cfg.insecure})
+		if err == nil {
+			err = waitProgress(ctx, progress, func(p ProgressEvent) { t.update(name, p) })
+		}
+		var serverErr *progressError
+		if err == nil || attempt >= t.cfg.retries || errors.As(err, &serverErr) || !retryable(ctx, err) {
+			if err != nil {
+				return fmt.Errorf("cannot pull %s: %w", name, err)
+			}
+			return nil
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("cannot pull %s: %w", name, errors.Join(ctx.Err(), err))
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+func (t *pullTracker) update(name string, p ProgressEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.cfg.progress != nil {
+		t.cfg.progress(p)
+	}
+	if t.cfg.aggregate == nil || p.Digest == "" {
+		return
+	}
+	t.layers[name+"@"+p.Digest] = p
+	var completed, total int64
+	for _, layer := range t.layers {
+		completed += layer.Completed
+		total += layer.Total
+	}
+	t.cfg.aggregate(completed, total)
+}
+
+// waitProgress consumes the progress of a long operation, passing every
+// update to fn if not nil, and reports whether the operation succeeded.
+func waitProgress(ctx context.Context, progress <-chan ProgressEvent, fn func(ProgressEvent)) error {
+	var status string
+	for p := range progress {
+		if p.Error != nil {
+			for range progress {
+			}
+			return p.Error
+		}
+		if fn != nil {
+			fn(p)
+		}
+		status = p.Status
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if status != "success" {
+		return fmt.Errorf("operation ended with status %q", status)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsureModel(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "present:latest"})
+	t.Cleanup(srv.Close)
+	srv.AddRegistryModel(ollamatest.Model{Name: "remote", Size: 100})
+	client := srv.Client()
+	ctx := context.Background()
+
+	require.NoError(t, ollamago.EnsureModel(ctx, client, "present"))
+	require.Len(t, srv.Requests(), 1, "models already pulled are not pulled again")
+
+	var progress []ollamago.ProgressEvent
+	err := ollamago.EnsureModel(ctx, client, "remote", ollamago.WithPullProgress(func(p ollamago.ProgressEvent) {
+		progress = append(progress, p)
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "pulling manifest", progress[0].Status)
+	require.Equal(t, "success", progress[len(progress)-1].Status)
+	var completed int64
+	for _, p := range progress {
+		completed = max(completed, p.Completed)
+	}
+	require.EqualValues(t, 100, completed)
+	_, err = client.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: "remote", Input: []string{"a"}})
+	require.NoError(t, err, "the pulled model is ready")
+
+	err = ollamago.EnsureModel(ctx, client, "missing")
+	require.ErrorContains(t, err, "file does not exist")
+}
+
+func TestPullModels(t *testing.T) {
+	srv := ollamatest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.AddRegistryModel(ollamatest.Model{Name: "a", Size: 100})
+	srv.AddRegistryModel(ollamatest.Model{Name: "b", Size: 300})
+	srv.FailNext("/api/pull", 1, http.StatusServiceUnavailable, "busy")
+	client := srv.Client()
+
+	var completed, total int64
+	err := ollamago.PullModels(context.Background(), client, []string{"a", "b", "missing"},
+		ollamago.WithPullRetries(3, time.Millisecond),
+		ollamago.WithAggregateProgress(func(c, t int64) {
+			completed, total = c, t
+		}))
+	require.ErrorContains(t, err, "cannot pull missing")
+	require.NotContains(t, err.Error(), "cannot pull a")
+	require.EqualValues(t, 400, completed)
+	require.EqualValues(t, 400, total)
+	require.Len(t, srv.Requests(), 4, "the failed pull is retried, the missing model is not")
+}
+
+func TestPullResume(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.Write([]byte(`{"status":"pulling abc","digest":"abc","total":10,"completed":4}` + "\n"))
+			return
+		}
+		w.Write([]byte(`{"status":"pulling abc","digest":"abc","total":10,"completed":10}` + "\n"))
+		w.Write([]byte(`{"status":"success"}` + "\n"))
+	}))
+	t.Cleanup(server.Close)
+	client := &ollamago.Client{BaseURL: server.URL}
+
+	var statuses []string
+	err := ollamago.PullModels(context.Background(), client, []string{"test"},
+		ollamago.WithPullRetries(1, time.Millisecond),
+		ollamago.WithPullProgress(func(p ollamago.ProgressEvent) {
+			statuses = append(statuses, p.Status+" "+strings.Repeat("#", int(p.Completed)))
+		}))
+	require.NoError(t, err)
+	require.EqualValues(t, 2, attempts.Load())
+	require.Equal(t, []string{"pulling abc ####", "pulling abc ##########", "success "}, statuses)
+}
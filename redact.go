@@ -0,0 +1,406 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Detector finds sensitive data, such as e-mail addresses, in a text.
+type Detector struct {
+	// Name labels what is detected, such as "EMAIL". It is the default
+	// replacement of the matches, in brackets.
+	Name string
+
+	// Find returns the byte ranges of the matches, as
+	// regexp.Regexp.FindAllStringIndex does.
+	Find func(text string) [][]int
+}
+
+// RegexpDetector returns a detector of the matches of the regular
+// expression. It panics if the expression does not compile.
+func RegexpDetector(name, expr string) Detector {
+	re := regexp.MustCompile(expr)
+	return Detector{Name: name, Find: func(text string) [][]int {
+		return re.FindAllStringIndex(text, -1)
+	}}
+}
+
+// DenyList returns a detector of the given words and phrases, such as
+// project code names, ignoring case.
+func DenyList(name string, terms ...string) Detector {
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+	return RegexpDetector(name, `(?i)\b(?:`+strings.Join(quoted, "|")+`)\b`)
+}
+
+// Built-in detectors of common personal data.
+var (
+	EmailDetector = RegexpDetector("EMAIL", `[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)
+	PhoneDetector = RegexpDetector("PHONE", `(?:\+\d{1,3}[ .-]?)?(?:\(\d{3}\)|\b\d{3})[ .-]?\d{3}[ .-]?\d{4}\b`)
+	SSNDetector   = RegexpDetector("SSN", `\b\d{3}-\d{2}-\d{4}\b`)
+	IPv4Detector  = RegexpDetector("IP", `\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`)
+
+	// CreditCardDetector matches card numbers passing the Luhn check.
+	CreditCardDetector = Detector{Name: "CARD", Find: func(text string) [][]int {
+		var found [][]int
+		for _, loc := range cardNumber.FindAllStringIndex(text, -1) {
+			if luhn(text[loc[0]:loc[1]]) {
+				found = append(found, loc)
+			}
+		}
+		return found
+	}}
+)
+
+var cardNumber = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+
+func luhn(number string) bool {
+	sum, n := 0, 0
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return sum%10 == 0
+}
+
+// DefaultDetectors returns the built-in detectors.
+func DefaultDetectors() []Detector {
+	return []Detector{EmailDetector, CreditCardDetector, SSNDetector, PhoneDetector, IPv4Detector}
+}
+
+// RedactScope selects what a Redactor scrubs.
+type RedactScope int
+
+// Redaction scopes, which can be combined.
+const (
+	// RedactPrompts scrubs the prompts, system prompts and messages sent
+	// to the server.
+	RedactPrompts RedactScope = 1 << iota
+
+	// RedactResponses scrubs the text generated by the server before it
+	// reaches the application.
+	RedactResponses
+)
+
+// defaultHoldback is the number of bytes of streamed text held back until
+// more text shows whether they are part of sensitive data.
+const defaultHoldback = 64
+
+// Redactor replaces sensitive data in the text exchanged with the server.
+// It is plugged into a Client as an Interceptor:
+//
+//	redactor := &ollamago.Redactor{Scope: ollamago.RedactPrompts | ollamago.RedactResponses}
+//	client := &ollamago.Client{Interceptors: []ollamago.Interceptor{redactor.Intercept}}
+//
+// Streamed text is held back by Holdback bytes, so that data split across
+// chunks is still detected; longer data may go undetected when split.
+type Redactor struct {
+	// Detectors find the data to redact. If nil, DefaultDetectors is
+	// used.
+	Detectors []Detector
+
+	// Replace returns the replacement of a match. If nil, matches are
+	// replaced with the detector name in brackets, such as "[EMAIL]".
+	Replace func(detector, match string) string
+
+	// Scope selects what is redacted. If zero, RedactPrompts is used.
+	Scope RedactScope
+
+	// Holdback is the number of bytes of streamed text held back. If
+	// zero, 64 is used.
+	Holdback int
+
+	// AllowUnredactable forwards the request bodies that cannot be
+	// redacted, such as those compressed by an earlier interceptor,
+	// unchanged. If false, such requests fail with ErrUnredactable and
+	// their bodies are dropped from audit records.
+	AllowUnredactable bool
+}
+
+// ErrUnredactable is returned for the requests whose bodies a Redactor
+// cannot parse, and hence cannot redact.
+var ErrUnredactable = errors.New("cannot redact request body")
+
+type redaction struct {
+	start, end int
+	detector   string
+}
+
+func (r *Redactor) find(text string) []redaction {
+	detectors := r.Detectors
+	if detectors == nil {
+		detectors = DefaultDetectors()
+	}
+	var found []redaction
+	for _, d := range detectors {
+		for _, loc := range d.Find(text) {
+			found = append(found, redaction{loc[0], loc[1], d.Name})
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].start != found[j].start {
+			return found[i].start < found[j].start
+		}
+		return found[i].end > found[j].end
+	})
+	// Drop the matches overlapping an earlier one.
+	kept := found[:0]
+	end := 0
+	for _, f := range found {
+		if f.start < end {
+			continue
+		}
+		kept = append(kept, f)
+		end = f.end
+	}
+	return kept
+}
+
+func (r *Redactor) replace(text string, found []redaction) string {
+	var sb strings.Builder
+	last := 0
+	for _, f := range found {
+		sb.WriteString(text[last:f.start])
+		if r.Replace != nil {
+			sb.WriteString(r.Replace(f.detector, text[f.start:f.end]))
+		} else {
+			sb.WriteString("[" + f.detector + "]")
+		}
+		last = f.end
+	}
+	sb.WriteString(text[last:])
+	return sb.String()
+}
+
+// Redact returns text with the sensitive data replaced.
+func (r *Redactor) Redact(text string) string {
+	return r.replace(text, r.find(text))
+}
+
+func (r *Redactor) scope() RedactScope {
+	if r.Scope == 0 {
+		return RedactPrompts
+	}
+	return r.Scope
+}
+
+// Intercept is the Interceptor redacting the calls.
+func (r *Redactor) Intercept(next http.RoundTripper) http.RoundTripper {
+	return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if r.scope()&RedactPrompts != 0 && req.Body != nil {
+			body, err := io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			if body, err = r.redactRequest(body); err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			req.ContentLength = int64(len(body))
+		}
+		resp, err := next.RoundTrip(req)
+		if err != nil || r.scope()&RedactResponses == 0 || resp.StatusCode != http.StatusOK {
+			return resp, err
+		}
+		resp.Body = &redactedBody{ReadCloser: resp.Body, r: bufio.NewReader(resp.Body), redactor: r}
+		resp.ContentLength = -1
+		resp.Header.Del("Content-Length")
+		return resp, nil
+	})
+}
+
+// redactRequest scrubs the prompt, the system prompt and the message
+// contents of a request body, keeping the other fields verbatim. Empty
+// bodies are left as they are; bodies that cannot be parsed are too, if
+// AllowUnredactable is set.
+func (r *Redactor) redactRequest(body []byte) ([]byte, error) {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return body, nil
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return r.unredactable(body, err)
+	}
+	r.redactField(fields, "prompt")
+	r.redactField(fields, "system")
+	if raw, ok := fields["messages"]; ok {
+		var messages []map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &messages); err != nil {
+			return r.unredactable(body, err)
+		}
+		for _, m := range messages {
+			r.redactField(m, "content")
+		}
+		fields["messages"], _ = json.Marshal(messages)
+	}
+	redacted, err := json.Marshal(fields)
+	if err != nil {
+		return r.unredactable(body, err)
+	}
+	return redacted, nil
+}
+
+func (r *Redactor) unredactable(body []byte, err error) ([]byte, error) {
+	if r.AllowUnredactable {
+		return body, nil
+	}
+	return nil, fmt.Errorf("%w: %w", ErrUnredactable, err)
+}
+
+func (r *Redactor) redactField(fields map[string]json.RawMessage, key string) {
+	var s string
+	if json.Unmarshal(fields[key], &s) != nil || s == "" {
+		return
+	}
+	fields[key], _ = json.Marshal(r.Redact(s))
+}
+
+// AuditHook redacts the request and response bodies of audit records.
+// Use it with WithAuditHook to keep sensitive data out of audit logs.
+// Request bodies that cannot be redacted are dropped, unless
+// AllowUnredactable is set.
+func (r *Redactor) AuditHook(rec *AuditRecord) {
+	if len(rec.Request) > 0 {
+		rec.Request, _ = r.redactRequest(rec.Request)
+	}
+	for i, line := range rec.Response {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(line, &fields) != nil {
+			continue
+		}
+		r.redactField(fields, "response")
+		if raw, ok := fields["message"]; ok {
+			var message map[string]json.RawMessage
+			if json.Unmarshal(raw, &message) == nil {
+				r.redactField(message, "content")
+				fields["message"], _ = json.Marshal(message)
+			}
+		}
+		if redacted, err := json.Marshal(fields); err == nil {
+			rec.Response[i] = redacted
+		}
+	}
+}
+
+// redactedBody rewrites the NDJSON lines of a response, holding back the
+// streamed text that may be the start of sensitive data.
+type redactedBody struct {
+	io.ReadCloser
+	r        *bufio.Reader
+	redactor *Redactor
+	pending  string
+	out      bytes.Buffer
+	err      error
+}
+
+func (b *redactedBody) Read(p []byte) (int, error) {
+	for b.out.Len() == 0 && b.err == nil {
+		line, err := b.r.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			b.out.Write(b.line(line))
+		}
+		b.err = err
+	}
+	if b.out.Len() > 0 {
+		return b.out.Read(p)
+	}
+	return 0, b.err
+}
+
+func (b *redactedBody) line(line []byte) []byte {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(line, &fields) != nil {
+		return line
+	}
+	var done bool
+	json.Unmarshal(fields["done"], &done)
+	var text string
+	if json.Unmarshal(fields["response"], &text) == nil {
+		fields["response"], _ = json.Marshal(b.emit(text, done))
+	} else if raw, ok := fields["message"]; ok {
+		var message map[string]json.RawMessage
+		if json.Unmarshal(raw, &message) != nil {
+			return line
+		}
+		json.Unmarshal(message["content"], &text)
+		message["content"], _ = json.Marshal(b.emit(text, done))
+		fields["message"], _ = json.Marshal(message)
+	} else {
+		return line
+	}
+	redacted, err := json.Marshal(fields)
+	if err != nil {
+		return line
+	}
+	return append(redacted, '\n')
+}
+
+// emit appends text to the pending text and returns the redacted part that
+// can be released.
+func (b *redactedBody) emit(text string, done bool) string {
+	b.pending += text
+	found := b.redactor.find(b.pending)
+	cut := len(b.pending)
+	if !done {
+		holdback := b.redactor.Holdback
+		if holdback <= 0 {
+			holdback = defaultHoldback
+		}
+		cut = max(cut-holdback, 0)
+		for cut > 0 && !utf8.RuneStart(b.pending[cut]) {
+			cut--
+		}
+	}
+	released := found[:0]
+	for _, f := range found {
+		if f.end <= cut {
+			released = append(released, f)
+			continue
+		}
+		if f.start < cut {
+			cut = f.start
+		}
+		break
+	}
+	out := b.redactor.replace(b.pending[:cut], released)
+	b.pending = b.pending[cut:]
+	return out
+}
@@ -0,0 +1,96 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactor(t *testing.T) {
+	r := &ollamago.Redactor{Detectors: append(ollamago.DefaultDetectors(), ollamago.DenyList("PROJECT", "Bluebird"))}
+	got := r.Redact("Mail jane.doe@example.com or call (555) 123-4567 about bluebird; card 4111 1111 1111 1111, not 4111 1111 1111 1112, from 10.0.0.1.")
+	require.Equal(t, "Mail [EMAIL] or call [PHONE] about [PROJECT]; card [CARD], not 4111 1111 1111 1112, from [IP].", got)
+
+	r = &ollamago.Redactor{Replace: func(detector, match string) string { return strings.Repeat("*", len(match)) }}
+	require.Equal(t, "ssn ***********", r.Redact("ssn 123-45-6789"))
+}
+
+func TestRedactorIntercept(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "llama3.2", Chunks: []string{"write to jane", ".doe@exam", "ple.com", " today"}})
+	t.Cleanup(srv.Close)
+	var audit bytes.Buffer
+	redactor := &ollamago.Redactor{Scope: ollamago.RedactPrompts | ollamago.RedactResponses, Holdback: 16}
+	client := srv.Client()
+	client.Interceptors = append(client.Interceptors,
+		redactor.Intercept,
+		ollamago.NewAuditLog(&audit, ollamago.WithAuditHook(redactor.AuditHook)).Interceptor(),
+	)
+	ctx := context.Background()
+	resp, err := client.GenerateChat(ctx, ollamago.ChatRequest{
+		Model:    "llama3.2",
+		Messages: []ollamago.ChatMessage{ollamago.UserMessage("I am john@example.com")},
+		Stream:   true,
+	})
+	require.NoError(t, err)
+	var content strings.Builder
+	for r := range resp {
+		require.NoError(t, r.Error)
+		require.NotContains(t, r.Message.Content, "@")
+		content.WriteString(r.Message.Content)
+	}
+	require.Equal(t, "write to [EMAIL] today", content.String())
+	requests := srv.Requests()
+	require.Contains(t, string(requests[len(requests)-1].Body), "I am [EMAIL]")
+
+	completion, err := client.GenerateCompletion(ctx, ollamago.CompletionRequest{Model: "llama3.2", Prompt: "hi", Stream: true})
+	require.NoError(t, err)
+	content.Reset()
+	for r := range completion {
+		content.WriteString(r.Response)
+	}
+	require.Equal(t, "write to [EMAIL] today", content.String())
+	require.NotContains(t, audit.String(), "@example.com")
+}
+
+func TestRedactorUnredactable(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "llama3.2"})
+	t.Cleanup(srv.Close)
+	redactor := &ollamago.Redactor{}
+	client := srv.Client()
+	// The compression runs first, leaving the redactor a gzip body.
+	client.Interceptors = []ollamago.Interceptor{ollamago.CompressRequests(0), redactor.Intercept}
+	ctx := context.Background()
+	req := ollamago.EmbedRequest{Model: "llama3.2", Input: []string{"I am john@example.com"}}
+	_, err := client.GenerateEmbeddings(ctx, req)
+	require.ErrorIs(t, err, ollamago.ErrUnredactable)
+	require.Empty(t, srv.Requests(), "unredactable requests are not sent")
+
+	redactor.AllowUnredactable = true
+	_, err = client.GenerateEmbeddings(ctx, req)
+	require.Error(t, err, "the fake server does not decompress requests")
+	require.NotErrorIs(t, err, ollamago.ErrUnredactable)
+	require.Len(t, srv.Requests(), 1)
+
+	rec := &ollamago.AuditRecord{Request: []byte("not json")}
+	(&ollamago.Redactor{}).AuditHook(rec)
+	require.Nil(t, rec.Request, "unredactable bodies are dropped from audit records")
+}
@@ -0,0 +1,154 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// RepairJSON makes a best-effort attempt at turning the slightly broken
+// JSON that small models tend to produce into valid JSON. It strips
+// surrounding prose and Markdown code fences, quotes unquoted and
+// single-quoted keys and strings, drops trailing commas and closes
+// truncated strings, arrays and objects. Input that is already valid JSON
+// is returned unchanged apart from surrounding text.
+func RepairJSON(s string) string {
+	if i := strings.IndexAny(s, "{["); i >= 0 {
+		s = s[i:]
+	}
+	var (
+		out       []byte
+		closers   []byte
+		inString  bool
+		quote     byte
+		escaped   bool
+		expectKey bool
+		afterKey  bool
+	)
+	inObject := func() bool {
+		return len(closers) > 0 && closers[len(closers)-1] == '}'
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+				// \' is not a JSON escape: the apostrophe needs none.
+				if c != '\'' {
+					out = append(out, '\\')
+				}
+				out = append(out, c)
+			case c == '\\':
+				escaped = true
+			case c == quote:
+				inString = false
+				out = append(out, '"')
+				if expectKey {
+					expectKey, afterKey = false, true
+				}
+			case c == '"':
+				out = append(out, '\\', '"')
+			case c == '\n':
+				out = append(out, '\\', 'n')
+			case c == '\r':
+				out = append(out, '\\', 'r')
+			case c == '\t':
+				out = append(out, '\\', 't')
+			case c < 0x20:
+				out = fmt.Appendf(out, `\u%04x`, c)
+			default:
+				out = append(out, c)
+			}
+			continue
+		}
+		switch {
+		case c == '"' || c == '\'':
+			inString, quote = true, c
+			out = append(out, '"')
+		case c == '{' || c == '[':
+			if c == '{' {
+				closers = append(closers, '}')
+			} else {
+				closers = append(closers, ']')
+			}
+			out = append(out, c)
+			expectKey = c == '{'
+		case c == '}' || c == ']':
+			out = trimTrailingComma(out)
+			if afterKey {
+				out = append(out, ':', 'n', 'u', 'l', 'l')
+				afterKey = false
+			}
+			if len(closers) > 0 {
+				c = closers[len(closers)-1]
+				closers = closers[:len(closers)-1]
+			}
+			out = append(out, c)
+			expectKey = false
+			if len(closers) == 0 {
+				return string(out)
+			}
+		case c == ',':
+			out = append(out, c)
+			expectKey = inObject()
+		case c == ':':
+			out = append(out, c)
+			expectKey, afterKey = false, false
+		case expectKey && isIdentByte(c):
+			j := i
+			for j < len(s) && isIdentByte(s[j]) {
+				j++
+			}
+			out = append(out, '"')
+			out = append(out, s[i:j]...)
+			out = append(out, '"')
+			i = j - 1
+			expectKey, afterKey = false, true
+		default:
+			out = append(out, c)
+		}
+	}
+	if inString {
+		// An unfinished escape was never written out.
+		out = append(out, '"')
+	}
+	out = trimTrailingComma(out)
+	if bytes.HasSuffix(out, []byte(":")) {
+		out = append(out, "null"...)
+	} else if afterKey {
+		out = append(out, ":null"...)
+	}
+	for i := len(closers) - 1; i >= 0; i-- {
+		out = trimTrailingComma(out)
+		out = append(out, closers[i])
+	}
+	return string(out)
+}
+
+func trimTrailingComma(out []byte) []byte {
+	trimmed := bytes.TrimRight(out, " \t\r\n")
+	if bytes.HasSuffix(trimmed, []byte(",")) {
+		return trimmed[:len(trimmed)-1]
+	}
+	return out
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c == '-' ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
@@ -0,0 +1,67 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"testing"
+
+	"cirello.io/ollamago"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepairJSON(t *testing.T) {
+	for input, want := range map[string]string{
+		`{"a":1}`:                              `{"a":1}`,
+		`{"a":1,}`:                             `{"a":1}`,
+		`[1, 2, 3, ]`:                          `[1,2,3]`,
+		`{name: "Ada", age: 36}`:               `{"name":"Ada","age":36}`,
+		`{'name': 'Ada "the first"'}`:          `{"name":"Ada \"the first\""}`,
+		`{'name': 'Ada\'s notes'}`:             `{"name":"Ada's notes"}`,
+		`{"name": "Ada\'s notes"}`:             `{"name":"Ada's notes"}`,
+		`{'path': 'C:\\notes\n'}`:              `{"path":"C:\\notes\n"}`,
+		`{"name":"Ada","tags":["x","y`:         `{"name":"Ada","tags":["x","y"]}`,
+		`{"a":"abc\`:                           `{"a":"abc"}`,
+		"{\"a\":\"line\r\nnext\x01\"}":         `{"a":"line\r\nnext\u0001"}`,
+		`{"name":"Ada","age":`:                 `{"name":"Ada","age":null}`,
+		`{"name":"Ada","age"`:                  `{"name":"Ada","age":null}`,
+		"```json\n{\"a\": true}\n```":          `{"a":true}`,
+		`Sure! Here it is: {"a": [1, {b: 2}]}`: `{"a":[1,{"b":2}]}`,
+	} {
+		require.JSONEq(t, want, ollamago.RepairJSON(input), "input: %s", input)
+	}
+}
+
+func TestChatIntoJSONRepair(t *testing.T) {
+	req := ollamago.ChatRequest{
+		Model:    "test",
+		Messages: []ollamago.ChatMessage{{Role: "user", Content: "Ada Lovelace, 36"}},
+	}
+	_, err := ollamago.ChatInto[person](context.Background(), scriptedChat(`{name: "Ada", age: 36,`), req)
+	require.ErrorIs(t, err, ollamago.ErrInvalidOutput)
+
+	p, err := ollamago.ChatInto[person](context.Background(), scriptedChat(`{name: "Ada", age: 36,`), req, ollamago.WithJSONRepair())
+	require.NoError(t, err)
+	require.Equal(t, person{Name: "Ada", Age: 36}, p)
+
+	mock := scriptedChat(`nope`, `{"name":"Ada","age":36}`)
+	_, err = ollamago.ChatInto[person](context.Background(), mock, req,
+		ollamago.WithRetries(1),
+		ollamago.WithFeedback(func(reply string, err error) string { return "fix: " + reply }),
+	)
+	require.NoError(t, err)
+	retry := mock.CallsTo("GenerateChat")[1].Request.(ollamago.ChatRequest)
+	require.Equal(t, "fix: nope", retry.Messages[2].Content)
+}
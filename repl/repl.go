@@ -0,0 +1,265 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package repl implements an interactive terminal chat on top of an
+// ollamago.Conversation. Lines typed by the user are sent to the model and
+// the reply is printed as it streams; lines starting with a slash are
+// commands:
+//
+//	/model [name]    show or change the model
+//	/system [prompt] show or change the system prompt
+//	/save id         save the conversation in the Store
+//	/load id         restore a conversation from the Store
+//	/clear           forget the conversation
+//	/help            list the commands
+//	/bye             exit
+//
+// A message spanning several lines is entered between lines holding only
+// three double quotes ("""). Applications add their own commands with
+// REPL.Commands.
+package repl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"cirello.io/ollamago"
+)
+
+// Command runs a slash-command. args is the rest of the line after the
+// command name, with the surrounding spaces trimmed. Errors are printed
+// and do not end the session.
+type Command func(ctx context.Context, r *REPL, args string) error
+
+// ErrExit is returned by a Command to end the session; Run then returns
+// nil.
+var ErrExit = errors.New("exit")
+
+// REPL reads messages and commands from In and writes the replies to Out.
+type REPL struct {
+	// Conversation holds the model, the system prompt and the history of
+	// the session.
+	Conversation *ollamago.Conversation
+
+	// Store persists the sessions for /save and /load. If nil, those
+	// commands are unavailable.
+	Store ollamago.ConversationStore
+
+	In  io.Reader
+	Out io.Writer
+
+	// Prompt is printed before each input. If empty, ">>> " is used.
+	Prompt string
+
+	// Commands holds additional slash-commands keyed by name, without the
+	// slash. They take precedence over the built-in ones.
+	Commands map[string]Command
+}
+
+const multiline = `"""`
+
+// Run reads the input until it is exhausted, /bye is entered or ctx is
+// done. Errors of a single exchange with the model are printed and the
+// session continues.
+func (r *REPL) Run(ctx context.Context) error {
+	if r.Conversation == nil {
+		return errors.New("repl has no conversation")
+	}
+	input := bufio.NewScanner(r.In)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fmt.Fprint(r.Out, r.prompt())
+		line, ok := r.readLine(input)
+		if !ok {
+			fmt.Fprintln(r.Out)
+			return input.Err()
+		}
+		var err error
+		if name, args, ok := parseCommand(line); ok {
+			err = r.runCommand(ctx, name, args)
+		} else if strings.TrimSpace(line) != "" {
+			err = r.send(ctx, line)
+		}
+		switch {
+		case errors.Is(err, ErrExit):
+			return nil
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err != nil:
+			fmt.Fprintln(r.Out, "error:", err)
+		}
+	}
+}
+
+func (r *REPL) prompt() string {
+	if r.Prompt == "" {
+		return ">>> "
+	}
+	return r.Prompt
+}
+
+// readLine reads a line of input, or all the lines of a multiline message.
+func (r *REPL) readLine(input *bufio.Scanner) (string, bool) {
+	if !input.Scan() {
+		return "", false
+	}
+	line := input.Text()
+	if strings.TrimSpace(line) != multiline {
+		return line, true
+	}
+	var lines []string
+	for input.Scan() {
+		if strings.TrimSpace(input.Text()) == multiline {
+			return strings.Join(lines, "\n"), true
+		}
+		lines = append(lines, input.Text())
+	}
+	return strings.Join(lines, "\n"), len(lines) > 0
+}
+
+func parseCommand(line string) (name, args string, ok bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "/") {
+		return "", "", false
+	}
+	name, args, _ = strings.Cut(line[1:], " ")
+	return name, strings.TrimSpace(args), true
+}
+
+func (r *REPL) runCommand(ctx context.Context, name, args string) error {
+	if cmd, ok := r.Commands[name]; ok {
+		return cmd(ctx, r, args)
+	}
+	if cmd, ok := builtins[name]; ok {
+		return cmd(ctx, r, args)
+	}
+	return fmt.Errorf("unknown command /%s, type /help for the list", name)
+}
+
+// send streams the reply to a user message.
+func (r *REPL) send(ctx context.Context, message string) error {
+	resp, err := r.Conversation.Send(ctx, message)
+	if err != nil {
+		return err
+	}
+	var errs error
+	for chunk := range resp {
+		if chunk.Error != nil && errs == nil {
+			errs = chunk.Error
+		}
+		fmt.Fprint(r.Out, chunk.Message.Content)
+	}
+	fmt.Fprintln(r.Out)
+	return errs
+}
+
+var builtins map[string]Command
+
+func init() {
+	builtins = map[string]Command{
+		"model":  model,
+		"system": system,
+		"save":   save,
+		"load":   load,
+		"clear":  reset,
+		"help":   help,
+		"bye":    bye,
+	}
+}
+
+func model(ctx context.Context, r *REPL, args string) error {
+	if args == "" {
+		fmt.Fprintln(r.Out, r.Conversation.Model)
+		return nil
+	}
+	r.Conversation.Model = args
+	return nil
+}
+
+func system(ctx context.Context, r *REPL, args string) error {
+	if args == "" {
+		fmt.Fprintln(r.Out, r.Conversation.System)
+		return nil
+	}
+	r.Conversation.System = args
+	return nil
+}
+
+func save(ctx context.Context, r *REPL, args string) error {
+	if r.Store == nil {
+		return errors.New("no store to save the conversation in")
+	}
+	if args == "" {
+		return errors.New("usage: /save id")
+	}
+	if err := r.Store.Save(ctx, r.Conversation.Snapshot(args)); err != nil {
+		return err
+	}
+	fmt.Fprintf(r.Out, "Saved conversation %s.\n", args)
+	return nil
+}
+
+func load(ctx context.Context, r *REPL, args string) error {
+	if r.Store == nil {
+		return errors.New("no store to load the conversation from")
+	}
+	if args == "" {
+		return errors.New("usage: /load id")
+	}
+	session, err := r.Store.Load(ctx, args)
+	if err != nil {
+		return err
+	}
+	r.Conversation.Restore(session)
+	fmt.Fprintf(r.Out, "Loaded conversation %s with %s.\n", args, session.Model)
+	return nil
+}
+
+func reset(ctx context.Context, r *REPL, args string) error {
+	r.Conversation.Reset()
+	return nil
+}
+
+func help(ctx context.Context, r *REPL, args string) error {
+	fmt.Fprint(r.Out, `/model [name]    show or change the model
+/system [prompt] show or change the system prompt
+/save id         save the conversation
+/load id         restore a conversation
+/clear           forget the conversation
+/bye             exit
+Enter """ to begin and end a multiline message.
+`)
+	var extra []string
+	for name := range r.Commands {
+		if _, ok := builtins[name]; !ok {
+			extra = append(extra, "/"+name)
+		}
+	}
+	if len(extra) > 0 {
+		slices.Sort(extra)
+		fmt.Fprintln(r.Out, "Other commands:", strings.Join(extra, " "))
+	}
+	return nil
+}
+
+func bye(ctx context.Context, r *REPL, args string) error {
+	return ErrExit
+}
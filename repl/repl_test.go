@@ -0,0 +1,89 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repl_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"cirello.io/ollamago/repl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestREPL(t *testing.T) {
+	srv := ollamatest.NewServer(
+		ollamatest.Model{Name: "llama3.2", Chunks: []string{"hello ", "world"}},
+		ollamatest.Model{Name: "qwen2.5", Chunks: []string{"hi"}},
+	)
+	t.Cleanup(srv.Close)
+	input := strings.Join([]string{
+		"/system be brief",
+		`"""`,
+		"first line",
+		"second line",
+		`"""`,
+		"/save s1",
+		"/model qwen2.5",
+		"/clear",
+		"again",
+		"/nope",
+		"/load s1",
+		"/model",
+		"/echo ping",
+		"/bye",
+		"never sent",
+	}, "\n")
+	var out strings.Builder
+	r := &repl.REPL{
+		Conversation: &ollamago.Conversation{Client: srv.Client(), Model: "llama3.2"},
+		Store:        &ollamago.JSONFileStore{Dir: t.TempDir()},
+		In:           strings.NewReader(input),
+		Out:          &out,
+		Commands: map[string]repl.Command{
+			"echo": func(ctx context.Context, r *repl.REPL, args string) error {
+				fmt.Fprintln(r.Out, args)
+				return nil
+			},
+		},
+	}
+	require.NoError(t, r.Run(context.Background()))
+
+	reqs := srv.Requests()
+	require.Len(t, reqs, 2)
+	require.JSONEq(t, `{"model":"llama3.2","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"first line\nsecond line"}],"options":{},"stream":true}`, string(reqs[0].Body))
+	require.JSONEq(t, `{"model":"qwen2.5","messages":[{"role":"system","content":"be brief"},{"role":"user","content":"again"}],"options":{},"stream":true}`, string(reqs[1].Body))
+	require.Contains(t, out.String(), ">>> hello world\n")
+	require.Contains(t, out.String(), ">>> hi\n")
+	require.Contains(t, out.String(), "error: unknown command /nope")
+	require.Contains(t, out.String(), "Loaded conversation s1 with llama3.2.\n>>> llama3.2\n>>> ping\n")
+	require.Len(t, r.Conversation.History(), 2)
+}
+
+func TestREPLWithoutStore(t *testing.T) {
+	var out strings.Builder
+	r := &repl.REPL{
+		Conversation: &ollamago.Conversation{Model: "llama3.2"},
+		In:           strings.NewReader("/save s1\n/help\n"),
+		Out:          &out,
+		Prompt:       "> ",
+	}
+	require.NoError(t, r.Run(context.Background()))
+	require.Contains(t, out.String(), "> error: no store to save the conversation in\n")
+	require.Contains(t, out.String(), "/bye             exit\n")
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"net/http"
+)
+
+// ResponseHook returns an Interceptor that calls fn with the HTTP response
+// of every call, such as to inspect the status and the headers added by
+// gateways and proxies. fn is called before the body is decoded and must
+// neither read nor close it.
+func ResponseHook(fn func(*http.Response)) Interceptor {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(req)
+			if err == nil {
+				fn(resp)
+			}
+			return resp, err
+		})
+	}
+}
+
+type responseHookKey struct{}
+
+// WithResponseHook returns a context whose calls report their HTTP
+// response to fn, as with ResponseHook, for debugging a single call:
+//
+//	var raw *http.Response
+//	ctx = ollamago.WithResponseHook(ctx, func(r *http.Response) { raw = r })
+//	resp, err := client.GenerateChat(ctx, req)
+//	log.Println(raw.Header.Get("X-Request-Id"))
+//
+// Hooks already in ctx are called too.
+func WithResponseHook(ctx context.Context, fn func(*http.Response)) context.Context {
+	if prev, ok := ctx.Value(responseHookKey{}).(func(*http.Response)); ok {
+		next := fn
+		fn = func(resp *http.Response) {
+			prev(resp)
+			next(resp)
+		}
+	}
+	return context.WithValue(ctx, responseHookKey{}, fn)
+}
+
+func applyResponseHooks(next http.RoundTripper) http.RoundTripper {
+	return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		if fn, ok := req.Context().Value(responseHookKey{}).(func(*http.Response)); ok && err == nil {
+			fn(resp)
+		}
+		return resp, err
+	})
+}
@@ -0,0 +1,73 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseHook(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test", Chunks: []string{"a", "b"}})
+	t.Cleanup(srv.Close)
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-1")
+		proxy, err := http.NewRequestWithContext(r.Context(), r.Method, srv.URL+r.URL.Path, r.Body)
+		require.NoError(t, err)
+		resp, err := http.DefaultClient.Do(proxy)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(gateway.Close)
+
+	var statuses []int
+	client := &ollamago.Client{
+		BaseURL: gateway.URL,
+		Interceptors: []ollamago.Interceptor{ollamago.ResponseHook(func(r *http.Response) {
+			statuses = append(statuses, r.StatusCode)
+		})},
+	}
+
+	var raw *http.Response
+	ctx := ollamago.WithResponseHook(context.Background(), func(r *http.Response) { raw = r })
+	resp, err := client.GenerateChat(ctx, ollamago.ChatRequest{
+		Model:    "test",
+		Messages: []ollamago.ChatMessage{ollamago.UserMessage("hi")},
+		Stream:   true,
+	})
+	require.NoError(t, err)
+	var content string
+	for r := range resp {
+		content += r.Message.Content
+	}
+	require.Equal(t, "ab", content)
+	require.NotNil(t, raw)
+	require.Equal(t, "req-1", raw.Header.Get("X-Request-Id"))
+
+	raw = nil
+	_, err = client.ShowModelInfo(context.Background(), ollamago.ShowModelRequest{Model: "missing"})
+	require.Error(t, err)
+	require.Nil(t, raw, "the hook of another context was called")
+	require.Equal(t, []int{http.StatusOK, http.StatusNotFound}, statuses)
+}
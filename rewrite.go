@@ -0,0 +1,134 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// RewriteOption configures Translate and Rewrite.
+type RewriteOption func(*rewriteConfig)
+
+type rewriteConfig struct {
+	ratio   float64
+	stream  func(chunk string)
+	options ModelParameters
+}
+
+// WithLengthRatio bounds the length of the output to ratio times the
+// length of the input, plus some slack for short texts. The default is 3.
+func WithLengthRatio(ratio float64) RewriteOption {
+	return func(c *rewriteConfig) {
+		c.ratio = ratio
+	}
+}
+
+// WithRewriteStream registers a callback receiving the output as it is
+// generated.
+func WithRewriteStream(stream func(chunk string)) RewriteOption {
+	return func(c *rewriteConfig) {
+		c.stream = stream
+	}
+}
+
+// WithRewriteOptions sets the model parameters of the request.
+func WithRewriteOptions(options ModelParameters) RewriteOption {
+	return func(c *rewriteConfig) {
+		c.options = options
+	}
+}
+
+// ErrOutputTooLong is returned by Translate and Rewrite when the model
+// output exceeds the length allowed by WithLengthRatio, which usually
+// means that the model is answering or commenting the text instead of
+// rewriting it.
+var ErrOutputTooLong = errors.New("output too long")
+
+// Translate translates text into the target language, such as "French"
+// or "pt-BR", preserving its meaning, tone and formatting.
+func Translate(ctx context.Context, client API, model, text, targetLanguage string, opts ...RewriteOption) (string, error) {
+	return rewrite(ctx, client, model, text,
+		"Translate the text given by the user into "+targetLanguage+". Preserve the meaning, the tone and the formatting, such as line breaks and Markdown. Do not translate code, names or URLs. Reply with the translation only, without notes.",
+		opts)
+}
+
+// Rewrite rewrites text following the style instructions, such as "make
+// it formal" or "simplify it for a young reader", preserving its meaning.
+func Rewrite(ctx context.Context, client API, model, text, instructions string, opts ...RewriteOption) (string, error) {
+	return rewrite(ctx, client, model, text,
+		"Rewrite the text given by the user as instructed, preserving its meaning. Instructions: "+instructions+". Reply with the rewritten text only, without notes.",
+		opts)
+}
+
+func rewrite(ctx context.Context, client API, model, text, system string, opts []RewriteOption) (string, error) {
+	cfg := rewriteConfig{ratio: 3}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if strings.TrimSpace(text) == "" {
+		return text, nil
+	}
+	limit := int(cfg.ratio*float64(utf8.RuneCountInString(text))) + 200
+	options := cfg.options
+	if options.NumPredict == nil {
+		options.NumPredict = Ptr(limit/4 + 16)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	resp, err := client.GenerateChat(ctx, ChatRequest{
+		Model: model,
+		Messages: []ChatMessage{
+			SystemMessage(system),
+			UserMessage(text),
+		},
+		Stream:  true,
+		Options: options,
+	})
+	if err != nil {
+		return "", fmt.Errorf("cannot rewrite text: %w", err)
+	}
+	var (
+		out     strings.Builder
+		errs    error
+		tooLong bool
+	)
+	for r := range resp {
+		if tooLong {
+			continue
+		}
+		if r.Error != nil && errs == nil {
+			errs = r.Error
+		}
+		out.WriteString(r.Message.Content)
+		if cfg.stream != nil && r.Message.Content != "" {
+			cfg.stream(r.Message.Content)
+		}
+		if utf8.RuneCountInString(out.String()) > limit {
+			tooLong = true
+			cancel()
+		}
+	}
+	if tooLong {
+		return "", fmt.Errorf("cannot rewrite text: %w: more than %d characters", ErrOutputTooLong, limit)
+	}
+	if errs != nil {
+		return "", fmt.Errorf("cannot rewrite text: %w", errs)
+	}
+	return strings.TrimSpace(out.String()), nil
+}
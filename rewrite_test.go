@@ -0,0 +1,56 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslateAndRewrite(t *testing.T) {
+	srv := ollamatest.NewServer(
+		ollamatest.Model{Name: "test", Chunks: []string{"Bonjour ", "le monde\n"}},
+		ollamatest.Model{Name: "chatty", Chunks: []string{strings.Repeat("Sure! Here is a long answer. ", 20)}},
+	)
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+	ctx := context.Background()
+
+	var streamed strings.Builder
+	out, err := ollamago.Translate(ctx, client, "test", "Hello world", "French", ollamago.WithRewriteStream(func(chunk string) {
+		streamed.WriteString(chunk)
+	}))
+	require.NoError(t, err)
+	require.Equal(t, "Bonjour le monde", out)
+	require.Equal(t, "Bonjour le monde\n", streamed.String())
+	body := string(srv.Requests()[0].Body)
+	require.Contains(t, body, "into French")
+	require.Contains(t, body, `"num_predict":`)
+
+	out, err = ollamago.Rewrite(ctx, client, "test", "hey world", "make it formal", ollamago.WithRewriteOptions(ollamago.ModelParameters{NumPredict: ollamago.Ptr(10)}))
+	require.NoError(t, err)
+	require.Equal(t, "Bonjour le monde", out)
+	body = string(srv.Requests()[1].Body)
+	require.Contains(t, body, "Instructions: make it formal.")
+	require.Contains(t, body, `"num_predict":10`)
+
+	_, err = ollamago.Rewrite(ctx, client, "chatty", "hi", "make it formal", ollamago.WithLengthRatio(1))
+	require.ErrorIs(t, err, ollamago.ErrOutputTooLong)
+}
@@ -0,0 +1,213 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+)
+
+// Priority is the class of a call queued by a Scheduler. Lower values go
+// first.
+type Priority int
+
+const (
+	// PriorityInteractive is for the calls a user waits on, such as
+	// chats. It is the priority of the calls that do not set one.
+	PriorityInteractive Priority = iota
+
+	// PriorityBatch is for bulk jobs, such as embedding a corpus, which
+	// yield to the interactive calls.
+	PriorityBatch
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a context whose calls are queued by a Scheduler
+// with priority p.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+func priorityOf(ctx context.Context) Priority {
+	p, _ := ctx.Value(priorityKey{}).(Priority)
+	return p
+}
+
+// Scheduler wraps an API, queuing the generation and embedding calls so
+// that at most MaxConcurrent of them, and at most the limit of their
+// model, are in flight. Queued calls start in the order of their
+// priority, set with WithPriority, and then of their arrival, so that
+// bulk jobs sharing a server cannot starve the calls users wait on. A
+// streamed call holds its slot until its response is over.
+type Scheduler struct {
+	API
+
+	// MaxConcurrent caps the calls in flight. If zero, only the model
+	// limits apply.
+	MaxConcurrent int
+
+	// MaxPerModel caps the calls in flight per model, unless ModelLimits
+	// sets the limit of the model. If zero, models are not limited.
+	MaxPerModel int
+
+	// ModelLimits caps the calls in flight of specific models, the tag
+	// defaulting to "latest".
+	ModelLimits map[string]int
+
+	mu       sync.Mutex
+	inFlight int
+	perModel map[string]int
+	queue    []*schedulerWaiter
+	seq      uint64
+}
+
+var _ API = (*Scheduler)(nil)
+
+type schedulerWaiter struct {
+	model    string
+	priority Priority
+	seq      uint64
+	ready    chan struct{}
+}
+
+// modelLimit returns the limit of model, tagged as by withTag.
+func (s *Scheduler) modelLimit(model string) int {
+	for name, limit := range s.ModelLimits {
+		if withTag(name) == model {
+			return limit
+		}
+	}
+	return s.MaxPerModel
+}
+
+// acquire waits for a slot for a call to model, returning the function
+// that frees it.
+func (s *Scheduler) acquire(ctx context.Context, model string) (func(), error) {
+	model = withTag(model)
+	w := &schedulerWaiter{model: model, priority: priorityOf(ctx), ready: make(chan struct{})}
+	s.mu.Lock()
+	s.seq++
+	w.seq = s.seq
+	i, _ := slices.BinarySearchFunc(s.queue, w, func(a, b *schedulerWaiter) int {
+		if a.priority != b.priority {
+			return cmp.Compare(a.priority, b.priority)
+		}
+		return cmp.Compare(a.seq, b.seq)
+	})
+	s.queue = slices.Insert(s.queue, i, w)
+	s.dispatch()
+	s.mu.Unlock()
+	release := func() {
+		s.mu.Lock()
+		s.inFlight--
+		s.perModel[model]--
+		s.dispatch()
+		s.mu.Unlock()
+	}
+	select {
+	case <-w.ready:
+		return sync.OnceFunc(release), nil
+	case <-ctx.Done():
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := slices.Index(s.queue, w); i >= 0 {
+		s.queue = slices.Delete(s.queue, i, i+1)
+		s.dispatch()
+		return nil, ctx.Err()
+	}
+	// The slot was granted as ctx was done.
+	s.inFlight--
+	s.perModel[w.model]--
+	s.dispatch()
+	return nil, ctx.Err()
+}
+
+// dispatch starts the queued calls that have a slot, in queue order. A
+// call waiting for its model limit does not hold back the others, but
+// none overtakes a call waiting for the overall limit.
+func (s *Scheduler) dispatch() {
+	if s.perModel == nil {
+		s.perModel = make(map[string]int)
+	}
+	for i := 0; i < len(s.queue); {
+		if s.MaxConcurrent > 0 && s.inFlight >= s.MaxConcurrent {
+			return
+		}
+		w := s.queue[i]
+		if limit := s.modelLimit(w.model); limit > 0 && s.perModel[w.model] >= limit {
+			i++
+			continue
+		}
+		s.queue = slices.Delete(s.queue, i, i+1)
+		s.inFlight++
+		s.perModel[w.model]++
+		close(w.ready)
+	}
+}
+
+// GenerateCompletion queues the completion.
+func (s *Scheduler) GenerateCompletion(ctx context.Context, req CompletionRequest) (<-chan CompletionResponse, error) {
+	return scheduleStream(ctx, s, req.Model, func() (<-chan CompletionResponse, error) {
+		return s.API.GenerateCompletion(ctx, req)
+	})
+}
+
+// GenerateChat queues the chat.
+func (s *Scheduler) GenerateChat(ctx context.Context, req ChatRequest) (<-chan ChatResponse, error) {
+	return scheduleStream(ctx, s, req.Model, func() (<-chan ChatResponse, error) {
+		return s.API.GenerateChat(ctx, req)
+	})
+}
+
+// GenerateEmbeddings queues the embedding.
+func (s *Scheduler) GenerateEmbeddings(ctx context.Context, req EmbedRequest) (*EmbedResponse, error) {
+	return scheduleUnary(ctx, s, req.Model, func() (*EmbedResponse, error) {
+		return s.API.GenerateEmbeddings(ctx, req)
+	})
+}
+
+// GenerateEmbeddings32 queues the embedding.
+func (s *Scheduler) GenerateEmbeddings32(ctx context.Context, req EmbedRequest) (*EmbedResponse32, error) {
+	return scheduleUnary(ctx, s, req.Model, func() (*EmbedResponse32, error) {
+		return s.API.GenerateEmbeddings32(ctx, req)
+	})
+}
+
+func scheduleStream[T any](ctx context.Context, s *Scheduler, model string, call func() (<-chan T, error)) (<-chan T, error) {
+	release, err := s.acquire(ctx, model)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := call()
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return relay(ctx, resp, release), nil
+}
+
+func scheduleUnary[T any](ctx context.Context, s *Scheduler, model string, call func() (T, error)) (T, error) {
+	release, err := s.acquire(ctx, model)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	defer release()
+	return call()
+}
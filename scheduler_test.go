@@ -0,0 +1,114 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamagotest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	gate := make(chan struct{})
+	mock := &ollamagotest.MockClient{
+		GenerateEmbeddingsFunc: func(ctx context.Context, req ollamago.EmbedRequest) (*ollamago.EmbedResponse, error) {
+			mu.Lock()
+			order = append(order, req.Input[0])
+			mu.Unlock()
+			if req.Input[0] == "first" {
+				<-gate
+			}
+			return &ollamago.EmbedResponse{Model: req.Model}, nil
+		},
+	}
+	s := &ollamago.Scheduler{API: mock, MaxConcurrent: 1}
+	var wg sync.WaitGroup
+	embed := func(ctx context.Context, input string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: "test", Input: []string{input}})
+			require.NoError(t, err)
+		}()
+		time.Sleep(20 * time.Millisecond)
+	}
+	batch := ollamago.WithPriority(context.Background(), ollamago.PriorityBatch)
+	embed(batch, "first")
+	embed(batch, "batch 1")
+	embed(batch, "batch 2")
+	embed(context.Background(), "interactive")
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := s.GenerateEmbeddings(canceled, ollamago.EmbedRequest{Model: "test", Input: []string{"canceled"}})
+	require.ErrorIs(t, err, context.Canceled)
+
+	close(gate)
+	wg.Wait()
+	require.Equal(t, []string{"first", "interactive", "batch 1", "batch 2"}, order)
+}
+
+func TestSchedulerModelLimits(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		inFlight = map[string]int{}
+		peak     = map[string]int{}
+	)
+	mock := &ollamagotest.MockClient{
+		GenerateChatFunc: func(ctx context.Context, req ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error) {
+			model := strings.TrimSuffix(req.Model, ":latest")
+			mu.Lock()
+			inFlight[model]++
+			peak[model] = max(peak[model], inFlight[model])
+			mu.Unlock()
+			out := make(chan ollamago.ChatResponse)
+			go func() {
+				defer close(out)
+				time.Sleep(5 * time.Millisecond)
+				mu.Lock()
+				inFlight[model]--
+				mu.Unlock()
+				out <- ollamago.ChatResponse{Done: true}
+			}()
+			return out, nil
+		},
+	}
+	s := &ollamago.Scheduler{API: mock, MaxPerModel: 2, ModelLimits: map[string]int{"big": 1}}
+	var wg sync.WaitGroup
+	for range 5 {
+		for _, model := range []string{"small", "small:latest", "big", "big:latest"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := s.GenerateChat(context.Background(), ollamago.ChatRequest{Model: model})
+				require.NoError(t, err)
+				for range resp {
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	require.Equal(t, map[string]int{"small": 2, "big": 1}, peak)
+}
@@ -0,0 +1,177 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	rawMessageType    = reflect.TypeFor[json.RawMessage]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// Schema derives the JSON schema of t, for use as a structured output
+// format or as tool parameters. Struct fields follow the encoding/json
+// naming rules and are further described by these tags:
+//
+//	description:"..."  documents the field
+//	enum:"a,b,c"       restricts the field to the listed values
+//	required:"false"   makes the field optional
+//	required:"true"    makes the field mandatory even if omitempty
+//
+// Without a required tag, fields are mandatory unless tagged omitempty or
+// omitzero.
+func Schema(t reflect.Type) json.RawMessage {
+	out, err := json.Marshal(jsonSchema(t))
+	if err != nil {
+		// The schema is built from maps, slices and strings only.
+		panic(err)
+	}
+	return out
+}
+
+func jsonSchema(t reflect.Type) map[string]any {
+	return schemaOf(t, make(map[reflect.Type]bool))
+}
+
+func schemaOf(t reflect.Type, visiting map[reflect.Type]bool) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]any{}
+	case t.Kind() != reflect.Struct && t.Implements(textMarshalerType):
+		return map[string]any{"type": "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), visiting)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem(), visiting)}
+	case reflect.Struct:
+		if visiting[t] {
+			return map[string]any{"type": "object"}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		properties := map[string]any{}
+		required := []string{}
+		structFields(t, visiting, properties, &required)
+		return map[string]any{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		}
+	default:
+		return map[string]any{}
+	}
+}
+
+func structFields(t reflect.Type, visiting map[reflect.Type]bool, properties map[string]any, required *[]string) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				structFields(ft, visiting, properties, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		schema := schemaOf(f.Type, visiting)
+		if desc := f.Tag.Get("description"); desc != "" {
+			schema["description"] = desc
+		}
+		if enum, ok := f.Tag.Lookup("enum"); ok {
+			target := schema
+			if items, ok := schema["items"].(map[string]any); ok {
+				target = items
+			}
+			target["enum"] = enumValues(f.Type, enum)
+		}
+		properties[name] = schema
+		isRequired := !hasOption(opts, "omitempty") && !hasOption(opts, "omitzero")
+		if r, ok := f.Tag.Lookup("required"); ok {
+			isRequired = r == "true"
+		}
+		if isRequired {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// enumValues splits a comma-separated enum tag, decoding the values as
+// JSON literals when the field is not a string.
+func enumValues(t reflect.Type, enum string) []any {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	var values []any
+	for _, v := range strings.Split(enum, ",") {
+		v = strings.TrimSpace(v)
+		var literal any
+		if t.Kind() != reflect.String && json.Unmarshal([]byte(v), &literal) == nil {
+			values = append(values, literal)
+			continue
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+func hasOption(opts, option string) bool {
+	for opts != "" {
+		var o string
+		o, opts, _ = strings.Cut(opts, ",")
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,52 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"cirello.io/ollamago"
+	"github.com/stretchr/testify/require"
+)
+
+type ticket struct {
+	Title    string            `json:"title" description:"Short summary"`
+	Priority int               `json:"priority" enum:"1,2,3"`
+	Status   string            `json:"status,omitempty" enum:"open,closed" required:"true"`
+	Tags     []string          `json:"tags" enum:"bug,feature" required:"false"`
+	Due      *time.Time        `json:"due,omitempty"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Parent   *ticket           `json:"parent,omitempty"`
+	internal string
+}
+
+func TestSchema(t *testing.T) {
+	require.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"title": {"type": "string", "description": "Short summary"},
+			"priority": {"type": "integer", "enum": [1, 2, 3]},
+			"status": {"type": "string", "enum": ["open", "closed"]},
+			"tags": {"type": "array", "items": {"type": "string", "enum": ["bug", "feature"]}},
+			"due": {"type": "string", "format": "date-time"},
+			"labels": {"type": "object", "additionalProperties": {"type": "string"}},
+			"parent": {"type": "object"}
+		},
+		"required": ["title", "priority", "status"]
+	}`, string(ollamago.Schema(reflect.TypeFor[ticket]())))
+	require.JSONEq(t, `{"type":"array","items":{"type":"number"}}`, string(ollamago.Schema(reflect.TypeFor[[]float64]())))
+}
@@ -0,0 +1,299 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Session is the persisted state of a Conversation.
+type Session struct {
+	ID        string        `json:"id"`
+	Model     string        `json:"model"`
+	System    string        `json:"system,omitempty"`
+	Summary   string        `json:"summary,omitempty"`
+	Messages  []ChatMessage `json:"messages"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// Snapshot captures the state of the conversation as a Session with the
+// given ID. The running summary is included when Memory is a
+// *SummaryMemory.
+func (c *Conversation) Snapshot(id string) Session {
+	c.mu.Lock()
+	s := Session{
+		ID:        id,
+		Model:     c.Model,
+		System:    c.System,
+		Messages:  append([]ChatMessage(nil), c.history...),
+		UpdatedAt: time.Now().UTC(),
+	}
+	c.mu.Unlock()
+	if m, ok := c.Memory.(*SummaryMemory); ok {
+		s.Summary = m.Summary()
+	}
+	return s
+}
+
+// Restore replaces the state of the conversation with the given Session.
+func (c *Conversation) Restore(s Session) {
+	c.mu.Lock()
+	c.Model = s.Model
+	c.System = s.System
+	c.history = append([]ChatMessage(nil), s.Messages...)
+	c.mu.Unlock()
+	if m, ok := c.Memory.(*SummaryMemory); ok {
+		m.mu.Lock()
+		m.summary = s.Summary
+		m.mu.Unlock()
+	}
+}
+
+// ErrSessionNotFound is returned by ConversationStore implementations when
+// the requested session does not exist.
+var ErrSessionNotFound = errors.New("session not found")
+
+// ConversationStore persists conversation sessions by ID.
+type ConversationStore interface {
+	Save(ctx context.Context, s Session) error
+	Load(ctx context.Context, id string) (Session, error)
+	List(ctx context.Context) ([]string, error)
+	Delete(ctx context.Context, id string) error
+}
+
+var (
+	_ ConversationStore = (*JSONFileStore)(nil)
+	_ ConversationStore = (*SQLiteStore)(nil)
+)
+
+// JSONFileStore stores each session as a JSON file named after its ID in
+// Dir.
+type JSONFileStore struct {
+	Dir string
+}
+
+func (s *JSONFileStore) path(id string) (string, error) {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("invalid session ID %q", id)
+	}
+	return filepath.Join(s.Dir, id+".json"), nil
+}
+
+func (s *JSONFileStore) Save(ctx context.Context, session Session) error {
+	path, err := s.path(session.ID)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(session, "", "\t")
+	if err != nil {
+		return fmt.Errorf("cannot encode session: %w", err)
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return fmt.Errorf("cannot create session directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.Dir, ".session-*")
+	if err != nil {
+		return fmt.Errorf("cannot save session: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("cannot save session: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("cannot save session: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("cannot save session: %w", err)
+	}
+	return nil
+}
+
+func (s *JSONFileStore) Load(ctx context.Context, id string) (Session, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return Session{}, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Session{}, ErrSessionNotFound
+	} else if err != nil {
+		return Session{}, fmt.Errorf("cannot load session: %w", err)
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return Session{}, fmt.Errorf("cannot decode session: %w", err)
+	}
+	return session, nil
+}
+
+func (s *JSONFileStore) List(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("cannot list sessions: %w", err)
+	}
+	var ids []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(name, ".json"))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (s *JSONFileStore) Delete(ctx context.Context, id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrSessionNotFound
+	} else if err != nil {
+		return fmt.Errorf("cannot delete session: %w", err)
+	}
+	return nil
+}
+
+// SQLiteStore stores sessions in a SQLite database. The caller opens DB
+// with the SQLite driver of their choice and calls Init once to create the
+// table.
+type SQLiteStore struct {
+	DB *sql.DB
+
+	// Table is the name of the sessions table, optionally
+	// schema-qualified. If empty, "ollamago_sessions" is used.
+	Table string
+}
+
+var validIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+func (s *SQLiteStore) table() (string, error) {
+	if s.Table == "" {
+		return "ollamago_sessions", nil
+	}
+	if !validIdentifier.MatchString(s.Table) {
+		return "", fmt.Errorf("invalid table name %q", s.Table)
+	}
+	return s.Table, nil
+}
+
+// Init creates the sessions table if it does not exist.
+func (s *SQLiteStore) Init(ctx context.Context) error {
+	table, err := s.table()
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+table+` (
+		id TEXT PRIMARY KEY,
+		data TEXT NOT NULL,
+		updated_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("cannot create sessions table: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Save(ctx context.Context, session Session) error {
+	table, err := s.table()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("cannot encode session: %w", err)
+	}
+	_, err = s.DB.ExecContext(ctx, `INSERT INTO `+table+` (id, data, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at`,
+		session.ID, string(data), session.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("cannot save session: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Load(ctx context.Context, id string) (Session, error) {
+	table, err := s.table()
+	if err != nil {
+		return Session{}, err
+	}
+	var data string
+	err = s.DB.QueryRowContext(ctx, `SELECT data FROM `+table+` WHERE id = ?`, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Session{}, ErrSessionNotFound
+	} else if err != nil {
+		return Session{}, fmt.Errorf("cannot load session: %w", err)
+	}
+	var session Session
+	if err := json.Unmarshal([]byte(data), &session); err != nil {
+		return Session{}, fmt.Errorf("cannot decode session: %w", err)
+	}
+	return session, nil
+}
+
+func (s *SQLiteStore) List(ctx context.Context) ([]string, error) {
+	table, err := s.table()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.DB.QueryContext(ctx, `SELECT id FROM `+table+` ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list sessions: %w", err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("cannot list sessions: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("cannot list sessions: %w", err)
+	}
+	return ids, nil
+}
+
+func (s *SQLiteStore) Delete(ctx context.Context, id string) error {
+	table, err := s.table()
+	if err != nil {
+		return err
+	}
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM `+table+` WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("cannot delete session: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/internal/fakesql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONFileStore(t *testing.T) {
+	ctx := context.Background()
+	store := &ollamago.JSONFileStore{Dir: t.TempDir()}
+
+	conv := &ollamago.Conversation{Model: "test", System: "be brief", Memory: &ollamago.SummaryMemory{}}
+	conv.Append(
+		ollamago.ChatMessage{Role: "user", Content: "hi"},
+		ollamago.ChatMessage{Role: "assistant", Content: "hello"},
+	)
+	require.NoError(t, store.Save(ctx, conv.Snapshot("session-1")))
+
+	ids, err := store.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"session-1"}, ids)
+
+	session, err := store.Load(ctx, "session-1")
+	require.NoError(t, err)
+	resumed := &ollamago.Conversation{}
+	resumed.Restore(session)
+	require.Equal(t, "test", resumed.Model)
+	require.Equal(t, "be brief", resumed.System)
+	require.Equal(t, conv.History(), resumed.History())
+
+	require.NoError(t, store.Delete(ctx, "session-1"))
+	_, err = store.Load(ctx, "session-1")
+	require.ErrorIs(t, err, ollamago.ErrSessionNotFound)
+	require.ErrorIs(t, store.Delete(ctx, "session-1"), ollamago.ErrSessionNotFound)
+	require.Error(t, store.Save(ctx, ollamago.Session{ID: "../escape"}))
+}
+
+func TestSQLiteStore(t *testing.T) {
+	// rows holds the table, as the fake database sees it.
+	rows := make(map[string]string)
+	var statements []string
+	db := fakesql.Open(func(query string, args []any) (*fakesql.Rows, int64, error) {
+		query = strings.Join(strings.Fields(query), " ")
+		statements = append(statements, query)
+		switch {
+		case strings.Contains(query, "locked"):
+			return nil, 0, errors.New("database is locked")
+		case strings.HasPrefix(query, "CREATE TABLE"):
+			return nil, 0, nil
+		case strings.HasPrefix(query, "INSERT INTO"):
+			rows[args[0].(string)] = args[1].(string)
+			return nil, 1, nil
+		case strings.HasPrefix(query, "SELECT data"):
+			data, ok := rows[args[0].(string)]
+			if !ok {
+				return &fakesql.Rows{Columns: []string{"data"}}, 0, nil
+			}
+			return &fakesql.Rows{Columns: []string{"data"}, Values: [][]any{{data}}}, 0, nil
+		case strings.HasPrefix(query, "SELECT id"):
+			ids := &fakesql.Rows{Columns: []string{"id"}}
+			for _, id := range slices.Sorted(maps.Keys(rows)) {
+				ids.Values = append(ids.Values, []any{id})
+			}
+			return ids, 0, nil
+		case strings.HasPrefix(query, "DELETE FROM"):
+			_, ok := rows[args[0].(string)]
+			delete(rows, args[0].(string))
+			if ok {
+				return nil, 1, nil
+			}
+			return nil, 0, nil
+		}
+		return nil, 0, fmt.Errorf("unexpected statement %q", query)
+	})
+	t.Cleanup(func() { db.Close() })
+	ctx := context.Background()
+	store := &ollamago.SQLiteStore{DB: db, Table: "sessions"}
+
+	require.NoError(t, store.Init(ctx))
+	require.Equal(t, []string{"CREATE TABLE IF NOT EXISTS sessions ( id TEXT PRIMARY KEY, data TEXT NOT NULL, updated_at TIMESTAMP NOT NULL )"}, statements)
+
+	conv := &ollamago.Conversation{Model: "test", System: "be brief"}
+	conv.Append(ollamago.UserMessage("hi"), ollamago.AssistantMessage("hello"))
+	require.NoError(t, store.Save(ctx, conv.Snapshot("session-2")))
+	require.NoError(t, store.Save(ctx, conv.Snapshot("session-1")))
+	require.Equal(t, "INSERT INTO sessions (id, data, updated_at) VALUES (?, ?, ?) ON CONFLICT(id) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at", statements[1])
+
+	ids, err := store.List(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"session-1", "session-2"}, ids)
+
+	session, err := store.Load(ctx, "session-1")
+	require.NoError(t, err)
+	require.Equal(t, "test", session.Model)
+	require.Equal(t, "be brief", session.System)
+	require.Equal(t, conv.History(), session.Messages)
+
+	require.NoError(t, store.Delete(ctx, "session-1"))
+	_, err = store.Load(ctx, "session-1")
+	require.ErrorIs(t, err, ollamago.ErrSessionNotFound)
+	require.ErrorIs(t, store.Delete(ctx, "session-1"), ollamago.ErrSessionNotFound)
+
+	rows["broken"] = "{"
+	_, err = store.Load(ctx, "broken")
+	require.ErrorContains(t, err, "cannot decode session")
+	locked := &ollamago.SQLiteStore{DB: db, Table: "locked"}
+	require.EqualError(t, locked.Save(ctx, conv.Snapshot("session-3")), "cannot save session: database is locked")
+	_, err = locked.List(ctx)
+	require.EqualError(t, err, "cannot list sessions: database is locked")
+
+	sent := len(statements)
+	invalid := &ollamago.SQLiteStore{DB: db, Table: "sessions; DROP TABLE users"}
+	require.ErrorContains(t, invalid.Init(ctx), "invalid table name")
+	require.ErrorContains(t, invalid.Delete(ctx, "session-2"), "invalid table name")
+	require.Len(t, statements, sent, "nothing is sent for invalid tables")
+}
@@ -0,0 +1,213 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sync"
+)
+
+// OverflowPolicy decides what happens to the chunks of a chat or
+// completion stream when its consumer lags behind and Client.StreamBuffer
+// chunks are already pending.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock stops reading the response until the consumer
+	// catches up.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropOldest discards the oldest pending chunk, losing its
+	// content. The final chunk and errors are never discarded.
+	OverflowDropOldest
+
+	// OverflowCoalesce merges the new chunk into the newest pending one,
+	// concatenating their contents, so that no content is lost.
+	OverflowCoalesce
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "block"
+	case OverflowDropOldest:
+		return "drop-oldest"
+	case OverflowCoalesce:
+		return "coalesce"
+	}
+	return "unknown"
+}
+
+// streamChannel returns the channel of a stream, the function its reader
+// sends the chunks with, and the function that ends it. merge joins two
+// chunks, reporting false if either is a final or failed one.
+func streamChannel[T any](c *Client, merge func(a, b T) (T, bool)) (<-chan T, func(T), func()) {
+	if c.StreamOverflow == OverflowBlock {
+		out := make(chan T, max(c.StreamBuffer, 0))
+		return out, func(v T) { out <- v }, func() { close(out) }
+	}
+	q := &streamQueue[T]{
+		out:    make(chan T),
+		wake:   make(chan struct{}, 1),
+		limit:  max(c.StreamBuffer, 1),
+		policy: c.StreamOverflow,
+		merge:  merge,
+	}
+	go q.pump()
+	return q.out, q.push, q.close
+}
+
+// streamQueue holds the pending chunks of a stream, so that reading the
+// response never waits on the consumer.
+type streamQueue[T any] struct {
+	out    chan T
+	wake   chan struct{}
+	limit  int
+	policy OverflowPolicy
+	merge  func(a, b T) (T, bool)
+
+	mu      sync.Mutex
+	pending []T
+	closed  bool
+}
+
+func (q *streamQueue[T]) push(v T) {
+	q.mu.Lock()
+	q.enqueue(v)
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *streamQueue[T]) enqueue(v T) {
+	if len(q.pending) < q.limit {
+		q.pending = append(q.pending, v)
+		return
+	}
+	// Final chunks, and whatever follows them, overflow the limit rather
+	// than push out or absorb another chunk.
+	last := len(q.pending) - 1
+	switch q.policy {
+	case OverflowDropOldest:
+		if _, ok := q.merge(q.pending[last], v); ok {
+			q.pending = q.pending[1:]
+		}
+	case OverflowCoalesce:
+		if merged, ok := q.merge(q.pending[last], v); ok {
+			q.pending[last] = merged
+			return
+		}
+	}
+	q.pending = append(q.pending, v)
+}
+
+func (q *streamQueue[T]) close() {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+	q.signal()
+}
+
+func (q *streamQueue[T]) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *streamQueue[T]) pump() {
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			closed := q.closed
+			q.mu.Unlock()
+			if closed {
+				close(q.out)
+				return
+			}
+			<-q.wake
+			continue
+		}
+		v := q.pending[0]
+		q.pending = q.pending[1:]
+		q.mu.Unlock()
+		q.out <- v
+	}
+}
+
+func mergeChat(a, b ChatResponse) (ChatResponse, bool) {
+	if a.Done || b.Done || a.Error != nil || b.Error != nil {
+		return a, false
+	}
+	a.Message.Content += b.Message.Content
+	a.Message.ToolCalls = append(a.Message.ToolCalls[:len(a.Message.ToolCalls):len(a.Message.ToolCalls)], b.Message.ToolCalls...)
+	a.ToolCallDeltas = append(a.ToolCallDeltas[:len(a.ToolCallDeltas):len(a.ToolCallDeltas)], b.ToolCallDeltas...)
+	a.Logprobs = append(a.Logprobs[:len(a.Logprobs):len(a.Logprobs)], b.Logprobs...)
+	return a, true
+}
+
+func mergeCompletion(a, b CompletionResponse) (CompletionResponse, bool) {
+	if a.Done || b.Done || a.Error != nil || b.Error != nil {
+		return a, false
+	}
+	a.Response += b.Response
+	a.Logprobs = append(a.Logprobs[:len(a.Logprobs):len(a.Logprobs)], b.Logprobs...)
+	return a, true
+}
+
+// readerPool holds the buffered readers of streamed responses, which
+// would otherwise be allocated for every call.
+var readerPool = sync.Pool{
+	New: func() any {
+		return bufio.NewReaderSize(nil, 16<<10)
+	},
+}
+
+// readNDJSON calls fn with each line of the newline-delimited JSON read
+// from r, skipping blank lines, until fn or reading fails. The line is
+// only valid until fn returns. It returns nil at the end of r.
+func readNDJSON(r io.Reader, fn func(line []byte) error) error {
+	br := readerPool.Get().(*bufio.Reader)
+	br.Reset(r)
+	defer func() {
+		br.Reset(nil)
+		readerPool.Put(br)
+	}()
+	var long []byte
+	for {
+		line, err := br.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// Lines longer than the buffer, such as those of large
+			// tool calls, are assembled apart.
+			long = append(long[:0], line...)
+			for err == bufio.ErrBufferFull {
+				line, err = br.ReadSlice('\n')
+				long = append(long, line...)
+			}
+			line = long
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if fnErr := fn(line); fnErr != nil {
+				return fnErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+type closeNotifier struct {
+	io.ReadCloser
+	closed chan struct{}
+}
+
+func (c *closeNotifier) Close() error {
+	defer close(c.closed)
+	return c.ReadCloser.Close()
+}
+
+func TestStreamOverflow(t *testing.T) {
+	chunks := strings.Split("abcdefghij", "")
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "test", Chunks: chunks})
+	t.Cleanup(srv.Close)
+
+	for _, policy := range []ollamago.OverflowPolicy{ollamago.OverflowBlock, ollamago.OverflowDropOldest, ollamago.OverflowCoalesce} {
+		t.Run(policy.String(), func(t *testing.T) {
+			read := make(chan struct{})
+			client := srv.Client()
+			client.StreamBuffer = 2
+			client.StreamOverflow = policy
+			client.Interceptors = []ollamago.Interceptor{func(next http.RoundTripper) http.RoundTripper {
+				return ollamago.RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+					resp, err := next.RoundTrip(req)
+					if err == nil {
+						resp.Body = &closeNotifier{ReadCloser: resp.Body, closed: read}
+					}
+					return resp, err
+				})
+			}}
+			resp, err := client.GenerateChat(context.Background(), ollamago.ChatRequest{
+				Model:    "test",
+				Messages: []ollamago.ChatMessage{ollamago.UserMessage("hi")},
+				Stream:   true,
+			})
+			require.NoError(t, err)
+			if policy != ollamago.OverflowBlock {
+				// The response is read without waiting for the consumer.
+				<-read
+			}
+			var (
+				content string
+				n       int
+				done    bool
+			)
+			for r := range resp {
+				require.NoError(t, r.Error)
+				content += r.Message.Content
+				done = done || r.Done
+				n++
+			}
+			require.True(t, done)
+			switch policy {
+			case ollamago.OverflowBlock:
+				require.Equal(t, "abcdefghij", content)
+			case ollamago.OverflowDropOldest:
+				require.True(t, strings.HasSuffix(content, "ij"), content)
+				require.Less(t, len(content), len(chunks))
+			case ollamago.OverflowCoalesce:
+				require.Equal(t, "abcdefghij", content)
+				require.Less(t, n, len(chunks))
+			}
+		})
+	}
+}
+
+func TestStreamLongLines(t *testing.T) {
+	long := strings.Repeat("x", 100<<10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "{\"message\":{\"role\":\"assistant\",\"content\":%q}}\n\n", long)
+		fmt.Fprint(w, `{"message":{"role":"assistant","content":"!"},"done":true}`)
+	}))
+	t.Cleanup(server.Close)
+	client := ollamago.Client{BaseURL: server.URL}
+	resp, err := client.GenerateChat(context.Background(), ollamago.ChatRequest{
+		Model:    "test",
+		Messages: []ollamago.ChatMessage{ollamago.UserMessage("hi")},
+	})
+	require.NoError(t, err)
+	var content string
+	done := false
+	for r := range resp {
+		require.NoError(t, r.Error)
+		content += r.Message.Content
+		done = done || r.Done
+	}
+	require.True(t, done)
+	require.Equal(t, long+"!", content)
+}
@@ -0,0 +1,137 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// StructuredOption configures ChatInto.
+type StructuredOption func(*structuredConfig)
+
+type structuredConfig struct {
+	retries  int
+	repair   bool
+	feedback func(reply string, err error) string
+}
+
+// WithRetries makes ChatInto retry up to n times when the reply does not
+// decode or validate. Each retry appends the failed reply and the error to
+// the conversation so the model can correct itself.
+func WithRetries(n int) StructuredOption {
+	return func(c *structuredConfig) {
+		c.retries = n
+	}
+}
+
+// WithJSONRepair makes ChatInto run RepairJSON over replies that fail to
+// decode before giving up on them.
+func WithJSONRepair() StructuredOption {
+	return func(c *structuredConfig) {
+		c.repair = true
+	}
+}
+
+// WithFeedback sets the message sent to the model after an invalid reply
+// when retrying. It receives the rejected reply and the decoding or
+// validation error.
+func WithFeedback(feedback func(reply string, err error) string) StructuredOption {
+	return func(c *structuredConfig) {
+		c.feedback = feedback
+	}
+}
+
+func defaultFeedback(reply string, err error) string {
+	return fmt.Sprintf("Your reply is not valid: %v. Reply again with JSON matching the schema.", err)
+}
+
+// Validator is implemented by types that check their own consistency after
+// being decoded by ChatInto.
+type Validator interface {
+	Validate() error
+}
+
+// ErrInvalidOutput is wrapped by the error returned from ChatInto when the
+// model reply cannot be decoded into the requested type.
+var ErrInvalidOutput = errors.New("invalid structured output")
+
+// ChatInto sends a chat request whose Format is set to the JSON schema of
+// T and decodes the model reply into a T. If T implements Validator, the
+// decoded value is validated as well.
+func ChatInto[T any](ctx context.Context, client API, req ChatRequest, opts ...StructuredOption) (T, error) {
+	req.Format = Schema(reflect.TypeFor[T]())
+	return chatStructured(ctx, client, req, opts, func(content string, repair bool) (T, error) {
+		return decodeStructured[T](content, repair)
+	})
+}
+
+// chatStructured sends req and decodes the reply with decode, retrying as
+// configured by opts.
+func chatStructured[T any](ctx context.Context, client API, req ChatRequest, opts []StructuredOption, decode func(content string, repair bool) (T, error)) (T, error) {
+	cfg := structuredConfig{feedback: defaultFeedback}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	var zero T
+	req.Messages = append([]ChatMessage(nil), req.Messages...)
+	for attempt := 0; ; attempt++ {
+		resp, err := client.GenerateChat(ctx, req)
+		if err != nil {
+			return zero, fmt.Errorf("cannot generate structured output: %w", err)
+		}
+		reply, err := collectChat(resp)
+		if err != nil {
+			return zero, fmt.Errorf("cannot generate structured output: %w", err)
+		}
+		v, err := decode(reply.Content, cfg.repair)
+		if err == nil {
+			return v, nil
+		}
+		if attempt >= cfg.retries {
+			return zero, err
+		}
+		req.Messages = append(req.Messages,
+			ChatMessage{Role: RoleAssistant, Content: reply.Content},
+			ChatMessage{Role: RoleUser, Content: cfg.feedback(reply.Content, err)},
+		)
+	}
+}
+
+func decodeStructured[T any](content string, repair bool) (T, error) {
+	var v T
+	if err := json.Unmarshal([]byte(content), &v); err != nil {
+		if !repair {
+			return v, fmt.Errorf("%w: %w", ErrInvalidOutput, err)
+		}
+		v = *new(T)
+		if repairErr := json.Unmarshal([]byte(RepairJSON(content)), &v); repairErr != nil {
+			return v, fmt.Errorf("%w: %w", ErrInvalidOutput, err)
+		}
+	}
+	if val, ok := any(&v).(Validator); ok {
+		if err := val.Validate(); err != nil {
+			return v, fmt.Errorf("%w: %w", ErrInvalidOutput, err)
+		}
+	} else if val, ok := any(v).(Validator); ok {
+		if err := val.Validate(); err != nil {
+			return v, fmt.Errorf("%w: %w", ErrInvalidOutput, err)
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamagotest"
+	"github.com/stretchr/testify/require"
+)
+
+type person struct {
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func (p person) Validate() error {
+	if p.Age < 0 {
+		return errors.New("age must not be negative")
+	}
+	return nil
+}
+
+func scriptedChat(replies ...string) *ollamagotest.MockClient {
+	var n int
+	return &ollamagotest.MockClient{
+		GenerateChatFunc: func(ctx context.Context, req ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error) {
+			reply := replies[min(n, len(replies)-1)]
+			n++
+			return ollamagotest.Stream(ollamago.ChatResponse{
+				Message: ollamago.ChatMessage{Role: "assistant", Content: reply},
+				Done:    true,
+			}), nil
+		},
+	}
+}
+
+func TestChatInto(t *testing.T) {
+	mock := scriptedChat(`{"name":"Ada","age":36}`)
+	p, err := ollamago.ChatInto[person](context.Background(), mock, ollamago.ChatRequest{
+		Model:    "test",
+		Messages: []ollamago.ChatMessage{{Role: "user", Content: "Ada Lovelace, 36"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, person{Name: "Ada", Age: 36}, p)
+	req := mock.CallsTo("GenerateChat")[0].Request.(ollamago.ChatRequest)
+	require.JSONEq(t, `{
+		"type": "object",
+		"properties": {"name": {"type": "string"}, "age": {"type": "integer"}},
+		"required": ["name", "age"]
+	}`, string(req.Format))
+}
+
+func TestChatIntoRetries(t *testing.T) {
+	mock := scriptedChat(`not json`, `{"name":"Ada","age":-1}`, `{"name":"Ada","age":36}`)
+	req := ollamago.ChatRequest{
+		Model:    "test",
+		Messages: []ollamago.ChatMessage{{Role: "user", Content: "Ada Lovelace, 36"}},
+	}
+	_, err := ollamago.ChatInto[person](context.Background(), mock, req, ollamago.WithRetries(1))
+	require.ErrorIs(t, err, ollamago.ErrInvalidOutput)
+	require.ErrorContains(t, err, "age must not be negative")
+
+	mock = scriptedChat(`not json`, `{"name":"Ada","age":36}`)
+	p, err := ollamago.ChatInto[person](context.Background(), mock, req, ollamago.WithRetries(2))
+	require.NoError(t, err)
+	require.Equal(t, 36, p.Age)
+	retry := mock.CallsTo("GenerateChat")[1].Request.(ollamago.ChatRequest)
+	require.Len(t, retry.Messages, 3)
+	require.Equal(t, "not json", retry.Messages[1].Content)
+	require.Contains(t, retry.Messages[2].Content, "not valid")
+}
@@ -0,0 +1,201 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"cirello.io/ollamago/textsplit"
+)
+
+// SummarizeOption configures Summarize.
+type SummarizeOption func(*summarizeConfig)
+
+type summarizeConfig struct {
+	chunkTokens int
+	concurrency int
+	style       string
+	words       int
+	options     ModelParameters
+	tokens      func(string) int
+}
+
+// WithChunkTokens sets the size, in tokens, of the pieces summarized in a
+// single request. It must leave room in the context window of the model
+// for the instructions and the summary. The default is 2048.
+func WithChunkTokens(n int) SummarizeOption {
+	return func(c *summarizeConfig) {
+		c.chunkTokens = n
+	}
+}
+
+// WithSummaryConcurrency sets how many pieces are summarized at once. The
+// default is 4.
+func WithSummaryConcurrency(n int) SummarizeOption {
+	return func(c *summarizeConfig) {
+		c.concurrency = n
+	}
+}
+
+// WithSummaryStyle describes the summary to write, such as "a bulleted
+// list of the key decisions".
+func WithSummaryStyle(style string) SummarizeOption {
+	return func(c *summarizeConfig) {
+		c.style = style
+	}
+}
+
+// WithSummaryLength asks for a final summary of about the given number of
+// words.
+func WithSummaryLength(words int) SummarizeOption {
+	return func(c *summarizeConfig) {
+		c.words = words
+	}
+}
+
+// WithSummaryOptions sets the model parameters of the summarization
+// requests.
+func WithSummaryOptions(options ModelParameters) SummarizeOption {
+	return func(c *summarizeConfig) {
+		c.options = options
+	}
+}
+
+// WithSummaryTokens sets the function counting the tokens of a text. The
+// default is textsplit.ApproxTokens.
+func WithSummaryTokens(tokens func(string) int) SummarizeOption {
+	return func(c *summarizeConfig) {
+		c.tokens = tokens
+	}
+}
+
+// maxSummaryDepth bounds the rounds of reduction, in case the model writes
+// summaries longer than their input.
+const maxSummaryDepth = 8
+
+// Summarize summarizes a document of any length. The document is split
+// into pieces that fit the context window, which are summarized
+// concurrently (map); the partial summaries are then summarized together,
+// recursively, until a single summary remains (reduce).
+func Summarize(ctx context.Context, client API, model string, r io.Reader, opts ...SummarizeOption) (string, error) {
+	cfg := summarizeConfig{
+		chunkTokens: 2048,
+		concurrency: 4,
+		tokens:      textsplit.ApproxTokens,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	cfg.chunkTokens = max(cfg.chunkTokens, 1)
+	cfg.concurrency = max(cfg.concurrency, 1)
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", fmt.Errorf("cannot read document: %w", err)
+	}
+	splitter := textsplit.Token{ChunkSize: cfg.chunkTokens, Tokens: cfg.tokens}
+	text := strings.TrimSpace(string(b))
+	if text == "" {
+		return "", errors.New("cannot summarize an empty document")
+	}
+	partial := false
+	for depth := 0; ; depth++ {
+		if cfg.tokens(text) <= cfg.chunkTokens {
+			return summarizeChunk(ctx, client, model, text, cfg, partial, true)
+		}
+		if depth == maxSummaryDepth {
+			return "", fmt.Errorf("cannot reduce summaries below %d tokens", cfg.chunkTokens)
+		}
+		summaries, err := summarizeChunks(ctx, client, model, splitter.Split(text), cfg, partial)
+		if err != nil {
+			return "", err
+		}
+		text, partial = strings.Join(summaries, "\n\n"), true
+	}
+}
+
+// summarizeChunks summarizes the chunks concurrently, keeping their order.
+func summarizeChunks(ctx context.Context, client API, model string, chunks []string, cfg summarizeConfig, partial bool) ([]string, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	summaries := make([]string, len(chunks))
+	sem := make(chan struct{}, cfg.concurrency)
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			summary, err := summarizeChunk(ctx, client, model, chunk, cfg, partial, false)
+			if err != nil {
+				cancel(fmt.Errorf("cannot summarize part %d of %d: %w", i+1, len(chunks), err))
+				return
+			}
+			summaries[i] = summary
+		}()
+	}
+	wg.Wait()
+	if err := context.Cause(ctx); err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+func summarizeChunk(ctx context.Context, client API, model, text string, cfg summarizeConfig, partial, final bool) (string, error) {
+	var prompt strings.Builder
+	if partial {
+		prompt.WriteString("The following are summaries of consecutive parts of a document. Combine them into a single summary of the document")
+	} else if final {
+		prompt.WriteString("Summarize the following document")
+	} else {
+		prompt.WriteString("Summarize the following part of a longer document")
+	}
+	prompt.WriteString(", keeping the key facts, names and figures.")
+	if cfg.style != "" {
+		fmt.Fprintf(&prompt, " Write %s.", cfg.style)
+	}
+	if final && cfg.words > 0 {
+		fmt.Fprintf(&prompt, " Use about %d words.", cfg.words)
+	}
+	prompt.WriteString(" Reply with the summary only.")
+	resp, err := client.GenerateChat(ctx, ChatRequest{
+		Model: model,
+		Messages: []ChatMessage{
+			SystemMessage(prompt.String()),
+			UserMessage(text),
+		},
+		Stream:  true,
+		Options: cfg.options,
+	})
+	if err != nil {
+		return "", err
+	}
+	reply, err := collectChat(resp)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(reply.Content), nil
+}
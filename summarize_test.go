@@ -0,0 +1,89 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamagotest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummarize(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		prompts []string
+	)
+	// The fake model summarizes a text as its first word.
+	mock := &ollamagotest.MockClient{
+		GenerateChatFunc: func(ctx context.Context, req ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error) {
+			mu.Lock()
+			prompts = append(prompts, req.Messages[0].Content)
+			mu.Unlock()
+			first := strings.Fields(req.Messages[1].Content)[0]
+			return ollamagotest.Stream(ollamago.ChatResponse{
+				Message: ollamago.ChatMessage{Role: ollamago.RoleAssistant, Content: first},
+				Done:    true,
+			}), nil
+		},
+	}
+	words := func(s string) int { return len(strings.Fields(s)) }
+	var doc strings.Builder
+	for i := range 27 {
+		doc.WriteString(strings.Repeat(string(rune('a'+i%26)), i+1) + " ")
+	}
+
+	summary, err := ollamago.Summarize(context.Background(), mock, "test", strings.NewReader(doc.String()),
+		ollamago.WithChunkTokens(3),
+		ollamago.WithSummaryTokens(words),
+		ollamago.WithSummaryStyle("a haiku"),
+		ollamago.WithSummaryLength(17),
+	)
+	require.NoError(t, err)
+	require.Equal(t, "a", summary)
+	// 27 words map to 9 summaries, reduced to 3 and then to the final one.
+	require.Len(t, prompts, 9+3+1)
+	for _, p := range prompts {
+		require.Contains(t, p, "Write a haiku.")
+	}
+	require.Contains(t, prompts[len(prompts)-1], "Combine them")
+	require.Contains(t, prompts[len(prompts)-1], "Use about 17 words.")
+	require.NotContains(t, prompts[0], "Use about 17 words.")
+
+	prompts = nil
+	summary, err = ollamago.Summarize(context.Background(), mock, "test", strings.NewReader("short document"))
+	require.NoError(t, err)
+	require.Equal(t, "short", summary)
+	require.Len(t, prompts, 1)
+	require.Contains(t, prompts[0], "Summarize the following document")
+
+	_, err = ollamago.Summarize(context.Background(), mock, "test", strings.NewReader("  "))
+	require.Error(t, err)
+
+	errBoom := errors.New("boom")
+	failing := &ollamagotest.MockClient{
+		GenerateChatFunc: func(ctx context.Context, req ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error) {
+			return nil, errBoom
+		},
+	}
+	_, err = ollamago.Summarize(context.Background(), failing, "test", strings.NewReader(doc.String()),
+		ollamago.WithChunkTokens(3), ollamago.WithSummaryTokens(words))
+	require.ErrorIs(t, err, errBoom)
+}
@@ -0,0 +1,348 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// SyncReport lists what SyncModels did with each model.
+type SyncReport struct {
+	// UpToDate lists the models the destination already had with the
+	// same digest.
+	UpToDate []string
+
+	// Pulled lists the models pulled from the registry.
+	Pulled []string
+
+	// Transferred lists the models copied layer by layer from the model
+	// directory of the source because the registry does not have them.
+	Transferred []string
+
+	// Created lists the models recreated from their definition on the
+	// source because the registry does not have them.
+	Created []string
+}
+
+// SyncModels makes the destination hold the named models, or every model
+// of the source if none is named, with the digests the source has.
+// Missing or outdated models are pulled from the registry by the
+// destination. Models the registry does not know, such as those derived
+// locally, are recreated from their definition on the source on top of
+// their parent model, which is pulled if needed. A model is only as
+// current as the registry: if it changed since the source pulled it, the
+// destination gets the newer version.
+//
+// Failing models do not stop the synchronization of the others; their
+// errors are joined in the returned error.
+//
+// Models with neither a registry entry nor a parent, such as those created
+// from local weights, can only be synchronized by SyncModelsWithBlobs.
+func SyncModels(ctx context.Context, source, dest API, names ...string) (*SyncReport, error) {
+	return SyncModelsWithBlobs(ctx, source, dest, nil, names...)
+}
+
+// SyncModelsWithBlobs is SyncModels transferring the models the registry
+// does not have layer by layer: their manifest is read from models, the
+// model directory of the source, such as os.DirFS of ~/.ollama/models,
+// the missing layers are uploaded to dest, which must be a *Client, and
+// the model is created on dest from them. Models that cannot be
+// transferred are recreated from their parent. If models is nil, it
+// behaves as SyncModels.
+func SyncModelsWithBlobs(ctx context.Context, source, dest API, models fs.FS, names ...string) (*SyncReport, error) {
+	srcModels, err := source.ListModels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list source models: %w", err)
+	}
+	dstModels, err := dest.ListModels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list destination models: %w", err)
+	}
+	srcDigests := make(map[string]string)
+	for _, m := range srcModels.Models {
+		srcDigests[withTag(m.Name)] = m.Digest
+	}
+	if len(names) == 0 {
+		for _, m := range srcModels.Models {
+			names = append(names, m.Name)
+		}
+	}
+	dstDigests := make(map[string]string)
+	for _, m := range dstModels.Models {
+		dstDigests[withTag(m.Name)] = m.Digest
+	}
+
+	report := &SyncReport{}
+	var errs, pending []error
+	var unpulled []string
+	for _, name := range names {
+		digest, ok := srcDigests[withTag(name)]
+		switch {
+		case !ok:
+			errs = append(errs, fmt.Errorf("cannot sync %s: not found on the source", name))
+		case dstDigests[withTag(name)] == digest:
+			report.UpToDate = append(report.UpToDate, name)
+		default:
+			progress, err := dest.PullModel(ctx, PullModelRequest{Model: name})
+			if err == nil {
+				err = waitProgress(ctx, progress, nil)
+			}
+			if err == nil {
+				report.Pulled = append(report.Pulled, name)
+				continue
+			}
+			if ctx.Err() != nil {
+				return report, ctx.Err()
+			}
+			unpulled = append(unpulled, name)
+			pending = append(pending, err)
+		}
+	}
+	// Models are recreated after the pulls, so that their parents are
+	// already in place when they are synchronized too.
+	for i, name := range unpulled {
+		if models != nil {
+			err := transferModel(ctx, models, dest, name)
+			if err == nil {
+				report.Transferred = append(report.Transferred, name)
+				continue
+			}
+			if ctx.Err() != nil {
+				return report, ctx.Err()
+			}
+			pending[i] = errors.Join(pending[i], err)
+		}
+		if err := recreateModel(ctx, source, dest, name); err != nil {
+			errs = append(errs, fmt.Errorf("cannot sync %s: %w", name, errors.Join(pending[i], err)))
+			continue
+		}
+		report.Created = append(report.Created, name)
+	}
+	return report, errors.Join(errs...)
+}
+
+// blobStore is the part of Client that transferModel uploads layers to.
+type blobStore interface {
+	BlobExists(ctx context.Context, digest string) (bool, error)
+	CreateBlob(ctx context.Context, digest string, content io.Reader) error
+}
+
+// manifest is the list of layers of a model in an Ollama model directory.
+type manifest struct {
+	Layers []struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+	} `json:"layers"`
+}
+
+// transferModel creates a model on dest from its layers in models, the
+// model directory of the source.
+func transferModel(ctx context.Context, models fs.FS, dest API, name string) error {
+	blobs, ok := dest.(blobStore)
+	if !ok {
+		return fmt.Errorf("cannot transfer %s: the destination does not take blobs", name)
+	}
+	data, err := fs.ReadFile(models, manifestPath(name))
+	if err != nil {
+		return fmt.Errorf("cannot read manifest: %w", err)
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("cannot decode manifest: %w", err)
+	}
+	req := CreateModelRequest{Model: name}
+	for _, layer := range m.Layers {
+		blob := "blobs/" + strings.Replace(layer.Digest, ":", "-", 1)
+		switch strings.TrimPrefix(layer.MediaType, "application/vnd.ollama.image.") {
+		case "model", "projector", "adapter":
+			if err := uploadBlob(ctx, blobs, models, blob, layer.Digest); err != nil {
+				return err
+			}
+			if layer.MediaType == "application/vnd.ollama.image.adapter" {
+				req.Adapters = addFile(req.Adapters, "adapter", layer.Digest)
+			} else {
+				req.Files = addFile(req.Files, "model", layer.Digest)
+			}
+			continue
+		}
+		content, err := fs.ReadFile(models, blob)
+		if err != nil {
+			return fmt.Errorf("cannot read layer: %w", err)
+		}
+		switch layer.MediaType {
+		case "application/vnd.ollama.image.template":
+			req.Template = string(content)
+		case "application/vnd.ollama.image.system":
+			req.System = string(content)
+		case "application/vnd.ollama.image.license":
+			req.License = append(req.License, string(content))
+		case "application/vnd.ollama.image.params":
+			err = json.Unmarshal(content, &req.Parameters)
+		case "application/vnd.ollama.image.messages":
+			err = json.Unmarshal(content, &req.Messages)
+		default:
+			err = fmt.Errorf("unsupported layer type %q", layer.MediaType)
+		}
+		if err != nil {
+			return fmt.Errorf("cannot transfer layer %s: %w", layer.Digest, err)
+		}
+	}
+	if len(req.Files) == 0 {
+		return fmt.Errorf("%s has no weights to transfer", name)
+	}
+	progress, err := dest.CreateModel(ctx, req)
+	if err != nil {
+		return err
+	}
+	return waitProgress(ctx, progress, nil)
+}
+
+// manifestPath returns the path of the manifest of a model in an Ollama
+// model directory, the host defaulting to the registry, the namespace to
+// "library" and the tag to "latest".
+func manifestPath(name string) string {
+	name = withTag(name)
+	i := strings.LastIndex(name, ":")
+	parts := strings.Split(name[:i], "/")
+	switch len(parts) {
+	case 1:
+		parts = []string{"registry.ollama.ai", "library", parts[0]}
+	case 2:
+		parts = append([]string{"registry.ollama.ai"}, parts...)
+	}
+	return path.Join("manifests", path.Join(parts...), name[i+1:])
+}
+
+// uploadBlob uploads the blob file of models unless blobs has it.
+func uploadBlob(ctx context.Context, blobs blobStore, models fs.FS, blob, digest string) error {
+	exists, err := blobs.BlobExists(ctx, digest)
+	if err != nil || exists {
+		return err
+	}
+	f, err := models.Open(blob)
+	if err != nil {
+		return fmt.Errorf("cannot read layer: %w", err)
+	}
+	defer f.Close()
+	return blobs.CreateBlob(ctx, digest, f)
+}
+
+// addFile adds digest to files under a name made of prefix, unique among
+// files.
+func addFile(files map[string]string, prefix, digest string) map[string]string {
+	if files == nil {
+		files = make(map[string]string)
+	}
+	name := prefix + ".gguf"
+	for i := 1; files[name] != ""; i++ {
+		name = prefix + strconv.Itoa(i) + ".gguf"
+	}
+	files[name] = digest
+	return files
+}
+
+// recreateModel creates a model on dest from its definition on source.
+func recreateModel(ctx context.Context, source, dest API, name string) error {
+	show, err := source.ShowModelInfo(ctx, ShowModelRequest{Model: name})
+	if err != nil {
+		return err
+	}
+	parent := show.Details.ParentModel
+	if parent == "" {
+		return fmt.Errorf("%s has no parent model to recreate it from", name)
+	}
+	if err := EnsureModel(ctx, dest, parent); err != nil {
+		return err
+	}
+	req := CreateModelRequest{
+		Model:      name,
+		From:       parent,
+		Template:   show.Template,
+		System:     show.System,
+		Parameters: parseParameters(show.Parameters),
+		Messages:   show.Messages,
+		Modelfile:  rebase(show.Modelfile, parent),
+	}
+	if show.License != "" {
+		req.License = []string{show.License}
+	}
+	progress, err := dest.CreateModel(ctx, req)
+	if err != nil {
+		return err
+	}
+	return waitProgress(ctx, progress, nil)
+}
+
+// parseParameters parses the parameters listed by /api/show, one "name
+// value" pair per line.
+func parseParameters(s string) map[string]any {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	params := make(map[string]any)
+	for _, line := range lines {
+		name, raw, _ := strings.Cut(strings.TrimSpace(line), " ")
+		raw = strings.TrimSpace(raw)
+		var value any = raw
+		if unquoted, err := strconv.Unquote(raw); err == nil {
+			value = unquoted
+		} else if f, err := strconv.ParseFloat(raw, 64); err == nil {
+			value = f
+		} else if b, err := strconv.ParseBool(raw); err == nil {
+			value = b
+		}
+		switch prev := params[name].(type) {
+		case nil:
+			if name == "stop" {
+				value = []any{value}
+			}
+			params[name] = value
+		case []any:
+			params[name] = append(prev, value)
+		default:
+			params[name] = []any{prev, value}
+		}
+	}
+	return params
+}
+
+// rebase replaces the FROM instruction of a Modelfile, which names a blob
+// path on the source, with the parent model.
+func rebase(modelfile, parent string) string {
+	if modelfile == "" {
+		return ""
+	}
+	lines := strings.Split(modelfile, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(strings.ToUpper(line), "FROM ") {
+			lines[i] = "FROM " + parent
+		}
+	}
+	return strings.Join(lines, "\n")
+}
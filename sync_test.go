@@ -0,0 +1,97 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncModels(t *testing.T) {
+	base := ollamatest.Model{Name: "base", Digest: "b1"}
+	same := ollamatest.Model{Name: "same", Digest: "s1"}
+	stale := ollamatest.Model{Name: "stale", Digest: "new"}
+	source := ollamatest.NewServer(base, same, stale)
+	t.Cleanup(source.Close)
+	dest := ollamatest.NewServer(same, ollamatest.Model{Name: "stale", Digest: "old"})
+	t.Cleanup(dest.Close)
+	for _, m := range []ollamatest.Model{base, stale} {
+		dest.AddRegistryModel(m)
+	}
+	ctx := context.Background()
+
+	src, dst := source.Client(), dest.Client()
+	mf := new(ollamago.Modelfile).From("base").System("You are derived.").Parameter("stop", "###").Parameter("num_ctx", 2048)
+	progress, err := src.CreateModel(ctx, mf.Request("derived"))
+	require.NoError(t, err)
+	for range progress {
+	}
+
+	report, err := ollamago.SyncModels(ctx, src, dst)
+	require.NoError(t, err)
+	require.Equal(t, &ollamago.SyncReport{
+		UpToDate: []string{"same"},
+		Pulled:   []string{"base", "stale"},
+		Created:  []string{"derived"},
+	}, report)
+
+	show, err := dst.ShowModelInfo(ctx, ollamago.ShowModelRequest{Model: "derived"})
+	require.NoError(t, err)
+	require.Equal(t, "You are derived.", show.System)
+	require.Equal(t, "num_ctx 2048\nstop ###", show.Parameters)
+	require.Equal(t, "base", show.Details.ParentModel)
+
+	report, err = ollamago.SyncModels(ctx, src, dst, "same", "missing")
+	require.ErrorContains(t, err, "missing: not found on the source")
+	require.Equal(t, []string{"same"}, report.UpToDate)
+}
+
+func TestSyncModelsWithBlobs(t *testing.T) {
+	source := ollamatest.NewServer(ollamatest.Model{Name: "local:latest", Digest: "l1"}, ollamatest.Model{Name: "orphan:latest", Digest: "o1"})
+	t.Cleanup(source.Close)
+	dest := ollamatest.NewServer()
+	t.Cleanup(dest.Close)
+
+	models := fstest.MapFS{}
+	layer := func(mediaType, content string) string {
+		digest := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(content)))
+		models["blobs/"+strings.Replace(digest, ":", "-", 1)] = &fstest.MapFile{Data: []byte(content)}
+		return fmt.Sprintf(`{"mediaType":"application/vnd.ollama.image.%s","digest":%q}`, mediaType, digest)
+	}
+	weights := layer("model", "GGUF weights")
+	models["manifests/registry.ollama.ai/library/local/latest"] = &fstest.MapFile{Data: []byte(`{"schemaVersion":2,"layers":[` +
+		weights + "," + layer("system", "You are local.") + "," + layer("params", `{"num_ctx":2048}`) + `]}`)}
+
+	ctx := context.Background()
+	report, err := ollamago.SyncModelsWithBlobs(ctx, source.Client(), dest.Client(), models)
+	require.ErrorContains(t, err, "cannot sync orphan:latest: ")
+	require.ErrorContains(t, err, "cannot read manifest")
+	require.ErrorContains(t, err, "orphan:latest has no parent model")
+	require.Equal(t, &ollamago.SyncReport{Transferred: []string{"local:latest"}}, report)
+	require.Equal(t, []string{fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("GGUF weights")))}, dest.Blobs())
+
+	show, err := dest.Client().ShowModelInfo(ctx, ollamago.ShowModelRequest{Model: "local"})
+	require.NoError(t, err)
+	require.Equal(t, "You are local.", show.System)
+	require.Equal(t, "num_ctx 2048", show.Parameters)
+}
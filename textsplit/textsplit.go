@@ -0,0 +1,306 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package textsplit splits documents into chunks suitable for embedding,
+// for instance with ollamago.EmbedBatch.
+package textsplit
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Splitter splits a text into chunks.
+type Splitter interface {
+	Split(text string) []string
+}
+
+var (
+	_ Splitter = Recursive{}
+	_ Splitter = Sentence{}
+	_ Splitter = Markdown{}
+	_ Splitter = Token{}
+)
+
+// DefaultChunkSize is the chunk size used when a splitter's ChunkSize is
+// zero.
+const DefaultChunkSize = 1000
+
+// DefaultSeparators are the separators used by Recursive, from the
+// coarsest to the finest.
+var DefaultSeparators = []string{"\n\n", "\n", " ", ""}
+
+// MarkdownSeparators are the separators used by Markdown: headings first,
+// then code fences and horizontal rules, then the DefaultSeparators.
+var MarkdownSeparators = []string{
+	"\n# ", "\n## ", "\n### ", "\n#### ", "\n##### ", "\n###### ",
+	"\n```", "\n---\n", "\n\n", "\n", " ", "",
+}
+
+// Characters measures text in characters (runes).
+func Characters(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// ApproxTokens estimates the number of tokens of a text, assuming about
+// four characters per token.
+func ApproxTokens(s string) int {
+	return (utf8.RuneCountInString(s) + 3) / 4
+}
+
+// Recursive splits text on the coarsest separator that yields pieces
+// smaller than ChunkSize, recursing with finer separators into pieces
+// that are still too large, and then merges adjacent pieces back into
+// chunks of up to ChunkSize. Separators are kept at the start of the piece
+// that follows them.
+type Recursive struct {
+	// ChunkSize is the maximum chunk length. If zero, DefaultChunkSize is
+	// used.
+	ChunkSize int
+
+	// Overlap is the length of text repeated from the end of a chunk at
+	// the start of the next one. It must be smaller than ChunkSize.
+	Overlap int
+
+	// Separators are tried in order. If nil, DefaultSeparators is used.
+	// The empty separator splits between characters.
+	Separators []string
+
+	// Length measures chunks. If nil, Characters is used.
+	Length func(string) int
+}
+
+func (r Recursive) Split(text string) []string {
+	size := r.ChunkSize
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	length := r.Length
+	if length == nil {
+		length = Characters
+	}
+	separators := r.Separators
+	if separators == nil {
+		separators = DefaultSeparators
+	}
+	overlap := min(max(r.Overlap, 0), size-1)
+	var chunks []string
+	for _, c := range r.split(text, separators, size, overlap, length) {
+		if c = strings.TrimSpace(c); c != "" {
+			chunks = append(chunks, c)
+		}
+	}
+	return chunks
+}
+
+func (r Recursive) split(text string, separators []string, size, overlap int, length func(string) int) []string {
+	if length(text) <= size {
+		return []string{text}
+	}
+	sep, rest := "", []string(nil)
+	for i, s := range separators {
+		if s == "" || strings.Contains(text, s) {
+			sep, rest = s, separators[i+1:]
+			break
+		}
+	}
+	var pieces []string
+	for _, p := range splitKeep(text, sep) {
+		if length(p) <= size || len(rest) == 0 {
+			pieces = append(pieces, p)
+			continue
+		}
+		pieces = append(pieces, r.split(p, rest, size, overlap, length)...)
+	}
+	return merge(pieces, size, overlap, length)
+}
+
+// splitKeep splits text on sep, keeping sep at the start of every piece
+// but the first. An empty sep splits between runes.
+func splitKeep(text, sep string) []string {
+	if sep == "" {
+		pieces := make([]string, 0, len(text))
+		for _, r := range text {
+			pieces = append(pieces, string(r))
+		}
+		return pieces
+	}
+	var pieces []string
+	for {
+		i := strings.Index(text[min(1, len(text)):], sep)
+		if i < 0 {
+			break
+		}
+		i++
+		pieces = append(pieces, text[:i])
+		text = text[i:]
+	}
+	return append(pieces, text)
+}
+
+// merge joins adjacent pieces into chunks of at most size, repeating up to
+// overlap worth of trailing pieces at the start of the following chunk.
+func merge(pieces []string, size, overlap int, length func(string) int) []string {
+	var (
+		chunks  []string
+		current []string
+		total   int
+	)
+	for _, p := range pieces {
+		n := length(p)
+		if total+n > size && len(current) > 0 {
+			chunks = append(chunks, strings.Join(current, ""))
+			for len(current) > 0 && (total > overlap || total+n > size) {
+				total -= length(current[0])
+				current = current[1:]
+			}
+		}
+		current = append(current, p)
+		total += n
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, strings.Join(current, ""))
+	}
+	return chunks
+}
+
+var sentenceEnd = regexp.MustCompile(`[.!?]+["')\]]*\s+`)
+
+// Sentence splits text into sentences and groups consecutive sentences
+// into chunks of up to ChunkSize. Sentences longer than ChunkSize are
+// split further with Recursive.
+type Sentence struct {
+	// ChunkSize is the maximum chunk length. If zero, DefaultChunkSize is
+	// used.
+	ChunkSize int
+
+	// Overlap is the length of text repeated from the end of a chunk at
+	// the start of the next one; whole sentences are repeated.
+	Overlap int
+
+	// Length measures chunks. If nil, Characters is used.
+	Length func(string) int
+}
+
+func (s Sentence) Split(text string) []string {
+	size := s.ChunkSize
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	length := s.Length
+	if length == nil {
+		length = Characters
+	}
+	return s.splitSentences(sentences(text), size, length)
+}
+
+// sentences splits text after sentence-ending punctuation, normalizing
+// the trailing whitespace of each sentence to a single space.
+func sentences(text string) []string {
+	var out []string
+	for {
+		loc := sentenceEnd.FindStringIndex(text)
+		if loc == nil {
+			break
+		}
+		out = append(out, strings.TrimRightFunc(text[:loc[1]], isSpace)+" ")
+		text = text[loc[1]:]
+	}
+	return append(out, text)
+}
+
+func (s Sentence) splitSentences(sentences []string, size int, length func(string) int) []string {
+	fallback := Recursive{ChunkSize: size, Overlap: s.Overlap, Length: length}
+	var pieces []string
+	for _, sentence := range sentences {
+		if length(sentence) <= size {
+			pieces = append(pieces, sentence)
+			continue
+		}
+		for _, p := range fallback.Split(sentence) {
+			pieces = append(pieces, p+" ")
+		}
+	}
+	var chunks []string
+	for _, c := range merge(pieces, size, min(max(s.Overlap, 0), size-1), length) {
+		if c = strings.TrimSpace(c); c != "" {
+			chunks = append(chunks, c)
+		}
+	}
+	return chunks
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+// Markdown is a Recursive splitter that prefers to break Markdown
+// documents at headings, then at code fences, rules and paragraphs.
+type Markdown struct {
+	// ChunkSize is the maximum chunk length. If zero, DefaultChunkSize is
+	// used.
+	ChunkSize int
+
+	// Overlap is the length of text repeated from the end of a chunk at
+	// the start of the next one.
+	Overlap int
+
+	// Length measures chunks. If nil, Characters is used.
+	Length func(string) int
+}
+
+func (m Markdown) Split(text string) []string {
+	return Recursive{
+		ChunkSize:  m.ChunkSize,
+		Overlap:    m.Overlap,
+		Separators: MarkdownSeparators,
+		Length:     m.Length,
+	}.Split("\n" + text)
+}
+
+// Token is a Recursive splitter whose ChunkSize and Overlap are expressed
+// in approximate tokens, which maps better onto model context windows than
+// character counts.
+type Token struct {
+	// ChunkSize is the maximum chunk size in tokens. If zero, 256 is used.
+	ChunkSize int
+
+	// Overlap is the number of tokens repeated from the end of a chunk at
+	// the start of the next one.
+	Overlap int
+
+	// Separators are tried in order. If nil, DefaultSeparators is used.
+	Separators []string
+
+	// Tokens counts the tokens of a text. If nil, ApproxTokens is used.
+	Tokens func(string) int
+}
+
+func (t Token) Split(text string) []string {
+	size := t.ChunkSize
+	if size <= 0 {
+		size = 256
+	}
+	tokens := t.Tokens
+	if tokens == nil {
+		tokens = ApproxTokens
+	}
+	return Recursive{
+		ChunkSize:  size,
+		Overlap:    t.Overlap,
+		Separators: t.Separators,
+		Length:     tokens,
+	}.Split(text)
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package textsplit_test
+
+import (
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago/textsplit"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecursive(t *testing.T) {
+	text := "first paragraph here\n\nsecond paragraph is a bit longer\n\nthird"
+	chunks := textsplit.Recursive{ChunkSize: 25}.Split(text)
+	require.Equal(t, []string{
+		"first paragraph here",
+		"second paragraph is a",
+		"bit longer\n\nthird",
+	}, chunks)
+	for _, c := range chunks {
+		require.LessOrEqual(t, len(c), 25)
+	}
+
+	chunks = textsplit.Recursive{ChunkSize: 10, Overlap: 4, Separators: []string{" "}}.Split("a b c d e f g h i j k l")
+	require.Equal(t, []string{"a b c d e", "d e f g h", "g h i j k", "j k l"}, chunks)
+}
+
+func TestSentence(t *testing.T) {
+	text := "One sentence. Another one! A question? The end."
+	require.Equal(t, []string{
+		"One sentence. Another one!",
+		"A question? The end.",
+	}, textsplit.Sentence{ChunkSize: 30}.Split(text))
+	require.Equal(t, []string{
+		"One sentence. Another one!",
+		"Another one! A question?",
+		"A question? The end.",
+	}, textsplit.Sentence{ChunkSize: 30, Overlap: 13}.Split(text))
+}
+
+func TestMarkdown(t *testing.T) {
+	doc := "# Title\n\nIntro text.\n\n## Section A\n\nBody of A.\n\n## Section B\n\nBody of B."
+	chunks := textsplit.Markdown{ChunkSize: 30}.Split(doc)
+	require.Equal(t, []string{
+		"# Title\n\nIntro text.",
+		"## Section A\n\nBody of A.",
+		"## Section B\n\nBody of B.",
+	}, chunks)
+}
+
+func TestToken(t *testing.T) {
+	text := strings.Repeat("word ", 100)
+	chunks := textsplit.Token{ChunkSize: 10}.Split(text)
+	require.NotEmpty(t, chunks)
+	for _, c := range chunks {
+		require.LessOrEqual(t, textsplit.ApproxTokens(c), 10)
+	}
+	require.Equal(t, strings.TrimSpace(text), strings.Join(chunks, " "))
+}
@@ -0,0 +1,248 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tokens counts tokens, approximately with per-family heuristics
+// or exactly with registered tokenizers, and estimates whether a
+// conversation fits the context window of a model. Counters plug into
+// ollamago.Conversation for trimming and into textsplit.Token for
+// chunking:
+//
+//	counter := tokens.ForFamily("qwen2")
+//	conv.Tokens = tokens.MessageCounter(counter)
+//	splitter := textsplit.Token{Tokens: counter.Count}
+package tokens
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"cirello.io/ollamago"
+)
+
+// Counter counts the tokens of a text.
+type Counter interface {
+	Count(text string) int
+}
+
+// CounterFunc adapts a function to Counter.
+type CounterFunc func(text string) int
+
+func (f CounterFunc) Count(text string) int {
+	return f(text)
+}
+
+// Approx estimates tokens from the number of characters per token typical
+// of a tokenizer. Characters outside the Latin script, which tokenizers
+// split finer, count as a token each.
+type Approx struct {
+	// CharsPerToken is the average number of Latin characters per token.
+	// If zero, 4 is used.
+	CharsPerToken float64
+}
+
+func (a Approx) Count(text string) int {
+	ratio := a.CharsPerToken
+	if ratio <= 0 {
+		ratio = 4
+	}
+	latin, other := 0, 0
+	for _, r := range text {
+		if r < utf8.RuneSelf || unicode.Is(unicode.Latin, r) {
+			latin++
+		} else {
+			other++
+		}
+	}
+	return int(math.Ceil(float64(latin)/ratio)) + other
+}
+
+// familyRatios are the characters per token of English text with the
+// tokenizers of common model families.
+var familyRatios = map[string]float64{
+	"llama":   3.8,
+	"mistral": 3.5,
+	"gemma":   4.0,
+	"gemma2":  4.0,
+	"gemma3":  4.0,
+	"qwen2":   3.7,
+	"qwen3":   3.7,
+	"phi3":    3.5,
+	"bert":    4.2,
+}
+
+var (
+	mu       sync.RWMutex
+	counters = map[string]Counter{}
+)
+
+// Register makes ForFamily return c for the family, such as an exact
+// tokenizer of the family vocabulary.
+func Register(family string, c Counter) {
+	mu.Lock()
+	defer mu.Unlock()
+	counters[family] = c
+}
+
+// ForFamily returns the counter of a model family, as reported in
+// ModelDetails.Family: the registered one, or else an Approx heuristic.
+func ForFamily(family string) Counter {
+	mu.RLock()
+	c, ok := counters[family]
+	mu.RUnlock()
+	if ok {
+		return c
+	}
+	return Approx{CharsPerToken: familyRatios[family]}
+}
+
+// Per-message costs of chat templates, in tokens.
+const (
+	// MessageOverhead covers the role header and the end of turn marker.
+	MessageOverhead = 4
+
+	// ImageTokens is the typical cost of an image with vision models.
+	ImageTokens = 768
+)
+
+// Message counts the tokens of a chat message, including its template
+// overhead, images and tool calls.
+func Message(c Counter, m ollamago.ChatMessage) int {
+	n := MessageOverhead + c.Count(m.Role) + c.Count(m.Content) + len(m.Images)*ImageTokens
+	for _, call := range m.ToolCalls {
+		n += c.Count(call.Function.Name) + c.Count(string(call.Function.Arguments))
+	}
+	return n
+}
+
+// Messages counts the tokens of chat messages.
+func Messages(c Counter, messages []ollamago.ChatMessage) int {
+	n := 0
+	for _, m := range messages {
+		n += Message(c, m)
+	}
+	return n
+}
+
+// MessageCounter adapts c to ollamago.Conversation.Tokens.
+func MessageCounter(c Counter) func(ollamago.ChatMessage) int {
+	return func(m ollamago.ChatMessage) int {
+		return Message(c, m)
+	}
+}
+
+// DefaultNumCtx is the context window the server allocates when neither
+// the request nor the model parameters set num_ctx.
+const DefaultNumCtx = 4096
+
+// Window returns the context window the server allocates for the model:
+// its num_ctx parameter, or else DefaultNumCtx, within the context length
+// it was trained with.
+func Window(show *ollamago.ShowModelResponse) int {
+	window := DefaultNumCtx
+	for _, line := range strings.Split(show.Parameters, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "num_ctx" {
+			if n, err := strconv.Atoi(fields[1]); err == nil && n > 0 {
+				window = n
+			}
+		}
+	}
+	if trained := show.ContextLength(); trained > 0 {
+		window = min(window, trained)
+	}
+	return window
+}
+
+// Fit is the estimated use of a context window.
+type Fit struct {
+	// Tokens is the estimated size of the messages, plus the reserve.
+	Tokens int
+
+	// Window is the context window of the model.
+	Window int
+}
+
+// Fits reports whether the messages fit the window.
+func (f Fit) Fits() bool {
+	return f.Tokens <= f.Window
+}
+
+// Remaining returns the number of free tokens of the window, negative if
+// the messages overflow it.
+func (f Fit) Remaining() int {
+	return f.Window - f.Tokens
+}
+
+// Option configures FitsContext.
+type Option func(*config)
+
+type config struct {
+	numCtx  int
+	reserve int
+	counter Counter
+}
+
+// WithNumCtx sets the context window, as when requests set the num_ctx
+// option, instead of the model default.
+func WithNumCtx(n int) Option {
+	return func(c *config) {
+		c.numCtx = n
+	}
+}
+
+// WithReserve reserves tokens of the window for the reply.
+func WithReserve(n int) Option {
+	return func(c *config) {
+		c.reserve = n
+	}
+}
+
+// WithCounter counts with c instead of the counter of the model family.
+func WithCounter(c Counter) Option {
+	return func(cfg *config) {
+		cfg.counter = c
+	}
+}
+
+// FitsContext estimates whether messages fit the context window of model,
+// which it looks up with /api/show. The system prompt of the model is
+// counted too.
+func FitsContext(ctx context.Context, client ollamago.API, model string, messages []ollamago.ChatMessage, opts ...Option) (Fit, error) {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	show, err := client.ShowModelInfo(ctx, ollamago.ShowModelRequest{Model: model})
+	if err != nil {
+		return Fit{}, fmt.Errorf("cannot show model %q: %w", model, err)
+	}
+	counter := cfg.counter
+	if counter == nil {
+		counter = ForFamily(show.Details.Family)
+	}
+	fit := Fit{Tokens: Messages(counter, messages) + cfg.reserve, Window: cfg.numCtx}
+	if fit.Window <= 0 {
+		fit.Window = Window(show)
+	}
+	if show.System != "" && (len(messages) == 0 || messages[0].Role != ollamago.RoleSystem) {
+		fit.Tokens += Message(counter, ollamago.SystemMessage(show.System))
+	}
+	return fit, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tokens_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"cirello.io/ollamago/tokens"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCount(t *testing.T) {
+	require.Equal(t, 3, tokens.Approx{}.Count("hello world!"))
+	require.Equal(t, 5, tokens.Approx{}.Count("日本語です"), "non-Latin characters count as a token each")
+	require.Equal(t, 4, tokens.ForFamily("mistral").Count("hello world!"))
+	require.Equal(t, 3, tokens.ForFamily("unknown").Count("hello world!"))
+
+	tokens.Register("words", tokens.CounterFunc(func(text string) int { return len(strings.Fields(text)) }))
+	words := tokens.ForFamily("words")
+	require.Equal(t, 2, words.Count("hello world!"))
+	m := ollamago.UserMessageWithImages("describe this", "aW1n")
+	require.Equal(t, tokens.MessageOverhead+1+2+tokens.ImageTokens, tokens.Message(words, m))
+	require.Equal(t, 2*tokens.Message(words, m), tokens.Messages(words, []ollamago.ChatMessage{m, m}))
+	require.Equal(t, tokens.Message(words, m), tokens.MessageCounter(words)(m))
+}
+
+func TestFitsContext(t *testing.T) {
+	srv := ollamatest.NewServer(
+		ollamatest.Model{Name: "small", Show: ollamago.ShowModelResponse{
+			Parameters: "num_ctx                        64\nstop                           \"<|eot|>\"",
+			System:     "Be brief.",
+			Details:    ollamago.ModelDetails{Family: "llama"},
+		}},
+		ollamatest.Model{Name: "large", Show: ollamago.ShowModelResponse{
+			ModelInfo: map[string]any{"general.architecture": "llama", "llama.context_length": 2048.0},
+		}},
+	)
+	t.Cleanup(srv.Close)
+	client := srv.Client()
+	ctx := context.Background()
+	messages := []ollamago.ChatMessage{ollamago.UserMessage(strings.Repeat("word ", 40))}
+
+	fit, err := tokens.FitsContext(ctx, client, "small", messages)
+	require.NoError(t, err)
+	require.Equal(t, 64, fit.Window)
+	require.False(t, fit.Fits())
+	require.Negative(t, fit.Remaining())
+
+	fit, err = tokens.FitsContext(ctx, client, "small", messages, tokens.WithNumCtx(8192), tokens.WithReserve(1000))
+	require.NoError(t, err)
+	require.True(t, fit.Fits())
+	require.Greater(t, fit.Tokens, 1000)
+
+	fit, err = tokens.FitsContext(ctx, client, "large", messages)
+	require.NoError(t, err)
+	require.Equal(t, 2048, fit.Window, "the default window is capped by the trained context length")
+
+	_, err = tokens.FitsContext(ctx, client, "missing", messages)
+	require.Error(t, err)
+}
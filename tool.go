@@ -0,0 +1,119 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+var anonymousFunc = regexp.MustCompile(`^func\d+$`)
+
+// NewTool derives a tool definition and its ToolFunc from a typed Go
+// function. The parameters schema is generated from Args, which must be a
+// struct.
+//
+// The tool name and description are read from the tags of a blank field of
+// Args, if present:
+//
+//	type WeatherArgs struct {
+//		_    struct{} `tool:"weather" description:"Get the current weather"`
+//		City string   `json:"city" description:"City name"`
+//	}
+//
+// Otherwise the name of fn is used; anonymous functions must declare the
+// tool tag. The result is handed to the model as is when Result is a
+// string, and JSON-encoded otherwise.
+func NewTool[Args, Result any](fn func(context.Context, Args) (Result, error)) (ToolFunction, ToolFunc, error) {
+	argsType := reflect.TypeFor[Args]()
+	if argsType.Kind() != reflect.Struct {
+		return ToolFunction{}, nil, fmt.Errorf("tool arguments must be a struct, got %s", argsType)
+	}
+	var def ToolFunction
+	if f, ok := argsType.FieldByName("_"); ok {
+		def.Name = f.Tag.Get("tool")
+		def.Description = f.Tag.Get("description")
+	}
+	if def.Name == "" {
+		def.Name = funcName(fn)
+	}
+	if def.Name == "" {
+		return ToolFunction{}, nil, fmt.Errorf("cannot derive tool name for %s: add a `tool` tag to a blank field", argsType)
+	}
+	def.Parameters = Schema(argsType)
+	call := func(ctx context.Context, arguments json.RawMessage) (string, error) {
+		var args Args
+		if err := unmarshalArguments(arguments, &args); err != nil {
+			return "", fmt.Errorf("cannot decode %s arguments: %w", def.Name, err)
+		}
+		result, err := fn(ctx, args)
+		if err != nil {
+			return "", err
+		}
+		if s, ok := any(result).(string); ok {
+			return s, nil
+		}
+		out, err := json.Marshal(result)
+		if err != nil {
+			return "", fmt.Errorf("cannot encode %s result: %w", def.Name, err)
+		}
+		return string(out), nil
+	}
+	return def, call, nil
+}
+
+// RegisterFunc registers a typed Go function as a tool of the agent. See
+// NewTool for how the tool definition is derived.
+func RegisterFunc[Args, Result any](a *Agent, fn func(context.Context, Args) (Result, error)) error {
+	def, call, err := NewTool(fn)
+	if err != nil {
+		return err
+	}
+	a.RegisterTool(def, call)
+	return nil
+}
+
+func funcName(fn any) string {
+	f := runtime.FuncForPC(reflect.ValueOf(fn).Pointer())
+	if f == nil {
+		return ""
+	}
+	name := f.Name()
+	name = name[strings.LastIndex(name, "/")+1:]
+	name = strings.TrimSuffix(name, "-fm")
+	name = name[strings.LastIndex(name, ".")+1:]
+	if anonymousFunc.MatchString(name) {
+		return ""
+	}
+	return name
+}
+
+// unmarshalArguments decodes tool call arguments, tolerating models that
+// send the arguments object encoded as a JSON string.
+func unmarshalArguments(arguments json.RawMessage, v any) error {
+	if len(arguments) == 0 {
+		arguments = json.RawMessage("{}")
+	}
+	var s string
+	if err := json.Unmarshal(arguments, &s); err == nil {
+		arguments = json.RawMessage(s)
+	}
+	return json.Unmarshal(arguments, v)
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"cirello.io/ollamago"
+	"github.com/stretchr/testify/require"
+)
+
+type weatherArgs struct {
+	_     struct{} `tool:"weather" description:"Get the current weather"`
+	City  string   `json:"city" description:"City name"`
+	Units string   `json:"units,omitempty"`
+}
+
+type weatherResult struct {
+	Forecast string `json:"forecast"`
+}
+
+type sumArgs struct {
+	A, B int
+}
+
+func sum(ctx context.Context, args sumArgs) (int, error) {
+	return args.A + args.B, nil
+}
+
+func TestNewTool(t *testing.T) {
+	def, call, err := ollamago.NewTool(func(ctx context.Context, args weatherArgs) (weatherResult, error) {
+		return weatherResult{Forecast: "sunny in " + args.City}, nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "weather", def.Name)
+	require.Equal(t, "Get the current weather", def.Description)
+	require.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"city": {"type": "string", "description": "City name"},
+			"units": {"type": "string"}
+		},
+		"required": ["city"]
+	}`, string(def.Parameters))
+
+	result, err := call(context.Background(), json.RawMessage(`{"city":"Paris"}`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"forecast":"sunny in Paris"}`, result)
+
+	result, err = call(context.Background(), json.RawMessage(`"{\"city\":\"Rome\"}"`))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"forecast":"sunny in Rome"}`, result)
+
+	_, err = call(context.Background(), json.RawMessage(`{"city":1}`))
+	require.Error(t, err)
+}
+
+func TestRegisterFunc(t *testing.T) {
+	agent := &ollamago.Agent{}
+	require.NoError(t, ollamago.RegisterFunc(agent, sum))
+	tools := agent.Tools()
+	require.Len(t, tools, 1)
+	require.Equal(t, "sum", tools[0].Function.Name)
+
+	err := ollamago.RegisterFunc(agent, func(ctx context.Context, args sumArgs) (int, error) { return 0, nil })
+	require.Error(t, err)
+}
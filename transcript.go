@@ -0,0 +1,208 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TranscriptVersion is the version of the JSON format of Transcript.
+// ReadTranscript rejects newer versions.
+const TranscriptVersion = 1
+
+// Transcript is the record of a chat session, with the time of every
+// message and the metrics of the replies. It is exported to JSON, which
+// ReadTranscript imports back, or rendered as Markdown. Its methods are
+// safe for concurrent use.
+type Transcript struct {
+	Version   int               `json:"version"`
+	ID        string            `json:"id,omitempty"`
+	Model     string            `json:"model"`
+	System    string            `json:"system,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	Entries   []TranscriptEntry `json:"entries"`
+
+	mu sync.Mutex
+}
+
+// TranscriptEntry is a message of a Transcript.
+type TranscriptEntry struct {
+	Time    time.Time   `json:"time"`
+	Message ChatMessage `json:"message"`
+
+	// Metrics are those of the reply, for assistant messages.
+	Metrics *Metrics `json:"metrics,omitempty"`
+}
+
+// NewTranscript returns an empty transcript of a session with model.
+func NewTranscript(id, model, system string) *Transcript {
+	return &Transcript{
+		Version:   TranscriptVersion,
+		ID:        id,
+		Model:     model,
+		System:    system,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// Add records messages, such as the user messages sent to the model.
+func (t *Transcript) Add(messages ...ChatMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now().UTC()
+	for _, m := range messages {
+		t.Entries = append(t.Entries, TranscriptEntry{Time: now, Message: m})
+	}
+}
+
+// Record relays a chat stream, recording the reply with its metrics once
+// it is complete. Failed replies are not recorded.
+func (t *Transcript) Record(resp <-chan ChatResponse) <-chan ChatResponse {
+	out := make(chan ChatResponse)
+	go func() {
+		defer close(out)
+		var (
+			reply   ChatMessage
+			content strings.Builder
+			metrics *Metrics
+			failed  bool
+		)
+		reply.Role = RoleAssistant
+		for r := range resp {
+			if r.Error != nil {
+				failed = true
+			}
+			if r.Message.Role != "" {
+				reply.Role = r.Message.Role
+			}
+			content.WriteString(r.Message.Content)
+			reply.ToolCalls = append(reply.ToolCalls, r.Message.ToolCalls...)
+			if r.Done {
+				m := r.Metrics
+				metrics = &m
+			}
+			out <- r
+		}
+		if failed || metrics == nil {
+			return
+		}
+		reply.Content = content.String()
+		t.mu.Lock()
+		t.Entries = append(t.Entries, TranscriptEntry{Time: time.Now().UTC(), Message: reply, Metrics: metrics})
+		t.mu.Unlock()
+	}()
+	return out
+}
+
+// Messages returns the recorded messages, excluding the system prompt.
+func (t *Transcript) Messages() []ChatMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	messages := make([]ChatMessage, len(t.Entries))
+	for i, e := range t.Entries {
+		messages[i] = e.Message
+	}
+	return messages
+}
+
+// Session returns the transcript as a Session, to replay it with
+// Conversation.Restore.
+func (t *Transcript) Session() Session {
+	messages := t.Messages()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := Session{ID: t.ID, Model: t.Model, System: t.System, Messages: messages, UpdatedAt: t.CreatedAt}
+	if n := len(t.Entries); n > 0 {
+		s.UpdatedAt = t.Entries[n-1].Time
+	}
+	return s
+}
+
+// WriteJSON writes the transcript as JSON. Tool call arguments are kept
+// verbatim.
+func (t *Transcript) WriteJSON(w io.Writer) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := json.NewEncoder(w).Encode(t); err != nil {
+		return fmt.Errorf("cannot encode transcript: %w", err)
+	}
+	return nil
+}
+
+// ReadTranscript reads a transcript written by WriteJSON.
+func ReadTranscript(r io.Reader) (*Transcript, error) {
+	var t Transcript
+	if err := json.NewDecoder(r).Decode(&t); err != nil {
+		return nil, fmt.Errorf("cannot decode transcript: %w", err)
+	}
+	if t.Version < 1 || t.Version > TranscriptVersion {
+		return nil, fmt.Errorf("unsupported transcript version %d", t.Version)
+	}
+	return &t, nil
+}
+
+// WriteMarkdown renders the transcript as a readable Markdown document.
+func (t *Transcript) WriteMarkdown(w io.Writer) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	bw := bufio.NewWriter(w)
+	title := t.ID
+	if title == "" {
+		title = "Transcript"
+	}
+	fmt.Fprintf(bw, "# %s\n\n", title)
+	fmt.Fprintf(bw, "Model: `%s`  \nStarted: %s\n", t.Model, t.CreatedAt.Format(time.RFC3339))
+	if t.System != "" {
+		fmt.Fprintf(bw, "\n## System\n\n%s\n", t.System)
+	}
+	for _, e := range t.Entries {
+		fmt.Fprintf(bw, "\n## %s\n\n", markdownRole(e.Message.Role))
+		fmt.Fprintf(bw, "_%s_\n", e.Time.Format(time.RFC3339))
+		if e.Message.Content != "" {
+			fmt.Fprintf(bw, "\n%s\n", e.Message.Content)
+		}
+		for _, call := range e.Message.ToolCalls {
+			fmt.Fprintf(bw, "\nCalled `%s`:\n\n```json\n%s\n```\n", call.Function.Name, call.Function.Arguments)
+		}
+		if m := e.Metrics; m != nil && m.EvalCount > 0 {
+			fmt.Fprintf(bw, "\n> %d prompt tokens, %d generated tokens", m.PromptEvalCount, m.EvalCount)
+			if m.EvalDuration > 0 {
+				fmt.Fprintf(bw, " at %.1f tokens/s", float64(m.EvalCount)/m.EvalDuration.Seconds())
+			}
+			fmt.Fprintln(bw)
+		}
+	}
+	return bw.Flush()
+}
+
+func markdownRole(role string) string {
+	switch role {
+	case RoleUser:
+		return "User"
+	case RoleAssistant:
+		return "Assistant"
+	case RoleTool:
+		return "Tool"
+	case RoleSystem:
+		return "System"
+	}
+	return role
+}
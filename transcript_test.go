@@ -0,0 +1,75 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamagotest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranscript(t *testing.T) {
+	tr := ollamago.NewTranscript("support", "llama3.2", "Be brief.")
+	tr.Add(ollamago.UserMessage("weather in Rome?"))
+	for range tr.Record(ollamagotest.Stream(
+		ollamago.ChatResponse{Message: ollamago.ChatMessage{Role: ollamago.RoleAssistant, ToolCalls: []ollamago.ToolCall{{
+			Function: ollamago.ToolCallFunction{Name: "weather", Arguments: json.RawMessage(`{"city":"Rome"}`)},
+		}}}},
+		ollamago.ChatResponse{Done: true, Metrics: ollamago.Metrics{PromptEvalCount: 12, EvalCount: 8, EvalDuration: time.Second}},
+	)) {
+	}
+	tr.Add(ollamago.ToolResult("weather", "sunny"))
+	for range tr.Record(ollamagotest.Stream(
+		ollamago.ChatResponse{Message: ollamago.ChatMessage{Role: ollamago.RoleAssistant, Content: "It is "}},
+		ollamago.ChatResponse{Message: ollamago.ChatMessage{Content: "sunny."}, Done: true, Metrics: ollamago.Metrics{EvalCount: 3}},
+	)) {
+	}
+	for range tr.Record(ollamagotest.Stream(ollamago.ChatResponse{Error: errors.New("broken")})) {
+	}
+	messages := tr.Messages()
+	require.Len(t, messages, 4)
+	require.Equal(t, "It is sunny.", messages[3].Content)
+	require.Equal(t, "weather", messages[1].ToolCalls[0].Function.Name)
+	require.Equal(t, 8, tr.Entries[1].Metrics.EvalCount)
+	require.Nil(t, tr.Entries[0].Metrics)
+
+	var js strings.Builder
+	require.NoError(t, tr.WriteJSON(&js))
+	imported, err := ollamago.ReadTranscript(strings.NewReader(js.String()))
+	require.NoError(t, err)
+	require.Equal(t, tr.Entries, imported.Entries)
+	session := imported.Session()
+	require.Equal(t, "support", session.ID)
+	require.Equal(t, "Be brief.", session.System)
+	require.Equal(t, messages, session.Messages)
+	require.Equal(t, tr.Entries[3].Time, session.UpdatedAt)
+
+	var md strings.Builder
+	require.NoError(t, tr.WriteMarkdown(&md))
+	require.Contains(t, md.String(), "# support\n")
+	require.Contains(t, md.String(), "## System\n\nBe brief.\n")
+	require.Contains(t, md.String(), "Called `weather`:\n\n```json\n{\"city\":\"Rome\"}\n```\n")
+	require.Contains(t, md.String(), "> 12 prompt tokens, 8 generated tokens at 8.0 tokens/s\n")
+	require.Contains(t, md.String(), "## Tool\n")
+
+	_, err = ollamago.ReadTranscript(strings.NewReader(`{"version":99}`))
+	require.Error(t, err)
+}
@@ -0,0 +1,59 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// NewTransport returns the transport of clients without HTTPClient, tuned
+// for the few hosts and many concurrent, long-lived calls of model
+// serving:
+//
+//   - up to 64 idle connections are kept per host, instead of 2, so that
+//     parallel calls reuse their connections;
+//   - responses are not compressed, as gzip holds back the chunks of
+//     streamed responses and model output compresses poorly;
+//   - connections are kept alive, with TCP keep-alive probes, as calls
+//     may stay silent while a model loads;
+//   - HTTP/2 is attempted with TLS servers.
+//
+// It is a starting point for custom transports. To turn HTTP/2 off, set
+// ForceAttemptHTTP2 to false and TLSNextProto to an empty map.
+func NewTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          256,
+		MaxIdleConnsPerHost:   64,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		DisableCompression:    true,
+		TLSClientConfig:       &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+}
+
+// defaultHTTPClient is shared by the clients without HTTPClient, so that
+// they share their connections too.
+var defaultHTTPClient = &http.Client{Transport: NewTransport()}
@@ -0,0 +1,44 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"cirello.io/ollamago"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTransport(t *testing.T) {
+	transport := ollamago.NewTransport()
+	require.Greater(t, transport.MaxIdleConnsPerHost, http.DefaultMaxIdleConnsPerHost)
+	require.True(t, transport.DisableCompression)
+	require.False(t, transport.DisableKeepAlives)
+	require.True(t, transport.ForceAttemptHTTP2)
+
+	var encodings []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get("Accept-Encoding"))
+		w.Write([]byte(`{"version":"1.0.0"}`))
+	}))
+	t.Cleanup(server.Close)
+	client := ollamago.Client{BaseURL: server.URL}
+	_, err := client.Version(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, []string{""}, encodings, "the default client asked for compressed responses")
+}
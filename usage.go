@@ -0,0 +1,157 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+type usageTagKey struct{}
+
+// WithUsageTag tags the calls made with ctx, such as with the name of a
+// team or a feature, for Usage to account them separately.
+func WithUsageTag(ctx context.Context, tag string) context.Context {
+	return context.WithValue(ctx, usageTagKey{}, tag)
+}
+
+// UsageStats are the totals of a set of calls.
+type UsageStats struct {
+	Calls  int `json:"calls"`
+	Errors int `json:"errors"`
+
+	PromptTokens    int `json:"prompt_tokens"`
+	GeneratedTokens int `json:"generated_tokens"`
+
+	// Wall is the time spent in calls, from sending the request to
+	// consuming the response.
+	Wall time.Duration `json:"wall"`
+
+	// EvalDuration is the generation time reported by the server.
+	EvalDuration time.Duration `json:"eval_duration"`
+}
+
+func (s *UsageStats) add(o UsageStats) {
+	s.Calls += o.Calls
+	s.Errors += o.Errors
+	s.PromptTokens += o.PromptTokens
+	s.GeneratedTokens += o.GeneratedTokens
+	s.Wall += o.Wall
+	s.EvalDuration += o.EvalDuration
+}
+
+// UsageRecord is the usage of a model by a tag.
+type UsageRecord struct {
+	Model string `json:"model"`
+	Tag   string `json:"tag,omitempty"`
+	UsageStats
+}
+
+type usageKey struct {
+	model, tag string
+}
+
+// Usage accounts the calls made by the clients it intercepts, per model
+// and per tag set with WithUsageTag:
+//
+//	var usage ollamago.Usage
+//	client := &ollamago.Client{Interceptors: []ollamago.Interceptor{usage.Interceptor()}}
+//
+// It is safe for concurrent use.
+type Usage struct {
+	mu    sync.Mutex
+	stats map[usageKey]*UsageStats
+}
+
+// Interceptor returns the interceptor feeding the accounting.
+func (u *Usage) Interceptor() Interceptor {
+	return Observe(u.record)
+}
+
+func (u *Usage) record(s CallStats) {
+	if s.Model == "" {
+		return
+	}
+	tag, _ := s.Request.Context().Value(usageTagKey{}).(string)
+	call := UsageStats{
+		Calls:           1,
+		PromptTokens:    s.PromptTokens,
+		GeneratedTokens: s.GeneratedTokens,
+		Wall:            s.Duration,
+		EvalDuration:    s.EvalDuration,
+	}
+	if s.Err != nil || s.Status != http.StatusOK {
+		call.Errors = 1
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.stats == nil {
+		u.stats = make(map[usageKey]*UsageStats)
+	}
+	k := usageKey{model: s.Model, tag: tag}
+	if u.stats[k] == nil {
+		u.stats[k] = &UsageStats{}
+	}
+	u.stats[k].add(call)
+}
+
+// Snapshot returns the usage so far, sorted by model and tag.
+func (u *Usage) Snapshot() []UsageRecord {
+	u.mu.Lock()
+	records := make([]UsageRecord, 0, len(u.stats))
+	for k, s := range u.stats {
+		records = append(records, UsageRecord{Model: k.model, Tag: k.tag, UsageStats: *s})
+	}
+	u.mu.Unlock()
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Model != records[j].Model {
+			return records[i].Model < records[j].Model
+		}
+		return records[i].Tag < records[j].Tag
+	})
+	return records
+}
+
+// ByModel returns the usage so far of each model, all tags included.
+func (u *Usage) ByModel() map[string]UsageStats {
+	return u.aggregate(func(k usageKey) string { return k.model })
+}
+
+// ByTag returns the usage so far of each tag, all models included.
+func (u *Usage) ByTag() map[string]UsageStats {
+	return u.aggregate(func(k usageKey) string { return k.tag })
+}
+
+func (u *Usage) aggregate(by func(usageKey) string) map[string]UsageStats {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	out := make(map[string]UsageStats)
+	for k, s := range u.stats {
+		total := out[by(k)]
+		total.add(*s)
+		out[by(k)] = total
+	}
+	return out
+}
+
+// Reset discards the usage so far.
+func (u *Usage) Reset() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.stats = nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsage(t *testing.T) {
+	srv := ollamatest.NewServer(
+		ollamatest.Model{Name: "llama3.2", Chunks: []string{"a", "b"}},
+		ollamatest.Model{Name: "qwen2.5", Chunks: []string{"c"}},
+	)
+	t.Cleanup(srv.Close)
+	var usage ollamago.Usage
+	client := srv.Client()
+	client.Interceptors = append(client.Interceptors, usage.Interceptor())
+	chat := func(ctx context.Context, model string) {
+		t.Helper()
+		resp, err := client.GenerateChat(ctx, ollamago.ChatRequest{Model: model, Messages: []ollamago.ChatMessage{ollamago.UserMessage("hi")}, Stream: true})
+		if err != nil {
+			return
+		}
+		for range resp {
+		}
+	}
+	ctx := context.Background()
+	search := ollamago.WithUsageTag(ctx, "search")
+	chat(ctx, "llama3.2")
+	chat(search, "llama3.2")
+	chat(search, "llama3.2")
+	chat(search, "qwen2.5")
+	chat(search, "missing")
+	_, err := client.ListModels(ctx)
+	require.NoError(t, err)
+
+	records := usage.Snapshot()
+	require.Len(t, records, 4)
+	require.Equal(t, "llama3.2", records[0].Model)
+	require.Equal(t, "", records[0].Tag)
+	require.Equal(t, 1, records[0].Calls)
+	require.Equal(t, 2, records[0].GeneratedTokens)
+	require.Positive(t, records[0].PromptTokens)
+	require.Equal(t, "search", records[1].Tag)
+	require.Equal(t, 2, records[1].Calls)
+	require.Equal(t, 4, records[1].GeneratedTokens)
+	require.Equal(t, "missing", records[2].Model)
+	require.Equal(t, 1, records[2].Errors)
+
+	byModel := usage.ByModel()
+	require.Equal(t, 3, byModel["llama3.2"].Calls)
+	require.Equal(t, 6, byModel["llama3.2"].GeneratedTokens)
+	byTag := usage.ByTag()
+	require.Equal(t, 4, byTag["search"].Calls)
+	require.Equal(t, 1, byTag[""].Calls)
+
+	usage.Reset()
+	require.Empty(t, usage.Snapshot())
+}
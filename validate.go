@@ -0,0 +1,144 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidRequest is matched by the errors returned when a request fails
+// validation before being sent.
+var ErrInvalidRequest = errors.New("invalid request")
+
+// ValidationError lists the problems found in a request.
+type ValidationError struct {
+	// Request is the type of the invalid request, such as "ChatRequest".
+	Request  string
+	Problems []error
+}
+
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		problems[i] = p.Error()
+	}
+	return "invalid " + e.Request + ": " + strings.Join(problems, "; ")
+}
+
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidRequest
+}
+
+// Validate checks the request for the mistakes the server would reject.
+func (r CompletionRequest) Validate() error {
+	var problems []error
+	if r.Model == "" {
+		problems = append(problems, errors.New("model is required"))
+	}
+	problems = appendLogprobsProblems(problems, r.TopLogprobs)
+	problems = appendOptionProblems(problems, r.Options)
+	return validationError("CompletionRequest", problems)
+}
+
+// Validate checks the request for the mistakes the server would reject.
+func (r ChatRequest) Validate() error {
+	var problems []error
+	if r.Model == "" {
+		problems = append(problems, errors.New("model is required"))
+	}
+	// calls are those of the last assistant turn, which tool messages
+	// answer.
+	var calls []ToolCall
+	for i, msg := range r.Messages {
+		switch msg.Role {
+		case RoleSystem, RoleUser:
+			calls = nil
+		case RoleAssistant:
+			calls = msg.ToolCalls
+		case RoleTool:
+			if msg.ToolCallID != "" && answersUnknownCall(calls, msg.ToolCallID) {
+				problems = append(problems, fmt.Errorf("message %d: answers unknown tool call %q", i, msg.ToolCallID))
+			}
+		default:
+			problems = append(problems, fmt.Errorf("message %d: invalid role %q", i, msg.Role))
+		}
+		if len(msg.Images) > 0 && msg.Role != RoleUser {
+			problems = append(problems, fmt.Errorf("message %d: images are only supported in user messages", i))
+		}
+	}
+	for i, tool := range r.Tools {
+		if tool.Function.Name == "" {
+			problems = append(problems, fmt.Errorf("tool %d: function name is required", i))
+		}
+	}
+	problems = appendLogprobsProblems(problems, r.TopLogprobs)
+	problems = appendOptionProblems(problems, r.Options)
+	return validationError("ChatRequest", problems)
+}
+
+// answersUnknownCall reports whether id matches none of calls, when they
+// are identified.
+func answersUnknownCall(calls []ToolCall, id string) bool {
+	identified := false
+	for _, call := range calls {
+		if call.ID == id {
+			return false
+		}
+		identified = identified || call.ID != ""
+	}
+	return identified
+}
+
+func appendLogprobsProblems(problems []error, top int) []error {
+	if top < 0 || top > 20 {
+		problems = append(problems, fmt.Errorf("top_logprobs must be in [0, 20], got %d", top))
+	}
+	return problems
+}
+
+// Validate checks the request for the mistakes the server would reject.
+func (r EmbedRequest) Validate() error {
+	var problems []error
+	if r.Model == "" {
+		problems = append(problems, errors.New("model is required"))
+	}
+	if len(r.Input) == 0 {
+		problems = append(problems, errors.New("input is required"))
+	}
+	return validationError("EmbedRequest", problems)
+}
+
+func appendOptionProblems(problems []error, options ModelParameters) []error {
+	err := options.Validate()
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return append(problems, joined.Unwrap()...)
+	} else if err != nil {
+		return append(problems, err)
+	}
+	return problems
+}
+
+func validationError(request string, problems []error) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Request: request, Problems: problems}
+}
@@ -0,0 +1,74 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	err := ollamago.ChatRequest{
+		Messages: []ollamago.ChatMessage{
+			{Role: "System", Content: "be brief"},
+			{Role: "assistant", Content: "look", Images: []string{"aGk="}},
+		},
+		Options: ollamago.ModelParameters{TopP: ollamago.Ptr(2.0)},
+	}.Validate()
+	require.ErrorIs(t, err, ollamago.ErrInvalidRequest)
+	require.EqualError(t, err, `invalid ChatRequest: model is required; message 0: invalid role "System"; message 1: images are only supported in user messages; top_p must be in [0, 1], got 2`)
+
+	require.EqualError(t, ollamago.CompletionRequest{Model: "test", Logprobs: true, TopLogprobs: 21}.Validate(),
+		"invalid CompletionRequest: top_logprobs must be in [0, 20], got 21")
+	require.EqualError(t, ollamago.EmbedRequest{Model: "test"}.Validate(), "invalid EmbedRequest: input is required")
+	require.EqualError(t, ollamago.CompletionRequest{Options: ollamago.ModelParameters{NumCtx: ollamago.Ptr(0)}}.Validate(),
+		"invalid CompletionRequest: model is required; num_ctx must be at least 1, got 0")
+
+	require.NoError(t, ollamago.ChatRequest{
+		Model:    "test",
+		Messages: []ollamago.ChatMessage{{Role: "user", Content: "what is this?", Images: []string{"aGk="}}},
+	}.Validate())
+	require.NoError(t, ollamago.CompletionRequest{Model: "test"}.Validate(), "an empty prompt loads the model")
+	require.NoError(t, ollamago.ChatRequest{Model: "test"}.Validate(), "an empty chat loads the model")
+
+	call := ollamago.ToolCall{ID: "call_1", Function: ollamago.ToolCallFunction{Name: "add"}}
+	require.EqualError(t, ollamago.ChatRequest{
+		Model: "test",
+		Messages: []ollamago.ChatMessage{
+			ollamago.UserMessage("add"),
+			ollamago.AssistantToolCalls("", call),
+			ollamago.ToolResult("call_2", "3"),
+		},
+	}.Validate(), `invalid ChatRequest: message 2: answers unknown tool call "call_2"`)
+}
+
+func TestValidateBeforeSending(t *testing.T) {
+	pool, servers := newPool(t, 2, ollamatest.Model{Name: "test"})
+	ctx := context.Background()
+	_, err := pool.GenerateChat(ctx, ollamago.ChatRequest{Model: "test", Messages: []ollamago.ChatMessage{{Role: "robot"}}})
+	var validationErr *ollamago.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Equal(t, "ChatRequest", validationErr.Request)
+	_, err = pool.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Input: []string{"hi"}})
+	require.ErrorIs(t, err, ollamago.ErrInvalidRequest)
+	for _, srv := range servers {
+		require.Empty(t, srv.Requests())
+	}
+	require.Equal(t, []bool{true, true}, pool.Healthy(), "invalid requests are not retried")
+}
@@ -0,0 +1,145 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"container/heap"
+	"math"
+)
+
+// Float is the set of element types accepted by the embedding math
+// helpers. All of them accumulate in float64 regardless of the input
+// precision.
+type Float interface {
+	~float32 | ~float64
+}
+
+func checkLengths(a, b int) {
+	if a != b {
+		panic("ollamago: vectors have different lengths")
+	}
+}
+
+// DotProduct returns the dot product of a and b. It panics if the vectors
+// have different lengths.
+func DotProduct[F Float](a, b []F) float64 {
+	checkLengths(len(a), len(b))
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// Norm returns the euclidean norm of v, scaling intermediate values to
+// avoid overflow and underflow.
+func Norm[F Float](v []F) float64 {
+	var scale float64
+	for _, x := range v {
+		scale = max(scale, math.Abs(float64(x)))
+	}
+	if scale == 0 || math.IsInf(scale, 0) || math.IsNaN(scale) {
+		return scale
+	}
+	var sum float64
+	for _, x := range v {
+		y := float64(x) / scale
+		sum += y * y
+	}
+	return scale * math.Sqrt(sum)
+}
+
+// CosineSimilarity returns the cosine of the angle between a and b, in
+// [-1, 1]. It returns 0 if either vector is zero and panics if the vectors
+// have different lengths.
+func CosineSimilarity[F Float](a, b []F) float64 {
+	checkLengths(len(a), len(b))
+	na, nb := Norm(a), Norm(b)
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += (float64(a[i]) / na) * (float64(b[i]) / nb)
+	}
+	return max(-1, min(1, sum))
+}
+
+// Normalize returns a copy of v scaled to unit length. The zero vector is
+// returned unchanged.
+func Normalize[F Float](v []F) []F {
+	out := make([]F, len(v))
+	n := Norm(v)
+	if n == 0 {
+		copy(out, v)
+		return out
+	}
+	for i, x := range v {
+		out[i] = F(float64(x) / n)
+	}
+	return out
+}
+
+// Match is a TopK result: the index of a candidate and its cosine
+// similarity to the query.
+type Match struct {
+	Index int
+	Score float64
+}
+
+// TopK returns the k candidates most similar to query by cosine
+// similarity, best first. Ties are broken by the lower index.
+func TopK[F Float](query []F, candidates [][]F, k int) []Match {
+	if k <= 0 {
+		return nil
+	}
+	h := make(matchHeap, 0, min(k, len(candidates)))
+	for i, c := range candidates {
+		m := Match{Index: i, Score: CosineSimilarity(query, c)}
+		if len(h) < k {
+			heap.Push(&h, m)
+		} else if h.less(h[0], m) {
+			h[0] = m
+			heap.Fix(&h, 0)
+		}
+	}
+	out := make([]Match, len(h))
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(&h).(Match)
+	}
+	return out
+}
+
+// matchHeap is a min-heap on match quality, keeping the worst retained
+// match at the root.
+type matchHeap []Match
+
+func (h matchHeap) less(a, b Match) bool {
+	if a.Score == b.Score {
+		return a.Index > b.Index
+	}
+	return a.Score < b.Score
+}
+
+func (h matchHeap) Len() int           { return len(h) }
+func (h matchHeap) Less(i, j int) bool { return h.less(h[i], h[j]) }
+func (h matchHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *matchHeap) Push(x any)        { *h = append(*h, x.(Match)) }
+func (h *matchHeap) Pop() any {
+	old := *h
+	m := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return m
+}
@@ -0,0 +1,52 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"math"
+	"testing"
+
+	"cirello.io/ollamago"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVectorMath(t *testing.T) {
+	require.Equal(t, 11.0, ollamago.DotProduct([]float64{1, 2}, []float64{3, 4}))
+	require.Equal(t, 11.0, ollamago.DotProduct([]float32{1, 2}, []float32{3, 4}))
+	require.Equal(t, 5.0, ollamago.Norm([]float64{3, 4}))
+	require.Equal(t, 5e300, ollamago.Norm([]float64{3e300, 4e300}))
+
+	require.InDelta(t, 1, ollamago.CosineSimilarity([]float32{1, 1}, []float32{2, 2}), 1e-12)
+	require.InDelta(t, 0, ollamago.CosineSimilarity([]float64{1, 0}, []float64{0, 1}), 1e-12)
+	require.InDelta(t, -1, ollamago.CosineSimilarity([]float64{1, 0}, []float64{-3, 0}), 1e-12)
+	require.Equal(t, 0.0, ollamago.CosineSimilarity([]float64{0, 0}, []float64{1, 1}))
+	require.Panics(t, func() { ollamago.DotProduct([]float64{1}, []float64{1, 2}) })
+
+	n := ollamago.Normalize([]float32{3, 4})
+	require.InDeltaSlice(t, []float32{0.6, 0.8}, n, 1e-6)
+	require.InDelta(t, 1, ollamago.Norm(n), 1e-6)
+	require.Equal(t, []float64{0, 0}, ollamago.Normalize([]float64{0, 0}))
+	require.False(t, math.IsNaN(ollamago.CosineSimilarity([]float64{1e-320}, []float64{1e-320})))
+}
+
+func TestTopK(t *testing.T) {
+	candidates := [][]float64{{0, 1}, {1, 0}, {1, 1}, {1, 0.1}, {-1, 0}}
+	require.Equal(t, []ollamago.Match{
+		{Index: 1, Score: 1},
+		{Index: 3, Score: ollamago.CosineSimilarity([]float64{1, 0}, []float64{1, 0.1})},
+	}, ollamago.TopK([]float64{1, 0}, candidates, 2))
+	require.Len(t, ollamago.TopK([]float64{1, 0}, candidates, 10), 5)
+	require.Nil(t, ollamago.TopK([]float64{1, 0}, candidates, 0))
+}
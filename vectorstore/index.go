@@ -0,0 +1,78 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+
+	"cirello.io/ollamago"
+)
+
+// TextKey is the metadata key under which Index keeps the embedded text.
+const TextKey = "text"
+
+// Index couples a vector store with an Ollama embedding model so that
+// texts can be added and searched directly.
+type Index struct {
+	// Store holds the vectors.
+	Store VectorStore
+
+	// Client is used to generate embeddings.
+	Client ollamago.API
+
+	// Model is the embedding model.
+	Model string
+}
+
+func (ix *Index) embed(ctx context.Context, texts ...string) ([][]float64, error) {
+	if ix.Client == nil || ix.Store == nil {
+		return nil, errors.New("index needs a client and a store")
+	}
+	resp, err := ix.Client.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: ix.Model, Input: texts})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("got %d embeddings for %d texts", len(resp.Embeddings), len(texts))
+	}
+	return resp.Embeddings, nil
+}
+
+// AddText embeds text and stores it under id. The text is kept in the
+// metadata under TextKey.
+func (ix *Index) AddText(ctx context.Context, id, text string, metadata map[string]any) error {
+	vectors, err := ix.embed(ctx, text)
+	if err != nil {
+		return fmt.Errorf("cannot embed text: %w", err)
+	}
+	md := maps.Clone(metadata)
+	if md == nil {
+		md = make(map[string]any)
+	}
+	md[TextKey] = text
+	return ix.Store.Add(ctx, id, vectors[0], md)
+}
+
+// SearchText embeds query and returns the k closest stored texts.
+func (ix *Index) SearchText(ctx context.Context, query string, k int) ([]Result, error) {
+	vectors, err := ix.embed(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("cannot embed query: %w", err)
+	}
+	return ix.Store.Search(ctx, vectors[0], k)
+}
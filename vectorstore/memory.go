@@ -0,0 +1,190 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vectorstore provides vector indexes for embeddings generated by
+// Ollama, enabling retrieval-augmented generation flows built only on
+// ollamago.
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"cirello.io/ollamago"
+)
+
+// Metric selects how vectors are compared.
+type Metric int
+
+const (
+	// Cosine ranks by cosine similarity; higher scores are closer.
+	Cosine Metric = iota
+
+	// DotProduct ranks by dot product; higher scores are closer.
+	DotProduct
+
+	// Euclidean ranks by euclidean distance; lower scores are closer.
+	Euclidean
+)
+
+func (m Metric) String() string {
+	switch m {
+	case Cosine:
+		return "cosine"
+	case DotProduct:
+		return "dot"
+	case Euclidean:
+		return "euclidean"
+	default:
+		return fmt.Sprintf("Metric(%d)", int(m))
+	}
+}
+
+// VectorStore is implemented by vector indexes.
+type VectorStore interface {
+	// Add inserts or replaces the vector stored under id.
+	Add(ctx context.Context, id string, vector []float64, metadata map[string]any) error
+
+	// Delete removes the vector stored under id, if any.
+	Delete(ctx context.Context, id string) error
+
+	// Search returns the k vectors closest to query, best first. Scores
+	// follow the conventions of Metric.
+	Search(ctx context.Context, query []float64, k int) ([]Result, error)
+}
+
+var (
+	_ VectorStore = (*Memory)(nil)
+	_ VectorStore = (*SQLite)(nil)
+	_ VectorStore = (*Postgres)(nil)
+)
+
+// Result is a single search hit.
+type Result struct {
+	ID       string
+	Score    float64
+	Metadata map[string]any
+}
+
+// ErrDimensionMismatch is returned when a vector does not have the same
+// number of dimensions as the vectors already in the index.
+var ErrDimensionMismatch = errors.New("vector dimension mismatch")
+
+type entry struct {
+	vector   []float64
+	norm     float64
+	metadata map[string]any
+}
+
+// Memory is an in-memory vector index. The zero value is an empty index
+// using cosine similarity. It is safe for concurrent use.
+type Memory struct {
+	// Metric is the comparison used by Search. It must not change once
+	// vectors have been added.
+	Metric Metric
+
+	mu      sync.RWMutex
+	dims    int
+	entries map[string]entry
+}
+
+// Add inserts or replaces the vector stored under id.
+func (m *Memory) Add(ctx context.Context, id string, vector []float64, metadata map[string]any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.entries == nil {
+		m.entries = make(map[string]entry)
+	}
+	if len(m.entries) == 0 {
+		m.dims = len(vector)
+	}
+	if len(vector) != m.dims {
+		return fmt.Errorf("%w: got %d, want %d", ErrDimensionMismatch, len(vector), m.dims)
+	}
+	m.entries[id] = entry{
+		vector:   append([]float64(nil), vector...),
+		norm:     ollamago.Norm(vector),
+		metadata: metadata,
+	}
+	return nil
+}
+
+// Delete removes the vector stored under id, if any.
+func (m *Memory) Delete(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, id)
+	return nil
+}
+
+// Len returns the number of vectors in the index.
+func (m *Memory) Len() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return len(m.entries)
+}
+
+// Search returns the k vectors closest to query, best first.
+func (m *Memory) Search(ctx context.Context, query []float64, k int) ([]Result, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.entries) == 0 || k <= 0 {
+		return nil, nil
+	}
+	if len(query) != m.dims {
+		return nil, fmt.Errorf("%w: got %d, want %d", ErrDimensionMismatch, len(query), m.dims)
+	}
+	queryNorm := ollamago.Norm(query)
+	results := make([]Result, 0, len(m.entries))
+	for id, e := range m.entries {
+		results = append(results, Result{
+			ID:       id,
+			Score:    m.score(query, queryNorm, e),
+			Metadata: e.metadata,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score == results[j].Score {
+			return results[i].ID < results[j].ID
+		}
+		if m.Metric == Euclidean {
+			return results[i].Score < results[j].Score
+		}
+		return results[i].Score > results[j].Score
+	})
+	return results[:min(k, len(results))], nil
+}
+
+func (m *Memory) score(query []float64, queryNorm float64, e entry) float64 {
+	switch m.Metric {
+	case DotProduct:
+		return ollamago.DotProduct(query, e.vector)
+	case Euclidean:
+		var sum float64
+		for i := range query {
+			d := query[i] - e.vector[i]
+			sum += d * d
+		}
+		return math.Sqrt(sum)
+	default:
+		if queryNorm == 0 || e.norm == 0 {
+			return 0
+		}
+		return ollamago.CosineSimilarity(query, e.vector)
+	}
+}
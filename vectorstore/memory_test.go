@@ -0,0 +1,67 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vectorstore_test
+
+import (
+	"context"
+	"testing"
+
+	"cirello.io/ollamago/ollamatest"
+	"cirello.io/ollamago/vectorstore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemorySearch(t *testing.T) {
+	for _, tt := range []struct {
+		metric vectorstore.Metric
+		want   []string
+	}{
+		{vectorstore.Cosine, []string{"a", "c"}},
+		{vectorstore.DotProduct, []string{"c", "a"}},
+		{vectorstore.Euclidean, []string{"a", "b"}},
+	} {
+		t.Run(tt.metric.String(), func(t *testing.T) {
+			ctx := context.Background()
+			m := &vectorstore.Memory{Metric: tt.metric}
+			require.NoError(t, m.Add(ctx, "a", []float64{1, 0}, nil))
+			require.NoError(t, m.Add(ctx, "b", []float64{0, 1}, nil))
+			require.NoError(t, m.Add(ctx, "c", []float64{3, 3}, map[string]any{"k": "v"}))
+			require.ErrorIs(t, m.Add(ctx, "d", []float64{1}, nil), vectorstore.ErrDimensionMismatch)
+			results, err := m.Search(ctx, []float64{1, 0.1}, 2)
+			require.NoError(t, err)
+			var ids []string
+			for _, r := range results {
+				ids = append(ids, r.ID)
+			}
+			require.Equal(t, tt.want, ids)
+		})
+	}
+}
+
+func TestIndex(t *testing.T) {
+	srv := ollamatest.NewServer(ollamatest.Model{Name: "embed"})
+	t.Cleanup(srv.Close)
+	ix := &vectorstore.Index{Store: &vectorstore.Memory{}, Client: srv.Client(), Model: "embed"}
+	ctx := context.Background()
+	require.NoError(t, ix.AddText(ctx, "1", "the cat sat on the mat", nil))
+	require.NoError(t, ix.AddText(ctx, "2", "quarterly revenue grew", map[string]any{"source": "report"}))
+	results, err := ix.SearchText(ctx, "quarterly revenue grew", 1)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, "2", results[0].ID)
+	require.Equal(t, "quarterly revenue grew", results[0].Metadata[vectorstore.TextKey])
+	require.Equal(t, "report", results[0].Metadata["source"])
+	require.InDelta(t, 1, results[0].Score, 1e-9)
+}
@@ -0,0 +1,210 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/textsplit"
+)
+
+// Metadata keys set by RAG.Ingest, in addition to TextKey.
+const (
+	DocumentKey = "document"
+	ChunkKey    = "chunk"
+)
+
+// DefaultRAGPrompt is the system prompt used by RAG when Prompt is empty.
+const DefaultRAGPrompt = "Answer the question using only the numbered context passages. " +
+	"Cite the passages you rely on as [n]. " +
+	"If the context does not contain the answer, say that you do not know."
+
+// Document is a text to be ingested by RAG.
+type Document struct {
+	ID       string
+	Text     string
+	Metadata map[string]any
+}
+
+// RAG is a reference retrieval-augmented generation pipeline: documents
+// are split, embedded and stored; questions are answered by a chat model
+// grounded on the closest chunks.
+type RAG struct {
+	// Store holds the chunk embeddings.
+	Store VectorStore
+
+	// Client is used to talk to the Ollama server.
+	Client ollamago.API
+
+	// EmbeddingModel embeds chunks and questions.
+	EmbeddingModel string
+
+	// ChatModel writes the answers.
+	ChatModel string
+
+	// Splitter chunks ingested documents. If nil, a textsplit.Recursive
+	// splitter with 1000-character chunks and a 100-character overlap is
+	// used.
+	Splitter textsplit.Splitter
+
+	// TopK is the number of chunks retrieved per question. If zero, 4 is
+	// used.
+	TopK int
+
+	// Reranker, if set, reorders the retrieved candidates before the
+	// best TopK are kept.
+	Reranker Reranker
+
+	// Candidates is the number of chunks retrieved for the Reranker. If
+	// zero, three times TopK is used.
+	Candidates int
+
+	// Prompt is the system prompt. If empty, DefaultRAGPrompt is used.
+	Prompt string
+
+	// Options are the model parameters used for answering.
+	Options ollamago.ModelParameters
+}
+
+func (r *RAG) check() error {
+	if r.Store == nil || r.Client == nil {
+		return errors.New("RAG needs a store and a client")
+	}
+	return nil
+}
+
+// Ingest splits, embeds and stores the documents. Chunks are stored under
+// "<document ID>#<chunk index>".
+func (r *RAG) Ingest(ctx context.Context, docs ...Document) error {
+	if err := r.check(); err != nil {
+		return err
+	}
+	splitter := r.Splitter
+	if splitter == nil {
+		splitter = textsplit.Recursive{ChunkSize: 1000, Overlap: 100}
+	}
+	type chunk struct {
+		doc   *Document
+		index int
+		text  string
+	}
+	var (
+		chunks []chunk
+		texts  []string
+	)
+	for i := range docs {
+		for j, text := range splitter.Split(docs[i].Text) {
+			chunks = append(chunks, chunk{&docs[i], j, text})
+			texts = append(texts, text)
+		}
+	}
+	vectors, err := ollamago.EmbedBatch(ctx, r.Client, r.EmbeddingModel, texts)
+	if err != nil {
+		return fmt.Errorf("cannot embed documents: %w", err)
+	}
+	for i, c := range chunks {
+		md := maps.Clone(c.doc.Metadata)
+		if md == nil {
+			md = make(map[string]any)
+		}
+		md[TextKey] = c.text
+		md[DocumentKey] = c.doc.ID
+		md[ChunkKey] = c.index
+		if err := r.Store.Add(ctx, fmt.Sprintf("%s#%d", c.doc.ID, c.index), vectors[i], md); err != nil {
+			return fmt.Errorf("cannot store chunk %d of %s: %w", c.index, c.doc.ID, err)
+		}
+	}
+	return nil
+}
+
+// Retrieve returns the chunks closest to the question, reranked if a
+// Reranker is set.
+func (r *RAG) Retrieve(ctx context.Context, question string) ([]Result, error) {
+	if err := r.check(); err != nil {
+		return nil, err
+	}
+	resp, err := r.Client.GenerateEmbeddings(ctx, ollamago.EmbedRequest{Model: r.EmbeddingModel, Input: []string{question}})
+	if err != nil {
+		return nil, fmt.Errorf("cannot embed question: %w", err)
+	}
+	if len(resp.Embeddings) != 1 {
+		return nil, fmt.Errorf("got %d embeddings for the question", len(resp.Embeddings))
+	}
+	k := r.TopK
+	if k <= 0 {
+		k = 4
+	}
+	if r.Reranker == nil {
+		return r.Store.Search(ctx, resp.Embeddings[0], k)
+	}
+	candidates := r.Candidates
+	if candidates <= 0 {
+		candidates = 3 * k
+	}
+	results, err := r.Store.Search(ctx, resp.Embeddings[0], candidates)
+	if err != nil {
+		return nil, err
+	}
+	results, err = r.Reranker.Rerank(ctx, question, results)
+	if err != nil {
+		return nil, fmt.Errorf("cannot rerank chunks: %w", err)
+	}
+	return results[:min(k, len(results))], nil
+}
+
+// Ask retrieves the chunks relevant to question and streams a grounded
+// answer citing them. The returned sources are numbered in the prompt in
+// the order they are returned, starting from 1.
+func (r *RAG) Ask(ctx context.Context, question string) (<-chan ollamago.ChatResponse, []Result, error) {
+	sources, err := r.Retrieve(ctx, question)
+	if err != nil {
+		return nil, nil, err
+	}
+	prompt := r.Prompt
+	if prompt == "" {
+		prompt = DefaultRAGPrompt
+	}
+	resp, err := r.Client.GenerateChat(ctx, ollamago.ChatRequest{
+		Model: r.ChatModel,
+		Messages: []ollamago.ChatMessage{
+			{Role: ollamago.RoleSystem, Content: prompt},
+			{Role: ollamago.RoleUser, Content: GroundedPrompt(question, sources)},
+		},
+		Stream:  true,
+		Options: r.Options,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot answer question: %w", err)
+	}
+	return resp, sources, nil
+}
+
+// GroundedPrompt renders the question and the numbered context passages
+// taken from the TextKey metadata of sources.
+func GroundedPrompt(question string, sources []Result) string {
+	var sb strings.Builder
+	sb.WriteString("Context:\n")
+	for i, s := range sources {
+		text, _ := s.Metadata[TextKey].(string)
+		fmt.Fprintf(&sb, "[%d] %s\n", i+1, text)
+	}
+	fmt.Fprintf(&sb, "\nQuestion: %s", question)
+	return sb.String()
+}
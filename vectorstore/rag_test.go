@@ -0,0 +1,67 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vectorstore_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamatest"
+	"cirello.io/ollamago/textsplit"
+	"cirello.io/ollamago/vectorstore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRAG(t *testing.T) {
+	srv := ollamatest.NewServer(
+		ollamatest.Model{Name: "embed"},
+		ollamatest.Model{Name: "chat", Chunks: []string{"Paris [1]"}},
+	)
+	t.Cleanup(srv.Close)
+	store := &vectorstore.Memory{}
+	rag := &vectorstore.RAG{
+		Store:          store,
+		Client:         srv.Client(),
+		EmbeddingModel: "embed",
+		ChatModel:      "chat",
+		Splitter:       textsplit.Sentence{ChunkSize: 40},
+		TopK:           1,
+	}
+	ctx := context.Background()
+	require.NoError(t, rag.Ingest(ctx, vectorstore.Document{
+		ID:   "geo",
+		Text: "The capital of France is Paris. The capital of Italy is Rome.",
+	}))
+	require.Equal(t, 2, store.Len())
+
+	resp, sources, err := rag.Ask(ctx, "The capital of France is Paris.")
+	require.NoError(t, err)
+	var answer string
+	for r := range resp {
+		answer += r.Message.Content
+	}
+	require.Equal(t, "Paris [1]", answer)
+	require.Len(t, sources, 1)
+	require.Equal(t, "geo#0", sources[0].ID)
+	require.Equal(t, "geo", sources[0].Metadata[vectorstore.DocumentKey])
+
+	reqs := srv.Requests()
+	var chat ollamago.ChatRequest
+	require.NoError(t, json.Unmarshal(reqs[len(reqs)-1].Body, &chat))
+	require.Equal(t, vectorstore.DefaultRAGPrompt, chat.Messages[0].Content)
+	require.Contains(t, chat.Messages[1].Content, "[1] The capital of France is Paris.")
+}
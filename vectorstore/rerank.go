@@ -0,0 +1,127 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vectorstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"cirello.io/ollamago"
+)
+
+// Reranker reorders search results by their relevance to a query.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, results []Result) ([]Result, error)
+}
+
+var _ Reranker = (*LLMReranker)(nil)
+
+// DefaultRerankPrompt is the system prompt used by LLMReranker when Prompt
+// is empty.
+const DefaultRerankPrompt = "Rate how relevant the passage is to answering the query " +
+	"on a scale from 0 (unrelated) to 10 (answers it completely)."
+
+type relevance struct {
+	Score int `json:"score" description:"Relevance from 0 to 10"`
+}
+
+func (r relevance) Validate() error {
+	if r.Score < 0 || r.Score > 10 {
+		return fmt.Errorf("score %d is out of range", r.Score)
+	}
+	return nil
+}
+
+// LLMReranker asks a chat model, or a reranking model used through the
+// chat endpoint, to grade each passage against the query. The returned
+// results carry the grade, scaled to [0, 1], as their Score.
+type LLMReranker struct {
+	// Client is used to talk to the Ollama server.
+	Client ollamago.API
+
+	// Model grades the passages.
+	Model string
+
+	// Prompt is the grading instruction. If empty, DefaultRerankPrompt is
+	// used.
+	Prompt string
+
+	// Concurrency is the number of passages graded at once. If zero, 4 is
+	// used.
+	Concurrency int
+
+	// Options are the model parameters used for grading.
+	Options ollamago.ModelParameters
+}
+
+// Rerank implements Reranker.
+func (r *LLMReranker) Rerank(ctx context.Context, query string, results []Result) ([]Result, error) {
+	if r.Client == nil {
+		return nil, errors.New("reranker has no client")
+	}
+	prompt := r.Prompt
+	if prompt == "" {
+		prompt = DefaultRerankPrompt
+	}
+	concurrency := r.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	reranked := make([]Result, len(results))
+	copy(reranked, results)
+	var (
+		wg       sync.WaitGroup
+		sem      = make(chan struct{}, concurrency)
+		mu       sync.Mutex
+		firstErr error
+	)
+	for i := range reranked {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			text, _ := reranked[i].Metadata[TextKey].(string)
+			grade, err := ollamago.ChatInto[relevance](ctx, r.Client, ollamago.ChatRequest{
+				Model: r.Model,
+				Messages: []ollamago.ChatMessage{
+					{Role: ollamago.RoleSystem, Content: prompt},
+					{Role: ollamago.RoleUser, Content: fmt.Sprintf("Query: %s\n\nPassage: %s", query, text)},
+				},
+				Options: r.Options,
+			}, ollamago.WithRetries(1), ollamago.WithJSONRepair())
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = fmt.Errorf("cannot grade %s: %w", reranked[i].ID, err)
+				}
+				mu.Unlock()
+				return
+			}
+			reranked[i].Score = float64(grade.Score) / 10
+		}()
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	sort.SliceStable(reranked, func(i, j int) bool {
+		return reranked[i].Score > reranked[j].Score
+	})
+	return reranked, nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vectorstore_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamagotest"
+	"cirello.io/ollamago/vectorstore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLLMReranker(t *testing.T) {
+	mock := &ollamagotest.MockClient{
+		GenerateChatFunc: func(ctx context.Context, req ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error) {
+			score := `{"score": 2}`
+			if strings.Contains(req.Messages[1].Content, "Paris") {
+				score = `{"score": 9}`
+			}
+			return ollamagotest.Stream(ollamago.ChatResponse{
+				Message: ollamago.ChatMessage{Role: "assistant", Content: score},
+				Done:    true,
+			}), nil
+		},
+	}
+	reranker := &vectorstore.LLMReranker{Client: mock, Model: "judge"}
+	results, err := reranker.Rerank(context.Background(), "capital of France?", []vectorstore.Result{
+		{ID: "rome", Score: 0.9, Metadata: map[string]any{vectorstore.TextKey: "Rome is in Italy"}},
+		{ID: "paris", Score: 0.8, Metadata: map[string]any{vectorstore.TextKey: "Paris is in France"}},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "paris", results[0].ID)
+	require.InDelta(t, 0.9, results[0].Score, 1e-9)
+	require.Equal(t, "rome", results[1].ID)
+	require.Len(t, mock.CallsTo("GenerateChat"), 2)
+}
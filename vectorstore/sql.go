@@ -0,0 +1,311 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vectorstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var validIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+func tableName(table, fallback string) (string, error) {
+	if table == "" {
+		return fallback, nil
+	}
+	if !validIdentifier.MatchString(table) {
+		return "", fmt.Errorf("invalid table name %q", table)
+	}
+	return table, nil
+}
+
+// vectorLiteral renders v in the "[1,2,3]" text form understood by both
+// sqlite-vec and pgvector.
+func vectorLiteral(v []float64) string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for i, f := range v {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
+
+func encodeMetadata(metadata map[string]any) (string, error) {
+	if metadata == nil {
+		return "{}", nil
+	}
+	out, err := json.Marshal(metadata)
+	if err != nil {
+		return "", fmt.Errorf("cannot encode metadata: %w", err)
+	}
+	return string(out), nil
+}
+
+func scanResults(rows *sql.Rows, score func(distance float64) float64) ([]Result, error) {
+	defer rows.Close()
+	var results []Result
+	for rows.Next() {
+		var (
+			r        Result
+			distance float64
+			metadata sql.NullString
+		)
+		if err := rows.Scan(&r.ID, &distance, &metadata); err != nil {
+			return nil, fmt.Errorf("cannot read search results: %w", err)
+		}
+		r.Score = score(distance)
+		if metadata.Valid && metadata.String != "" {
+			if err := json.Unmarshal([]byte(metadata.String), &r.Metadata); err != nil {
+				return nil, fmt.Errorf("cannot decode metadata: %w", err)
+			}
+		}
+		results = append(results, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read search results: %w", err)
+	}
+	return results, nil
+}
+
+// SQLite is a VectorStore backed by a sqlite-vec vec0 virtual table. The
+// caller opens DB with a SQLite driver that has the sqlite-vec extension
+// loaded and calls Init once to create the table. DotProduct is not
+// supported by sqlite-vec.
+type SQLite struct {
+	DB *sql.DB
+
+	// Table is the name of the virtual table. If empty, "ollamago_vectors"
+	// is used.
+	Table string
+
+	// Dimensions is the number of dimensions of the stored vectors.
+	Dimensions int
+
+	// Metric is the comparison used by Search.
+	Metric Metric
+}
+
+func (s *SQLite) table() (string, error) {
+	return tableName(s.Table, "ollamago_vectors")
+}
+
+// Init creates the virtual table if it does not exist.
+func (s *SQLite) Init(ctx context.Context) error {
+	table, err := s.table()
+	if err != nil {
+		return err
+	}
+	var metric string
+	switch s.Metric {
+	case Cosine:
+		metric = "cosine"
+	case Euclidean:
+		metric = "L2"
+	default:
+		return fmt.Errorf("sqlite-vec does not support the %s metric", s.Metric)
+	}
+	_, err = s.DB.ExecContext(ctx, fmt.Sprintf(
+		`CREATE VIRTUAL TABLE IF NOT EXISTS %s USING vec0(id TEXT PRIMARY KEY, embedding float[%d] distance_metric=%s, +metadata TEXT)`,
+		table, s.Dimensions, metric))
+	if err != nil {
+		return fmt.Errorf("cannot create vector table: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLite) Add(ctx context.Context, id string, vector []float64, metadata map[string]any) error {
+	table, err := s.table()
+	if err != nil {
+		return err
+	}
+	if len(vector) != s.Dimensions {
+		return fmt.Errorf("%w: got %d, want %d", ErrDimensionMismatch, len(vector), s.Dimensions)
+	}
+	md, err := encodeMetadata(metadata)
+	if err != nil {
+		return err
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("cannot add vector: %w", err)
+	}
+	defer tx.Rollback()
+	// vec0 tables do not support upserts.
+	if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("cannot add vector: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO `+table+` (id, embedding, metadata) VALUES (?, ?, ?)`,
+		id, vectorLiteral(vector), md); err != nil {
+		return fmt.Errorf("cannot add vector: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("cannot add vector: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLite) Delete(ctx context.Context, id string) error {
+	table, err := s.table()
+	if err != nil {
+		return err
+	}
+	if _, err := s.DB.ExecContext(ctx, `DELETE FROM `+table+` WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("cannot delete vector: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLite) Search(ctx context.Context, query []float64, k int) ([]Result, error) {
+	table, err := s.table()
+	if err != nil {
+		return nil, err
+	}
+	if len(query) != s.Dimensions {
+		return nil, fmt.Errorf("%w: got %d, want %d", ErrDimensionMismatch, len(query), s.Dimensions)
+	}
+	rows, err := s.DB.QueryContext(ctx, `SELECT id, distance, metadata FROM `+table+`
+		WHERE embedding MATCH ? AND k = ? ORDER BY distance`, vectorLiteral(query), k)
+	if err != nil {
+		return nil, fmt.Errorf("cannot search vectors: %w", err)
+	}
+	return scanResults(rows, func(distance float64) float64 {
+		if s.Metric == Cosine {
+			return 1 - distance
+		}
+		return distance
+	})
+}
+
+// Postgres is a VectorStore backed by a PostgreSQL table using the
+// pgvector extension. The caller opens DB with a PostgreSQL driver and
+// calls Init once to create the extension, the table and an HNSW index.
+type Postgres struct {
+	DB *sql.DB
+
+	// Table is the name of the table, optionally schema-qualified. If
+	// empty, "ollamago_vectors" is used.
+	Table string
+
+	// Dimensions is the number of dimensions of the stored vectors.
+	Dimensions int
+
+	// Metric is the comparison used by Search.
+	Metric Metric
+}
+
+func (p *Postgres) table() (string, error) {
+	return tableName(p.Table, "ollamago_vectors")
+}
+
+func (p *Postgres) operator() (op, opclass string) {
+	switch p.Metric {
+	case DotProduct:
+		return "<#>", "vector_ip_ops"
+	case Euclidean:
+		return "<->", "vector_l2_ops"
+	default:
+		return "<=>", "vector_cosine_ops"
+	}
+}
+
+// Init creates the vector extension, the table and its index if they do
+// not exist.
+func (p *Postgres) Init(ctx context.Context) error {
+	table, err := p.table()
+	if err != nil {
+		return err
+	}
+	_, opclass := p.operator()
+	index := strings.ReplaceAll(table, ".", "_") + "_embedding_idx"
+	for _, stmt := range []string{
+		`CREATE EXTENSION IF NOT EXISTS vector`,
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (id TEXT PRIMARY KEY, embedding vector(%d) NOT NULL, metadata JSONB)`,
+			table, p.Dimensions),
+		fmt.Sprintf(`CREATE INDEX IF NOT EXISTS %s ON %s USING hnsw (embedding %s)`, index, table, opclass),
+	} {
+		if _, err := p.DB.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("cannot initialize vector table: %w", err)
+		}
+	}
+	return nil
+}
+
+func (p *Postgres) Add(ctx context.Context, id string, vector []float64, metadata map[string]any) error {
+	table, err := p.table()
+	if err != nil {
+		return err
+	}
+	if len(vector) != p.Dimensions {
+		return fmt.Errorf("%w: got %d, want %d", ErrDimensionMismatch, len(vector), p.Dimensions)
+	}
+	md, err := encodeMetadata(metadata)
+	if err != nil {
+		return err
+	}
+	_, err = p.DB.ExecContext(ctx, `INSERT INTO `+table+` (id, embedding, metadata) VALUES ($1, $2::vector, $3::jsonb)
+		ON CONFLICT (id) DO UPDATE SET embedding = excluded.embedding, metadata = excluded.metadata`,
+		id, vectorLiteral(vector), md)
+	if err != nil {
+		return fmt.Errorf("cannot add vector: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) Delete(ctx context.Context, id string) error {
+	table, err := p.table()
+	if err != nil {
+		return err
+	}
+	if _, err := p.DB.ExecContext(ctx, `DELETE FROM `+table+` WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("cannot delete vector: %w", err)
+	}
+	return nil
+}
+
+func (p *Postgres) Search(ctx context.Context, query []float64, k int) ([]Result, error) {
+	table, err := p.table()
+	if err != nil {
+		return nil, err
+	}
+	if len(query) != p.Dimensions {
+		return nil, fmt.Errorf("%w: got %d, want %d", ErrDimensionMismatch, len(query), p.Dimensions)
+	}
+	op, _ := p.operator()
+	rows, err := p.DB.QueryContext(ctx, `SELECT id, embedding `+op+` $1::vector AS distance, metadata::text FROM `+table+`
+		ORDER BY distance LIMIT $2`, vectorLiteral(query), k)
+	if err != nil {
+		return nil, fmt.Errorf("cannot search vectors: %w", err)
+	}
+	return scanResults(rows, func(distance float64) float64 {
+		switch p.Metric {
+		case DotProduct:
+			// <#> returns the negative inner product.
+			return -distance
+		case Euclidean:
+			return distance
+		default:
+			return 1 - distance
+		}
+	})
+}
@@ -0,0 +1,191 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vectorstore_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"cirello.io/ollamago/internal/fakesql"
+	"cirello.io/ollamago/vectorstore"
+	"github.com/stretchr/testify/require"
+)
+
+type statement struct {
+	Query string
+	Args  []any
+}
+
+// recorder is a database recording its statements, with their whitespace
+// collapsed, and answering the queries with Rows.
+type recorder struct {
+	Rows       *fakesql.Rows
+	Err        error
+	statements []statement
+}
+
+func (r *recorder) handle(query string, args []any) (*fakesql.Rows, int64, error) {
+	r.statements = append(r.statements, statement{strings.Join(strings.Fields(query), " "), args})
+	if r.Err != nil {
+		return nil, 0, r.Err
+	}
+	if strings.HasPrefix(query, "SELECT") {
+		return r.Rows, 0, nil
+	}
+	return nil, 1, nil
+}
+
+// take returns the statements recorded since the last call.
+func (r *recorder) take() []statement {
+	statements := r.statements
+	r.statements = nil
+	return statements
+}
+
+func TestSQLite(t *testing.T) {
+	rec := &recorder{}
+	db := fakesql.Open(rec.handle)
+	t.Cleanup(func() { db.Close() })
+	ctx := context.Background()
+	store := &vectorstore.SQLite{DB: db, Dimensions: 2, Metric: vectorstore.Cosine}
+
+	require.NoError(t, store.Init(ctx))
+	require.Equal(t, []statement{
+		{"CREATE VIRTUAL TABLE IF NOT EXISTS ollamago_vectors USING vec0(id TEXT PRIMARY KEY, embedding float[2] distance_metric=cosine, +metadata TEXT)", []any{}},
+	}, rec.take())
+
+	require.NoError(t, store.Add(ctx, "a", []float64{1, 0.5}, map[string]any{"k": "v"}))
+	require.Equal(t, []statement{
+		{"BEGIN", nil},
+		{"DELETE FROM ollamago_vectors WHERE id = ?", []any{"a"}},
+		{"INSERT INTO ollamago_vectors (id, embedding, metadata) VALUES (?, ?, ?)", []any{"a", "[1,0.5]", `{"k":"v"}`}},
+		{"COMMIT", nil},
+	}, rec.take())
+	require.ErrorIs(t, store.Add(ctx, "a", []float64{1}, nil), vectorstore.ErrDimensionMismatch)
+	require.Empty(t, rec.take())
+
+	require.NoError(t, store.Delete(ctx, "a"))
+	require.Equal(t, []statement{{"DELETE FROM ollamago_vectors WHERE id = ?", []any{"a"}}}, rec.take())
+
+	rec.Rows = &fakesql.Rows{
+		Columns: []string{"id", "distance", "metadata"},
+		Values: [][]any{
+			{"a", 0.25, `{"k":"v"}`},
+			{"b", 0.5, nil},
+		},
+	}
+	results, err := store.Search(ctx, []float64{0, 1}, 2)
+	require.NoError(t, err)
+	require.Equal(t, []statement{
+		{"SELECT id, distance, metadata FROM ollamago_vectors WHERE embedding MATCH ? AND k = ? ORDER BY distance", []any{"[0,1]", int64(2)}},
+	}, rec.take())
+	require.Equal(t, []vectorstore.Result{
+		{ID: "a", Score: 0.75, Metadata: map[string]any{"k": "v"}},
+		{ID: "b", Score: 0.5},
+	}, results, "cosine distances are turned into similarities")
+
+	store.Metric = vectorstore.Euclidean
+	results, err = store.Search(ctx, []float64{0, 1}, 2)
+	require.NoError(t, err)
+	require.Equal(t, 0.25, results[0].Score)
+	rec.take()
+
+	store.Metric = vectorstore.DotProduct
+	require.ErrorContains(t, store.Init(ctx), "does not support")
+	store.Table = "vectors; DROP TABLE users"
+	require.ErrorContains(t, store.Delete(ctx, "a"), "invalid table name")
+	require.Empty(t, rec.take(), "nothing is sent for unsupported metrics and invalid tables")
+}
+
+func TestSQLiteRollback(t *testing.T) {
+	rec := &recorder{}
+	db := fakesql.Open(func(query string, args []any) (*fakesql.Rows, int64, error) {
+		if strings.HasPrefix(query, "INSERT") {
+			rec.handle(query, args)
+			return nil, 0, errors.New("disk full")
+		}
+		return rec.handle(query, args)
+	})
+	t.Cleanup(func() { db.Close() })
+	store := &vectorstore.SQLite{DB: db, Dimensions: 1}
+	require.ErrorContains(t, store.Add(context.Background(), "a", []float64{1}, nil), "disk full")
+	var queries []string
+	for _, s := range rec.take() {
+		queries = append(queries, s.Query)
+	}
+	require.Equal(t, []string{
+		"BEGIN",
+		"DELETE FROM ollamago_vectors WHERE id = ?",
+		"INSERT INTO ollamago_vectors (id, embedding, metadata) VALUES (?, ?, ?)",
+		"ROLLBACK",
+	}, queries)
+}
+
+func TestPostgres(t *testing.T) {
+	rec := &recorder{}
+	db := fakesql.Open(rec.handle)
+	t.Cleanup(func() { db.Close() })
+	ctx := context.Background()
+	store := &vectorstore.Postgres{DB: db, Table: "rag.chunks", Dimensions: 2, Metric: vectorstore.DotProduct}
+
+	require.NoError(t, store.Init(ctx))
+	require.Equal(t, []statement{
+		{"CREATE EXTENSION IF NOT EXISTS vector", []any{}},
+		{"CREATE TABLE IF NOT EXISTS rag.chunks (id TEXT PRIMARY KEY, embedding vector(2) NOT NULL, metadata JSONB)", []any{}},
+		{"CREATE INDEX IF NOT EXISTS rag_chunks_embedding_idx ON rag.chunks USING hnsw (embedding vector_ip_ops)", []any{}},
+	}, rec.take())
+
+	require.NoError(t, store.Add(ctx, "a", []float64{1, 2}, nil))
+	require.Equal(t, []statement{
+		{"INSERT INTO rag.chunks (id, embedding, metadata) VALUES ($1, $2::vector, $3::jsonb) ON CONFLICT (id) DO UPDATE SET embedding = excluded.embedding, metadata = excluded.metadata", []any{"a", "[1,2]", "{}"}},
+	}, rec.take())
+
+	require.NoError(t, store.Delete(ctx, "a"))
+	require.Equal(t, []statement{{"DELETE FROM rag.chunks WHERE id = $1", []any{"a"}}}, rec.take())
+
+	rec.Rows = &fakesql.Rows{
+		Columns: []string{"id", "distance", "metadata"},
+		Values:  [][]any{{"a", -3.0, []byte(`{"n":1}`)}},
+	}
+	for _, tt := range []struct {
+		metric vectorstore.Metric
+		op     string
+		score  float64
+	}{
+		{vectorstore.DotProduct, "<#>", 3},
+		{vectorstore.Euclidean, "<->", -3},
+		{vectorstore.Cosine, "<=>", 4},
+	} {
+		store.Metric = tt.metric
+		results, err := store.Search(ctx, []float64{1, 1}, 5)
+		require.NoError(t, err)
+		require.Equal(t, []statement{
+			{"SELECT id, embedding " + tt.op + " $1::vector AS distance, metadata::text FROM rag.chunks ORDER BY distance LIMIT $2", []any{"[1,1]", int64(5)}},
+		}, rec.take())
+		require.Equal(t, []vectorstore.Result{{ID: "a", Score: tt.score, Metadata: map[string]any{"n": 1.0}}}, results)
+	}
+	_, err := store.Search(ctx, []float64{1}, 5)
+	require.ErrorIs(t, err, vectorstore.ErrDimensionMismatch)
+
+	rec.Rows.Values = [][]any{{"a", 0.0, "not json"}}
+	_, err = store.Search(ctx, []float64{1, 1}, 5)
+	require.ErrorContains(t, err, "cannot decode metadata")
+
+	rec.Err = errors.New("connection reset")
+	_, err = store.Search(ctx, []float64{1, 1}, 5)
+	require.ErrorContains(t, err, "cannot search vectors: connection reset")
+}
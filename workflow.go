@@ -0,0 +1,217 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// NodeFunc computes the output of a workflow node. inputs holds the
+// outputs of the dependencies of the node, keyed by their names.
+type NodeFunc func(ctx context.Context, inputs map[string]any) (any, error)
+
+// ErrWorkflowCycle is returned by Workflow.Run when the dependencies of
+// the nodes form a cycle.
+var ErrWorkflowCycle = errors.New("workflow has a cycle")
+
+type workflowNode struct {
+	fn   NodeFunc
+	deps []string
+}
+
+// Workflow runs a directed acyclic graph of nodes, such as LLM calls, tool
+// invocations or plain functions. Nodes run as soon as their dependencies
+// are done, so independent branches run in parallel; a node depending on
+// several others joins them.
+type Workflow struct {
+	// MaxConcurrency limits how many nodes run at once. Zero means no
+	// limit.
+	MaxConcurrency int
+
+	nodes map[string]workflowNode
+}
+
+// Add adds a node named name, computed by fn from the outputs of deps.
+// Dependencies may name nodes added later, or inputs given to Run.
+func (w *Workflow) Add(name string, fn NodeFunc, deps ...string) error {
+	if name == "" {
+		return errors.New("workflow node has no name")
+	}
+	if _, ok := w.nodes[name]; ok {
+		return fmt.Errorf("workflow node %q already exists", name)
+	}
+	if w.nodes == nil {
+		w.nodes = make(map[string]workflowNode)
+	}
+	w.nodes[name] = workflowNode{fn: fn, deps: slices.Clone(deps)}
+	return nil
+}
+
+// order checks that every dependency is known and that there are no
+// cycles, returning for each node the nodes depending on it and the number
+// of nodes it waits for.
+func (w *Workflow) order(inputs map[string]any) (dependents map[string][]string, waiting map[string]int, err error) {
+	dependents = make(map[string][]string)
+	waiting = make(map[string]int)
+	for name, n := range w.nodes {
+		if _, ok := inputs[name]; ok {
+			return nil, nil, fmt.Errorf("workflow node %q shadows an input", name)
+		}
+		for _, dep := range n.deps {
+			if _, ok := w.nodes[dep]; ok {
+				dependents[dep] = append(dependents[dep], name)
+				waiting[name]++
+			} else if _, ok := inputs[dep]; !ok {
+				return nil, nil, fmt.Errorf("workflow node %q depends on unknown %q", name, dep)
+			}
+		}
+	}
+	remaining := make(map[string]int, len(waiting))
+	var ready []string
+	for name := range w.nodes {
+		remaining[name] = waiting[name]
+		if waiting[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+	visited := 0
+	for len(ready) > 0 {
+		name := ready[len(ready)-1]
+		ready = ready[:len(ready)-1]
+		visited++
+		for _, d := range dependents[name] {
+			if remaining[d]--; remaining[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+	if visited != len(w.nodes) {
+		return nil, nil, ErrWorkflowCycle
+	}
+	return dependents, waiting, nil
+}
+
+// Run executes the workflow with the given inputs and returns the outputs
+// of every node along with the inputs. The first failure cancels the
+// nodes still running and is returned once they are done.
+func (w *Workflow) Run(ctx context.Context, inputs map[string]any) (map[string]any, error) {
+	dependents, waiting, err := w.order(inputs)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	outputs := make(map[string]any, len(inputs)+len(w.nodes))
+	for k, v := range inputs {
+		outputs[k] = v
+	}
+	var ready []string
+	for name := range w.nodes {
+		if waiting[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+	sort.Strings(ready)
+
+	type result struct {
+		name string
+		out  any
+		err  error
+	}
+	results := make(chan result)
+	running := 0
+	var firstErr error
+	for len(ready) > 0 || running > 0 {
+		for firstErr == nil && len(ready) > 0 && (w.MaxConcurrency <= 0 || running < w.MaxConcurrency) {
+			name := ready[0]
+			ready = ready[1:]
+			n := w.nodes[name]
+			in := make(map[string]any, len(n.deps))
+			for _, dep := range n.deps {
+				in[dep] = outputs[dep]
+			}
+			running++
+			go func() {
+				out, err := n.fn(ctx, in)
+				results <- result{name, out, err}
+			}()
+		}
+		if running == 0 {
+			break
+		}
+		r := <-results
+		running--
+		if r.err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("workflow node %q: %w", r.name, r.err)
+				cancel()
+			}
+			continue
+		}
+		outputs[r.name] = r.out
+		var next []string
+		for _, d := range dependents[r.name] {
+			if waiting[d]--; waiting[d] == 0 {
+				next = append(next, d)
+			}
+		}
+		sort.Strings(next)
+		ready = append(ready, next...)
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return outputs, nil
+}
+
+// ChatNode returns a node sending the text/template prompt, rendered with
+// the inputs of the node, as a user message appended to the messages of
+// req. Its output is the content of the reply.
+func ChatNode(client API, req ChatRequest, prompt string) NodeFunc {
+	render, err := Template[map[string]any](prompt)
+	chat := ChatStep(client, req)
+	return func(ctx context.Context, inputs map[string]any) (any, error) {
+		if err != nil {
+			return nil, err
+		}
+		return Then(render, chat)(ctx, inputs)
+	}
+}
+
+// ToolNode returns a node calling fn with the arguments obtained by
+// rendering the text/template arguments with the inputs of the node. Its
+// output is the result of the tool.
+func ToolNode(fn ToolFunc, arguments string) NodeFunc {
+	render, err := Template[map[string]any](arguments)
+	return func(ctx context.Context, inputs map[string]any) (any, error) {
+		if err != nil {
+			return nil, err
+		}
+		args, err := render(ctx, inputs)
+		if err != nil {
+			return nil, err
+		}
+		if !json.Valid([]byte(args)) {
+			return nil, fmt.Errorf("tool arguments are not valid JSON: %s", args)
+		}
+		return fn(ctx, json.RawMessage(args))
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2024 cirello.io/ollamago & U. Cirello
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ollamago_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cirello.io/ollamago"
+	"cirello.io/ollamago/ollamagotest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkflow(t *testing.T) {
+	mock := &ollamagotest.MockClient{
+		GenerateChatFunc: func(ctx context.Context, req ollamago.ChatRequest) (<-chan ollamago.ChatResponse, error) {
+			prompt := req.Messages[len(req.Messages)-1].Content
+			return ollamagotest.Stream(ollamago.ChatResponse{
+				Message: ollamago.ChatMessage{Role: ollamago.RoleAssistant, Content: strings.ToUpper(prompt)},
+				Done:    true,
+			}), nil
+		},
+	}
+	var running, peak atomic.Int32
+	slow := func(out string) ollamago.NodeFunc {
+		return func(ctx context.Context, inputs map[string]any) (any, error) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			return out + ":" + inputs["doc"].(string), nil
+		}
+	}
+	wordCount := func(ctx context.Context, arguments json.RawMessage) (string, error) {
+		var args struct{ Text string }
+		if err := json.Unmarshal(arguments, &args); err != nil {
+			return "", err
+		}
+		return strings.Repeat("*", len(strings.Fields(args.Text))), nil
+	}
+
+	var w ollamago.Workflow
+	require.NoError(t, w.Add("join", ollamago.ChatNode(mock, ollamago.ChatRequest{Model: "test"}, "{{.optimist}} / {{.pessimist}}"), "optimist", "pessimist"))
+	require.NoError(t, w.Add("optimist", slow("good"), "doc"))
+	require.NoError(t, w.Add("pessimist", slow("bad"), "doc"))
+	require.NoError(t, w.Add("count", ollamago.ToolNode(wordCount, `{"text":{{printf "%q" .join}}}`), "join"))
+	require.Error(t, w.Add("join", slow("again")))
+
+	out, err := w.Run(context.Background(), map[string]any{"doc": "text"})
+	require.NoError(t, err)
+	require.Equal(t, "GOOD:TEXT / BAD:TEXT", out["join"])
+	require.Equal(t, "***", out["count"])
+	require.Equal(t, "text", out["doc"])
+	require.EqualValues(t, 2, peak.Load(), "independent branches run in parallel")
+
+	peak.Store(0)
+	w.MaxConcurrency = 1
+	_, err = w.Run(context.Background(), map[string]any{"doc": "text"})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, peak.Load())
+
+	_, err = w.Run(context.Background(), nil)
+	require.ErrorContains(t, err, `depends on unknown "doc"`)
+
+	var cyclic ollamago.Workflow
+	require.NoError(t, cyclic.Add("a", slow("a"), "b"))
+	require.NoError(t, cyclic.Add("b", slow("b"), "a"))
+	_, err = cyclic.Run(context.Background(), nil)
+	require.ErrorIs(t, err, ollamago.ErrWorkflowCycle)
+
+	errBoom := errors.New("boom")
+	var failing ollamago.Workflow
+	var canceled atomic.Bool
+	require.NoError(t, failing.Add("fail", func(ctx context.Context, inputs map[string]any) (any, error) {
+		return nil, errBoom
+	}))
+	require.NoError(t, failing.Add("wait", func(ctx context.Context, inputs map[string]any) (any, error) {
+		<-ctx.Done()
+		canceled.Store(true)
+		return nil, ctx.Err()
+	}))
+	require.NoError(t, failing.Add("after", slow("never"), "fail"))
+	_, err = failing.Run(context.Background(), nil)
+	require.ErrorIs(t, err, errBoom)
+	require.ErrorContains(t, err, `workflow node "fail"`)
+	require.True(t, canceled.Load())
+}